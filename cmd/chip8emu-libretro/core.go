@@ -0,0 +1,251 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/cheat"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/compat"
+	"github.com/dustinbowers/chip8emu/ui/sound"
+)
+
+const sampleRate = 44100
+
+// stateSlack is room for a save state to grow over the size reported when the game loaded,
+// frontends size their buffers from retro_serialize_size
+const stateSlack = 1024
+
+// Joypad buttons by RETRO_DEVICE_ID_JOYPAD_*, laid out like cmd/chip8emu's controller defaults:
+// the D-pad presses the usual direction keys and the bottom face button (B) the usual "fire" 5
+var joypadKeys = map[int]uint8{
+	4:  0x2, // Up
+	5:  0x8, // Down
+	6:  0x4, // Left
+	7:  0x6, // Right
+	0:  0x5, // B
+	8:  0x0, // A
+	1:  0xA, // Y
+	9:  0xB, // X
+	10: 0x1, // L
+	11: 0x3, // R
+	2:  0xE, // Select
+	3:  0xF, // Start
+}
+
+// keyboardKeys maps the positional layout of cmd/chip8emu (1234 QWER ASDF ZXCV) to the
+// keypad. RETROK_* codes are the lower case ASCII characters.
+var keyboardKeys = map[rune]uint8{
+	'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xC,
+	'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xD,
+	'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xE,
+	'z': 0xA, 'x': 0x0, 'c': 0xB, 'v': 0xF,
+}
+
+// platformOption is the core option choosing the machine, "auto" uses the compatibility
+// database and falls back to guessing from the ROM's opcodes
+const platformOption = "chip8emu_platform"
+
+// colors are the screen's colors when the ROM doesn't pick its own, as 0xRRGGBB
+var colors = [4]uint32{0x000000, 0xFFFFFF, 0xAAAAAA, 0x555555}
+
+// core is the emulator behind the retro_* functions
+type core struct {
+	emu     *chip8.Chip8
+	synth   *sound.Synth
+	rom     []byte
+	keys    [16]bool // Held during the current frame
+	video   []uint32 // The screen as XRGB8888
+	width   int
+	height  int
+	audio   []int16 // A frame of interleaved stereo samples
+	memory  []byte  // The machine's memory after the last frame
+	shared  []byte  // The frontend's copy of memory, for cheats and achievements
+	cheats  map[int]string
+	active  *cheat.List
+	maxSize int // Largest save state, see stateSlack
+
+	alloc func(n int) []byte // Allocates shared, where the frontend can keep a pointer to it
+}
+
+func newCore(alloc func(n int) []byte) *core {
+	c := &core{
+		alloc:  alloc,
+		emu:    chip8.NewChip8(),
+		synth:  sound.NewSynth(sampleRate),
+		audio:  make([]int16, sampleRate/chip8.FrameRate*2),
+		cheats: map[int]string{},
+	}
+	c.emu.SetKeyProvider(c)
+	c.emu.SetAudioSink(beeper{c.synth})
+	return c
+}
+
+// Keys implements input.Keypad
+func (c *core) Keys() [16]bool {
+	return c.keys
+}
+
+// beeper adapts sound.Synth to the machine's audio sink
+type beeper struct {
+	*sound.Synth
+}
+
+func (b beeper) Beep(on bool) {
+	b.SetOn(on)
+}
+
+// load configures the machine for platform ("auto" or a profile name) and loads rom
+func (c *core) load(rom []byte, platform string) error {
+	if platform != "" && platform != "auto" {
+		p, err := chip8.LookupProfile(platform)
+		if err != nil {
+			return err
+		}
+		c.emu.SetProfile(p)
+	} else if entry, ok := compat.Lookup(rom); ok {
+		compat.Apply(c.emu, entry)
+	} else if p, ok := compat.Detect(rom).Profile(); ok {
+		c.emu.SetProfile(p)
+	}
+	if _, err := c.emu.LoadRomBytes(rom); err != nil {
+		return err
+	}
+	c.rom = rom
+	c.memory = c.emu.ReadMemory(0, c.emu.Profile().MemorySize)
+	c.shared = c.alloc(len(c.memory))
+	copy(c.shared, c.memory)
+	state, err := c.emu.SaveState()
+	if err != nil {
+		return err
+	}
+	c.maxSize = 4 + len(state) + stateSlack
+	return nil
+}
+
+// setInput updates the keypad from whether each joypad button and keyboard key is held
+func (c *core) setInput(button func(id int) bool, key func(code rune) bool) {
+	var keys [16]bool
+	for id, k := range joypadKeys {
+		keys[k] = keys[k] || button(id)
+	}
+	for code, k := range keyboardKeys {
+		keys[k] = keys[k] || key(code)
+	}
+	c.keys = keys
+}
+
+// runFrame runs a frame, taking in the changes the frontend made to its copy of memory first
+func (c *core) runFrame() error {
+	for i, b := range c.shared {
+		if b != c.memory[i] {
+			c.emu.WriteMemory(uint16(i), []byte{b})
+		}
+	}
+	if c.active != nil {
+		c.active.Apply(c.emu)
+	}
+	err := c.emu.RunFrame()
+	c.syncMemory()
+	return err
+}
+
+// syncMemory copies the machine's memory to the frontend's copy
+func (c *core) syncMemory() {
+	c.memory = c.emu.ReadMemory(0, len(c.memory))
+	copy(c.shared, c.memory)
+}
+
+// reset restarts the ROM
+func (c *core) reset() {
+	c.emu.Reset()
+	c.syncMemory()
+}
+
+// render draws the screen into c.video, reporting whether its size changed
+func (c *core) render() bool {
+	screen := c.emu.PeekScreen()
+	resized := screen.Width != c.width || screen.Height != c.height
+	if resized {
+		c.width, c.height = screen.Width, screen.Height
+		c.video = make([]uint32, c.width*c.height)
+	}
+	for i, v := range screen.Pix {
+		switch {
+		case screen.Palette != nil:
+			c.video[i] = screen.Palette[v] & 0xFFFFFF
+		default:
+			c.video[i] = colors[v&3]
+		}
+	}
+	return resized
+}
+
+// fillAudio generates the frame's sound into c.audio
+func (c *core) fillAudio() {
+	c.synth.Fill(c.audio, 2)
+}
+
+// setCheat turns the frontend's cheat index on or off. code uses the patches of pkg/chip8/cheat,
+// "ADDR=VV" or "ADDR?CC=VV" separated by commas or plus signs.
+func (c *core) setCheat(index int, enabled bool, code string) error {
+	if enabled {
+		c.cheats[index] = strings.ReplaceAll(code, "+", ",")
+	} else {
+		delete(c.cheats, index)
+	}
+	return c.buildCheats()
+}
+
+// resetCheats turns every cheat off
+func (c *core) resetCheats() {
+	c.cheats = map[int]string{}
+	c.active = nil
+}
+
+func (c *core) buildCheats() error {
+	var sb strings.Builder
+	for i, code := range c.cheats {
+		fmt.Fprintf(&sb, "Cheat %d: %s\n", i, code)
+	}
+	l, err := cheat.Parse(strings.NewReader(sb.String()))
+	if err != nil {
+		return err
+	}
+	c.active = l
+	return nil
+}
+
+// serialize writes the machine's state to buf: its length as 4 bytes, big-endian, then the state
+func (c *core) serialize(buf []byte) error {
+	state, err := c.emu.SaveState()
+	if err != nil {
+		return err
+	}
+	if 4+len(state) > len(buf) {
+		return fmt.Errorf("the state takes %d bytes, the buffer only has %d", 4+len(state), len(buf))
+	}
+	n := len(state)
+	buf[0], buf[1], buf[2], buf[3] = byte(n>>24), byte(n>>16), byte(n>>8), byte(n)
+	copy(buf[4:], state)
+	return nil
+}
+
+// unserialize loads a state written by serialize
+func (c *core) unserialize(buf []byte) error {
+	if len(buf) < 4 {
+		return fmt.Errorf("the state is too short")
+	}
+	n := int(buf[0])<<24 | int(buf[1])<<16 | int(buf[2])<<8 | int(buf[3])
+	if 4+n > len(buf) {
+		return fmt.Errorf("the state is cut short")
+	}
+	if err := c.emu.LoadState(buf[4 : 4+n]); err != nil {
+		return err
+	}
+	c.syncMemory()
+	return nil
+}
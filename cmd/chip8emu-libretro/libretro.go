@@ -0,0 +1,299 @@
+//go:build cgo
+// +build cgo
+
+// Command chip8emu-libretro builds the emulator as a libretro core, so CHIP-8 ROMs can run in
+// RetroArch and other libretro frontends with their shaders, save states, rewind and netplay.
+//
+// Build with `make libretro`, which runs
+//
+//	go build -buildmode=c-shared -o build/chip8emu_libretro.so ./cmd/chip8emu-libretro
+//
+// (use .dll or .dylib on Windows and macOS), then load the library as a core. The
+// "chip8emu_platform" core option picks the machine, and RetroArch cheats use the patches of
+// pkg/chip8/cheat ("3F0=03", several joined with + or commas).
+package main
+
+/*
+#include <stdlib.h>
+#include "libretro.h"
+
+static bool call_environment(retro_environment_t cb, unsigned cmd, void *data) {
+	return cb(cmd, data);
+}
+static void call_video_refresh(retro_video_refresh_t cb, const void *data, unsigned width, unsigned height, size_t pitch) {
+	cb(data, width, height, pitch);
+}
+static size_t call_audio_sample_batch(retro_audio_sample_batch_t cb, const int16_t *data, size_t frames) {
+	return cb(data, frames);
+}
+static void call_input_poll(retro_input_poll_t cb) {
+	cb();
+}
+static int16_t call_input_state(retro_input_state_t cb, unsigned port, unsigned device, unsigned index, unsigned id) {
+	return cb(port, device, index, id);
+}
+*/
+import "C"
+
+import (
+	"log"
+	"strings"
+	"unsafe"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// c-shared libraries still need a main package with a main function
+func main() {}
+
+var (
+	environment  C.retro_environment_t
+	videoRefresh C.retro_video_refresh_t
+	audioBatch   C.retro_audio_sample_batch_t
+	inputPoll    C.retro_input_poll_t
+	inputState   C.retro_input_state_t
+
+	rc      *core
+	crashed bool         // The ROM crashed, frames only redraw the screen until a reset
+	shared  []*C.uint8_t // C allocations handed to the frontend, freed with the game
+)
+
+// Strings the frontend keeps pointers to
+var (
+	libraryName    = C.CString("chip8emu")
+	libraryVersion = C.CString("1.0")
+	extensions     = C.CString(strings.Join(trimDots(chip8.RomExtensions), "|"))
+	optionKey      = C.CString(platformOption)
+	optionValue    = C.CString("Platform (restart); auto|" + strings.Join(chip8.ProfileNames(), "|"))
+)
+
+func trimDots(exts []string) []string {
+	trimmed := make([]string, len(exts))
+	for i, e := range exts {
+		trimmed[i] = strings.TrimPrefix(e, ".")
+	}
+	return trimmed
+}
+
+// alloc returns n bytes of zeroed C memory as a slice, for memory the frontend keeps a pointer to
+func alloc(n int) []byte {
+	p := (*C.uint8_t)(C.calloc(C.size_t(n), 1))
+	shared = append(shared, p)
+	return (*[1 << 30]byte)(unsafe.Pointer(p))[:n:n]
+}
+
+func freeShared() {
+	for _, p := range shared {
+		C.free(unsafe.Pointer(p))
+	}
+	shared = nil
+}
+
+//export retro_api_version
+func retro_api_version() C.unsigned {
+	return C.RETRO_API_VERSION
+}
+
+//export retro_set_environment
+func retro_set_environment(cb C.retro_environment_t) {
+	environment = cb
+	vars := []C.struct_retro_variable{{key: optionKey, value: optionValue}, {}}
+	C.call_environment(environment, C.RETRO_ENVIRONMENT_SET_VARIABLES, unsafe.Pointer(&vars[0]))
+}
+
+//export retro_set_video_refresh
+func retro_set_video_refresh(cb C.retro_video_refresh_t) {
+	videoRefresh = cb
+}
+
+//export retro_set_audio_sample
+func retro_set_audio_sample(cb C.retro_audio_sample_t) {
+	// The batch callback is used instead
+}
+
+//export retro_set_audio_sample_batch
+func retro_set_audio_sample_batch(cb C.retro_audio_sample_batch_t) {
+	audioBatch = cb
+}
+
+//export retro_set_input_poll
+func retro_set_input_poll(cb C.retro_input_poll_t) {
+	inputPoll = cb
+}
+
+//export retro_set_input_state
+func retro_set_input_state(cb C.retro_input_state_t) {
+	inputState = cb
+}
+
+//export retro_init
+func retro_init() {
+	rc = newCore(alloc)
+}
+
+//export retro_deinit
+func retro_deinit() {
+	rc = nil
+	freeShared()
+}
+
+//export retro_get_system_info
+func retro_get_system_info(info *C.struct_retro_system_info) {
+	*info = C.struct_retro_system_info{
+		library_name:     libraryName,
+		library_version:  libraryVersion,
+		valid_extensions: extensions,
+	}
+}
+
+//export retro_get_system_av_info
+func retro_get_system_av_info(info *C.struct_retro_system_av_info) {
+	w, h := rc.width, rc.height
+	if w == 0 {
+		w, h = 64, 32
+	}
+	*info = C.struct_retro_system_av_info{
+		geometry: geometry(w, h),
+		timing:   C.struct_retro_system_timing{fps: chip8.FrameRate, sample_rate: sampleRate},
+	}
+}
+
+func geometry(w, h int) C.struct_retro_game_geometry {
+	return C.struct_retro_game_geometry{
+		base_width:   C.unsigned(w),
+		base_height:  C.unsigned(h),
+		max_width:    chip8.MegaWidth,
+		max_height:   chip8.MegaHeight,
+		aspect_ratio: C.float(float64(w) / float64(h)),
+	}
+}
+
+//export retro_set_controller_port_device
+func retro_set_controller_port_device(port, device C.unsigned) {
+	// Every port is a RetroPad with a keyboard
+}
+
+//export retro_reset
+func retro_reset() {
+	rc.reset()
+	crashed = false
+}
+
+//export retro_run
+func retro_run() {
+	C.call_input_poll(inputPoll)
+	rc.setInput(func(id int) bool {
+		return C.call_input_state(inputState, 0, C.RETRO_DEVICE_JOYPAD, 0, C.unsigned(id)) != 0
+	}, func(code rune) bool {
+		return C.call_input_state(inputState, 0, C.RETRO_DEVICE_KEYBOARD, 0, C.unsigned(code)) != 0
+	})
+	if !crashed {
+		if err := rc.runFrame(); err != nil {
+			log.Printf("chip8emu: %v\n%s", err, strings.Join(rc.emu.PostMortem(err), "\n"))
+			crashed = true
+		}
+	}
+
+	if rc.render() {
+		g := geometry(rc.width, rc.height)
+		C.call_environment(environment, C.RETRO_ENVIRONMENT_SET_GEOMETRY, unsafe.Pointer(&g))
+	}
+	C.call_video_refresh(videoRefresh, unsafe.Pointer(&rc.video[0]), C.unsigned(rc.width), C.unsigned(rc.height), C.size_t(rc.width*4))
+	rc.fillAudio()
+	C.call_audio_sample_batch(audioBatch, (*C.int16_t)(unsafe.Pointer(&rc.audio[0])), C.size_t(len(rc.audio)/2))
+}
+
+//export retro_serialize_size
+func retro_serialize_size() C.size_t {
+	return C.size_t(rc.maxSize)
+}
+
+//export retro_serialize
+func retro_serialize(data unsafe.Pointer, size C.size_t) C.bool {
+	buf := (*[1 << 30]byte)(data)[:size:size]
+	if err := rc.serialize(buf); err != nil {
+		log.Printf("chip8emu: saving state: %v", err)
+		return false
+	}
+	return true
+}
+
+//export retro_unserialize
+func retro_unserialize(data unsafe.Pointer, size C.size_t) C.bool {
+	if err := rc.unserialize(C.GoBytes(data, C.int(size))); err != nil {
+		log.Printf("chip8emu: loading state: %v", err)
+		return false
+	}
+	crashed = false
+	return true
+}
+
+//export retro_cheat_reset
+func retro_cheat_reset() {
+	rc.resetCheats()
+}
+
+//export retro_cheat_set
+func retro_cheat_set(index C.unsigned, enabled C.bool, code *C.char) {
+	if err := rc.setCheat(int(index), bool(enabled), C.GoString(code)); err != nil {
+		log.Printf("chip8emu: cheat %d: %v", index, err)
+		rc.setCheat(int(index), false, "")
+	}
+}
+
+//export retro_load_game
+func retro_load_game(game *C.struct_retro_game_info) C.bool {
+	if game == nil || game.data == nil {
+		return false
+	}
+	format := C.int(C.RETRO_PIXEL_FORMAT_XRGB8888)
+	if !C.call_environment(environment, C.RETRO_ENVIRONMENT_SET_PIXEL_FORMAT, unsafe.Pointer(&format)) {
+		log.Printf("chip8emu: the frontend doesn't support XRGB8888")
+		return false
+	}
+
+	platform := "auto"
+	v := C.struct_retro_variable{key: optionKey}
+	if C.call_environment(environment, C.RETRO_ENVIRONMENT_GET_VARIABLE, unsafe.Pointer(&v)) && v.value != nil {
+		platform = C.GoString(v.value)
+	}
+	if err := rc.load(C.GoBytes(game.data, C.int(game.size)), platform); err != nil {
+		log.Printf("chip8emu: %v", err)
+		return false
+	}
+	crashed = false
+	rc.render()
+	return true
+}
+
+//export retro_load_game_special
+func retro_load_game_special(gameType C.unsigned, info *C.struct_retro_game_info, numInfo C.size_t) C.bool {
+	return false
+}
+
+//export retro_unload_game
+func retro_unload_game() {
+	rc = newCore(alloc)
+	freeShared()
+}
+
+//export retro_get_region
+func retro_get_region() C.unsigned {
+	return C.RETRO_REGION_NTSC
+}
+
+//export retro_get_memory_data
+func retro_get_memory_data(id C.unsigned) unsafe.Pointer {
+	if id != C.RETRO_MEMORY_SYSTEM_RAM || len(rc.shared) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&rc.shared[0])
+}
+
+//export retro_get_memory_size
+func retro_get_memory_size(id C.unsigned) C.size_t {
+	if id != C.RETRO_MEMORY_SYSTEM_RAM {
+		return 0
+	}
+	return C.size_t(len(rc.shared))
+}
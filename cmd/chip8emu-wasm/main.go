@@ -0,0 +1,56 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command chip8emu-wasm runs the emulator in a browser, see web/index.html.
+//
+// Build with `make wasm`.
+package main
+
+import (
+	"log"
+	"syscall/js"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/ui/web"
+)
+
+func main() {
+	emu := chip8.NewChip8()
+	emu.SetDisplay(web.NewCanvas("screen"))
+	keypad := web.NewKeypad()
+	keypad.AttachTouch("keypad")
+	emu.SetKeyProvider(keypad)
+	emu.SetAudioSink(web.NewBeeper())
+
+	running := false
+
+	// chip8LoadRom(Uint8Array) is called by the host page with the ROM to run
+	js.Global().Set("chip8LoadRom", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		rom := make([]byte, args[0].Get("length").Int())
+		js.CopyBytesToGo(rom, args[0])
+		info, err := emu.LoadRomBytes(rom)
+		if err != nil {
+			log.Print(err)
+			return nil
+		}
+		running = true
+		log.Printf("Loaded %v", info)
+		return nil
+	}))
+
+	// requestAnimationFrame fires at the display's refresh rate, close enough to 60Hz for the timers
+	var frame js.Func
+	frame = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if running {
+			if err := emu.RunFrame(); err != nil {
+				log.Printf("emu.RunFrame: %v", err)
+				running = false
+			}
+		}
+		js.Global().Call("requestAnimationFrame", frame)
+		return nil
+	})
+	js.Global().Call("requestAnimationFrame", frame)
+
+	select {} // Keep the Go runtime alive for the callbacks
+}
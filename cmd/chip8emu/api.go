@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/api"
+)
+
+// romLoad asks a frontend's main loop to switch ROMs. rom is read from path when it's nil.
+type romLoad struct {
+	path string
+	rom  []byte
+	done chan error // Receives the result
+}
+
+// startAPI serves the HTTP control API on opts.api in the background until ctx is done. Key
+// presses go to keys. ROM loads are handed to the frontend through loads, or loaded directly when
+// loads is nil.
+func startAPI(ctx context.Context, emu *chip8.Chip8, opts runOptions, keys keyReceiver, loads chan<- romLoad) error {
+	srv := api.New(emu, keys)
+	load := func(l romLoad) error {
+		if loads == nil {
+			opts.demo = false
+			if l.rom == nil {
+				_, err := loadRom(emu, opts, l.path)
+				return err
+			}
+			return loadRomBytes(emu, opts, l.rom)
+		}
+		l.done = make(chan error, 1)
+		select {
+		case loads <- l:
+			return <-l.done
+		case <-ctx.Done():
+			return errors.New("shutting down")
+		}
+	}
+	srv.LoadFile = func(path string) error {
+		return load(romLoad{path: path})
+	}
+	srv.LoadBytes = func(rom []byte) error {
+		return load(romLoad{path: "api.ch8", rom: rom}) // Save states go to the working directory
+	}
+	srv.ROMDir = expandHome(opts.romDir)
+	srv.Reset = func() error {
+		if opts.lockstep() {
+			return errors.New("can't reset while recording, playing back or in netplay")
+		}
+		emu.Reset()
+		return nil
+	}
+	srv.Pause = func() error {
+		if opts.host != "" || opts.join != "" {
+			return errors.New("can't pause in netplay")
+		}
+		emu.Pause()
+		return nil
+	}
+
+	l, err := net.Listen("tcp", localAddr(opts.api))
+	if err != nil {
+		return fmt.Errorf("api: %v", err)
+	}
+	log.Printf("API listening on http://%v", l.Addr())
+	serve(ctx, l, srv, "api")
+	return nil
+}
+
+// localAddr binds addresses without a host, such as :8080, to localhost only
+func localAddr(addr string) string {
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		return net.JoinHostPort("127.0.0.1", port)
+	}
+	return addr
+}
+
+// startSpectator serves the read-only spectator page and stream of api.Spectator on addr in the
+// background until ctx is done
+func startSpectator(ctx context.Context, emu *chip8.Chip8, addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("spectate: %v", err)
+	}
+	log.Printf("Spectators can watch on http://%v", l.Addr())
+	serve(ctx, l, api.NewSpectator(emu), "spectate")
+	return nil
+}
+
+// serve runs an HTTP server on l in the background, closing it and its connections when ctx is done
+func serve(ctx context.Context, l net.Listener, h http.Handler, name string) {
+	srv := &http.Server{Handler: h}
+	go func() {
+		if err := srv.Serve(l); err != http.ErrServerClosed {
+			log.Printf("%s: %v", name, err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+}
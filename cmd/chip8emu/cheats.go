@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/cheat"
+)
+
+// maxCheatKeys is how many cheats the number keys can toggle in the cheat panel
+const maxCheatKeys = 9
+
+// loadCheats reads the -cheats file, or the ROM's .cheats file next to it when there is one.
+// It returns nil when there are no cheats to apply.
+func loadCheats(path, romPath string) (*cheat.List, error) {
+	if path == "" {
+		if romPath == "" {
+			return nil, nil
+		}
+		path = romPath + ".cheats"
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+	l, err := cheat.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %d cheats from: %v", l.Len(), path)
+	return l, nil
+}
+
+// cheatPanel lists the cheats for the F6 panel, numbered for the keys that toggle them
+func cheatPanel(l *cheat.List) string {
+	lines := []string{"CHEATS - 1-9 TOGGLE, F6 CLOSES"}
+	for i, c := range l.Cheats() {
+		if i == maxCheatKeys {
+			break
+		}
+		mark := " "
+		if c.Enabled {
+			mark = "X"
+		}
+		lines = append(lines, fmt.Sprintf("%d [%s] %s", i+1, mark, c.Name))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/ui/capture"
+)
+
+// clipRecorder records gameplay clips. Frames are added by the emulation goroutine while the
+// main loop starts and stops recordings.
+type clipRecorder struct {
+	mu   sync.Mutex
+	enc  capture.Encoder
+	path string
+}
+
+func (c *clipRecorder) recording() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enc != nil
+}
+
+func (c *clipRecorder) start(path string, p color.Palette, scale int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("clip: %v", err)
+	}
+	enc, err := capture.Create(path, p, scale)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.enc, c.path = enc, path
+	c.mu.Unlock()
+	log.Printf("Recording clip to: %v", path)
+	return nil
+}
+
+func (c *clipRecorder) addFrame(screen *chip8.Framebuffer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.enc == nil {
+		return
+	}
+	if err := c.enc.AddFrame(screen); err != nil {
+		log.Printf("%v", err)
+		c.enc.Close()
+		c.enc = nil
+	}
+}
+
+// stop finishes the current recording, if any. Encoding happens outside the lock so the
+// emulation goroutine isn't held up.
+func (c *clipRecorder) stop() {
+	c.mu.Lock()
+	enc, path := c.enc, c.path
+	c.enc = nil
+	c.mu.Unlock()
+	if enc == nil {
+		return
+	}
+	if err := enc.Close(); err != nil {
+		log.Printf("%v", err)
+		return
+	}
+	log.Printf("Clip saved to: %v", path)
+}
+
+// capturePath is a timestamped file name in dir for a capture of the ROM at romPath
+func capturePath(dir, romPath, ext string) string {
+	name := fmt.Sprintf("%s-%s.%s", romName(romPath), time.Now().Format("20060102-150405"), strings.TrimPrefix(ext, "."))
+	return filepath.Join(expandHome(dir), name)
+}
+
+// romName is the ROM's file name without its extension
+func romName(romPath string) string {
+	return strings.TrimSuffix(filepath.Base(romPath), filepath.Ext(romPath))
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/ui/sound"
+)
+
+/*
+Config files are TOML (or JSON when the file ends in .json). Every setting is optional
+and flags given on the command line override the file:
+
+	platform = "vip"       # quirks and ipf below are only used without a platform
+	ipf = 15
+	quirks = "shift,vfreset"
+	unknown_opcodes = "error"
+	backend = "sdl"
+	roms = "~/chip8/roms"
+	screenshots = "~/Pictures/chip8"
+	rpl_flags = "~/.chip8/flags"
+	high_scores = "~/.chip8/scores"
+	clips = "~/Videos/chip8"
+	clip_format = "gif"
+	keys_preset = "positional"
+	pause_unfocused = true
+	idle_throttle = true
+	log_level = "info"
+	log = "all"
+
+	[display]
+	scale = 10
+	integer_scale = true
+	fullscreen = false
+	palette = "amber"
+	fg = "ffb000"
+	bg = "140a00"
+	ghosting = true
+	filter = "crt"
+	osd = true
+	vsync = true
+	low_latency = false
+	disasm = false
+
+	[audio]
+	mute = false
+	tone = 440
+	wave = "triangle"
+	volume = 25
+
+	[keys]
+	# CHIP-8 key = keyboard key, using SDL key names. Prefix a name with "scan:" to bind
+	# the key in that position on a US keyboard, whatever the layout.
+	5 = "Up"
+	8 = "scan:S"
+
+	[gamepad]
+	# CHIP-8 key = controller button, using SDL button names (a, b, x, y, back, start,
+	# leftshoulder, rightshoulder, dpup, dpdown, dpleft, dpright, ...)
+	5 = "b"
+
+	[macros]
+	# Keyboard key = macro it plays, see pkg/chip8/macro: CHIP-8 keys pressed for 2 frames
+	# (or :N frames) one after the other, with _N waiting N frames
+	F = "5 5 5"
+	"scan:G" = "4+5:10 _30 6"
+
+	[autofire]
+	# CHIP-8 key = frames it's pressed, then released, over and over while held
+	5 = 3
+*/
+
+// config holds the settings that can be stored in a config file
+type config struct {
+	Platform       string `json:"platform"` // Machine profile, empty for the emulator's defaults
+	IPF            int    `json:"ipf"`
+	Quirks         string `json:"quirks"`
+	Unknown        string `json:"unknown_opcodes"` // error, skip or halt
+	Backend        string `json:"backend"`
+	ROMs           string `json:"roms"`            // Directory listed by the ROM launcher
+	Screenshots    string `json:"screenshots"`     // Directory F12 saves screenshots to
+	RPLFlags       string `json:"rpl_flags"`       // Directory SCHIP RPL user flags are kept in, "" to forget them
+	HighScores     string `json:"high_scores"`     // Directory high scores are kept in, "" to forget them
+	Clips          string `json:"clips"`           // Directory F9 saves recordings to
+	ClipFormat     string `json:"clip_format"`     // gif, or an ffmpeg format such as mp4
+	KeysPreset     string `json:"keys_preset"`     // Keyboard layout for the keypad, see keyPresets
+	PauseUnfocused bool   `json:"pause_unfocused"` // Pause and mute while the window doesn't have the focus
+	IdleThrottle   bool   `json:"idle_throttle"`   // Poll and redraw less often while the machine is idle
+	LogLevel       string `json:"log_level"`       // debug, info or warn
+	Log            string `json:"log"`             // Components that log, see chip8.ParseLogComponents
+	Display        struct {
+		Scale        int    `json:"scale"`
+		IntegerScale bool   `json:"integer_scale"`
+		Fullscreen   bool   `json:"fullscreen"`
+		Palette      string `json:"palette"`
+		FG           string `json:"fg"`
+		BG           string `json:"bg"`
+		Ghosting     bool   `json:"ghosting"`
+		Filter       string `json:"filter"`
+		OSD          bool   `json:"osd"` // Messages drawn on top of the screen
+		VSync        bool   `json:"vsync"`
+		LowLatency   bool   `json:"low_latency"`
+		Disasm       bool   `json:"disasm"` // Disassembly pane beside the screen
+	} `json:"display"`
+	Audio struct {
+		Mute   bool    `json:"mute"`
+		Tone   float64 `json:"tone"`   // Hz
+		Wave   string  `json:"wave"`   // square, sine, triangle or noise
+		Volume int     `json:"volume"` // Percent
+	} `json:"audio"`
+	Keys     map[string]string `json:"keys"`     // CHIP-8 key (hex digit) -> SDL key name
+	Gamepad  map[string]string `json:"gamepad"`  // CHIP-8 key (hex digit) -> SDL controller button name
+	Macros   map[string]string `json:"macros"`   // SDL key name -> macro
+	Autofire map[string]int    `json:"autofire"` // CHIP-8 key (hex digit) -> frames pressed and released
+}
+
+func defaultConfig() config {
+	var c config
+	c.IPF = chip8.DefaultInstructionsPerFrame
+	c.Unknown = "error"
+	c.Backend = "sdl"
+	c.ROMs = "roms"
+	c.Screenshots = "screenshots"
+	c.RPLFlags = defaultFlagDir()
+	c.HighScores = defaultHighScoreDir()
+	c.Clips = "clips"
+	c.ClipFormat = "gif"
+	c.KeysPreset = "positional"
+	c.PauseUnfocused = true
+	c.IdleThrottle = true
+	c.LogLevel = "info"
+	c.Log = "all"
+	c.Display.Scale = 8
+	c.Display.Palette = "classic"
+	c.Display.Filter = "none"
+	c.Display.OSD = true
+	c.Display.VSync = true
+	c.Audio.Tone = sound.DefaultFrequency
+	c.Audio.Wave = sound.Square.String()
+	c.Audio.Volume = int(sound.DefaultVolume * 100)
+	return c
+}
+
+// defaultConfigPath is ~/.config/chip8emu/config.toml (or the platform's equivalent)
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8emu", "config.toml")
+}
+
+// configFromArgs loads the file named by a -config flag in args, or the default config file
+// if there is one. It runs before the flags are parsed so the file can supply their defaults.
+func configFromArgs(args []string) (config, error) {
+	path, explicit := defaultConfigPath(), false
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		switch {
+		case arg == name:
+			continue
+		case name == "config" && i+1 < len(args):
+			path, explicit = args[i+1], true
+		case strings.HasPrefix(name, "config="):
+			path, explicit = strings.TrimPrefix(name, "config="), true
+		}
+	}
+
+	cfg := defaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("config: failed reading file: %v", err)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &cfg)
+	} else {
+		err = unmarshalTOML(data, &cfg)
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("config: %v: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// unmarshalTOML decodes the subset of TOML used by config files (tables, strings,
+// integers and booleans) into v, reusing v's json tags for the key names
+func unmarshalTOML(data []byte, v interface{}) error {
+	root := map[string]interface{}{}
+	table := root
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return fmt.Errorf("line %d: invalid table header %q", lineNo, line)
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			table = map[string]interface{}{}
+			root[name] = table
+			continue
+		}
+
+		idx := strings.IndexByte(line, '=')
+		if idx < 0 {
+			return fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:idx]), `"`)
+		raw := strings.TrimSpace(line[idx+1:])
+		var value interface{}
+		switch {
+		case strings.HasPrefix(raw, `"`):
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid string %s", lineNo, raw)
+			}
+			value = s
+		case raw == "true" || raw == "false":
+			value = raw == "true"
+		default:
+			n, err := strconv.ParseInt(strings.Replace(raw, "_", "", -1), 0, 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid value %s", lineNo, raw)
+			}
+			value = n
+		}
+		table[key] = value
+	}
+
+	// Round trip through JSON so the struct tags (and type checking) only live in one place
+	js, err := json.Marshal(root)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(js, v)
+}
+
+// stripComment removes a trailing # comment that isn't inside a string
+func stripComment(line string) string {
+	inString := false
+	for i, r := range line {
+		switch {
+		case r == '"' && (i == 0 || line[i-1] != '\\'):
+			inString = !inString
+		case r == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// expandHome replaces a leading ~ in path with the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
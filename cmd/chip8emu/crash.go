@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// crashHelp is shown under the crash report
+const crashHelp = "R: reset   D: save report   Backspace: rewind   F7: load state   Esc: quit"
+
+// haltHelp is shown under the report when an unknown opcode halted the machine
+const haltHelp = "O: resume past it   D: save report   Esc: quit"
+
+// saveCrashReport writes the post-mortem and a memory dump of emu to path + ".crash.txt",
+// and a save state to path + ".crash.state". It returns the report's path.
+func saveCrashReport(emu *chip8.Chip8, err error, path string) (string, error) {
+	var sb strings.Builder
+	for _, line := range emu.PostMortem(err) {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("\nMemory:\n")
+	mem := emu.ReadMemory(0, 4096)
+	for row := 0; row < len(mem); row += 16 {
+		fmt.Fprintf(&sb, "%03X  % X\n", row, mem[row:row+16])
+	}
+
+	reportPath := path + ".crash.txt"
+	if err := ioutil.WriteFile(reportPath, []byte(sb.String()), 0644); err != nil {
+		return "", fmt.Errorf("crash report: failed writing file: %v", err)
+	}
+	state, err := emu.SaveState()
+	if err != nil {
+		return "", fmt.Errorf("crash report: failed saving state: %v", err)
+	}
+	if err := ioutil.WriteFile(path+".crash.state", state, 0644); err != nil {
+		return "", fmt.Errorf("crash report: failed writing state: %v", err)
+	}
+	return reportPath, nil
+}
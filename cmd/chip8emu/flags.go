@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// flagFiles keeps the SCHIP RPL user flags of each ROM in dir, one file per ROM named after its SHA-1
+type flagFiles struct {
+	dir string
+}
+
+// defaultFlagDir is ~/.config/chip8emu/flags (or the platform's equivalent)
+func defaultFlagDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8emu", "flags")
+}
+
+// LoadFlags implements device.FlagStore
+func (f flagFiles) LoadFlags(sha1 string) [16]byte {
+	var flags [16]byte
+	data, err := ioutil.ReadFile(filepath.Join(f.dir, sha1))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Loading RPL flags failed: %v", err)
+		}
+		return flags
+	}
+	copy(flags[:], data)
+	return flags
+}
+
+// SaveFlags implements device.FlagStore
+func (f flagFiles) SaveFlags(sha1 string, flags [16]byte) {
+	if err := os.MkdirAll(f.dir, 0755); err != nil {
+		log.Printf("Saving RPL flags failed: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(filepath.Join(f.dir, sha1), flags[:], 0644); err != nil {
+		log.Printf("Saving RPL flags failed: %v", err)
+	}
+}
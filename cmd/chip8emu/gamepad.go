@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// stickThreshold is how far the left stick has to be pushed to press a direction
+const stickThreshold = 16000
+
+// defaultPadBindings maps controller buttons (SDL names) to CHIP-8 keys. The D-pad uses the
+// keys most ROMs use for directions and A is the usual "fire" key 5.
+var defaultPadBindings = map[string]uint8{
+	"dpup":          0x2,
+	"dpdown":        0x8,
+	"dpleft":        0x4,
+	"dpright":       0x6,
+	"a":             0x5,
+	"b":             0x0,
+	"x":             0xA,
+	"y":             0xB,
+	"leftshoulder":  0x1,
+	"rightshoulder": 0x3,
+	"back":          0xE,
+	"start":         0xF,
+}
+
+// gamepad maps game controller buttons and the left stick to the CHIP-8 keypad.
+// Controllers can be plugged in and out at any time.
+type gamepad struct {
+	buttons     map[int]uint8 // SDL_GameControllerButton -> CHIP-8 key
+	controllers map[sdl.JoystickID]*sdl.GameController
+	stick       map[int]bool // D-pad directions currently held by the left stick
+}
+
+var pad *gamepad
+
+// newGamepad creates the button mapping, bindings (CHIP-8 key -> SDL button name) override the defaults
+func newGamepad(bindings map[string]string) (*gamepad, error) {
+	g := &gamepad{
+		buttons:     map[int]uint8{},
+		controllers: map[sdl.JoystickID]*sdl.GameController{},
+		stick:       map[int]bool{},
+	}
+	for name, k := range defaultPadBindings {
+		g.buttons[int(sdl.GameControllerGetButtonFromString(name))] = k
+	}
+	for chipKey, name := range bindings {
+		k, err := strconv.ParseUint(chipKey, 16, 4)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid CHIP-8 key %q (expected 0-F)", chipKey)
+		}
+		button := int(sdl.GameControllerGetButtonFromString(name))
+		if button == sdl.CONTROLLER_BUTTON_INVALID {
+			return nil, fmt.Errorf("config: unknown controller button %q for CHIP-8 key %X", name, k)
+		}
+		for b, mapped := range g.buttons {
+			if mapped == uint8(k) {
+				delete(g.buttons, b)
+			}
+		}
+		g.buttons[button] = uint8(k)
+	}
+	return g, nil
+}
+
+// handleEvent handles controller events, passing key presses on to keys.
+// It returns false for events that aren't from a controller.
+func (g *gamepad) handleEvent(event sdl.Event, keys keyReceiver) bool {
+	switch t := event.(type) {
+	case *sdl.ControllerDeviceEvent:
+		switch t.Type {
+		case sdl.CONTROLLERDEVICEADDED:
+			c := sdl.GameControllerOpen(int(t.Which))
+			if c == nil {
+				log.Printf("Failed opening controller: %v", sdl.GetError())
+				return true
+			}
+			g.controllers[c.Joystick().InstanceID()] = c
+			log.Printf("Controller connected: %v", c.Name())
+		case sdl.CONTROLLERDEVICEREMOVED:
+			if c, ok := g.controllers[t.Which]; ok {
+				log.Printf("Controller disconnected: %v", c.Name())
+				c.Close()
+				delete(g.controllers, t.Which)
+			}
+		}
+	case *sdl.ControllerButtonEvent:
+		g.press(int(t.Button), t.Type == sdl.CONTROLLERBUTTONDOWN, keys)
+	case *sdl.ControllerAxisEvent:
+		var negative, positive int
+		switch t.Axis {
+		case sdl.CONTROLLER_AXIS_LEFTX:
+			negative, positive = sdl.CONTROLLER_BUTTON_DPAD_LEFT, sdl.CONTROLLER_BUTTON_DPAD_RIGHT
+		case sdl.CONTROLLER_AXIS_LEFTY:
+			negative, positive = sdl.CONTROLLER_BUTTON_DPAD_UP, sdl.CONTROLLER_BUTTON_DPAD_DOWN
+		default:
+			return true
+		}
+		g.stickTo(negative, t.Value < -stickThreshold, keys)
+		g.stickTo(positive, t.Value > stickThreshold, keys)
+	default:
+		return false
+	}
+	return true
+}
+
+func (g *gamepad) press(button int, down bool, keys keyReceiver) {
+	k, ok := g.buttons[button]
+	if !ok {
+		return
+	}
+	if down {
+		keys.KeyDown(k)
+	} else {
+		keys.KeyUp(k)
+	}
+}
+
+// stickTo presses or releases a D-pad direction for the left stick, when it changed
+func (g *gamepad) stickTo(direction int, held bool, keys keyReceiver) {
+	if g.stick[direction] == held {
+		return
+	}
+	g.stick[direction] = held
+	g.press(direction, held, keys)
+}
+
+func (g *gamepad) close() {
+	for id, c := range g.controllers {
+		c.Close()
+		delete(g.controllers, id)
+	}
+}
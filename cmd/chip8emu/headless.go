@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/testsuite"
+)
+
+// runHeadless runs emu without a window, input or sound as fast as it goes, for ROM based tests
+// in CI. It stops after opts.maxCycles instructions or, with opts.exitOnLoop, when the program
+// finishes in an endless loop, which is how most test ROMs end, then prints the state of the machine.
+//
+// A crash fails with the crash report, and so does running out of instructions before
+// the program finished with -exit-on-loop (exit status 2).
+func runHeadless(emu *chip8.Chip8, opts runOptions) error {
+	if opts.maxCycles <= 0 && !opts.exitOnLoop {
+		return fmt.Errorf("-headless needs -max-cycles, -exit-on-loop or both, or it would run forever")
+	}
+	cycles := 0
+	for ; opts.maxCycles <= 0 || cycles < opts.maxCycles; cycles++ {
+		if pc, finished := emu.Finished(); finished && opts.exitOnLoop {
+			dumpState(emu, fmt.Sprintf("Finished: endless loop at %#03x after %d instructions", pc, cycles))
+			return nil
+		}
+		if err := emu.Step(); err != nil {
+			return fmt.Errorf("after %d instructions: %v\n%s", cycles, err, strings.Join(emu.PostMortem(err), "\n"))
+		}
+		if halt := emu.HaltReason(); halt != nil {
+			return fmt.Errorf("halted after %d instructions: %v\n%s", cycles, halt, strings.Join(emu.PostMortem(halt), "\n"))
+		}
+	}
+	if opts.exitOnLoop {
+		dumpState(emu, fmt.Sprintf("Unfinished: no endless loop within %d instructions", cycles))
+		return exitStatus{code: 2, err: fmt.Errorf("the program didn't finish within %d instructions", cycles)}
+	}
+	dumpState(emu, fmt.Sprintf("Stopped after %d instructions", cycles))
+	return nil
+}
+
+// dumpState prints why the run ended, the screen and the registers around PC
+func dumpState(emu *chip8.Chip8, reason string) {
+	screen, _ := emu.SnapshotScreen()
+	fmt.Println(reason)
+	fmt.Print(testsuite.FormatScreen(screen))
+	lines := emu.PostMortem(errors.New(reason))
+	fmt.Println(strings.Join(lines[1:], "\n")) // Without the reason again
+}
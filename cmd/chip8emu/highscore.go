@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/cheat"
+)
+
+// highScoreSaveInterval limits how often a climbing high score is written to disk
+const highScoreSaveInterval = time.Second
+
+// highScore tracks the best score of the running ROM, read from where its cheat file says the
+// game keeps it (see cheat.Score). Best scores are kept in a directory, one file per ROM named
+// after its SHA-1 holding the number.
+type highScore struct {
+	score   cheat.Score
+	path    string // "" to keep the best score for this session only
+	best    uint64
+	saved   uint64 // The best score on disk
+	savedAt time.Time
+	beaten  bool // The session beat the best score it started with
+}
+
+// defaultHighScoreDir is ~/.config/chip8emu/scores (or the platform's equivalent)
+func defaultHighScoreDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "chip8emu", "scores")
+}
+
+// newHighScore tracks the score described by cheats for the ROM emu is running, nil when
+// cheats doesn't say where the score is
+func newHighScore(emu *chip8.Chip8, cheats *cheat.List, dir string) *highScore {
+	if cheats == nil {
+		return nil
+	}
+	score, ok := cheats.Score()
+	if !ok {
+		return nil
+	}
+	h := &highScore{score: score}
+	if dir != "" {
+		h.path = filepath.Join(dir, emu.RomInfo().SHA1)
+	}
+	if h.path != "" {
+		data, err := ioutil.ReadFile(h.path)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Loading the high score failed: %v", err)
+		} else if err == nil {
+			if h.best, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+				log.Printf("Loading the high score failed: %v", err)
+			}
+		}
+	}
+	h.saved = h.best
+	log.Printf("Tracking the score at %v, the high score is %d", score, h.best)
+	return h
+}
+
+// update reads the score, saving it when it's a new best, and returns the text for the HUD
+func (h *highScore) update(emu *chip8.Chip8) string {
+	if v, ok := h.score.Read(emu); ok && v > h.best {
+		if !h.beaten && h.best > 0 {
+			notify("New high score!")
+		}
+		h.best, h.beaten = v, true
+	}
+	if h.best != h.saved && time.Since(h.savedAt) >= highScoreSaveInterval {
+		h.save()
+	}
+	return fmt.Sprintf("HI %d", h.best)
+}
+
+// save writes the best score to disk if it changed
+func (h *highScore) save() {
+	if h.path == "" || h.best == h.saved {
+		return
+	}
+	h.saved, h.savedAt = h.best, time.Now()
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		log.Printf("Saving the high score failed: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(h.path, []byte(strconv.FormatUint(h.best, 10)+"\n"), 0644); err != nil {
+		log.Printf("Saving the high score failed: %v", err)
+	}
+}
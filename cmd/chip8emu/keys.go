@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/macro"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// scanPrefix marks a key name as a scancode (a physical key position) rather than a keycode (a key label)
+const scanPrefix = "scan:"
+
+// keyPresets lay out the 4x4 keypad on the keyboard, listing the key for each CHIP-8 key 0-F:
+//
+//	1 2 3 C
+//	4 5 6 D
+//	7 8 9 E
+//	A 0 B F
+var keyPresets = map[string][16]string{
+	// The 1234 / QWER / ASDF / ZXCV block, by position so AZERTY, QWERTZ and Dvorak keyboards
+	// use the same physical keys as QWERTY ones
+	"positional": {
+		"scan:X", "scan:1", "scan:2", "scan:3",
+		"scan:Q", "scan:W", "scan:E", "scan:A",
+		"scan:S", "scan:D", "scan:Z", "scan:C",
+		"scan:4", "scan:R", "scan:F", "scan:V",
+	},
+	// The same block by key label, which moves with the keyboard layout
+	"qwerty": {
+		"X", "1", "2", "3",
+		"Q", "W", "E", "A",
+		"S", "D", "Z", "C",
+		"4", "R", "F", "V",
+	},
+	// The numeric keypad: 789/ 456* 123- 0.+ plus Enter for B
+	"numpad": {
+		"scan:Keypad .", "scan:Keypad 7", "scan:Keypad 8", "scan:Keypad 9",
+		"scan:Keypad 4", "scan:Keypad 5", "scan:Keypad 6", "scan:Keypad 1",
+		"scan:Keypad 2", "scan:Keypad 3", "scan:Keypad 0", "scan:Keypad Enter",
+		"scan:Keypad /", "scan:Keypad *", "scan:Keypad -", "scan:Keypad +",
+	},
+}
+
+// keyPresetNames lists the presets in alphabetical order
+func keyPresetNames() []string {
+	names := make([]string, 0, len(keyPresets))
+	for name := range keyPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// keyBindings maps keyboard keys to the CHIP-8 keypad, by scancode or keycode
+type keyBindings struct {
+	scancodes map[sdl.Scancode]uint8
+	keycodes  map[sdl.Keycode]uint8
+}
+
+var keyMap *keyBindings
+
+// newKeyBindings starts from a preset and applies the [keys] section of the config file
+// (CHIP-8 key -> key name), which replaces the preset's key for each CHIP-8 key it names
+func newKeyBindings(preset string, bindings map[string]string) (*keyBindings, error) {
+	names, ok := keyPresets[preset]
+	if !ok {
+		return nil, fmt.Errorf("unknown keys preset %q (expected %s)", preset, strings.Join(keyPresetNames(), ", "))
+	}
+	b := &keyBindings{scancodes: map[sdl.Scancode]uint8{}, keycodes: map[sdl.Keycode]uint8{}}
+	for k, name := range names {
+		if err := b.bind(uint8(k), name); err != nil {
+			return nil, err
+		}
+	}
+	for chipKey, name := range bindings {
+		k, err := strconv.ParseUint(chipKey, 16, 4)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid CHIP-8 key %q (expected 0-F)", chipKey)
+		}
+		if err := b.bind(uint8(k), name); err != nil {
+			return nil, fmt.Errorf("config: %v", err)
+		}
+	}
+	return b, nil
+}
+
+// parseKeyName looks up a key name, returning its scancode for names with scanPrefix and its keycode otherwise
+func parseKeyName(name string) (sdl.Scancode, sdl.Keycode, error) {
+	if strings.HasPrefix(name, scanPrefix) {
+		scancode := sdl.GetScancodeFromName(strings.TrimPrefix(name, scanPrefix))
+		if scancode == sdl.SCANCODE_UNKNOWN {
+			return sdl.SCANCODE_UNKNOWN, sdl.K_UNKNOWN, fmt.Errorf("unknown scancode name %q", name)
+		}
+		return scancode, sdl.K_UNKNOWN, nil
+	}
+	keycode := sdl.GetKeyFromName(name)
+	if keycode == sdl.K_UNKNOWN {
+		return sdl.SCANCODE_UNKNOWN, sdl.K_UNKNOWN, fmt.Errorf("unknown key name %q", name)
+	}
+	return sdl.SCANCODE_UNKNOWN, keycode, nil
+}
+
+// bind makes the key called name press CHIP-8 key k, replacing k's previous binding
+func (b *keyBindings) bind(k uint8, name string) error {
+	scancode, keycode, err := parseKeyName(name)
+	if err != nil {
+		return fmt.Errorf("%v for CHIP-8 key %X", err, k)
+	}
+
+	for c, mapped := range b.scancodes {
+		if mapped == k {
+			delete(b.scancodes, c)
+		}
+	}
+	for c, mapped := range b.keycodes {
+		if mapped == k {
+			delete(b.keycodes, c)
+		}
+	}
+	if scancode != sdl.SCANCODE_UNKNOWN {
+		b.scancodes[scancode] = k
+	} else {
+		b.keycodes[keycode] = k
+	}
+	return nil
+}
+
+// lookup returns the CHIP-8 key bound to a keyboard key
+func (b *keyBindings) lookup(key sdl.Keysym) (uint8, bool) {
+	if k, ok := b.scancodes[key.Scancode]; ok {
+		return k, true
+	}
+	k, ok := b.keycodes[key.Sym]
+	return k, ok
+}
+
+// labels names the key bound to each CHIP-8 key, as printed on the keyboard in use
+func (b *keyBindings) labels() [16]string {
+	var labels [16]string
+	for code, k := range b.keycodes {
+		labels[k] = sdl.GetKeyName(code)
+	}
+	for code, k := range b.scancodes {
+		labels[k] = sdl.GetKeyName(sdl.GetKeyFromScancode(code))
+	}
+	for k, label := range labels {
+		labels[k] = strings.Replace(label, "Keypad ", "KP", 1)
+	}
+	return labels
+}
+
+// macroBindings maps keyboard keys to the macros they play, by scancode or keycode
+type macroBindings struct {
+	scancodes map[sdl.Scancode]macro.Macro
+	keycodes  map[sdl.Keycode]macro.Macro
+}
+
+// newMacroBindings parses the [macros] section of the config file (key name -> macro)
+func newMacroBindings(bindings map[string]string) (*macroBindings, error) {
+	b := &macroBindings{scancodes: map[sdl.Scancode]macro.Macro{}, keycodes: map[sdl.Keycode]macro.Macro{}}
+	for name, steps := range bindings {
+		m, err := macro.Parse(steps)
+		if err != nil {
+			return nil, fmt.Errorf("config: key %q: %v", name, err)
+		}
+		scancode, keycode, err := parseKeyName(name)
+		if err != nil {
+			return nil, fmt.Errorf("config: %v for a macro", err)
+		}
+		if scancode != sdl.SCANCODE_UNKNOWN {
+			b.scancodes[scancode] = m
+		} else {
+			b.keycodes[keycode] = m
+		}
+	}
+	return b, nil
+}
+
+// lookup returns the macro bound to a keyboard key
+func (b *macroBindings) lookup(key sdl.Keysym) (macro.Macro, bool) {
+	if m, ok := b.scancodes[key.Scancode]; ok {
+		return m, true
+	}
+	m, ok := b.keycodes[key.Sym]
+	return m, ok
+}
+
+// newMacroPlayer passes keys through a macro.Player with the [autofire] section of the config
+// file (CHIP-8 key -> frames) applied
+func newMacroPlayer(keys macro.Keypad, autofire map[string]int) (*macro.Player, error) {
+	p := macro.NewPlayer(keys)
+	for chipKey, frames := range autofire {
+		k, err := strconv.ParseUint(chipKey, 16, 4)
+		if err != nil {
+			return nil, fmt.Errorf("config: invalid CHIP-8 key %q for autofire (expected 0-F)", chipKey)
+		}
+		if frames < 0 {
+			return nil, fmt.Errorf("config: invalid autofire rate %d for CHIP-8 key %X", frames, k)
+		}
+		p.SetAutofire(uint8(k), frames)
+	}
+	return p, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// latencyMeter measures input-to-photon latency: the time from a key press to presenting the
+// first frame the machine ran after it
+type latencyMeter struct {
+	mu      sync.Mutex
+	pressed time.Time // The oldest press not on screen yet, zero when there's none
+	ran     bool      // A frame started after pressed
+	last    time.Duration
+	total   time.Duration
+	count   int
+}
+
+// keyPressed is called when a key goes down
+func (m *latencyMeter) keyPressed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pressed.IsZero() {
+		m.pressed = time.Now()
+		m.ran = false
+	}
+}
+
+// frameRan is called after running a frame that started at start, on any goroutine
+func (m *latencyMeter) frameRan(start time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.pressed.IsZero() && !start.Before(m.pressed) {
+		m.ran = true
+	}
+}
+
+// presented is called after the screen was presented
+func (m *latencyMeter) presented() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.ran {
+		return
+	}
+	m.last = time.Since(m.pressed)
+	m.total += m.last
+	m.count++
+	m.pressed, m.ran = time.Time{}, false
+}
+
+// average returns the mean latency and how many presses it covers
+func (m *latencyMeter) average() (time.Duration, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.count == 0 {
+		return 0, 0
+	}
+	return m.total / time.Duration(m.count), m.count
+}
+
+// String formats the last and mean latency for the info overlay
+func (m *latencyMeter) String() string {
+	avg, n := m.average()
+	if n == 0 {
+		return "LAT -"
+	}
+	m.mu.Lock()
+	last := m.last
+	m.mu.Unlock()
+	return fmt.Sprintf("LAT %d/%dMS", last.Milliseconds(), avg.Milliseconds())
+}
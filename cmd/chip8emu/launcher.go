@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/ui"
+	"github.com/dustinbowers/chip8emu/ui/menu"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// findRoms lists the .ch8 files in dir and its subdirectories
+func findRoms(dir string) ([]string, error) {
+	var roms []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(path), ".ch8") {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	sort.Slice(roms, func(i, j int) bool {
+		return strings.ToLower(filepath.Base(roms[i])) < strings.ToLower(filepath.Base(roms[j]))
+	})
+	return roms, err
+}
+
+// menuKeys drives the launcher menu from CHIP-8 keys, so controllers work too
+type menuKeys struct {
+	m      *menu.Menu
+	chosen bool
+}
+
+func (k *menuKeys) KeyDown(key uint8) {
+	switch key {
+	case 0x2:
+		k.m.Up()
+	case 0x8:
+		k.m.Down()
+	case 0x5:
+		k.chosen = true
+	}
+}
+
+func (k *menuKeys) KeyUp(key uint8) {}
+
+// chooseRom shows a menu of the ROMs in dir on the CHIP-8 display and returns the selected path.
+// Up / down (or keypad 2 / 8) move the selection, enter (or keypad 5) picks it.
+// An empty path is returned if the window is closed or Esc is pressed.
+func chooseRom(dir string) (string, error) {
+	roms, err := findRoms(dir)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, len(roms))
+	for i, path := range roms {
+		names[i] = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	m := menu.New(names)
+	nav := &menuKeys{m: m}
+	ui.SetStatus("choose a ROM")
+	defer ui.SetStatus("")
+
+	ticker := time.NewTicker(time.Second / 60)
+	defer ticker.Stop()
+	for range ticker.C {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			if ui.HandleEvent(event) {
+				continue
+			}
+			if pad.handleEvent(event, nav) {
+				if i := m.Selected(); nav.chosen && i >= 0 {
+					return roms[i], nil
+				}
+				nav.chosen = false
+				continue
+			}
+			switch t := event.(type) {
+			case *sdl.QuitEvent:
+				return "", nil
+			case *sdl.DropEvent:
+				if t.Type == sdl.DROPFILE {
+					return t.File, nil
+				}
+			case *sdl.KeyboardEvent:
+				if t.Type != sdl.KEYDOWN {
+					continue
+				}
+				k, isKeypad := keyMap.lookup(t.Keysym)
+				switch {
+				case t.Keysym.Sym == sdl.K_ESCAPE:
+					return "", nil
+				case t.Keysym.Sym == sdl.K_UP || isKeypad && k == 0x2:
+					m.Up()
+				case t.Keysym.Sym == sdl.K_DOWN || isKeypad && k == 0x8:
+					m.Down()
+				case t.Keysym.Sym == sdl.K_RETURN || isKeypad && k == 0x5:
+					if i := m.Selected(); i >= 0 {
+						return roms[i], nil
+					}
+				}
+			}
+		}
+		m.Tick()
+		_ = ui.Draw(m.Render())
+	}
+	return "", nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+)
+
+var logLevelNames = []string{"debug", "info", "warn"}
+
+// stdLogger is a chip8.Logger writing messages at or above its level to the log package's
+// logger, e.g. "DEBUG waiting for key component=cpu pc=0x200"
+type stdLogger struct {
+	level int
+}
+
+func newStdLogger(level string) (*stdLogger, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(level, name) {
+			return &stdLogger{level: i}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown log level %q (expected one of: %v)", level, strings.Join(logLevelNames, ", "))
+}
+
+func (l *stdLogger) Debug(msg string, args ...interface{}) { l.print(levelDebug, msg, args) }
+func (l *stdLogger) Info(msg string, args ...interface{})  { l.print(levelInfo, msg, args) }
+func (l *stdLogger) Warn(msg string, args ...interface{})  { l.print(levelWarn, msg, args) }
+
+func (l *stdLogger) print(level int, msg string, args []interface{}) {
+	if level < l.level {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(strings.ToUpper(logLevelNames[level]))
+	b.WriteString(" ")
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	log.Print(b.String())
+}
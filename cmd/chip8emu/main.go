@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/demo"
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/compat"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/octo"
+)
+
+// command is a chip8emu subcommand
+type command struct {
+	usage string // One line summary shown by `chip8emu help`
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"run":      {"run a ROM (the default when no command is given)", runCommand},
+	"disasm":   {"print a program listing of a ROM", disasmCommand},
+	"asm":      {"assemble a source file into a ROM", asmCommand},
+	"test":     {"run a ROM headless for a number of frames and print the screen", testCommand},
+	"info":     {"print details about a ROM", infoCommand},
+	"octocart": {"pack a ROM or Octo source into an Octo cartridge image", octocartCommand},
+	"analyze":  {"statically check a ROM for unreachable code, bad jumps and unsupported opcodes", analyzeCommand},
+	"bench":    {"run a ROM headless as fast as possible and report the interpreter's speed", benchCommand},
+	"profile":  {"run a ROM headless and report the instructions each subroutine runs", profileCommand},
+	"coverage": {"run a ROM headless and report which of its instructions and branches ran", coverageCommand},
+}
+
+func main() {
+	args := os.Args[1:]
+	name := "run"
+	if len(args) > 0 {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage()
+			return
+		}
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		}
+	}
+
+	if err := commands[name].run(args); err != nil {
+		log.Printf("%s failed: %v", name, err)
+		code := 1
+		if s, ok := err.(exitStatus); ok {
+			code = s.code
+		}
+		os.Exit(code)
+	}
+}
+
+// exitStatus is an error that exits with code rather than 1
+type exitStatus struct {
+	code int
+	err  error
+}
+
+func (s exitStatus) Error() string {
+	return s.err.Error()
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: chip8emu [command] [flags] rom\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun `chip8emu <command> -h` for the flags each command accepts.\n")
+}
+
+// newFlagSet creates the flag set for a subcommand, args describes its positional arguments
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: chip8emu %s [flags] %s\n\nFlags:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses args with fs, allowing flags both before and after the positional arguments.
+// Between min and max positional arguments are accepted.
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(positional) < min || len(positional) > max {
+		fs.Usage()
+		if min == max {
+			return nil, fmt.Errorf("expected %d argument(s), got %d", min, len(positional))
+		}
+		return nil, fmt.Errorf("expected %d to %d arguments, got %d", min, max, len(positional))
+	}
+	return positional, nil
+}
+
+// explicitFlags returns the names of the flags that were given on the command line
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	return explicit
+}
+
+// configureMachine sets emu up as the named platform, or with the emulator's defaults when platform
+// is empty. quirks and ipf are applied on top, but only when given explicitly if a platform is named.
+func configureMachine(emu *chip8.Chip8, platform, quirks string, ipf int, explicit map[string]bool) error {
+	q, err := chip8.ParseQuirks(quirks)
+	if err != nil {
+		return err
+	}
+	profile := chip8.DefaultProfile()
+	if platform != "" {
+		if profile, err = chip8.LookupProfile(platform); err != nil {
+			return err
+		}
+	}
+	emu.SetProfile(profile)
+	if platform == "" || explicit["quirks"] {
+		emu.SetQuirks(q)
+	}
+	if platform == "" || explicit["ipf"] {
+		emu.SetInstructionsPerFrame(ipf)
+	}
+	return nil
+}
+
+// applyCompat looks rom up in the compatibility database and applies its settings.
+// Settings given explicitly on the command line are left alone, and an explicit -platform
+// keeps the quirks and speed it chose.
+func applyCompat(emu *chip8.Chip8, explicit map[string]bool, rom []byte) {
+	entry, ok := compat.Lookup(rom)
+	if !ok {
+		return
+	}
+	if explicit["platform"] {
+		entry.Platform = ""
+	}
+	if explicit["quirks"] || explicit["platform"] {
+		entry.Quirks = emu.Quirks()
+	}
+	if explicit["ipf"] || explicit["platform"] {
+		entry.InstructionsPerFrame = emu.InstructionsPerFrame()
+	}
+	if explicit["unknown"] {
+		entry.UnknownOpcodes = emu.UnknownOpcodePolicy()
+	}
+	compat.Apply(emu, entry)
+	log.Printf("Known ROM: %v (%v, quirks: %q)", entry.Title, emu.Profile().Name, emu.Quirks().String())
+}
+
+// readRom reads the ROM at path (a file, URL or .zip archive, see chip8.ReadRom), or the
+// embedded demo called path when demo is set. Octo source and cartridges are compiled, see fromOcto.
+func readRom(path string, demoRom bool) ([]byte, error) {
+	if demoRom {
+		if path == "" {
+			path = demo.Default
+		}
+		return demo.ROM(path)
+	}
+	data, err := chip8.ReadRom(path)
+	if err != nil {
+		return nil, err
+	}
+	return fromOcto(path, data)
+}
+
+// fromOcto compiles data when it's Octo source (a .o8 file) or an Octo cartridge, and returns
+// other ROMs as they are. A cartridge's options are registered with the compatibility database,
+// so applyCompat picks them up.
+func fromOcto(path string, data []byte) ([]byte, error) {
+	name := filepath.Base(path)
+	if chip8.IsRomURL(path) {
+		name = urlFileName(path)
+	}
+	switch {
+	case strings.EqualFold(filepath.Ext(name), ".o8"):
+		return octo.Compile(string(data))
+	case octo.IsCartridge(data):
+		cart, err := octo.ReadCartridge(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		rom, err := cart.ROM()
+		if err != nil {
+			return nil, err
+		}
+		compat.Register(rom, cart.Options.Entry(name))
+		return rom, nil
+	}
+	return data, nil
+}
+
+// urlFileName is the last element of a URL's path, e.g. game.zip for https://example.com/game.zip?dl=1
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "download.ch8"
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "download.ch8"
+	}
+	return name
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/debugger"
+	"github.com/dustinbowers/chip8emu/ui"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// memViewRows is how many rows of 16 bytes the F3 memory view shows
+const memViewRows = 16
+
+// disasmRows is how many instructions the Ctrl+D disassembly pane shows
+const disasmRows = 25
+
+const memViewHelp = "ARROWS PGUP PGDN HOME MOVE, 0-F EDIT, F3 CLOSES"
+
+// showMemoryView draws v, called every pass of the main loop while it's shown so it follows the machine
+func showMemoryView(v *debugger.MemoryView) {
+	lines, marks := v.Lines()
+	ui.ShowMemoryView(append(lines, memViewHelp), marks)
+}
+
+// showDisassembly opens or updates the disassembly pane, called every pass of the main loop while
+// it's shown so it follows PC, also while paused and single-stepping
+func showDisassembly(emu *chip8.Chip8) {
+	ui.ShowDisassembly(debugger.Disassemble(emu, disasmRows))
+}
+
+// memoryViewKey handles a key pressed while the memory view is shown, reporting whether it
+// was used. Hex digits edit memory, so they don't reach the keypad.
+func memoryViewKey(v *debugger.MemoryView, key sdl.Keycode) bool {
+	switch key {
+	case sdl.K_UP:
+		v.Move(-debugger.MemoryViewColumns)
+	case sdl.K_DOWN:
+		v.Move(debugger.MemoryViewColumns)
+	case sdl.K_LEFT:
+		v.Move(-1)
+	case sdl.K_RIGHT:
+		v.Move(1)
+	case sdl.K_PAGEUP:
+		v.Move(-memViewRows * debugger.MemoryViewColumns)
+	case sdl.K_PAGEDOWN:
+		v.Move(memViewRows * debugger.MemoryViewColumns)
+	case sdl.K_HOME:
+		v.Goto(0x200)
+	default:
+		if (key < sdl.K_0 || key > sdl.K_9) && (key < sdl.K_a || key > sdl.K_f) {
+			return false
+		}
+		if err := v.Type(rune(key)); err != nil {
+			ui.Notify(err.Error())
+		}
+	}
+	showMemoryView(v)
+	return true
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/netplay"
+)
+
+// startNetplay hosts or joins the -host / -join game, waiting for the other player.
+// It returns nil when neither flag is set.
+func startNetplay(emu *chip8.Chip8, opts runOptions) (*netplay.Session, error) {
+	switch {
+	case opts.host != "" && opts.join != "":
+		return nil, fmt.Errorf("-host and -join can't be used together")
+	case opts.host == "" && opts.join == "":
+		return nil, nil
+	case opts.record != "" || opts.playback != "":
+		return nil, fmt.Errorf("netplay can't be recorded or played back")
+	case opts.debug || opts.script != "" || opts.profile != "" || opts.coverage != "":
+		return nil, fmt.Errorf("netplay can't be combined with -debug, -script, -profile or -coverage")
+	}
+	if opts.host != "" {
+		log.Printf("Waiting for the other player on %v", opts.host)
+		return netplay.Host(emu, opts.rom, opts.host, netplay.Options{Seed: time.Now().UnixNano(), Delay: opts.netDelay})
+	}
+	log.Printf("Joining %v", opts.join)
+	return netplay.Join(emu, opts.rom, opts.join)
+}
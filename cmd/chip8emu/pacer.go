@@ -0,0 +1,54 @@
+package main
+
+import "time"
+
+// spinTime is how long before a deadline framePacer stops sleeping and busy-waits, since
+// sleeps can overshoot by a millisecond or more on some systems
+const spinTime = time.Millisecond
+
+// framePacer paces a loop to a fixed rate. Deadlines advance by exactly one period each frame,
+// so the error of each sleep is corrected on the next one instead of adding up.
+type framePacer struct {
+	period time.Duration
+	next   time.Time
+}
+
+func newFramePacer(period time.Duration) *framePacer {
+	return &framePacer{period: period}
+}
+
+// wait blocks until the start of the next frame. After falling more than a frame behind
+// (e.g. while the window was being dragged) it starts over rather than rushing to catch up.
+func (p *framePacer) wait() {
+	now := time.Now()
+	if p.next.IsZero() || now.Sub(p.next) > p.period {
+		p.next = now
+	}
+	p.next = p.next.Add(p.period)
+	if d := time.Until(p.next) - spinTime; d > 0 {
+		time.Sleep(d)
+	}
+	for time.Now().Before(p.next) {
+	}
+}
+
+// frameClock runs the machine at its own rate from a loop paced to the display, which may
+// refresh faster
+type frameClock struct {
+	period time.Duration
+	next   time.Time
+}
+
+// due reports whether it's time for the next frame. A loop running at the machine's rate can
+// arrive a little early, so frames are due from half a period before their deadline.
+func (c *frameClock) due() bool {
+	now := time.Now()
+	if !c.next.IsZero() && c.next.Sub(now) > c.period/2 {
+		return false
+	}
+	if c.next.IsZero() || now.Sub(c.next) > c.period {
+		c.next = now
+	}
+	c.next = c.next.Add(c.period)
+	return true
+}
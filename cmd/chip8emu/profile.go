@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/coverage"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/profiler"
+)
+
+// startProfiling starts the profiler behind -profile and -coverage. The returned function writes
+// their reports for the ROM loaded by then.
+func startProfiling(emu *chip8.Chip8, opts *runOptions) (func(), error) {
+	if opts.profile == "" && opts.coverage == "" {
+		return func() {}, nil
+	}
+	if opts.script != "" {
+		return nil, fmt.Errorf("-profile and -coverage can't be combined with -script")
+	}
+	prof := profiler.New(emu)
+	return func() {
+		if opts.profile != "" {
+			saveReport("Profile", opts.profile, []byte(prof.Profile().String()))
+		}
+		if opts.coverage != "" {
+			saveReport("Coverage", opts.coverage, coverageReport(prof, opts.rom, emu.RomInfo().Addr, opts.romPath, isLcovPath(opts.coverage)))
+		}
+	}, nil
+}
+
+// coverageReport formats the coverage of rom, loaded from romPath at addr, as an lcov tracefile
+// or annotated disassembly
+func coverageReport(prof *profiler.Profiler, rom []byte, addr uint16, romPath string, lcov bool) []byte {
+	r := coverage.New(rom, addr, prof)
+	var buf bytes.Buffer
+	if lcov {
+		_ = r.WriteLcov(&buf, romPath)
+	} else {
+		_ = r.WriteAnnotated(&buf)
+	}
+	return buf.Bytes()
+}
+
+// isLcovPath reports whether path has one of the extensions of lcov tracefiles
+func isLcovPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".info" || ext == ".lcov"
+}
+
+func saveReport(what, path string, report []byte) {
+	if err := ioutil.WriteFile(path, report, 0644); err != nil {
+		log.Printf("Saving %s failed: %v", strings.ToLower(what), err)
+		return
+	}
+	log.Printf("%s saved to: %v", what, path)
+}
@@ -0,0 +1,1273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/demo"
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/cheat"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/debugger"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/movie"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/netplay"
+	"github.com/dustinbowers/chip8emu/ui"
+	"github.com/dustinbowers/chip8emu/ui/sound"
+	"github.com/dustinbowers/chip8emu/ui/terminal"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	screenCols = 64
+	screenRows = 32
+)
+
+// Emulation speed modes, switched with hotkeys
+const (
+	speedNormal int32 = iota
+	speedTurbo        // As many frames as fit in each 60Hz tick
+	speedSlow         // One frame every slowMotionDivider ticks
+)
+
+const slowMotionDivider = 4
+
+const volumeStep = 0.1 // Change in volume for each press of [ or ]
+
+// While the machine has been idle for idleAfter frames the main loop only wakes up every idlePoll
+const (
+	idleAfter = chip8.FrameRate
+	idlePoll  = 50 * time.Millisecond
+)
+
+// keyReceiver is fed keypad input, either the emulator itself or a movie.Recorder wrapping it
+type keyReceiver interface {
+	KeyDown(key uint8)
+	KeyUp(key uint8)
+}
+
+// gatedKeys passes key presses on to *keys while live returns true, timing them with meter
+type gatedKeys struct {
+	keys  *keyReceiver
+	live  func() bool
+	meter *latencyMeter
+}
+
+func (g gatedKeys) KeyDown(key uint8) {
+	if g.live() {
+		g.meter.keyPressed()
+		(*g.keys).KeyDown(key)
+	}
+}
+
+func (g gatedKeys) KeyUp(key uint8) {
+	if g.live() {
+		(*g.keys).KeyUp(key)
+	}
+}
+
+// runOptions are the flags accepted by `chip8emu run`
+type runOptions struct {
+	romPath        string
+	rom            []byte   // Image of the loaded ROM
+	moreRoms       []romTab // ROMs after the first, to switch to with F10
+	demo           bool
+	romDir         string
+	screenshots    string
+	rplFlags       string
+	highScores     string
+	clips          string
+	clipFormat     string
+	platform       string
+	eti660         bool
+	ipf            int
+	quirks         string
+	backend        string
+	scale          int
+	integerScale   bool
+	fullscreen     bool
+	palette        string
+	fg, bg         string
+	ghosting       bool
+	filter         string
+	osd            bool
+	vsync          bool
+	pauseUnfocused bool
+	idleThrottle   bool
+	lowLatency     bool
+	disasm         bool
+	logLevel       string
+	logComponents  string
+	debug          bool
+	headless       bool
+	maxCycles      int
+	exitOnLoop     bool
+	compat         bool
+	unknown        string
+	record         string
+	playback       string
+	host           string
+	join           string
+	netDelay       int // Frames
+	api            string
+	spectate       string
+	script         string
+	cheats         string
+	symbols        string
+	profile        string
+	coverage       string
+	mute           bool
+	tone           float64
+	wave           string
+	volume         int // Percent
+	keyPreset      string
+	keys           map[string]string // CHIP-8 key -> SDL key name, from the config file
+	padButtons     map[string]string // CHIP-8 key -> SDL controller button name, from the config file
+	macros         map[string]string // SDL key name -> macro, from the config file
+	autofire       map[string]int    // CHIP-8 key -> autofire rate in frames, from the config file
+	explicit       map[string]bool   // Flags given on the command line
+}
+
+// lockstep reports whether the machine has to run exactly as a movie or the other netplay
+// player's does, which rules out rewinding, cheats, resets and the like
+func (o runOptions) lockstep() bool {
+	return o.record != "" || o.playback != "" || o.host != "" || o.join != ""
+}
+
+// runCommand implements `chip8emu run rom`
+func runCommand(args []string) error {
+	cfg, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+	opts := runOptions{keys: cfg.Keys, padButtons: cfg.Gamepad, macros: cfg.Macros, autofire: cfg.Autofire}
+	fs := newFlagSet("run", "rom...")
+	fs.String("config", defaultConfigPath(), "config file supplying the defaults for these flags")
+	fs.StringVar(&opts.platform, "platform", cfg.Platform, "machine to emulate, setting quirks, clock speed and font location: "+strings.Join(chip8.ProfileNames(), ", "))
+	fs.BoolVar(&opts.eti660, "eti660", false, "run an ETI-660 program loaded at 0x600, short for -platform eti660")
+	fs.IntVar(&opts.ipf, "ipf", cfg.IPF, "instructions executed per 60Hz frame (clock speed)")
+	fs.StringVar(&opts.quirks, "quirks", cfg.Quirks, "comma separated quirks to enable: "+strings.Join(chip8.QuirkNames(), ", "))
+	fs.StringVar(&opts.unknown, "unknown", cfg.Unknown, "what unknown opcodes do: "+strings.Join(chip8.UnknownOpcodePolicyNames(), ", "))
+	fs.StringVar(&opts.backend, "backend", cfg.Backend, "frontend to use: sdl or term")
+	fs.StringVar(&opts.romDir, "roms", cfg.ROMs, "directory listed by the ROM launcher when no ROM is given")
+	fs.StringVar(&opts.screenshots, "screenshots", cfg.Screenshots, "directory F12 saves screenshots to")
+	fs.StringVar(&opts.highScores, "high-scores", cfg.HighScores, "directory the high scores of ROMs whose cheat file has a @score line are kept in, empty to forget them on exit")
+	fs.StringVar(&opts.rplFlags, "rpl-flags", cfg.RPLFlags, "directory the SCHIP RPL user flags (Fx75) of each ROM are kept in, empty to forget them on exit")
+	fs.StringVar(&opts.clips, "clips", cfg.Clips, "directory F9 saves recorded clips to")
+	fs.StringVar(&opts.clipFormat, "clip-format", cfg.ClipFormat, "file type for clips: gif, or a video format ffmpeg can write such as mp4")
+	fs.StringVar(&opts.keyPreset, "keys", cfg.KeysPreset, "keyboard layout for the keypad: "+strings.Join(keyPresetNames(), ", "))
+	fs.IntVar(&opts.scale, "scale", cfg.Display.Scale, "initial window size as a multiple of 64x32")
+	fs.BoolVar(&opts.integerScale, "integer-scale", cfg.Display.IntegerScale, "only scale the display by whole multiples")
+	fs.BoolVar(&opts.fullscreen, "fullscreen", cfg.Display.Fullscreen, "start in fullscreen (F11 toggles)")
+	fs.StringVar(&opts.palette, "palette", cfg.Display.Palette, "color preset: "+strings.Join(ui.PresetNames(), ", "))
+	fs.StringVar(&opts.fg, "fg", cfg.Display.FG, "foreground color as hex, e.g. 00ff00 (overrides the palette)")
+	fs.StringVar(&opts.bg, "bg", cfg.Display.BG, "background color as hex, e.g. 001100 (overrides the palette)")
+	fs.BoolVar(&opts.ghosting, "ghosting", cfg.Display.Ghosting, "fade pixels out over a few frames (G toggles)")
+	fs.StringVar(&opts.filter, "filter", cfg.Display.Filter, "post-processing filter: none or crt")
+	fs.BoolVar(&opts.vsync, "vsync", cfg.Display.VSync, "sync drawing to the display's refresh rate")
+	fs.BoolVar(&opts.pauseUnfocused, "pause-unfocused", cfg.PauseUnfocused, "pause and mute while the window doesn't have the focus")
+	fs.BoolVar(&opts.idleThrottle, "idle-throttle", cfg.IdleThrottle, "poll for input and redraw less often while the machine sits idle, to save battery")
+	fs.BoolVar(&opts.lowLatency, "low-latency", cfg.Display.LowLatency, "run each frame right after reading input and draw it straight away")
+	fs.StringVar(&opts.logLevel, "log-level", cfg.LogLevel, "least important log messages shown: "+strings.Join(logLevelNames, ", "))
+	fs.StringVar(&opts.logComponents, "log", cfg.Log, "comma separated parts of the emulator that log: all, cpu, timer, ui, audio")
+	fs.BoolVar(&opts.disasm, "disasm", cfg.Display.Disasm, "open a disassembly pane beside the screen that follows PC (Ctrl+D toggles)")
+	fs.BoolVar(&opts.osd, "osd", cfg.Display.OSD, "show messages such as PAUSED on top of the screen")
+	fs.BoolVar(&opts.mute, "mute", cfg.Audio.Mute, "start with the sound muted (F8 toggles)")
+	fs.Float64Var(&opts.tone, "tone", cfg.Audio.Tone, "beeper pitch in Hz")
+	fs.StringVar(&opts.wave, "wave", cfg.Audio.Wave, "beeper waveform: "+strings.Join(sound.WaveformNames(), ", "))
+	fs.IntVar(&opts.volume, "volume", cfg.Audio.Volume, "beeper volume in percent")
+	fs.BoolVar(&opts.compat, "compat", true, "apply known settings for recognized ROMs (explicit -platform / -quirks / -ipf / -unknown still win)")
+	fs.BoolVar(&opts.demo, "demo", false, "run an embedded demo ROM, the optional argument names it: "+strings.Join(demo.Names(), ", "))
+	fs.BoolVar(&opts.debug, "debug", false, "start halted with a debugger prompt on stdin")
+	fs.BoolVar(&opts.headless, "headless", false, "run without a window, input or sound as fast as possible and print the final state, for tests in CI")
+	fs.IntVar(&opts.maxCycles, "max-cycles", 0, "with -headless, stop after this many instructions")
+	fs.BoolVar(&opts.exitOnLoop, "exit-on-loop", false, "with -headless, stop when the program finishes in an endless loop and fail (exit status 2) if it never does")
+	fs.StringVar(&opts.record, "record", "", "record keypad input to a movie file")
+	fs.StringVar(&opts.playback, "playback", "", "play back a movie file")
+	fs.StringVar(&opts.host, "host", "", "host a two-player netplay game on this address, e.g. :7000")
+	fs.StringVar(&opts.join, "join", "", "join the netplay game hosted at this address, e.g. example.com:7000")
+	fs.IntVar(&opts.netDelay, "net-delay", netplay.DefaultDelay, "frames of input delay when hosting netplay, more hides more lag")
+	fs.StringVar(&opts.api, "api", "", "serve the HTTP control API on this address, e.g. :8080 (on localhost unless a host is given, 0.0.0.0:8080 listens on every interface)")
+	fs.StringVar(&opts.spectate, "spectate", "", "let others watch in a browser at this address, e.g. :8081")
+	fs.StringVar(&opts.cheats, "cheats", "", "cheat file to apply (default: the ROM's path plus .cheats, when it exists)")
+	fs.StringVar(&opts.symbols, "symbols", "", "labels for debugging, as written by `asm -symbols` (default: the ROM's path with a .sym extension, when it exists)")
+	fs.StringVar(&opts.script, "script", "", "run a Lua script with hooks into the machine, see pkg/chip8/script")
+	fs.StringVar(&opts.profile, "profile", "", "count the instructions each subroutine runs and write the report to this file on exit")
+	fs.StringVar(&opts.coverage, "coverage", "", "write the ROM's instruction coverage to this file on exit, as lcov for .info or .lcov files and annotated disassembly otherwise")
+	pos, err := parseArgs(fs, args, 0, maxRomTabs)
+	if err != nil {
+		return err
+	}
+	opts.explicit = explicitFlags(fs)
+	if opts.eti660 {
+		opts.platform, opts.explicit["platform"] = "eti660", true
+	}
+	if opts.headless {
+		opts.backend = "headless"
+	}
+
+	logger, err := newStdLogger(opts.logLevel)
+	if err != nil {
+		return err
+	}
+	components, err := chip8.ParseLogComponents(opts.logComponents)
+	if err != nil {
+		return err
+	}
+
+	log.Print("Initializing emulator... ")
+	emu := chip8.NewChip8()
+	emu.SetLogger(logger, components)
+	ui.SetLogger(logger, components)
+	log.Println("Done")
+
+	if len(pos) > 1 {
+		if opts.demo || opts.lockstep() {
+			return fmt.Errorf("several ROMs can't be used with -demo, while recording, playing back or in netplay")
+		}
+		if opts.backend != "sdl" {
+			return fmt.Errorf("several ROMs need the sdl backend")
+		}
+	}
+	if len(pos) > 0 || opts.demo {
+		if len(pos) > 0 {
+			opts.romPath = pos[0]
+		}
+		if opts.rom, err = loadRom(emu, opts, opts.romPath); err != nil {
+			return err
+		}
+		switch {
+		case opts.demo:
+			opts.romPath = "demo.ch8" // Save states go to the working directory
+		case chip8.IsRomURL(opts.romPath):
+			opts.romPath = urlFileName(opts.romPath) // As do those of downloaded ROMs
+		}
+		// The other ROMs are read up front so a bad path fails now rather than on F10
+		for _, path := range pos[1:] {
+			rom, err := readRom(path, false)
+			if err != nil {
+				return fmt.Errorf("rom load failed: %v", err)
+			}
+			if chip8.IsRomURL(path) {
+				path = urlFileName(path)
+			}
+			opts.moreRoms = append(opts.moreRoms, romTab{path: path, rom: rom})
+		}
+	} else if opts.backend != "sdl" {
+		return fmt.Errorf("a ROM path is required with the %v backend", opts.backend)
+	}
+	if (opts.host != "" || opts.join != "") && opts.backend != "sdl" {
+		return fmt.Errorf("netplay needs the sdl backend")
+	}
+
+	// Games save high scores in the RPL user flags, but a movie, netplay or headless test has to start from the same ones
+	if opts.rplFlags != "" && !opts.lockstep() && !opts.headless {
+		emu.SetFlagStore(flagFiles{dir: expandHome(opts.rplFlags)})
+	}
+
+	// Keep the last few instructions around so a crash comes with some context
+	emu.SetTraceWriter(os.Stderr)
+	emu.SetTraceRing(32)
+
+	switch opts.backend {
+	case "sdl":
+		return runSDL(emu, opts)
+	case "term":
+		return runTerminal(emu, opts)
+	case "headless":
+		return runHeadless(emu, opts)
+	}
+	return fmt.Errorf("unknown backend: %v (expected sdl or term)", opts.backend)
+}
+
+// loadRom configures emu for the ROM at path (or the demo called path with -demo) and loads it,
+// replacing whatever was running
+func loadRom(emu *chip8.Chip8, opts runOptions, path string) ([]byte, error) {
+	rom, err := readRom(path, opts.demo)
+	if err != nil {
+		return nil, fmt.Errorf("rom load failed: %v", err)
+	}
+	log.Printf("Loading rom at: %v\n", path)
+	if err := loadRomBytes(emu, opts, rom); err != nil {
+		return nil, err
+	}
+	symPath := ""
+	if path == opts.romPath {
+		symPath = opts.symbols // Given for the ROM on the command line, not the ones loaded later
+	}
+	syms, err := loadSymbols(symPath, path)
+	if err != nil {
+		return nil, err
+	}
+	emu.SetSymbols(syms)
+	return rom, nil
+}
+
+// loadRomBytes configures emu for rom and loads it, replacing whatever was running
+func loadRomBytes(emu *chip8.Chip8, opts runOptions, rom []byte) error {
+	unknown, err := chip8.ParseUnknownOpcodePolicy(opts.unknown)
+	if err != nil {
+		return err
+	}
+	if err := configureMachine(emu, opts.platform, opts.quirks, opts.ipf, opts.explicit); err != nil {
+		return err
+	}
+	emu.SetUnknownOpcodePolicy(unknown)
+	if opts.compat {
+		applyCompat(emu, opts.explicit, rom)
+	}
+	emu.SetSymbols(nil) // They were for the previous ROM
+	info, err := emu.LoadRomBytes(rom)
+	if err != nil {
+		return err
+	}
+	log.Printf("Loaded %v", info)
+	return nil
+}
+
+// runSDL runs emu in an SDL window until it's closed or Esc is pressed
+func runSDL(emu *chip8.Chip8, opts runOptions) error {
+	palette, err := buildPalette(opts.palette, opts.fg, opts.bg)
+	if err != nil {
+		return err
+	}
+	filter, err := ui.ParseFilter(opts.filter)
+	if err != nil {
+		return err
+	}
+	if opts.scale < 1 {
+		return fmt.Errorf("invalid scale %d", opts.scale)
+	}
+	wave, err := sound.ParseWaveform(opts.wave)
+	if err != nil {
+		return err
+	}
+
+	if keyMap, err = newKeyBindings(opts.keyPreset, opts.keys); err != nil {
+		return err
+	}
+	macroKeys, err := newMacroBindings(opts.macros)
+	if err != nil {
+		return err
+	}
+	if pad, err = newGamepad(opts.padButtons); err != nil {
+		return err
+	}
+
+	ui.SetVSync(opts.vsync)
+	ui.Init(screenCols*opts.scale, screenRows*opts.scale, screenCols, screenRows)
+	defer ui.Cleanup()
+	defer pad.close()
+	// Cancelled on the way out, before the deferred calls above tear down SDL, to stop the
+	// goroutines that run the machine and serve it
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emu.SetAudioSink(ui.Window{})
+	synth := ui.Synth()
+	synth.SetWaveform(wave)
+	synth.SetFrequency(opts.tone)
+	synth.SetVolume(float64(opts.volume) / 100)
+	synth.SetMuted(opts.mute)
+	ui.SetIntegerScale(opts.integerScale)
+	ui.SetPalette(palette)
+	ui.SetGhosting(opts.ghosting)
+	ui.SetKeypadLabels(keyMap.labels())
+	ui.SetOSD(opts.osd)
+	if err := ui.SetFilter(filter); err != nil {
+		log.Printf("%v", err)
+	}
+	if opts.fullscreen {
+		if err := ui.SetFullscreen(true); err != nil {
+			log.Printf("%v", err)
+		}
+	}
+
+	if opts.romPath == "" {
+		path, err := chooseRom(expandHome(opts.romDir))
+		if err != nil {
+			return fmt.Errorf("rom launcher: %v", err)
+		}
+		if path == "" {
+			return nil
+		}
+		if opts.rom, err = loadRom(emu, opts, path); err != nil {
+			return err
+		}
+		opts.romPath = path
+	}
+	statePath := opts.romPath + ".state"
+
+	writeReports, err := startProfiling(emu, &opts)
+	if err != nil {
+		return err
+	}
+	defer writeReports()
+
+	osd := &scriptOSD{}
+	if opts.script != "" {
+		if err := loadScript(emu, opts.script, osd); err != nil {
+			return err
+		}
+	}
+
+	running := true
+	var rewinding int32 // Set while the rewind key is held, read by the emulation goroutine
+	var speed int32     // One of the speed* modes, read by the emulation goroutine
+	showSpeed := func() {
+		status := fmt.Sprintf("%d Hz", emu.ClockSpeed())
+		switch atomic.LoadInt32(&speed) {
+		case speedTurbo:
+			status += " (turbo)"
+		case speedSlow:
+			status += " (slow motion)"
+		}
+		ui.SetStatus(status)
+	}
+	showSpeed()
+	session, err := startNetplay(emu, opts)
+	if err != nil {
+		return err
+	}
+	var keypad keyReceiver = emu
+	runFrame := emu.RunFrame
+	var player *movie.Player
+	var dbg *debugger.Debugger
+	// Live input is ignored until a movie being played back finishes
+	latency := &latencyMeter{}
+	liveKeys := gatedKeys{keys: &keypad, live: func() bool { return player == nil || player.Done() }, meter: latency}
+	switch {
+	case opts.record != "":
+		recorder := movie.NewRecorder(emu, opts.rom, time.Now().UnixNano())
+		keypad = recorder
+		runFrame = recorder.RunFrame
+		log.Printf("Recording input to: %v", opts.record)
+		defer func() {
+			if err := recorder.Movie().Save(opts.record); err != nil {
+				log.Printf("Saving recording failed: %v", err)
+				return
+			}
+			log.Printf("Recording saved to: %v", opts.record)
+		}()
+	case opts.playback != "":
+		m, err := movie.Load(opts.playback)
+		if err != nil {
+			return fmt.Errorf("playback failed: %v", err)
+		}
+		if player, err = movie.NewPlayer(emu, opts.rom, m); err != nil {
+			return fmt.Errorf("playback failed: %v", err)
+		}
+		runFrame = player.RunFrame
+		log.Printf("Playing back: %v (%d frames)", opts.playback, m.Frames)
+	case session != nil:
+		defer session.Close()
+		keypad = session
+		runFrame = session.RunFrame
+		if session.Host() {
+			notify("Player 2 joined")
+		} else {
+			notify("Joined as player 2")
+		}
+	case opts.debug:
+		dbg = debugger.New(emu, os.Stdout)
+		go dbg.RunREPL(os.Stdin)
+		runFrame = dbg.RunFrame
+	}
+	// Macros and autofire press keys on the player's behalf, so they go wherever live input goes
+	macros, err := newMacroPlayer(keypad, opts.autofire)
+	if err != nil {
+		return err
+	}
+	keypad = macros
+	nextFrame := runFrame
+	runFrame = func() error {
+		macros.Frame()
+		return nextFrame()
+	}
+	// The ROM's cheats, replaced when the ROM changes and applied before every frame
+	var cheats atomic.Value
+	cheatList, err := loadCheats(opts.cheats, opts.romPath)
+	if err != nil {
+		return err
+	}
+	cheats.Store(cheatList)
+	cheatsShown := false
+	// The cheat file can also say where the score is, for the high score shown on the HUD
+	hiScore := newHighScore(emu, cheatList, expandHome(opts.highScores))
+	defer func() {
+		if hiScore != nil {
+			hiScore.save()
+		}
+	}()
+	memView := debugger.NewMemoryView(emu, memViewRows)
+	if opts.disasm {
+		showDisassembly(emu)
+	}
+	if !opts.lockstep() {
+		// Rewinding and cheats would desync a recording or netplay, so they're only available during normal play
+		emu.SetRewindBuffer(10 * chip8.FrameRate)
+		inner := runFrame
+		runFrame = func() error {
+			if l := cheats.Load().(*cheat.List); l != nil {
+				l.Apply(emu)
+			}
+			return inner()
+		}
+	} else if cheatList != nil {
+		log.Printf("Cheats are off while recording, playing back or in netplay")
+		cheats.Store((*cheat.List)(nil))
+	}
+
+	// A ROM that crashes stops the emulation goroutine until it's reset, the main loop shows why
+	crashes := make(chan error, 1)
+	var crashed int32
+	var crash error
+	// An unknown opcode under -unknown halt pauses the machine instead, with the same report on screen
+	var halted error
+	// A program that finished in an endless loop is paused rather than left spinning (P resumes it)
+	var finished bool
+
+	// dismissCrash hides the crash screen and lets the emulation goroutine run again
+	dismissCrash := func() {
+		if crash != nil {
+			crash = nil
+			ui.HideCrash()
+			atomic.StoreInt32(&crashed, 0)
+		}
+	}
+
+	// switchRom replaces the running ROM with one dropped onto the window or sent to the API
+	tabs := &romTabs{}
+	if opts.rom != nil {
+		tabs.loaded(opts.romPath, opts.rom)
+		tabs.tabs = append(tabs.tabs, opts.moreRoms...)
+	}
+	switchRom := func(load romLoad) error {
+		if opts.lockstep() {
+			return fmt.Errorf("can't load a new ROM while recording, playing back or in netplay")
+		}
+		opts.demo = false
+		if load.rom == nil {
+			rom, err := loadRom(emu, opts, load.path)
+			if err != nil {
+				return err
+			}
+			load.rom = rom
+		} else if err := loadRomBytes(emu, opts, load.rom); err != nil {
+			return err
+		} else if syms, err := loadSymbols("", load.path); err != nil {
+			log.Printf("%v", err)
+		} else {
+			emu.SetSymbols(syms)
+		}
+		opts.rom, opts.romPath = load.rom, load.path
+		statePath = load.path + ".state"
+		tabs.loaded(load.path, load.rom)
+		cheatList, err := loadCheats("", load.path)
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		cheats.Store(cheatList)
+		if hiScore != nil {
+			hiScore.save()
+		}
+		hiScore, osd.score = newHighScore(emu, cheatList, expandHome(opts.highScores)), ""
+		if cheatsShown {
+			cheatsShown = false
+			ui.SetPanel("")
+		}
+		dismissCrash()
+		emu.SetRewindBuffer(10 * chip8.FrameRate) // Don't rewind into the previous ROM
+		showSpeed()
+		return nil
+	}
+	// switchTab leaves the current ROM for tab i, which picks up where it was left
+	switchTab := func(i int) error {
+		state, err := emu.SaveState()
+		if err != nil {
+			return err
+		}
+		leaving, tab := tabs.current, tabs.tabs[i]
+		tabs.current = i
+		if err := switchRom(romLoad{path: tab.path, rom: tab.rom}); err != nil {
+			tabs.current = leaving
+			return err
+		}
+		tabs.tabs[leaving].state = state
+		if tab.state != nil {
+			if err := emu.LoadState(tab.state); err != nil {
+				return err
+			}
+		}
+		notify("%s", tabs.label(i))
+		return nil
+	}
+	var loads chan romLoad
+	if opts.api != "" {
+		loads = make(chan romLoad)
+		if err := startAPI(ctx, emu, opts, keypad, loads); err != nil {
+			return err
+		}
+	}
+	if opts.spectate != "" {
+		if err := startSpectator(ctx, emu, opts.spectate); err != nil {
+			return err
+		}
+	}
+
+	clip := &clipRecorder{}
+	defer clip.stop()
+
+	// The emulation goroutine publishes each completed frame, the main loop draws the latest
+	frameOut := make(chan chip8.Frame, 1)
+	emu.SetFrameChannel(frameOut)
+	defer emu.SetFrameChannel(nil)
+	frame := chip8.Frame{Screen: emu.PeekScreen()}
+	ui.Draw(frame.Screen)
+
+	// runTick runs the machine for one tick of the frame rate
+	runTick := func(tick int) {
+		if atomic.LoadInt32(&rewinding) == 1 {
+			_ = emu.Rewind(1)
+			clip.addFrame(emu.PeekScreen())
+			return
+		}
+		if atomic.LoadInt32(&crashed) == 1 {
+			return
+		}
+		frames := 1
+		switch atomic.LoadInt32(&speed) {
+		case speedSlow:
+			if tick%slowMotionDivider != 0 {
+				frames = 0
+			}
+		case speedTurbo:
+			frames = -1 // Until the tick's time budget runs out
+		}
+		start := time.Now()
+		for i := 0; i != frames; i++ {
+			if err := runFrame(); err != nil {
+				atomic.StoreInt32(&crashed, 1)
+				crashes <- err
+				break
+			}
+			latency.frameRan(start)
+			if frames < 0 && time.Since(start) > time.Second/chip8.FrameRate*3/4 {
+				break
+			}
+		}
+		clip.addFrame(emu.PeekScreen())
+	}
+
+	var emuDone sync.WaitGroup
+	// In low latency mode the main loop hands out the ticks, right after reading input, and
+	// waits for ticked to draw the frame straight away
+	ticks := make(chan time.Time, 1)
+	ticked := make(chan struct{}, 1)
+	emuDone.Add(1)
+	go func() {
+		defer emuDone.Done()
+		log.Println("Starting... ")
+		tickC := (<-chan time.Time)(ticks)
+		if !opts.lowLatency {
+			ticker := time.NewTicker(time.Second / chip8.FrameRate)
+			defer ticker.Stop()
+			tickC = ticker.C
+		}
+		for tick := 0; ; tick++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tickC:
+			}
+			runTick(tick)
+			if opts.lowLatency {
+				ticked <- struct{}{}
+			}
+		}
+	}()
+	// The emulation goroutine has to be gone before the window and the audio device are, so the
+	// frame it's in the middle of is let go: a netplay frame waiting on the other player, a frame
+	// halted in the debugger or one blocked while paused
+	defer func() {
+		cancel()
+		if session != nil {
+			session.Close()
+		}
+		if dbg != nil {
+			dbg.Detach()
+		}
+		emu.Resume()
+		emuDone.Wait()
+		emu.SetAudioSink(nil)
+	}()
+
+	showInfo := false
+	pacer := newFramePacer(time.Second / chip8.FrameRate)
+	if !ui.VSync() && ui.RefreshRate() > 0 {
+		pacer = newFramePacer(time.Second / time.Duration(ui.RefreshRate()))
+	}
+	var lastDraw time.Time
+	fpsFrames, fpsTime := emu.Frames(), time.Now()
+	// pollEvents handles the window, keyboard and controller events waiting in the queue
+	// Losing the focus pauses and mutes, getting it back undoes whichever of those it did
+	focusPaused, focusMuted := false, false
+	pollEvents := func() {
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			if w, ok := event.(*sdl.WindowEvent); ok && opts.pauseUnfocused {
+				switch w.Event {
+				case sdl.WINDOWEVENT_FOCUS_LOST:
+					// Netplay can't pause, the other player would be left waiting
+					if session == nil && !emu.Paused() {
+						emu.Pause()
+						focusPaused = true
+					}
+					if !synth.Muted() {
+						synth.SetMuted(true)
+						focusMuted = true
+					}
+				case sdl.WINDOWEVENT_FOCUS_GAINED:
+					if focusPaused && emu.Paused() {
+						emu.Resume()
+					}
+					if focusMuted && synth.Muted() {
+						synth.SetMuted(false)
+					}
+					focusPaused, focusMuted = false, false
+				}
+			}
+			if ui.HandleEvent(event) || pad.handleEvent(event, liveKeys) {
+				continue
+			}
+			switch t := event.(type) {
+			case *sdl.DropEvent:
+				if t.Type != sdl.DROPFILE {
+					continue
+				}
+				if err := switchRom(romLoad{path: t.File}); err != nil {
+					log.Printf("%v", err)
+				}
+			case *sdl.QuitEvent:
+				println("Quit")
+				running = false
+			case *sdl.KeyboardEvent:
+				if t.Keysym.Sym == sdl.K_ESCAPE {
+					running = false
+				}
+				if t.Keysym.Sym == sdl.K_F3 && t.Type == sdl.KEYDOWN {
+					if ui.MemoryViewShown() {
+						ui.HideMemoryView()
+					} else {
+						ui.HideSprites() // The debug views cover the whole screen, one at a time
+						showMemoryView(memView)
+					}
+				}
+				if t.Keysym.Sym == sdl.K_F4 && t.Type == sdl.KEYDOWN {
+					if ui.SpritesShown() {
+						ui.HideSprites()
+					} else {
+						ui.HideMemoryView()
+						ui.ShowSprites(debugger.Sprites(emu))
+					}
+				}
+				if t.Keysym.Sym == sdl.K_d && t.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+					if t.Type == sdl.KEYDOWN {
+						if ui.DisassemblyShown() {
+							ui.HideDisassembly()
+						} else {
+							showDisassembly(emu)
+						}
+					}
+					continue // Don't also press the keypad key on D
+				}
+				if ui.MemoryViewShown() && t.Type == sdl.KEYDOWN && memoryViewKey(memView, t.Keysym.Sym) {
+					continue
+				}
+				// Keys bound to the keypad only press it, so layouts like Dvorak, where the positional
+				// preset's R and S read P and O, don't pause the game as well. With Ctrl or Alt held
+				// they're hotkeys instead.
+				_, bound := keyMap.lookup(t.Keysym)
+				hotkey := !bound || t.Keysym.Mod&(sdl.KMOD_CTRL|sdl.KMOD_ALT) != 0
+
+				if hotkey && t.Keysym.Sym == sdl.K_p {
+					if session != nil {
+						notify("Can't pause in netplay")
+					} else if !emu.Paused() {
+						emu.Pause()
+						log.Printf("-Paused-")
+					}
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_o {
+					if emu.Paused() {
+						emu.Resume()
+						log.Printf("Resuming")
+					}
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_n && t.Type == sdl.KEYDOWN && emu.Paused() {
+					// Frame advance, or a single instruction with shift held
+					if opts.lockstep() {
+						log.Printf("Frame stepping isn't available while recording, playing back or in netplay")
+					} else if t.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+						if err := emu.AdvanceInstruction(); err != nil {
+							log.Printf("Step failed: %v", err)
+						}
+					} else if err := emu.AdvanceFrame(); err != nil {
+						log.Printf("Step failed: %v", err)
+					}
+				}
+				if t.Keysym.Sym == sdl.K_r && t.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+					if t.Type != sdl.KEYDOWN {
+						continue
+					}
+					if opts.lockstep() {
+						log.Printf("Can't reset while recording, playing back or in netplay")
+						continue
+					}
+					emu.Reset()
+					dismissCrash()
+					notify("Reset")
+					continue // Don't also press keypad D
+				}
+				if crash != nil && t.Type == sdl.KEYDOWN && (t.Keysym.Sym == sdl.K_r || t.Keysym.Sym == sdl.K_d) {
+					if t.Keysym.Sym == sdl.K_d {
+						if path, err := saveCrashReport(emu, crash, opts.romPath); err != nil {
+							notify("%v", err)
+						} else {
+							log.Printf("Crash report saved to: %v", path)
+							ui.Notify("Crash report saved")
+						}
+					} else if opts.lockstep() {
+						log.Printf("Can't reset while recording, playing back or in netplay")
+					} else {
+						emu.Reset()
+						dismissCrash()
+						notify("Reset")
+					}
+					continue // The keypad is dead while crashed anyway
+				}
+				if halted != nil && crash == nil && t.Type == sdl.KEYDOWN && t.Keysym.Sym == sdl.K_d {
+					if path, err := saveCrashReport(emu, halted, opts.romPath); err != nil {
+						notify("%v", err)
+					} else {
+						log.Printf("Crash report saved to: %v", path)
+						ui.Notify("Crash report saved")
+					}
+					continue // The machine is paused, the keypad can wait
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_i {
+					// inspect emulator state
+					if dbg != nil {
+						log.Printf("Emulator state:\n%s", dbg.Inspect())
+					} else {
+						log.Printf("Emulator state:\n%s", emu.Inspect())
+					}
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_BACKSPACE {
+					if t.Type == sdl.KEYDOWN && opts.lockstep() {
+						if t.Repeat == 0 {
+							log.Printf("Can't rewind while recording, playing back or in netplay")
+						}
+					} else if t.Type == sdl.KEYDOWN {
+						dismissCrash()
+						atomic.StoreInt32(&rewinding, 1)
+					} else {
+						atomic.StoreInt32(&rewinding, 0)
+					}
+				}
+				if hotkey && (t.Keysym.Sym == sdl.K_EQUALS || t.Keysym.Sym == sdl.K_KP_PLUS || t.Keysym.Sym == sdl.K_MINUS || t.Keysym.Sym == sdl.K_KP_MINUS) && t.Type == sdl.KEYDOWN {
+					if opts.lockstep() {
+						log.Printf("The clock speed can't change while recording, playing back or in netplay")
+					} else {
+						step := chip8.FrameRate
+						if t.Keysym.Sym == sdl.K_MINUS || t.Keysym.Sym == sdl.K_KP_MINUS {
+							step = -step
+						}
+						emu.SetClockSpeed(emu.ClockSpeed() + step)
+						showSpeed()
+						ui.Notify(fmt.Sprintf("%d Hz", emu.ClockSpeed()))
+					}
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_TAB {
+					if t.Type == sdl.KEYDOWN {
+						atomic.StoreInt32(&speed, speedTurbo)
+					} else {
+						atomic.StoreInt32(&speed, speedNormal)
+					}
+					showSpeed()
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_m && t.Type == sdl.KEYDOWN {
+					if atomic.LoadInt32(&speed) == speedSlow {
+						atomic.StoreInt32(&speed, speedNormal)
+						ui.Notify("Normal speed")
+					} else {
+						atomic.StoreInt32(&speed, speedSlow)
+						ui.Notify("Slow motion")
+					}
+					showSpeed()
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_g && t.Type == sdl.KEYDOWN {
+					ui.SetGhosting(!ui.Ghosting())
+					notify("Ghosting: %v", onOff(ui.Ghosting()))
+				}
+				if hotkey && (t.Keysym.Sym == sdl.K_LEFTBRACKET || t.Keysym.Sym == sdl.K_RIGHTBRACKET) && t.Type == sdl.KEYDOWN {
+					step := volumeStep
+					if t.Keysym.Sym == sdl.K_LEFTBRACKET {
+						step = -step
+					}
+					synth.SetVolume(synth.Volume() + step)
+					notify("Volume: %.0f%%", synth.Volume()*100)
+				}
+				if hotkey && t.Keysym.Sym == sdl.K_b && t.Type == sdl.KEYDOWN {
+					synth.SetWaveform((synth.Waveform() + 1) % sound.Waveform(len(sound.WaveformNames())))
+					notify("Waveform: %v", synth.Waveform())
+				}
+				if t.Keysym.Sym == sdl.K_F8 && t.Type == sdl.KEYDOWN {
+					synth.SetMuted(!synth.Muted())
+					notify("Muted: %v", onOff(synth.Muted()))
+				}
+				if t.Keysym.Sym == sdl.K_F1 && t.Type == sdl.KEYDOWN {
+					ui.ShowKeypad(!ui.KeypadShown())
+				}
+				if t.Keysym.Sym == sdl.K_F2 && t.Type == sdl.KEYDOWN {
+					showInfo = !showInfo
+					fpsFrames, fpsTime = emu.Frames(), time.Now()
+					if showInfo {
+						ui.SetInfo(fmt.Sprintf("%d HZ", emu.ClockSpeed()))
+					} else {
+						ui.SetInfo("")
+					}
+				}
+				if t.Keysym.Sym == sdl.K_F11 && t.Type == sdl.KEYDOWN {
+					if err := ui.ToggleFullscreen(); err != nil {
+						log.Printf("%v", err)
+					}
+				}
+				if t.Keysym.Sym == sdl.K_F12 && t.Type == sdl.KEYDOWN {
+					path, err := ui.SaveScreenshot(expandHome(opts.screenshots), romName(opts.romPath), emu.PeekScreen(), opts.scale)
+					if err != nil {
+						log.Printf("%v", err)
+					} else {
+						log.Printf("Screenshot saved to: %v", path)
+						ui.Notify("Screenshot saved")
+					}
+				}
+				if t.Keysym.Sym == sdl.K_F9 && t.Type == sdl.KEYDOWN {
+					if clip.recording() {
+						clip.stop()
+						ui.Notify("Clip saved")
+					} else {
+						path := capturePath(opts.clips, opts.romPath, opts.clipFormat)
+						if err := clip.start(path, ui.CurrentPalette().Colors(), opts.scale); err != nil {
+							notify("%v", err)
+						} else {
+							ui.Notify("Recording clip")
+						}
+					}
+				}
+				if t.Keysym.Sym == sdl.K_F6 && t.Type == sdl.KEYDOWN {
+					l := cheats.Load().(*cheat.List)
+					switch {
+					case cheatsShown:
+						cheatsShown = false
+						ui.SetPanel("")
+					case l == nil || l.Len() == 0:
+						ui.Notify("No cheats")
+					default:
+						cheatsShown = true
+						ui.SetPanel(cheatPanel(l))
+					}
+				}
+				// While the cheat panel is up, the number keys toggle cheats instead of pressing keys
+				if cheatsShown && t.Type == sdl.KEYDOWN && t.Keysym.Sym >= sdl.K_1 && t.Keysym.Sym < sdl.K_1+maxCheatKeys {
+					l := cheats.Load().(*cheat.List)
+					if i := int(t.Keysym.Sym - sdl.K_1); i < l.Len() {
+						l.Toggle(i)
+						ui.SetPanel(cheatPanel(l))
+					}
+					continue
+				}
+				if t.Keysym.Sym == sdl.K_F10 && t.Type == sdl.KEYDOWN && len(tabs.tabs) > 1 {
+					delta := 1
+					if t.Keysym.Mod&sdl.KMOD_SHIFT != 0 {
+						delta = -1
+					}
+					if err := switchTab(tabs.next(delta)); err != nil {
+						notify("Switching ROMs failed: %v", err)
+					}
+				}
+				if t.Keysym.Sym == sdl.K_F5 && t.Type == sdl.KEYDOWN {
+					saveState(emu, statePath)
+				}
+				if t.Keysym.Sym == sdl.K_F7 && t.Type == sdl.KEYDOWN {
+					if opts.lockstep() {
+						notify("Can't load a state while recording, playing back or in netplay")
+					} else if loadState(emu, statePath) {
+						dismissCrash()
+					}
+				}
+
+				// Send controller inputs if we have any
+				keyEventType := event.GetType()
+				if m, ok := macroKeys.lookup(t.Keysym); ok {
+					if keyEventType == sdl.KEYDOWN && t.Repeat == 0 && liveKeys.live() {
+						macros.Play(m)
+					}
+					continue
+				}
+				k, ok := keyMap.lookup(t.Keysym)
+				if !ok || hotkey && keyEventType == sdl.KEYDOWN {
+					continue // Not bound, or pressed with Ctrl or Alt for a hotkey. Releases always go through
+				}
+				if keyEventType == sdl.KEYDOWN && t.Repeat == 0 {
+					liveKeys.KeyDown(k)
+				} else if keyEventType == sdl.KEYUP {
+					liveKeys.KeyUp(k)
+				}
+			}
+		}
+	}
+	clock := &frameClock{period: time.Second / chip8.FrameRate}
+	pollTicker := time.NewTicker(time.Millisecond)
+	defer pollTicker.Stop()
+	tickPending := false
+	for running {
+		if opts.lowLatency {
+			// Read input right before the frame runs, then keep reading it while the frame runs
+			// so that presses land mid-frame. A frame blocked by pausing finishes after Resume.
+			pollEvents()
+			if !tickPending && running && !emu.Paused() && clock.due() {
+				ticks <- time.Now()
+				tickPending = true
+			}
+			for tickPending && running && !emu.Paused() {
+				select {
+				case <-ticked:
+					tickPending = false
+				case <-pollTicker.C:
+					pollEvents()
+				}
+			}
+		}
+		select {
+		case load := <-loads:
+			load.done <- switchRom(load)
+		case crash = <-crashes:
+			log.Printf("Crashed: %v\n%s", crash, strings.Join(emu.PostMortem(crash), "\n"))
+			ui.ShowCrash(append(emu.PostMortem(crash), "", crashHelp))
+		default:
+		}
+		if reason := emu.HaltReason(); reason != halted {
+			halted = reason
+			if halted != nil {
+				log.Printf("Halted: %v\n%s", halted, strings.Join(emu.PostMortem(halted), "\n"))
+				ui.ShowCrash(append(emu.PostMortem(halted), "", haltHelp))
+			} else if crash == nil {
+				ui.HideCrash()
+			}
+		}
+		if _, fin := emu.Finished(); fin != finished {
+			finished = fin
+			if finished && !opts.lockstep() && !emu.Paused() {
+				emu.Pause()
+				notify("Program finished")
+			}
+		}
+		if hiScore != nil {
+			osd.score = hiScore.update(emu)
+		}
+		osd.apply()
+		if ui.MemoryViewShown() {
+			showMemoryView(memView)
+		}
+		if ui.SpritesShown() {
+			ui.ShowSprites(debugger.Sprites(emu))
+		}
+		if ui.DisassemblyShown() {
+			showDisassembly(emu)
+		}
+		if emu.Paused() {
+			ui.SetBanner("PAUSED")
+		} else {
+			ui.SetBanner("")
+		}
+		if elapsed := time.Since(fpsTime); elapsed >= time.Second {
+			frames := emu.Frames()
+			fps := float64(frames-fpsFrames) / elapsed.Seconds()
+			fpsFrames, fpsTime = frames, time.Now()
+			if showInfo {
+				ui.SetInfo(fmt.Sprintf("%.0f FPS %d HZ %v", fps, emu.ClockSpeed(), latency))
+			}
+		}
+		keysChanged := ui.SetPressedKeys(emu.PressedKeys())
+		// Ghosting fades a step per Draw, so keep that to the emulator's frame rate even when
+		// the display refreshes faster
+		ghostDue := ui.Fading() && time.Since(lastDraw) >= time.Second/chip8.FrameRate
+		frameStart := time.Now()
+		changed := false
+		select {
+		case frame = <-frameOut:
+			changed = true
+		default:
+			frame.Dirty = 0
+		}
+		// A machine that's been idle for a while, e.g. on a static title screen, doesn't need
+		// the display's refresh rate. The first key press wakes it up.
+		idle := opts.idleThrottle && !opts.lowLatency && !emu.Paused() && emu.IdleFrames() >= idleAfter &&
+			!changed && !ghostDue && !keysChanged && !ui.OSDAnimating()
+		if changed || ghostDue {
+			ui.DrawDirty(frame.Screen, frame.Dirty)
+			lastDraw = frameStart
+		} else if !idle && (keysChanged || ui.OSDAnimating() || ui.VSync()) {
+			// With VSync, presenting every refresh is what paces the loop
+			ui.Refresh()
+		}
+		latency.presented()
+		if !opts.lowLatency {
+			pollEvents()
+		}
+		// Present returns straight away when there's nothing to sync to, e.g. while the
+		// window is minimized, so fall back to the pacer then
+		if idle {
+			time.Sleep(idlePoll)
+		} else if !ui.VSync() || time.Since(frameStart) < time.Millisecond {
+			pacer.wait()
+		}
+	}
+	if avg, n := latency.average(); n > 0 {
+		log.Printf("Input latency: %v on average over %d key presses", avg.Round(time.Millisecond/10), n)
+	}
+	return nil
+}
+
+// buildPalette looks up a preset palette by name and applies any -fg / -bg overrides
+func buildPalette(name, fg, bg string) (ui.Palette, error) {
+	p, ok := ui.Presets[name]
+	if !ok {
+		return p, fmt.Errorf("unknown palette %q (expected one of: %v)", name, strings.Join(ui.PresetNames(), ", "))
+	}
+	for i, hex := range []string{bg, fg} {
+		if hex == "" {
+			continue
+		}
+		c, err := ui.ParseColor(hex)
+		if err != nil {
+			return p, err
+		}
+		p[i] = c
+	}
+	return p, nil
+}
+
+// runTerminal runs emu in the terminal until Esc or Ctrl-C is pressed
+func runTerminal(emu *chip8.Chip8, opts runOptions) error {
+	term, err := terminal.Open()
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+
+	if opts.script != "" {
+		if err := loadScript(emu, opts.script, nil); err != nil {
+			return err
+		}
+	}
+	cheats, err := loadCheats(opts.cheats, opts.romPath)
+	if err != nil {
+		return err
+	}
+	writeReports, err := startProfiling(emu, &opts)
+	if err != nil {
+		return err
+	}
+	defer writeReports()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if opts.api != "" {
+		if err := startAPI(ctx, emu, opts, emu, nil); err != nil {
+			return err
+		}
+	}
+	if opts.spectate != "" {
+		if err := startSpectator(ctx, emu, opts.spectate); err != nil {
+			return err
+		}
+	}
+
+	emu.SetDisplay(term)
+	emu.SetKeyProvider(term)
+	if !opts.mute {
+		emu.SetAudioSink(term)
+	}
+
+	go func() {
+		select {
+		case <-term.Quit():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	emu.SetRunCallbacks(chip8.RunCallbacks{
+		Tick: func() {
+			if cheats != nil {
+				cheats.Apply(emu)
+			}
+		},
+		Finished: func(pc uint16) { emu.Pause() }, // Leave the last screen up without spinning, Esc quits
+	})
+	err = emu.Run(ctx, chip8.FrameRate)
+	switch {
+	case ctx.Err() != nil:
+		return nil
+	case emu.HaltReason() != nil:
+		return fmt.Errorf("halted: %v\n%s", err, strings.Join(emu.PostMortem(err), "\n"))
+	}
+	return fmt.Errorf("emu.Run: %v\n%s", err, strings.Join(emu.PostMortem(err), "\n"))
+}
+
+func saveState(emu *chip8.Chip8, path string) {
+	state, err := emu.SaveState()
+	if err != nil {
+		notify("Save state failed: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(path, state, 0644); err != nil {
+		notify("Save state failed: %v", err)
+		return
+	}
+	log.Printf("State saved to: %v", path)
+	ui.Notify("State saved")
+}
+
+// loadState restores the state saved at path, reporting whether it worked
+func loadState(emu *chip8.Chip8, path string) bool {
+	state, err := ioutil.ReadFile(path)
+	if err != nil {
+		notify("Load state failed: %v", err)
+		return false
+	}
+	if err := emu.LoadState(state); err != nil {
+		notify("Load state failed: %v", err)
+		return false
+	}
+	log.Printf("State loaded from: %v", path)
+	ui.Notify("State loaded")
+	return true
+}
+
+// notify logs a message and shows it on screen
+func notify(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+	ui.Notify(msg)
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
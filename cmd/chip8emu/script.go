@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/script"
+	"github.com/dustinbowers/chip8emu/ui"
+)
+
+// scriptOSD passes a script's on-screen text to the ui. Hooks run on the emulation goroutine,
+// so the text waits here until the main loop calls apply.
+type scriptOSD struct {
+	mu      sync.Mutex
+	hud     *string
+	notices []string
+
+	// Only used by the main loop
+	scriptHUD string
+	score     string // The high score, shown under the script's HUD
+}
+
+func (o *scriptOSD) SetHUD(text string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.hud = &text
+}
+
+func (o *scriptOSD) Notify(text string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.notices = append(o.notices, text)
+}
+
+// apply shows the text set since the last call
+func (o *scriptOSD) apply() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.hud != nil {
+		o.scriptHUD = *o.hud
+		o.hud = nil
+	}
+	hud := o.scriptHUD
+	if o.score != "" && hud != "" {
+		hud += "\n"
+	}
+	hud += o.score
+	ui.SetHUD(hud)
+	for _, n := range o.notices {
+		ui.Notify(n)
+	}
+	o.notices = nil
+}
+
+// loadScript runs the -script file on emu, printing to the log. osd may be nil.
+func loadScript(emu *chip8.Chip8, path string, osd script.OSD) error {
+	if _, err := script.LoadFile(emu, path, log.Writer(), osd); err != nil {
+		return err
+	}
+	log.Printf("Running script: %v", path)
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+// symbolPath is where `asm -symbols` writes the labels of a ROM, and where they're looked for
+func symbolPath(romPath string) string {
+	return strings.TrimSuffix(romPath, filepath.Ext(romPath)) + ".sym"
+}
+
+// loadSymbols reads the -symbols file, or the ROM's .sym file next to it when there is one.
+// It returns nil when there are no symbols.
+func loadSymbols(path, romPath string) (*symbols.Table, error) {
+	if path == "" {
+		if romPath == "" {
+			return nil, nil
+		}
+		path = symbolPath(romPath)
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil
+		}
+	}
+	t, err := symbols.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Loaded %d symbols from: %v", t.Len(), path)
+	return t, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// maxRomTabs limits how many ROMs `chip8emu run` takes at once
+const maxRomTabs = 16
+
+// romTab is one of the ROMs of a session, which F10 switches between like tabs
+type romTab struct {
+	path  string // Where save states and the like go, see runOptions.romPath
+	rom   []byte
+	state []byte // The machine as it was when the tab was left, nil when it wasn't yet
+}
+
+// romTabs are the ROMs of a session, the current one is running
+type romTabs struct {
+	tabs    []romTab
+	current int
+}
+
+// loaded makes the ROM that was just loaded the current tab's, or the first tab when there's none
+func (t *romTabs) loaded(path string, rom []byte) {
+	if len(t.tabs) == 0 {
+		t.tabs = append(t.tabs, romTab{})
+	}
+	t.tabs[t.current] = romTab{path: path, rom: rom}
+}
+
+// next returns the index of the tab delta tabs away from the current one, wrapping around
+func (t *romTabs) next(delta int) int {
+	n := len(t.tabs)
+	return ((t.current+delta)%n + n) % n
+}
+
+// label describes tab i for the on-screen message shown when switching to it
+func (t *romTabs) label(i int) string {
+	return fmt.Sprintf("ROM %d/%d: %s", i+1, len(t.tabs), filepath.Base(t.tabs[i].path))
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/demo"
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/analyze"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/asm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/compat"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/octo"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/profiler"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/testsuite"
+)
+
+// disasmCommand implements `chip8emu disasm rom`
+func disasmCommand(args []string) error {
+	fs := newFlagSet("disasm", "rom")
+	origin := fs.Uint("origin", 0x200, "address the ROM is loaded at")
+	symPath := fs.String("symbols", "", "symbol file naming addresses (default: the ROM's path with a .sym extension, when it exists)")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rom, err := ioutil.ReadFile(pos[0])
+	if err != nil {
+		return err
+	}
+	syms, err := loadSymbols(*symPath, pos[0])
+	if err != nil {
+		return err
+	}
+	fmt.Print(disasm.ListingSymbols(rom, uint16(*origin), syms))
+	return nil
+}
+
+// asmCommand implements `chip8emu asm input.s [-o output.ch8]`
+func asmCommand(args []string) error {
+	fs := newFlagSet("asm", "input.s")
+	outPath := fs.String("o", "", "output path (defaults to the input path with a .ch8 extension)")
+	useOcto := fs.Bool("octo", false, "the source is in Octo's language (the default for .o8 files)")
+	writeSymbols := fs.Bool("symbols", false, "also write the labels to a .sym file next to the output, for debugging")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	inPath := pos[0]
+
+	src, err := ioutil.ReadFile(inPath)
+	if err != nil {
+		return err
+	}
+	assemble := asm.AssembleSymbols
+	if *useOcto || strings.EqualFold(filepath.Ext(inPath), ".o8") {
+		assemble = asm.AssembleOctoSymbols
+	}
+	rom, syms, err := assemble(string(src))
+	if err != nil {
+		return fmt.Errorf("%v: %v", inPath, err)
+	}
+
+	if *outPath == "" {
+		*outPath = strings.TrimSuffix(inPath, filepath.Ext(inPath)) + ".ch8"
+	}
+	if err := ioutil.WriteFile(*outPath, rom, 0644); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d bytes to: %v", len(rom), *outPath)
+	if *writeSymbols {
+		var buf bytes.Buffer
+		syms.WriteTo(&buf)
+		if err := ioutil.WriteFile(symbolPath(*outPath), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		log.Printf("Wrote %d symbols to: %v", syms.Len(), symbolPath(*outPath))
+	}
+	return nil
+}
+
+// testCommand implements `chip8emu test rom`. It runs the ROM without a window
+// and prints the final screen, which is handy for test ROMs and scripting.
+// `chip8emu test -suite dir` checks the test suite ROMs in dir against their goldens instead.
+func testCommand(args []string) error {
+	cfg, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+	fs := newFlagSet("test", "rom")
+	fs.String("config", defaultConfigPath(), "config file supplying the defaults for -platform, -ipf and -quirks")
+	frames := fs.Int("frames", 5*chip8.FrameRate, "number of 60Hz frames to run")
+	platform := fs.String("platform", cfg.Platform, "machine to emulate: "+strings.Join(chip8.ProfileNames(), ", "))
+	eti660 := fs.Bool("eti660", false, "run an ETI-660 program loaded at 0x600, short for -platform eti660")
+	ipf := fs.Int("ipf", cfg.IPF, "instructions executed per 60Hz frame")
+	quirks := fs.String("quirks", cfg.Quirks, "comma separated quirks to enable: "+strings.Join(chip8.QuirkNames(), ", "))
+	useCompat := fs.Bool("compat", true, "apply known settings for recognized ROMs")
+	demoRom := fs.Bool("demo", false, "run an embedded demo ROM instead, the argument names it: "+strings.Join(demo.Names(), ", "))
+	suite := fs.String("suite", "", "check the test suite ROMs in this directory against their golden screens (see pkg/chip8/testsuite)")
+	update := fs.Bool("update", false, "with -suite, rewrite the golden screens instead of checking them")
+	pos, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if *suite != "" {
+		return runSuite(*suite, *update)
+	}
+	if len(pos) == 0 && !*demoRom {
+		fs.Usage()
+		return fmt.Errorf("expected a ROM path")
+	}
+	rom, err := readRom(strings.Join(pos, ""), *demoRom)
+	if err != nil {
+		return err
+	}
+
+	emu := chip8.NewChip8()
+	explicit := explicitFlags(fs)
+	if *eti660 {
+		*platform, explicit["platform"] = "eti660", true
+	}
+	if err := configureMachine(emu, *platform, *quirks, *ipf, explicit); err != nil {
+		return err
+	}
+	if *useCompat {
+		applyCompat(emu, explicit, rom)
+	}
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		return err
+	}
+	for i := 0; i < *frames; i++ {
+		if err := emu.RunFrame(); err != nil {
+			return fmt.Errorf("frame %d: %v", i, err)
+		}
+	}
+
+	screen, _ := emu.SnapshotScreen()
+	fmt.Print(testsuite.FormatScreen(screen))
+	return nil
+}
+
+// octocartCommand implements `chip8emu octocart rom`. It packs a ROM or Octo source into an Octo
+// cartridge, with the machine's settings as its options and a screenshot of the program as its label.
+func octocartCommand(args []string) error {
+	cfg, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+	fs := newFlagSet("octocart", "rom|source.o8")
+	fs.String("config", defaultConfigPath(), "config file supplying the defaults for -platform, -ipf and -quirks")
+	outPath := fs.String("o", "", "output path (defaults to the input path with a .png extension)")
+	frames := fs.Int("frames", 2*chip8.FrameRate, "number of 60Hz frames to run before taking the label's screenshot")
+	platform := fs.String("platform", cfg.Platform, "machine to emulate: "+strings.Join(chip8.ProfileNames(), ", "))
+	ipf := fs.Int("ipf", cfg.IPF, "instructions executed per 60Hz frame")
+	quirks := fs.String("quirks", cfg.Quirks, "comma separated quirks to enable: "+strings.Join(chip8.QuirkNames(), ", "))
+	useCompat := fs.Bool("compat", true, "apply known settings for recognized ROMs")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	inPath := pos[0]
+
+	data, err := chip8.ReadRom(inPath)
+	if err != nil {
+		return err
+	}
+	rom, err := fromOcto(inPath, data)
+	if err != nil {
+		return err
+	}
+	program := octo.Source(rom)
+	if strings.EqualFold(filepath.Ext(inPath), ".o8") {
+		program = string(data)
+	}
+
+	emu := chip8.NewChip8()
+	explicit := explicitFlags(fs)
+	if err := configureMachine(emu, *platform, *quirks, *ipf, explicit); err != nil {
+		return err
+	}
+	if *useCompat {
+		applyCompat(emu, explicit, rom)
+	}
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		return err
+	}
+	for i := 0; i < *frames; i++ {
+		if err := emu.RunFrame(); err != nil {
+			log.Printf("Label screenshot taken at frame %d: %v", i, err)
+			break
+		}
+	}
+	screen, _ := emu.SnapshotScreen()
+	label := chip8.ScreenImage(screen, chip8.ImagePalette, 128/screen.Width)
+
+	options := octo.OptionsFor(emu.Quirks(), emu.InstructionsPerFrame())
+	switch emu.Profile().Name {
+	case "vip":
+		options.MaxSize = octo.MaxSizeVIP
+	case "chip48", "schip":
+		options.MaxSize = octo.MaxSizeSCHIP
+	case "xochip":
+		options.MaxSize = octo.MaxSizeXOCHIP
+	}
+
+	if *outPath == "" {
+		*outPath = strings.TrimSuffix(inPath, filepath.Ext(inPath)) + ".png"
+	}
+	var buf bytes.Buffer
+	if err := octo.WriteCartridge(&buf, &octo.Cartridge{Program: program, Options: options}, label); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(*outPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	log.Printf("Wrote cartridge to: %v", *outPath)
+	return nil
+}
+
+// runSuite checks every case of the test suite and prints a line per case
+func runSuite(dir string, update bool) error {
+	failed := 0
+	for _, r := range testsuite.CheckAll(dir, update) {
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("%-7v %-14s %s", r.Status, r.Case.Name, r.Detail)))
+		if r.Status == testsuite.Fail || r.Status == testsuite.NoGolden {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d cases failed", failed, len(testsuite.Cases))
+	}
+	return nil
+}
+
+// infoCommand implements `chip8emu info rom`
+func infoCommand(args []string) error {
+	fs := newFlagSet("info", "rom")
+	verbose := fs.Bool("v", false, "list every opcode that hints at the platform")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rom, err := ioutil.ReadFile(pos[0])
+	if err != nil {
+		return err
+	}
+
+	unknown := 0
+	for _, in := range disasm.Disassemble(rom, 0x200) {
+		if !in.Known {
+			unknown++
+		}
+	}
+	fmt.Printf("File:     %v\n", pos[0])
+	fmt.Printf("Size:     %d bytes (%d available)\n", len(rom), 0x1000-0x200)
+	fmt.Printf("SHA-1:    %x\n", sha1.Sum(rom))
+	fmt.Printf("CRC32:    %08x\n", crc32.ChecksumIEEE(rom))
+	if compat.HiresEntry(rom) {
+		fmt.Printf("Entry:    0x200, starts with the 1260 jump of 64x64 hires CHIP-8\n")
+	} else {
+		fmt.Printf("Entry:    0x200\n")
+	}
+	fmt.Printf("Unknown:  %d words (data or unsupported opcodes)\n", unknown)
+
+	hints := compat.Scan(rom)
+	fmt.Printf("Detected: %v (%d hint(s), scanning can't tell code from data)\n", compat.Detect(rom), len(hints))
+	if *verbose {
+		for _, h := range hints {
+			fmt.Printf("          %v\n", h)
+		}
+	}
+
+	if entry, ok := compat.Lookup(rom); ok {
+		fmt.Printf("Known ROM:\n")
+		fmt.Printf("  Title:    %v\n", entry.Title)
+		fmt.Printf("  Platform: %v\n", entry.Platform)
+		quirks := entry.Quirks.String()
+		if quirks == "" {
+			quirks = "none"
+		}
+		fmt.Printf("  Quirks:   %v\n", quirks)
+		if entry.InstructionsPerFrame > 0 {
+			fmt.Printf("  Speed:    %d instructions per frame\n", entry.InstructionsPerFrame)
+		}
+	} else {
+		fmt.Printf("Known ROM: no\n")
+	}
+	return nil
+}
+
+// analyzeCommand implements `chip8emu analyze rom`
+func analyzeCommand(args []string) error {
+	fs := newFlagSet("analyze", "rom")
+	pos, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	rom, err := ioutil.ReadFile(pos[0])
+	if err != nil {
+		return err
+	}
+	report := analyze.Analyze(rom)
+	fmt.Print(report)
+	if report.HasErrors() {
+		return fmt.Errorf("%v has errors", pos[0])
+	}
+	return nil
+}
+
+// benchCommand implements `chip8emu bench rom`. It runs the ROM headless as fast as possible
+// and reports the interpreter's speed.
+func benchCommand(args []string) error {
+	fs := newFlagSet("bench", "rom")
+	millions := fs.Float64("cycles", 10, "millions of instructions to run")
+	quirks := fs.String("quirks", "", "comma separated quirks to enable: "+strings.Join(chip8.QuirkNames(), ", "))
+	demoRom := fs.Bool("demo", false, "run an embedded demo ROM instead, the argument names it: "+strings.Join(demo.Names(), ", "))
+	pos, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 && !*demoRom {
+		fs.Usage()
+		return fmt.Errorf("expected a ROM path")
+	}
+	rom, err := readRom(strings.Join(pos, ""), *demoRom)
+	if err != nil {
+		return err
+	}
+	cycles := int(*millions * 1e6)
+	if cycles < 1 {
+		return fmt.Errorf("invalid cycles %v", *millions)
+	}
+
+	emu := chip8.NewChip8()
+	q, err := chip8.ParseQuirks(*quirks)
+	if err != nil {
+		return err
+	}
+	emu.SetQuirks(q)
+	emu.SeedRand(1)
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = emu.RunFor(cycles)
+	elapsed := time.Since(start)
+	if err != nil {
+		return err
+	}
+	perSecond := float64(cycles) / elapsed.Seconds()
+	fmt.Printf("Instructions: %d in %v\n", cycles, elapsed.Round(time.Millisecond))
+	fmt.Printf("Speed:        %.1f million instructions/s, %.1f ns each\n", perSecond/1e6, float64(elapsed.Nanoseconds())/float64(cycles))
+	fmt.Printf("Real time:    %.0fx at the default %d Hz\n", perSecond/float64(chip8.DefaultInstructionsPerFrame*chip8.FrameRate), chip8.DefaultInstructionsPerFrame*chip8.FrameRate)
+	return nil
+}
+
+// profileCommand implements `chip8emu profile rom`
+func profileCommand(args []string) error {
+	fs := newFlagSet("profile", "rom")
+	return profiledRun(fs, args, func(emu *chip8.Chip8, prof *profiler.Profiler, rom []byte, path string) error {
+		fmt.Print(prof.Profile())
+		if stack := prof.CallStack(); len(stack) > 0 {
+			fmt.Println("\nCall stack at the end:")
+			for i := len(stack) - 1; i >= 0; i-- {
+				fmt.Printf("  0x%03X (returns to 0x%03X, %d instructions so far)\n", stack[i].Addr, stack[i].Return, stack[i].Cycles)
+			}
+		}
+		return nil
+	})
+}
+
+// coverageCommand implements `chip8emu coverage rom`
+func coverageCommand(args []string) error {
+	fs := newFlagSet("coverage", "rom")
+	outPath := fs.String("o", "", "write the report to this file instead of stdout")
+	lcov := fs.Bool("lcov", false, "write an lcov tracefile rather than annotated disassembly (the default for .info and .lcov files)")
+	return profiledRun(fs, args, func(emu *chip8.Chip8, prof *profiler.Profiler, rom []byte, path string) error {
+		report := coverageReport(prof, rom, emu.RomInfo().Addr, path, *lcov || isLcovPath(*outPath))
+		if *outPath == "" {
+			_, err := os.Stdout.Write(report)
+			return err
+		}
+		return ioutil.WriteFile(*outPath, report, 0644)
+	})
+}
+
+// profiledRun runs a ROM headless under the profiler for the profile and coverage commands,
+// adding their common flags to fs, then calls report
+func profiledRun(fs *flag.FlagSet, args []string, report func(emu *chip8.Chip8, prof *profiler.Profiler, rom []byte, path string) error) error {
+	cfg, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+	fs.String("config", defaultConfigPath(), "config file supplying the defaults for -platform, -ipf and -quirks")
+	frames := fs.Int("frames", 10*chip8.FrameRate, "number of 60Hz frames to run")
+	platform := fs.String("platform", cfg.Platform, "machine to emulate: "+strings.Join(chip8.ProfileNames(), ", "))
+	ipf := fs.Int("ipf", cfg.IPF, "instructions executed per 60Hz frame")
+	quirks := fs.String("quirks", cfg.Quirks, "comma separated quirks to enable: "+strings.Join(chip8.QuirkNames(), ", "))
+	useCompat := fs.Bool("compat", true, "apply known settings for recognized ROMs")
+	demoRom := fs.Bool("demo", false, "run an embedded demo ROM instead, the argument names it: "+strings.Join(demo.Names(), ", "))
+	pos, err := parseArgs(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if len(pos) == 0 && !*demoRom {
+		fs.Usage()
+		return fmt.Errorf("expected a ROM path")
+	}
+	path := strings.Join(pos, "")
+	rom, err := readRom(path, *demoRom)
+	if err != nil {
+		return err
+	}
+
+	emu := chip8.NewChip8()
+	explicit := explicitFlags(fs)
+	if err := configureMachine(emu, *platform, *quirks, *ipf, explicit); err != nil {
+		return err
+	}
+	if *useCompat {
+		applyCompat(emu, explicit, rom)
+	}
+	emu.SeedRand(1)
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		return err
+	}
+	prof := profiler.New(emu)
+	for i := 0; i < *frames; i++ {
+		if err := emu.RunFrame(); err != nil {
+			fmt.Fprintf(os.Stderr, "Stopped at frame %d: %v\n", i, err)
+			break
+		}
+		if emu.HaltReason() != nil {
+			fmt.Fprintf(os.Stderr, "Halted at frame %d: %v\n", i, emu.HaltReason())
+			break
+		}
+	}
+	return report(emu, prof, rom, path)
+}
@@ -0,0 +1,36 @@
+// Package demo embeds a couple of small freely distributable ROMs, so the emulator
+// can be tried out (and tested) without any ROM files.
+package demo
+
+import (
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//go:embed *.ch8
+var files embed.FS
+
+// Default is the demo run when none is named
+const Default = "ibm-logo"
+
+// Names lists the embedded ROMs
+func Names() []string {
+	entries, _ := files.ReadDir(".")
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".ch8"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ROM returns the embedded ROM called name (see Names)
+func ROM(name string) ([]byte, error) {
+	rom, err := files.ReadFile(name + ".ch8")
+	if err != nil {
+		return nil, fmt.Errorf("demo: no demo called %q (expected one of: %v)", name, strings.Join(Names(), ", "))
+	}
+	return rom, nil
+}
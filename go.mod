@@ -1,7 +1,8 @@
 module github.com/dustinbowers/chip8emu
 
-go 1.14
+go 1.16
 
 require (
 	github.com/veandco/go-sdl2 v0.4.4
+	github.com/yuin/gopher-lua v1.1.1
 )
@@ -0,0 +1,343 @@
+// Package analyze statically checks a ROM before it's run.
+//
+// It walks the control flow graph from the 0x200 entry point, following jumps, calls, returns
+// and skips, and reports:
+//
+//   - unknown or unsupported opcodes that can be reached
+//   - jumps and calls outside the ROM or the address space
+//   - call chains deeper than the 16 entry stack, recursion, and returns with nothing to return to
+//   - computed jumps (Bnnn), whose targets can't be followed
+//   - self-modifying code: Fx33 / Fx55 writes, with a known I, that land on reachable code
+//   - regions that are never executed, which are either data (e.g. sprites) or dead code
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+)
+
+const (
+	origin     = 0x200
+	memorySize = 0x1000
+	stackSize  = 16
+)
+
+// Severity of an Issue
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return "info"
+}
+
+// Issue is a single finding
+type Issue struct {
+	Addr     uint16
+	Severity Severity
+	Msg      string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("0x%03X  %-7s %s", i.Addr, i.Severity, i.Msg)
+}
+
+// Region is a range of addresses, End is exclusive
+type Region struct {
+	Start, End uint16
+	Data       bool // Referenced by an LD I, nnn, so most likely sprite or lookup data
+}
+
+func (r Region) String() string {
+	return fmt.Sprintf("0x%03X-0x%03X (%d bytes)", r.Start, r.End-1, r.End-r.Start)
+}
+
+// Report is the result of Analyze
+type Report struct {
+	Reachable     []uint16 // Address of every reachable instruction, sorted
+	Issues        []Issue  // Sorted by address
+	Unreachable   []Region // Parts of the ROM that are never executed
+	SelfModifying []Region // Writes that land on reachable code
+	MaxCallDepth  int      // Deepest chain of nested calls found
+}
+
+// path is a point in the walk: an address and the subroutine it's part of
+type path struct {
+	addr uint16
+	fn   uint16 // Entry point of the subroutine, origin for the main program
+	i    int    // Value of I, or -1 if unknown
+}
+
+// Analyze walks rom, assuming it's loaded at 0x200
+func Analyze(rom []byte) *Report {
+	a := &analyzer{
+		rom:       rom,
+		reachable: map[uint16]bool{},
+		visited:   map[path]bool{},
+		dataRefs:  map[uint16]bool{},
+		calls:     map[uint16]map[uint16]uint16{},
+		issues:    map[string]Issue{},
+	}
+	a.walk(path{addr: origin, fn: origin, i: -1})
+	a.checkCallDepth()
+	return a.report()
+}
+
+type analyzer struct {
+	rom       []byte
+	reachable map[uint16]bool
+	visited   map[path]bool
+	dataRefs  map[uint16]bool              // Targets of LD I, nnn
+	writes    []Region                     // Memory written by Fx33 / Fx55 with a known I
+	calls     map[uint16]map[uint16]uint16 // Call graph: caller entry -> callee entry -> call site
+	issues    map[string]Issue             // Deduplicated by address and message
+	maxDepth  int
+}
+
+func (a *analyzer) issue(addr uint16, sev Severity, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	a.issues[fmt.Sprintf("%04X %s", addr, msg)] = Issue{Addr: addr, Severity: sev, Msg: msg}
+}
+
+func (a *analyzer) inROM(addr uint16) bool {
+	return addr >= origin && int(addr)+1 < origin+len(a.rom)
+}
+
+func (a *analyzer) walk(start path) {
+	work := []path{start}
+	for len(work) > 0 {
+		p := work[len(work)-1]
+		work = work[:len(work)-1]
+
+		for {
+			if a.visited[p] {
+				break
+			}
+			a.visited[p] = true
+			if !a.inROM(p.addr) {
+				a.issue(p.addr, Error, "execution runs outside the ROM")
+				break
+			}
+			a.reachable[p.addr] = true
+
+			op := uint16(a.rom[p.addr-origin])<<8 | uint16(a.rom[p.addr-origin+1])
+			in := disasm.Decode(p.addr, op)
+			next := path{addr: p.addr + 2, fn: p.fn, i: p.i}
+			nnn := op & 0x0FFF
+			x := op >> 8 & 0xF
+
+			if !in.Known {
+				a.issue(p.addr, Error, "unknown or unsupported opcode %04X (%s)", op, in.Text())
+				break
+			}
+
+			switch {
+			case op == 0x00EE:
+				if p.fn == origin {
+					a.issue(p.addr, Warning, "RET reachable from the main program, where the stack is empty")
+				}
+				// Calls are followed by walking both the subroutine and the return address,
+				// so there's nowhere left to go from here
+				next.addr = 0
+			case op&0xF000 == 0x1000:
+				if nnn == p.addr {
+					next.addr = 0 // Deliberate infinite loop, typically the end of a program
+				} else {
+					next.addr = a.target(p.addr, nnn, "JP")
+				}
+			case op&0xF000 == 0x2000:
+				if t := a.target(p.addr, nnn, "CALL"); t != 0 {
+					if a.calls[p.fn] == nil {
+						a.calls[p.fn] = map[uint16]uint16{}
+					}
+					a.calls[p.fn][t] = p.addr
+					work = append(work, path{addr: t, fn: t, i: -1})
+				}
+				next.i = -1 // The subroutine may have changed I
+			case op&0xF000 == 0xB000:
+				a.issue(p.addr, Warning, "computed jump to 0x%03X + V0, targets can't be followed", nnn)
+				next.addr = 0
+			case op&0xF000 == 0xA000:
+				next.i = int(nnn)
+				a.dataRefs[nnn] = true
+			case op&0xF0FF == 0xF01E, op&0xF0FF == 0xF029, op&0xF0FF == 0xF065:
+				next.i = -1
+			case op&0xF0FF == 0xF033, op&0xF0FF == 0xF055:
+				if p.i >= 0 {
+					size := 3
+					if op&0xFF == 0x55 {
+						size = int(x) + 1
+					}
+					a.writes = append(a.writes, Region{Start: uint16(p.i), End: uint16(p.i + size)})
+				}
+				next.i = -1
+			}
+
+			if skips(op) {
+				work = append(work, path{addr: p.addr + 4, fn: p.fn, i: next.i})
+			}
+			if next.addr == 0 {
+				break
+			}
+			p = next
+		}
+	}
+}
+
+// checkCallDepth finds the deepest chain of nested calls in the call graph, and any recursion
+func (a *analyzer) checkCallDepth() {
+	depth := map[uint16]int{} // Deepest chain below each subroutine
+	onStack := map[uint16]bool{}
+	var visit func(fn uint16) int
+	visit = func(fn uint16) int {
+		if d, ok := depth[fn]; ok {
+			return d
+		}
+		onStack[fn] = true
+		deepest := 0
+		for callee, site := range a.calls[fn] {
+			if onStack[callee] {
+				a.issue(site, Warning, "recursive CALL 0x%03X, the stack overflows after %d levels", callee, stackSize)
+				continue
+			}
+			if d := visit(callee) + 1; d > deepest {
+				deepest = d
+			}
+		}
+		onStack[fn] = false
+		depth[fn] = deepest
+		return deepest
+	}
+	a.maxDepth = visit(origin)
+	if a.maxDepth > stackSize {
+		a.issue(origin, Error, "calls nest %d deep, more than the %d entry stack", a.maxDepth, stackSize)
+	}
+}
+
+// target checks the destination of a jump or call, returning 0 if it can't be followed
+func (a *analyzer) target(from, to uint16, kind string) uint16 {
+	switch {
+	case to < origin:
+		a.issue(from, Error, "%s to 0x%03X, below the program area", kind, to)
+		return 0
+	case !a.inROM(to):
+		a.issue(from, Error, "%s to 0x%03X, past the end of the ROM", kind, to)
+		return 0
+	}
+	return to
+}
+
+// skips reports whether op conditionally skips the next instruction
+func skips(op uint16) bool {
+	switch op & 0xF000 {
+	case 0x3000, 0x4000, 0x5000, 0x9000:
+		return true
+	case 0xE000:
+		return op&0xFF == 0x9E || op&0xFF == 0xA1
+	}
+	return false
+}
+
+func (a *analyzer) report() *Report {
+	r := &Report{MaxCallDepth: a.maxDepth}
+	for addr := range a.reachable {
+		r.Reachable = append(r.Reachable, addr)
+	}
+	sort.Slice(r.Reachable, func(i, j int) bool { return r.Reachable[i] < r.Reachable[j] })
+
+	// Self-modifying code: writes landing on reachable instructions
+	for _, w := range a.writes {
+		for addr := w.Start; addr < w.End; addr++ {
+			if a.reachable[addr] || addr > 0 && a.reachable[addr-1] {
+				r.SelfModifying = append(r.SelfModifying, w)
+				break
+			}
+		}
+	}
+
+	// Unreachable regions: bytes of the ROM not covered by a reachable instruction
+	covered := make([]bool, len(a.rom))
+	for addr := range a.reachable {
+		covered[addr-origin] = true
+		covered[addr-origin+1] = true
+	}
+	for i := 0; i < len(covered); {
+		if covered[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(covered) && !covered[i] {
+			i++
+		}
+		region := Region{Start: uint16(origin + start), End: uint16(origin + i)}
+		for addr := range a.dataRefs {
+			if addr >= region.Start && addr < region.End {
+				region.Data = true
+				break
+			}
+		}
+		r.Unreachable = append(r.Unreachable, region)
+	}
+
+	for _, is := range a.issues {
+		r.Issues = append(r.Issues, is)
+	}
+	sort.Slice(r.Issues, func(i, j int) bool {
+		if r.Issues[i].Addr != r.Issues[j].Addr {
+			return r.Issues[i].Addr < r.Issues[j].Addr
+		}
+		return r.Issues[i].Msg < r.Issues[j].Msg
+	})
+	return r
+}
+
+// HasErrors reports whether any Error severity issues were found
+func (r *Report) HasErrors() bool {
+	for _, is := range r.Issues {
+		if is.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the report as plain text
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Reachable instructions: %d\n", len(r.Reachable))
+	fmt.Fprintf(&sb, "Deepest call chain:     %d of %d\n", r.MaxCallDepth, stackSize)
+
+	fmt.Fprintf(&sb, "\nIssues (%d):\n", len(r.Issues))
+	for _, is := range r.Issues {
+		fmt.Fprintf(&sb, "  %v\n", is)
+	}
+
+	fmt.Fprintf(&sb, "\nSelf-modifying writes (%d):\n", len(r.SelfModifying))
+	for _, region := range r.SelfModifying {
+		fmt.Fprintf(&sb, "  %v\n", region)
+	}
+
+	fmt.Fprintf(&sb, "\nNever executed (%d):\n", len(r.Unreachable))
+	for _, region := range r.Unreachable {
+		kind := "data or dead code"
+		if region.Data {
+			kind = "data, referenced by LD I"
+		}
+		fmt.Fprintf(&sb, "  %v  %s\n", region, kind)
+	}
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package analyze
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReachable(t *testing.T) {
+	r := Analyze([]byte{
+		0xA2, 0x0E, // 200: LD I, 0x20E
+		0x22, 0x08, // 202: CALL 0x208
+		0x12, 0x04, // 204: JP 0x204
+		0x00, 0xE0, // 206: CLS, dead code
+		0x30, 0x00, // 208: SE V0, 0
+		0x12, 0x0C, // 20A: JP 0x20C
+		0x00, 0xEE, // 20C: RET, reached both ways
+		0xF0, 0x90, // 20E: sprite
+	})
+	if want := []uint16{0x200, 0x202, 0x204, 0x208, 0x20A, 0x20C}; !reflect.DeepEqual(r.Reachable, want) {
+		t.Errorf("reachable %03X, want %03X", r.Reachable, want)
+	}
+	if want := []Region{{0x206, 0x208, false}, {0x20E, 0x210, true}}; !reflect.DeepEqual(r.Unreachable, want) {
+		t.Errorf("unreachable %v, want %v", r.Unreachable, want)
+	}
+	if len(r.Issues) != 0 || r.HasErrors() {
+		t.Errorf("issues %v", r.Issues)
+	}
+	if r.MaxCallDepth != 1 {
+		t.Errorf("call depth %d, want 1", r.MaxCallDepth)
+	}
+}
+
+func TestIssues(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  []byte
+		addr uint16
+		sev  Severity
+		want string
+	}{
+		{"jump below", []byte{0x11, 0x00}, 0x200, Error, "JP to 0x100, below the program area"},
+		{"jump past", []byte{0x13, 0x00}, 0x200, Error, "JP to 0x300, past the end of the ROM"},
+		{"call past", []byte{0x00, 0xE0, 0x2F, 0xFE}, 0x202, Error, "CALL to 0xFFE, past the end"},
+		{"unknown", []byte{0x51, 0x21}, 0x200, Error, "unknown or unsupported opcode 5121"},
+		{"computed", []byte{0xB2, 0x04}, 0x200, Warning, "computed jump to 0x204 + V0"},
+		{"main RET", []byte{0x00, 0xEE}, 0x200, Warning, "RET reachable from the main program"},
+		{"recursion", []byte{0x22, 0x04, 0x12, 0x02, 0x22, 0x04, 0x00, 0xEE}, 0x204, Warning, "recursive CALL 0x204"},
+		{"falls off", []byte{0x00, 0xE0}, 0x202, Error, "execution runs outside the ROM"},
+	}
+	for _, tt := range tests {
+		r := Analyze(tt.rom)
+		found := false
+		for _, is := range r.Issues {
+			if is.Addr == tt.addr && is.Severity == tt.sev && strings.Contains(is.Msg, tt.want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: got %v, want %v %q at 0x%03X", tt.name, r.Issues, tt.sev, tt.want, tt.addr)
+		}
+		if got := r.HasErrors(); got != (tt.sev == Error) {
+			t.Errorf("%s: HasErrors %v", tt.name, got)
+		}
+	}
+}
+
+// TestTruncated covers ROMs that end partway through an instruction
+func TestTruncated(t *testing.T) {
+	tests := []struct {
+		name        string
+		rom         []byte
+		addr        uint16
+		unreachable []Region
+	}{
+		{"empty", nil, 0x200, nil},
+		{"one byte", []byte{0x00}, 0x200, []Region{{0x200, 0x201, false}}},
+		{"trailing byte", []byte{0x00, 0xE0, 0x12}, 0x202, []Region{{0x202, 0x203, false}}},
+		{"jump into the last byte", []byte{0x12, 0x03, 0x00, 0xE0}, 0x200, []Region{{0x202, 0x204, false}}},
+	}
+	for _, tt := range tests {
+		r := Analyze(tt.rom)
+		if !r.HasErrors() || r.Issues[0].Addr != tt.addr {
+			t.Errorf("%s: got issues %v, want an error at 0x%03X", tt.name, r.Issues, tt.addr)
+		}
+		if !reflect.DeepEqual(r.Unreachable, tt.unreachable) {
+			t.Errorf("%s: unreachable %v, want %v", tt.name, r.Unreachable, tt.unreachable)
+		}
+	}
+}
+
+func TestCallDepth(t *testing.T) {
+	// Main calls 0x204, which calls 0x208, and so on, levels deep
+	nest := func(levels int) []byte {
+		rom := []byte{0x22, 0x04, 0x12, 0x02} // CALL 0x204; JP 0x202
+		for i := 1; i <= levels; i++ {
+			next := 0x200 + 4*(i+1)
+			if i < levels {
+				rom = append(rom, 0x20|byte(next>>8), byte(next))
+			} else {
+				rom = append(rom, 0x00, 0xE0) // CLS
+			}
+			rom = append(rom, 0x00, 0xEE)
+		}
+		return rom
+	}
+	if r := Analyze(nest(stackSize)); r.MaxCallDepth != stackSize || r.HasErrors() {
+		t.Errorf("%d levels: depth %d, issues %v", stackSize, r.MaxCallDepth, r.Issues)
+	}
+	r := Analyze(nest(stackSize + 1))
+	if r.MaxCallDepth != stackSize+1 || !r.HasErrors() || !strings.Contains(r.Issues[0].Msg, "calls nest 17 deep") {
+		t.Errorf("%d levels: depth %d, issues %v", stackSize+1, r.MaxCallDepth, r.Issues)
+	}
+}
+
+func TestSelfModifying(t *testing.T) {
+	r := Analyze([]byte{
+		0xA2, 0x06, // 200: LD I, 0x206
+		0xF0, 0x33, // 202: LD B, V0, writes 0x206-0x208
+		0xA3, 0x00, // 204: LD I, 0x300
+		0xF0, 0x55, // 206: LD [I], V0, writes 0x300, not code
+		0x12, 0x08, // 208: JP 0x208
+	})
+	if want := []Region{{0x206, 0x209, false}}; !reflect.DeepEqual(r.SelfModifying, want) {
+		t.Errorf("self-modifying %v, want %v", r.SelfModifying, want)
+	}
+}
@@ -0,0 +1,356 @@
+// Package api serves an HTTP/JSON interface for controlling and inspecting a running chip8.Chip8,
+// so the emulator can be scripted, driven from test harnesses or watched from a web dashboard.
+//
+// Endpoints (addresses are hex, 0x prefix optional):
+//
+//	GET  /state                     registers, timers, stack, paused, frames and clock speed as JSON
+//	GET  /memory?addr=200&len=16    len bytes of memory from addr as JSON (len defaults to 16)
+//	GET  /screen.png?scale=8        the framebuffer as a PNG (scale defaults to 1)
+//	POST /pause, /resume, /reset
+//	POST /load?path=rom.ch8         load a ROM file from Server.ROMDir, or the ROM sent as the request
+//	                                body when path is missing
+//	POST /keys/{key}/{down|up|press}
+//	                                change the state of key 0-F, press holds it for ?ms=100
+//	GET  /watch/                    a page for watching the machine in a browser, see Spectator
+//	GET  /watch/stream              the WebSocket stream of the screen and sound it watches
+//
+// Every POST replies with the new state, errors are JSON objects with an "error" field.
+// Requests from web pages of other origins are refused, so a page open in the browser can't drive
+// the machine. Anyone who can reach the address can, so serve it on localhost unless that's intended.
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+const (
+	defaultPressTime = 100 * time.Millisecond
+	maxScale         = 32
+)
+
+// Keypad receives key presses, either the emulator itself or something wrapping it (e.g. a movie.Recorder)
+type Keypad interface {
+	KeyDown(key uint8)
+	KeyUp(key uint8)
+}
+
+// Server is an http.Handler serving the API for one machine
+type Server struct {
+	emu  *chip8.Chip8
+	mux  *http.ServeMux
+	keys Keypad
+
+	// LoadFile and LoadBytes load a ROM for /load. They default to reading the file and calling
+	// Chip8.LoadRomBytes, frontends replace them to apply their own settings or refuse loading.
+	LoadFile  func(path string) error
+	LoadBytes func(rom []byte) error
+
+	// ROMDir is the directory /load?path= loads from. Paths leading outside it are refused, and
+	// so is loading by path when it's empty.
+	ROMDir string
+
+	// Reset and Pause serve /reset and /pause. They default to calling the Chip8, frontends replace
+	// them to refuse while the machine has to keep in step with a movie or another player.
+	Reset func() error
+	Pause func() error
+}
+
+// State is the reply to GET /state and every POST
+type State struct {
+	PC            uint16   `json:"pc"`
+	I             uint16   `json:"i"`
+	SP            uint16   `json:"sp"`
+	V             []int    `json:"v"`
+	Stack         []uint16 `json:"stack"`
+	DT            uint8    `json:"dt"`
+	ST            uint8    `json:"st"`
+	Paused        bool     `json:"paused"`
+	WaitingForKey bool     `json:"waiting_for_key"`
+	Finished      bool     `json:"finished"` // In an endless loop, see chip8.Finished
+	Frames        uint64   `json:"frames"`
+	ClockSpeed    int      `json:"clock_hz"`
+}
+
+// Memory is the reply to GET /memory
+type Memory struct {
+	Addr uint16 `json:"addr"`
+	Data []int  `json:"data"`
+}
+
+// New creates a Server for emu. Key presses go to keys, or straight to emu when keys is nil.
+func New(emu *chip8.Chip8, keys Keypad) *Server {
+	if keys == nil {
+		keys = emu
+	}
+	s := &Server{emu: emu, mux: http.NewServeMux(), keys: keys}
+	s.LoadFile = func(path string) error {
+		_, err := emu.LoadRom(path)
+		return err
+	}
+	s.LoadBytes = func(rom []byte) error {
+		_, err := emu.LoadRomBytes(rom)
+		return err
+	}
+	s.Reset = func() error {
+		emu.Reset()
+		return nil
+	}
+	s.Pause = func() error {
+		emu.Pause()
+		return nil
+	}
+
+	s.mux.HandleFunc("/state", s.get(s.handleState))
+	s.mux.HandleFunc("/memory", s.get(s.handleMemory))
+	s.mux.HandleFunc("/screen.png", s.get(s.handleScreen))
+	s.mux.HandleFunc("/pause", s.post(func(w http.ResponseWriter, r *http.Request) error {
+		return s.Pause()
+	}))
+	s.mux.HandleFunc("/resume", s.post(func(w http.ResponseWriter, r *http.Request) error {
+		emu.Resume()
+		return nil
+	}))
+	s.mux.HandleFunc("/reset", s.post(func(w http.ResponseWriter, r *http.Request) error {
+		return s.Reset()
+	}))
+	s.mux.HandleFunc("/load", s.post(s.handleLoad))
+	s.mux.HandleFunc("/keys/", s.post(s.handleKey))
+	s.mux.Handle("/watch/", http.StripPrefix("/watch", NewSpectator(emu)))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" && !sameOrigin(origin, r.Host) {
+		writeError(w, httpError{http.StatusForbidden, fmt.Errorf("requests from %v aren't allowed", origin)})
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// sameOrigin reports whether a browser's Origin header names the server at host
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, host)
+}
+
+// State returns the machine's current state
+func (s *Server) State() State {
+	regs := s.emu.Registers()
+	_, finished := s.emu.Finished()
+	st := State{
+		PC:            regs.PC,
+		I:             regs.I,
+		SP:            regs.SP,
+		V:             make([]int, len(regs.V)),
+		Stack:         regs.Stack[:],
+		DT:            regs.DT,
+		ST:            regs.ST,
+		Paused:        s.emu.Paused(),
+		WaitingForKey: s.emu.WaitingForKey(),
+		Finished:      finished,
+		Frames:        s.emu.Frames(),
+		ClockSpeed:    s.emu.ClockSpeed(),
+	}
+	for i, v := range regs.V {
+		st.V[i] = int(v)
+	}
+	return st
+}
+
+// httpError is an error with a status code other than 400 Bad Request
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+// get wraps a handler that writes its own reply
+func (s *Server) get(h func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, httpError{http.StatusMethodNotAllowed, fmt.Errorf("%v requires GET", r.URL.Path)})
+			return
+		}
+		if err := h(w, r); err != nil {
+			writeError(w, err)
+		}
+	}
+}
+
+// post wraps a handler that changes the machine, replying with the new state
+func (s *Server) post(h func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, httpError{http.StatusMethodNotAllowed, fmt.Errorf("%v requires POST", r.URL.Path)})
+			return
+		}
+		if err := h(w, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.State())
+	}
+}
+
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) error {
+	writeJSON(w, http.StatusOK, s.State())
+	return nil
+}
+
+func (s *Server) handleMemory(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	addr, err := parseHex(q.Get("addr"), 0)
+	if err != nil {
+		return fmt.Errorf("invalid addr: %v", err)
+	}
+	n := 16
+	if l := q.Get("len"); l != "" {
+		if n, err = strconv.Atoi(l); err != nil || n < 1 {
+			return fmt.Errorf("invalid len %q", l)
+		}
+	}
+	if addr >= 4096 {
+		return fmt.Errorf("addr 0x%X is outside memory", addr)
+	}
+
+	mem := Memory{Addr: uint16(addr)}
+	for _, b := range s.emu.ReadMemory(uint16(addr), n) {
+		mem.Data = append(mem.Data, int(b))
+	}
+	writeJSON(w, http.StatusOK, mem)
+	return nil
+}
+
+func (s *Server) handleScreen(w http.ResponseWriter, r *http.Request) error {
+	scale := 1
+	if v := r.URL.Query().Get("scale"); v != "" {
+		var err error
+		if scale, err = strconv.Atoi(v); err != nil || scale < 1 || scale > maxScale {
+			return fmt.Errorf("invalid scale %q (expected 1-%d)", v, maxScale)
+		}
+	}
+
+	img := chip8.ScreenImage(s.emu.PeekScreen(), chip8.ImagePalette, scale)
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	return png.Encode(w, img)
+}
+
+func (s *Server) handleLoad(w http.ResponseWriter, r *http.Request) error {
+	if path := r.URL.Query().Get("path"); path != "" {
+		full, err := s.romPath(path)
+		if err != nil {
+			return err
+		}
+		return s.LoadFile(full)
+	}
+	max := s.emu.MaxRomSize() // Larger for MegaChip
+	rom, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, int64(max)+1))
+	if err != nil {
+		return fmt.Errorf("failed reading ROM: %v", err)
+	}
+	switch {
+	case len(rom) == 0:
+		return fmt.Errorf("expected a path parameter or a ROM in the request body")
+	case len(rom) > max:
+		return fmt.Errorf("ROM is larger than %d bytes", max)
+	}
+	return s.LoadBytes(rom)
+}
+
+// romPath finds path in ROMDir, following symlinks so none leads out of it
+func (s *Server) romPath(path string) (string, error) {
+	if s.ROMDir == "" {
+		return "", httpError{http.StatusForbidden, fmt.Errorf("loading by path is disabled, send the ROM as the request body")}
+	}
+	dir, err := filepath.EvalSymlinks(s.ROMDir)
+	if err != nil {
+		return "", httpError{http.StatusInternalServerError, fmt.Errorf("ROM directory: %v", err)}
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(dir, filepath.FromSlash(path)))
+	if err != nil {
+		return "", httpError{http.StatusNotFound, fmt.Errorf("no ROM %q", path)}
+	}
+	if rel, err := filepath.Rel(dir, full); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", httpError{http.StatusForbidden, fmt.Errorf("%q is outside the ROM directory", path)}
+	}
+	return full, nil
+}
+
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) error {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/keys/"), "/")
+	if len(parts) != 2 {
+		return httpError{http.StatusNotFound, fmt.Errorf("expected /keys/{key}/{down|up|press}")}
+	}
+	key, err := parseHex(parts[0], 0xF)
+	if err != nil {
+		return fmt.Errorf("invalid key %q (expected 0-F)", parts[0])
+	}
+
+	switch parts[1] {
+	case "down":
+		s.keys.KeyDown(uint8(key))
+	case "up":
+		s.keys.KeyUp(uint8(key))
+	case "press":
+		hold := defaultPressTime
+		if v := r.URL.Query().Get("ms"); v != "" {
+			ms, err := strconv.Atoi(v)
+			if err != nil || ms < 0 {
+				return fmt.Errorf("invalid ms %q", v)
+			}
+			hold = time.Duration(ms) * time.Millisecond
+		}
+		s.keys.KeyDown(uint8(key))
+		time.AfterFunc(hold, func() { s.keys.KeyUp(uint8(key)) })
+	default:
+		return httpError{http.StatusNotFound, fmt.Errorf("unknown key action %q (expected down, up or press)", parts[1])}
+	}
+	return nil
+}
+
+// parseHex parses a hex number no larger than max (0 for no limit beyond 16 bits)
+func parseHex(s string, max uint64) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 16)
+	if err != nil {
+		return 0, err
+	}
+	if max > 0 && n > max {
+		return 0, fmt.Errorf("%v is out of range", s)
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if he, ok := err.(httpError); ok {
+		status = he.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
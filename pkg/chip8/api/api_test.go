@@ -0,0 +1,254 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// do sends a request to s, returning the status and body. Header values come in pairs.
+func do(t *testing.T, s *Server, method, target string, header ...string) (int, string) {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w.Code, w.Body.String()
+}
+
+func newServer(t *testing.T) (*Server, *chip8.Chip8) {
+	t.Helper()
+	emu := chip8.NewChip8()
+	if _, err := emu.LoadRomBytes([]byte{0x6A, 0x42, 0x12, 0x02}); err != nil { // LD VA, 0x42; JP 0x202
+		t.Fatal(err)
+	}
+	if err := emu.RunFor(1); err != nil {
+		t.Fatal(err)
+	}
+	return New(emu, nil), emu
+}
+
+func TestState(t *testing.T) {
+	s, _ := newServer(t)
+	code, body := do(t, s, "GET", "/state")
+	if code != http.StatusOK {
+		t.Fatalf("GET /state: %d %s", code, body)
+	}
+	var st State
+	if err := json.Unmarshal([]byte(body), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.PC != 0x202 || st.V[0xA] != 0x42 || len(st.Stack) != 16 {
+		t.Errorf("got %+v", st)
+	}
+
+	if code, _ := do(t, s, "POST", "/state"); code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /state: got %d, want 405", code)
+	}
+	if code, _ := do(t, s, "GET", "/reset"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /reset: got %d, want 405", code)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	s, _ := newServer(t)
+	tests := []struct {
+		query string
+		code  int
+		data  []int
+	}{
+		{"addr=200&len=2", http.StatusOK, []int{0x6A, 0x42}},
+		{"addr=0x202", http.StatusOK, nil}, // 16 bytes
+		{"addr=FFE&len=16", http.StatusOK, []int{0, 0}},
+		{"addr=1000", http.StatusBadRequest, nil},
+		{"addr=200&len=0", http.StatusBadRequest, nil},
+		{"addr=zz", http.StatusBadRequest, nil},
+		{"len=4", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		code, body := do(t, s, "GET", "/memory?"+tt.query)
+		if code != tt.code {
+			t.Errorf("%s: got %d %s, want %d", tt.query, code, body, tt.code)
+			continue
+		}
+		if code != http.StatusOK {
+			continue
+		}
+		var mem Memory
+		if err := json.Unmarshal([]byte(body), &mem); err != nil {
+			t.Fatal(err)
+		}
+		if tt.data == nil && len(mem.Data) != 16 || tt.data != nil && len(mem.Data) != len(tt.data) {
+			t.Errorf("%s: got %d bytes", tt.query, len(mem.Data))
+			continue
+		}
+		for i, b := range tt.data {
+			if mem.Data[i] != b {
+				t.Errorf("%s: got %v, want %v", tt.query, mem.Data, tt.data)
+				break
+			}
+		}
+	}
+}
+
+type keyLog []string
+
+func (k *keyLog) KeyDown(key uint8) { *k = append(*k, "down "+string("0123456789ABCDEF"[key])) }
+func (k *keyLog) KeyUp(key uint8)   { *k = append(*k, "up "+string("0123456789ABCDEF"[key])) }
+
+func TestKeys(t *testing.T) {
+	keys := &keyLog{}
+	s := New(chip8.NewChip8(), keys)
+	tests := []struct {
+		target string
+		code   int
+	}{
+		{"/keys/5/down", http.StatusOK},
+		{"/keys/0xf/up", http.StatusOK},
+		{"/keys/10/down", http.StatusBadRequest},
+		{"/keys/G/down", http.StatusBadRequest},
+		{"/keys/5/sideways", http.StatusNotFound},
+		{"/keys/5", http.StatusNotFound},
+		{"/keys/5/press?ms=-1", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code, body := do(t, s, "POST", tt.target); code != tt.code {
+			t.Errorf("%s: got %d %s, want %d", tt.target, code, body, tt.code)
+		}
+	}
+	if code, _ := do(t, s, "GET", "/keys/5/down"); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /keys/5/down: got %d, want 405", code)
+	}
+	if got := strings.Join(*keys, ", "); got != "down 5, up F" {
+		t.Errorf("keys got %q", got)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	s, emu := newServer(t)
+	s.Reset = func() error { return errors.New("not now") }
+	if code, body := do(t, s, "POST", "/reset"); code != http.StatusBadRequest || !strings.Contains(body, "not now") {
+		t.Errorf("refused reset: got %d %s", code, body)
+	}
+	if emu.Registers().PC != 0x202 {
+		t.Error("the refused reset reset the machine")
+	}
+	if code, _ := do(t, s, "POST", "/pause"); code != http.StatusOK || !emu.Paused() {
+		t.Errorf("pause: got %d, paused %v", code, emu.Paused())
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	s, _ := newServer(t)
+	tests := []struct {
+		origin string
+		code   int
+	}{
+		{"", http.StatusOK}, // Not from a browser
+		{"http://example.com", http.StatusOK},
+		{"https://example.com", http.StatusOK},
+		{"http://evil.example", http.StatusForbidden},
+		{"http://example.com.evil.example", http.StatusForbidden},
+		{"null", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		// httptest requests are for example.com
+		code, body := do(t, s, "POST", "/resume", "Origin", tt.origin)
+		if code != tt.code {
+			t.Errorf("Origin %q: got %d %s, want %d", tt.origin, code, body, tt.code)
+		}
+	}
+}
+
+func TestLoadPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	roms := filepath.Join(dir, "roms")
+	for _, path := range []string{filepath.Join(roms, "games", "pong.ch8"), filepath.Join(dir, "secret")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte{0x12, 0x00}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	linked := os.Symlink(filepath.Join(dir, "secret"), filepath.Join(roms, "link.ch8")) == nil
+
+	s, _ := newServer(t)
+	var loaded []string
+	s.LoadFile = func(path string) error {
+		loaded = append(loaded, path)
+		return nil
+	}
+	if code, _ := do(t, s, "POST", "/load?path=games/pong.ch8"); code != http.StatusForbidden {
+		t.Errorf("without a ROM directory: got %d, want 403", code)
+	}
+
+	s.ROMDir = roms
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"games/pong.ch8", http.StatusOK},
+		{"/games/pong.ch8", http.StatusOK}, // Still inside
+		{"games/../games/pong.ch8", http.StatusOK},
+		{"../secret", http.StatusForbidden},
+		{"games/../../secret", http.StatusForbidden},
+		{"missing.ch8", http.StatusNotFound},
+	}
+	if linked {
+		tests = append(tests, struct {
+			path string
+			code int
+		}{"link.ch8", http.StatusForbidden})
+	}
+	for _, tt := range tests {
+		if code, body := do(t, s, "POST", "/load?path="+tt.path); code != tt.code {
+			t.Errorf("%s: got %d %s, want %d", tt.path, code, body, tt.code)
+		}
+	}
+	if len(loaded) != 3 || filepath.Base(loaded[0]) != "pong.ch8" {
+		t.Errorf("loaded %v", loaded)
+	}
+}
+
+func TestLoadBody(t *testing.T) {
+	s, emu := newServer(t)
+	post := func(size int) int {
+		rom := make([]byte, size)
+		rom[0], rom[1] = 0x12, 0x00 // JP 0x200
+		r := httptest.NewRequest("POST", "/load", strings.NewReader(string(rom)))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := post(4096 - 0x200); code != http.StatusOK {
+		t.Errorf("3584 bytes: got %d, want 200", code)
+	}
+	if code := post(4096 - 0x200 + 1); code == http.StatusOK {
+		t.Errorf("3585 bytes: got %d, want an error", code)
+	}
+
+	// MegaChip ROMs can be much larger
+	mega, _ := chip8.LookupProfile("megachip")
+	emu.SetProfile(mega)
+	if code := post(64 * 1024); code != http.StatusOK {
+		t.Errorf("64K MegaChip ROM: got %d, want 200", code)
+	}
+	if code := post(emu.MaxRomSize() + 1); code == http.StatusOK {
+		t.Errorf("%d byte MegaChip ROM: got %d, want an error", emu.MaxRomSize()+1, code)
+	}
+}
@@ -0,0 +1,174 @@
+package api
+
+import (
+	_ "embed" // For the spectator page
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+/*
+Spectating streams the screen and sound of a machine over a WebSocket, for watching it in a
+browser. Every message is binary and starts with its type:
+
+	'F' width uint16, height uint16, bits uint8, rows   the whole screen
+	'R' (y uint16, row)...                              rows that changed since the last message
+	'A' on uint8, pitch uint8, loaded uint8, pattern    the sound, whenever it changes
+
+Multi-byte values are big-endian. A row is the row's pixel values packed bits to a pixel (1 for
+CHIP-8, 2 for XO-CHIP's two planes, 8 otherwise), highest bits first and padded to a whole byte.
+pattern is the 16 byte XO-CHIP audio pattern, played at chip8.PatternRate(pitch) while on when
+loaded is set, and a plain beep otherwise. A new spectator starts with an 'F' and an 'A'.
+*/
+
+// maxSpectators is how many spectators can watch at once
+const maxSpectators = 32
+
+//go:embed spectate.html
+var spectatePage []byte
+
+// Spectator serves a machine for watching: GET / is a page that draws the screen and plays
+// the sound, GET /stream the WebSocket stream it watches. It can't change the machine.
+type Spectator struct {
+	emu      *chip8.Chip8
+	watchers int32
+}
+
+// NewSpectator creates a Spectator for emu
+func NewSpectator(emu *chip8.Chip8) *Spectator {
+	return &Spectator{emu: emu}
+}
+
+// Watchers returns how many spectators are watching
+func (sp *Spectator) Watchers() int {
+	return int(atomic.LoadInt32(&sp.watchers))
+}
+
+func (sp *Spectator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, httpError{http.StatusMethodNotAllowed, fmt.Errorf("%v requires GET", r.URL.Path)})
+		return
+	}
+	switch r.URL.Path {
+	case "/", "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(spectatePage)
+	case "/stream":
+		if err := sp.stream(w, r); err != nil {
+			writeError(w, err)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// stream sends the machine to a spectator until they leave
+func (sp *Spectator) stream(w http.ResponseWriter, r *http.Request) error {
+	if atomic.AddInt32(&sp.watchers, 1) > maxSpectators {
+		atomic.AddInt32(&sp.watchers, -1)
+		return httpError{http.StatusServiceUnavailable, fmt.Errorf("too many spectators")}
+	}
+	defer atomic.AddInt32(&sp.watchers, -1)
+	ws, err := upgrade(w, r)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	ticker := time.NewTicker(time.Second / chip8.FrameRate)
+	defer ticker.Stop()
+	var screen *chip8.Framebuffer
+	var sound []byte
+	for {
+		current := sp.emu.PeekScreen()
+		if msg := screenUpdate(screen, current); msg != nil {
+			if err := ws.write(opBinary, msg); err != nil {
+				return nil
+			}
+		}
+		screen = current
+		if msg := soundMessage(sp.emu); string(msg) != string(sound) {
+			if err := ws.write(opBinary, msg); err != nil {
+				return nil
+			}
+			sound = msg
+		}
+		select {
+		case <-ws.closed:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// screenUpdate returns the message bringing a spectator from old (nil for nothing yet) to
+// screen, or nil when nothing changed
+func screenUpdate(old, screen *chip8.Framebuffer) []byte {
+	if old == nil || old.Width != screen.Width || old.Height != screen.Height || pixelBits(old) != pixelBits(screen) {
+		msg := []byte{'F', 0, 0, 0, 0, pixelBits(screen)}
+		binary.BigEndian.PutUint16(msg[1:], uint16(screen.Width))
+		binary.BigEndian.PutUint16(msg[3:], uint16(screen.Height))
+		for y := 0; y < screen.Height; y++ {
+			msg = appendRow(msg, screen, y)
+		}
+		return msg
+	}
+	var msg []byte
+	for y := 0; y < screen.Height; y++ {
+		row := screen.Pix[y*screen.Width : (y+1)*screen.Width]
+		if string(row) == string(old.Pix[y*old.Width:(y+1)*old.Width]) {
+			continue
+		}
+		if msg == nil {
+			msg = []byte{'R'}
+		}
+		msg = append(msg, byte(y>>8), byte(y))
+		msg = appendRow(msg, screen, y)
+	}
+	return msg
+}
+
+// pixelBits returns how many bits the stream spends on each of screen's pixels
+func pixelBits(screen *chip8.Framebuffer) byte {
+	switch {
+	case screen.Planes <= 1 && screen.Palette == nil:
+		return 1
+	case screen.Planes <= 2 && screen.Palette == nil:
+		return 2
+	}
+	return 8
+}
+
+// appendRow packs row y of screen onto msg
+func appendRow(msg []byte, screen *chip8.Framebuffer, y int) []byte {
+	bits := uint(pixelBits(screen))
+	var b byte
+	used := uint(0)
+	for _, v := range screen.Pix[y*screen.Width : (y+1)*screen.Width] {
+		b = b<<bits | v&byte(1<<bits-1)
+		if used += bits; used == 8 {
+			msg = append(msg, b)
+			b, used = 0, 0
+		}
+	}
+	if used > 0 {
+		msg = append(msg, b<<(8-used))
+	}
+	return msg
+}
+
+func soundMessage(emu *chip8.Chip8) []byte {
+	pattern, pitch, loaded := emu.AudioPattern()
+	msg := []byte{'A', 0, pitch, 0}
+	if emu.Registers().ST > 0 {
+		msg[1] = 1
+	}
+	if loaded {
+		msg[3] = 1
+	}
+	return append(msg, pattern[:]...)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// readFrame reads a server frame, which is never masked
+func readFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	var h [2]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := int(h[1])
+	if n == 126 {
+		var b [2]byte
+		io.ReadFull(r, b[:])
+		n = int(b[0])<<8 | int(b[1])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return h[0] & 0xF, payload
+}
+
+func TestSpectate(t *testing.T) {
+	emu := chip8.NewChip8()
+	// Draw the font's 0 at (0, 0) and wait
+	if _, err := emu.LoadRomBytes([]byte{0x60, 0x00, 0xF0, 0x29, 0xD0, 0x05, 0x12, 0x06}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(New(emu, nil))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /watch/stream HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %v", resp.Status)
+	}
+	// The example from RFC 6455
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("got Sec-WebSocket-Accept %q", got)
+	}
+
+	op, msg := readFrame(t, r)
+	if op != opBinary || len(msg) != 6+32*8 || string(msg[:6]) != "F\x00\x40\x00\x20\x01" {
+		t.Fatalf("got op %d, %d byte message starting %q", op, len(msg), msg[:6])
+	}
+	if _, msg = readFrame(t, r); msg[0] != 'A' || msg[1] != 0 {
+		t.Errorf("got sound message %v", msg)
+	}
+
+	if err := emu.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	// The 0 is 0xF0 0x90 0x90 0x90 0xF0 in the first byte of rows 0-4
+	_, msg = readFrame(t, r)
+	want := "R"
+	for y, b := range []byte{0xF0, 0x90, 0x90, 0x90, 0xF0} {
+		want += string([]byte{0, byte(y), b, 0, 0, 0, 0, 0, 0, 0})
+	}
+	if string(msg) != want {
+		t.Errorf("got %x, want %x", msg, want)
+	}
+}
+
+func TestSpectateRequiresUpgrade(t *testing.T) {
+	srv := httptest.NewServer(NewSpectator(chip8.NewChip8()))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %v", resp.Status)
+	}
+	if resp, err = http.Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("got status %v, content type %v", resp.Status, resp.Header.Get("Content-Type"))
+	}
+}
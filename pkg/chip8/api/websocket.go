@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// The parts of RFC 6455 a server that only sends needs: the handshake, unmasked binary frames
+// out, and reading (and mostly discarding) the client's frames to notice when it goes away.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// maxClientFrame is the largest frame accepted from a client, which has nothing to say
+const maxClientFrame = 4096
+
+// websocket is a server side WebSocket connection
+type websocket struct {
+	conn   net.Conn
+	rw     *bufio.ReadWriter
+	mu     sync.Mutex // Serializes writes
+	closed chan struct{}
+}
+
+// upgrade answers a WebSocket handshake and takes over the connection. On failure it has
+// already replied with an error.
+func upgrade(w http.ResponseWriter, r *http.Request) (*websocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	switch {
+	case !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket"):
+		return nil, fmt.Errorf("expected a WebSocket upgrade")
+	case r.Header.Get("Sec-WebSocket-Version") != "13":
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, httpError{http.StatusUpgradeRequired, fmt.Errorf("unsupported WebSocket version")}
+	case key == "":
+		return nil, fmt.Errorf("missing Sec-WebSocket-Key")
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, httpError{http.StatusInternalServerError, fmt.Errorf("the connection can't be upgraded")}
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, httpError{http.StatusInternalServerError, err}
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ws := &websocket{conn: conn, rw: rw, closed: make(chan struct{})}
+	go ws.read()
+	return ws, nil
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// write sends one unfragmented frame
+func (ws *websocket) write(op byte, payload []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	header := []byte{0x80 | op, 0}
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = append(header, byte(n>>8), byte(n))
+	default:
+		header[1] = 127
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	ws.rw.Write(header)
+	ws.rw.Write(payload)
+	return ws.rw.Flush()
+}
+
+// read handles the client's frames until it closes the connection or breaks the protocol
+func (ws *websocket) read() {
+	defer close(ws.closed)
+	for {
+		var h [2]byte
+		if _, err := io.ReadFull(ws.rw, h[:]); err != nil {
+			return
+		}
+		op := h[0] & 0xF
+		n := uint64(h[1] & 0x7F)
+		switch n {
+		case 126:
+			var b [2]byte
+			if _, err := io.ReadFull(ws.rw, b[:]); err != nil {
+				return
+			}
+			n = uint64(binary.BigEndian.Uint16(b[:]))
+		case 127:
+			var b [8]byte
+			if _, err := io.ReadFull(ws.rw, b[:]); err != nil {
+				return
+			}
+			n = binary.BigEndian.Uint64(b[:])
+		}
+		if h[1]&0x80 == 0 || n > maxClientFrame { // Clients must mask their frames
+			ws.write(opClose, []byte{0x03, 0xEA}) // 1002, protocol error
+			return
+		}
+		var mask [4]byte
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(ws.rw, mask[:]); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(ws.rw, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+		switch op {
+		case opClose:
+			ws.write(opClose, nil)
+			return
+		case opPing:
+			ws.write(opPong, payload)
+		}
+	}
+}
+
+// Close closes the connection without the closing handshake
+func (ws *websocket) Close() error {
+	return ws.conn.Close()
+}
@@ -0,0 +1,331 @@
+// Package asm assembles CHIP-8 source into a .ch8 binary.
+//
+// The syntax mirrors the output of package disasm (Cowgod's mnemonics), one instruction per line:
+//
+//	; comments start with a semicolon
+//	start:  LD   V0, 0x0A        ; labels end with a colon
+//	        LD   I, sprite       ; labels can be used anywhere an address or byte is expected
+//	        DRW  V0, V0, 5
+//	loop:   JP   loop
+//	sprite: db   0xF0, 0x90, 0x90, 0x90, 0xF0
+//	        dw   0x1234
+//
+// Numbers may be written in decimal, hex (0x), octal (0o) or binary (0b).
+// Programs are assembled for the standard 0x200 entry point.
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+// Origin is the address the first assembled byte will be loaded at
+const Origin = 0x200
+
+// Error describes a problem with a single line of source
+type Error struct {
+	Line int    // 1-based source line
+	Msg  string // What went wrong
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// statement is a single parsed line that emits bytes
+type statement struct {
+	line     int
+	mnemonic string   // upper-cased mnemonic or directive
+	operands []string // raw operand text
+	addr     int      // address assigned during the first pass
+	size     int      // bytes emitted
+}
+
+// Assemble assembles src into a ROM image that can be passed to Chip8.LoadRomBytes
+func Assemble(src string) ([]byte, error) {
+	rom, _, err := AssembleSymbols(src)
+	return rom, err
+}
+
+// AssembleSymbols is Assemble, also returning the labels for debugging
+func AssembleSymbols(src string) ([]byte, *symbols.Table, error) {
+	labels := map[string]int{}
+	var stmts []*statement
+
+	// Pass 1: tokenize, size each statement and assign label addresses
+	addr := Origin
+	for i, raw := range strings.Split(src, "\n") {
+		lineNo := i + 1
+		line := raw
+		if idx := strings.IndexByte(line, ';'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+
+		for {
+			idx := strings.IndexByte(line, ':')
+			if idx < 0 {
+				break
+			}
+			label := strings.TrimSpace(line[:idx])
+			if !isIdent(label) {
+				return nil, nil, &Error{lineNo, fmt.Sprintf("invalid label %q", label)}
+			}
+			if _, ok := labels[label]; ok {
+				return nil, nil, &Error{lineNo, fmt.Sprintf("duplicate label %q", label)}
+			}
+			labels[label] = addr
+			line = strings.TrimSpace(line[idx+1:])
+		}
+		if line == "" {
+			continue
+		}
+
+		mnemonic, rest := line, ""
+		if idx := strings.IndexAny(line, " \t"); idx >= 0 {
+			mnemonic, rest = line[:idx], strings.TrimSpace(line[idx:])
+		}
+		st := &statement{line: lineNo, mnemonic: strings.ToUpper(mnemonic), addr: addr}
+		if rest != "" {
+			for _, op := range strings.Split(rest, ",") {
+				st.operands = append(st.operands, strings.TrimSpace(op))
+			}
+		}
+
+		switch st.mnemonic {
+		case "DB":
+			st.size = len(st.operands)
+		case "DW":
+			st.size = 2 * len(st.operands)
+		default:
+			st.size = 2
+		}
+		if st.size == 0 {
+			return nil, nil, &Error{lineNo, fmt.Sprintf("%s needs at least one value", st.mnemonic)}
+		}
+		addr += st.size
+		stmts = append(stmts, st)
+	}
+	if addr > 0x1000 {
+		return nil, nil, fmt.Errorf("program is %d bytes, larger than the %d bytes available", addr-Origin, 0x1000-Origin)
+	}
+
+	// Pass 2: encode
+	out := make([]byte, 0, addr-Origin)
+	for _, st := range stmts {
+		a := assembler{labels: labels, st: st}
+		b, err := a.encode()
+		if err != nil {
+			return nil, nil, &Error{st.line, err.Error()}
+		}
+		out = append(out, b...)
+	}
+	return out, symbols.FromMap(labels), nil
+}
+
+type assembler struct {
+	labels map[string]int
+	st     *statement
+}
+
+func (a *assembler) encode() ([]byte, error) {
+	st := a.st
+	switch st.mnemonic {
+	case "DB":
+		out := make([]byte, 0, len(st.operands))
+		for _, op := range st.operands {
+			v, err := a.value(op, 0xFF)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, byte(v))
+		}
+		return out, nil
+	case "DW":
+		out := make([]byte, 0, 2*len(st.operands))
+		for _, op := range st.operands {
+			v, err := a.value(op, 0xFFFF)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, byte(v>>8), byte(v))
+		}
+		return out, nil
+	}
+
+	op, err := a.opcode()
+	if err != nil {
+		return nil, err
+	}
+	return []byte{byte(op >> 8), byte(op)}, nil
+}
+
+func (a *assembler) opcode() (uint16, error) {
+	st := a.st
+	ops := st.operands
+	kinds := make([]string, len(ops))
+	for i, op := range ops {
+		kinds[i] = operandKind(op)
+	}
+	sig := strings.Join(kinds, ",")
+
+	reg := func(i int) uint16 {
+		r, _ := strconv.ParseUint(ops[i][1:], 16, 8)
+		return uint16(r)
+	}
+	xy := func(base uint16) uint16 {
+		return base | reg(0)<<8 | reg(1)<<4
+	}
+	xkk := func(base uint16) (uint16, error) {
+		v, err := a.value(ops[1], 0xFF)
+		return base | reg(0)<<8 | uint16(v), err
+	}
+	nnn := func(base uint16, i int) (uint16, error) {
+		v, err := a.value(ops[i], 0xFFF)
+		return base | uint16(v), err
+	}
+
+	switch st.mnemonic + " " + sig {
+	case "CLS ":
+		return 0x00E0, nil
+	case "RET ":
+		return 0x00EE, nil
+	case "SYS n":
+		return nnn(0x0000, 0)
+	case "JP n":
+		return nnn(0x1000, 0)
+	case "JP V,n":
+		if reg(0) != 0 {
+			return 0, fmt.Errorf("JP only supports V0 as an offset register")
+		}
+		return nnn(0xB000, 1)
+	case "CALL n":
+		return nnn(0x2000, 0)
+	case "SE V,n":
+		return xkk(0x3000)
+	case "SNE V,n":
+		return xkk(0x4000)
+	case "SE V,V":
+		return xy(0x5000), nil
+	case "LD V,n":
+		return xkk(0x6000)
+	case "ADD V,n":
+		return xkk(0x7000)
+	case "LD V,V":
+		return xy(0x8000), nil
+	case "OR V,V":
+		return xy(0x8001), nil
+	case "AND V,V":
+		return xy(0x8002), nil
+	case "XOR V,V":
+		return xy(0x8003), nil
+	case "ADD V,V":
+		return xy(0x8004), nil
+	case "SUB V,V":
+		return xy(0x8005), nil
+	case "SHR V":
+		return 0x8006 | reg(0)<<8, nil
+	case "SHR V,V":
+		return xy(0x8006), nil
+	case "SUBN V,V":
+		return xy(0x8007), nil
+	case "SHL V":
+		return 0x800E | reg(0)<<8, nil
+	case "SHL V,V":
+		return xy(0x800E), nil
+	case "SNE V,V":
+		return xy(0x9000), nil
+	case "LD I,n":
+		return nnn(0xA000, 1)
+	case "RND V,n":
+		return xkk(0xC000)
+	case "DRW V,V,n":
+		v, err := a.value(ops[2], 0xF)
+		return xy(0xD000) | uint16(v), err
+	case "SKP V":
+		return 0xE09E | reg(0)<<8, nil
+	case "SKNP V":
+		return 0xE0A1 | reg(0)<<8, nil
+	case "LD V,DT":
+		return 0xF007 | reg(0)<<8, nil
+	case "LD V,K":
+		return 0xF00A | reg(0)<<8, nil
+	case "LD DT,V":
+		return 0xF015 | reg(1)<<8, nil
+	case "LD ST,V":
+		return 0xF018 | reg(1)<<8, nil
+	case "ADD I,V":
+		return 0xF01E | reg(1)<<8, nil
+	case "LD F,V":
+		return 0xF029 | reg(1)<<8, nil
+	case "LD B,V":
+		return 0xF033 | reg(1)<<8, nil
+	case "LD [I],V":
+		return 0xF055 | reg(1)<<8, nil
+	case "LD V,[I]":
+		return 0xF065 | reg(0)<<8, nil
+	case "AUDIO ":
+		return 0xF002, nil
+	case "LD PITCH,V":
+		return 0xF03A | reg(1)<<8, nil
+	case "LD R,V":
+		return 0xF075 | reg(1)<<8, nil
+	case "LD V,R":
+		return 0xF085 | reg(0)<<8, nil
+	}
+
+	if st.operands == nil {
+		return 0, fmt.Errorf("unknown instruction %q", st.mnemonic)
+	}
+	return 0, fmt.Errorf("unknown instruction %q with operands %q", st.mnemonic, strings.Join(ops, ", "))
+}
+
+// value resolves a number or label, checking it fits in max
+func (a *assembler) value(op string, max int) (int, error) {
+	var v int
+	if addr, ok := a.labels[op]; ok {
+		v = addr
+	} else {
+		n, err := strconv.ParseInt(op, 0, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", op)
+		}
+		v = int(n)
+	}
+	if v < 0 || v > max {
+		return 0, fmt.Errorf("value %s out of range (max 0x%X)", op, max)
+	}
+	return v, nil
+}
+
+// operandKind classifies an operand as a register (V), one of the special
+// operands (I, DT, ST, K, F, B, [I], PITCH, R) or a value (n)
+func operandKind(op string) string {
+	u := strings.ToUpper(op)
+	switch u {
+	case "I", "DT", "ST", "K", "F", "B", "[I]", "PITCH", "R":
+		return u
+	}
+	if len(u) == 2 && u[0] == 'V' && strings.IndexByte("0123456789ABCDEF", u[1]) >= 0 {
+		return "V"
+	}
+	return "n"
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return operandKind(s) == "n"
+}
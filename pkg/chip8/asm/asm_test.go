@@ -0,0 +1,171 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []byte
+	}{
+		{"CLS", []byte{0x00, 0xE0}},
+		{"RET", []byte{0x00, 0xEE}},
+		{"SYS 0x123", []byte{0x01, 0x23}},
+		{"JP 0x208", []byte{0x12, 0x08}},
+		{"JP V0, 0x300", []byte{0xB3, 0x00}},
+		{"CALL 0xABC", []byte{0x2A, 0xBC}},
+		{"SE VA, 0x02", []byte{0x3A, 0x02}},
+		{"SNE VB, 3", []byte{0x4B, 0x03}},
+		{"SE V1, V2", []byte{0x51, 0x20}},
+		{"LD VA, 0b1010", []byte{0x6A, 0x0A}},
+		{"ADD VF, 0o17", []byte{0x7F, 0x0F}},
+		{"LD V1, V2", []byte{0x81, 0x20}},
+		{"OR V1, V2", []byte{0x81, 0x21}},
+		{"AND V1, V2", []byte{0x81, 0x22}},
+		{"XOR V1, V2", []byte{0x81, 0x23}},
+		{"ADD V1, V2", []byte{0x81, 0x24}},
+		{"SUB V1, V2", []byte{0x81, 0x25}},
+		{"SHR V1", []byte{0x81, 0x06}},
+		{"SHR V1, V2", []byte{0x81, 0x26}},
+		{"SUBN V1, V2", []byte{0x81, 0x27}},
+		{"SHL V1", []byte{0x81, 0x0E}},
+		{"SHL V1, V2", []byte{0x81, 0x2E}},
+		{"SNE V1, V2", []byte{0x91, 0x20}},
+		{"LD I, 0x300", []byte{0xA3, 0x00}},
+		{"RND V1, 0xFF", []byte{0xC1, 0xFF}},
+		{"DRW V1, V2, 15", []byte{0xD1, 0x2F}},
+		{"SKP V3", []byte{0xE3, 0x9E}},
+		{"SKNP V3", []byte{0xE3, 0xA1}},
+		{"LD V3, DT", []byte{0xF3, 0x07}},
+		{"LD V3, K", []byte{0xF3, 0x0A}},
+		{"LD DT, V3", []byte{0xF3, 0x15}},
+		{"LD ST, V3", []byte{0xF3, 0x18}},
+		{"ADD I, V3", []byte{0xF3, 0x1E}},
+		{"LD F, V3", []byte{0xF3, 0x29}},
+		{"LD B, V3", []byte{0xF3, 0x33}},
+		{"LD [I], V3", []byte{0xF3, 0x55}},
+		{"LD V3, [I]", []byte{0xF3, 0x65}},
+		{"AUDIO", []byte{0xF0, 0x02}},
+		{"LD PITCH, V3", []byte{0xF3, 0x3A}},
+		{"LD R, V3", []byte{0xF3, 0x75}},
+		{"LD V3, R", []byte{0xF3, 0x85}},
+		{"ld va, 0x02 ; lower case, with a comment", []byte{0x6A, 0x02}},
+		{"DB 1, 0x02, 0b11", []byte{0x01, 0x02, 0x03}},
+		{"DW 0x1234, 5", []byte{0x12, 0x34, 0x00, 0x05}},
+	}
+	for _, tt := range tests {
+		got, err := Assemble(tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%q: got % X, want % X", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestLabels(t *testing.T) {
+	rom, syms, err := AssembleSymbols(`
+start:  CALL draw        ; forward reference
+        JP   start       ; backward reference
+draw:
+again:  LD   I, sprite
+        SE   V0, 0x0C
+        RET
+sprite: DB   0xF0, 0x90
+len:    DW   start`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x22, 0x04, 0x12, 0x00, 0xA2, 0x0A, 0x30, 0x0C, 0x00, 0xEE, 0xF0, 0x90, 0x02, 0x00}
+	if !bytes.Equal(rom, want) {
+		t.Errorf("got % X, want % X", rom, want)
+	}
+	for name, addr := range map[string]uint16{"start": 0x200, "draw": 0x204, "again": 0x204, "sprite": 0x20A, "len": 0x20C} {
+		if got, ok := syms.Addr(name); !ok || got != addr {
+			t.Errorf("%s: got 0x%03X, %v, want 0x%03X", name, got, ok, addr)
+		}
+	}
+	if syms.Len() != 5 {
+		t.Errorf("got %d symbols, want 5", syms.Len())
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		line int // 0 for errors about the whole program
+		want string
+	}{
+		{"CLS\nFOO V1", 2, `unknown instruction "FOO"`},
+		{"JP V1, 0x300", 1, "only supports V0"},
+		{"LD I, V1", 1, `unknown instruction "LD" with operands "I, V1"`},
+		{"DRW V1, V2", 1, "unknown instruction"},
+		{"LD V0, 256", 1, "value 256 out of range (max 0xFF)"},
+		{"LD V0, -1", 1, "out of range"},
+		{"JP 0x1000", 1, "out of range (max 0xFFF)"},
+		{"DRW V0, V1, 16", 1, "out of range (max 0xF)"},
+		{"DB 0x100", 1, "out of range"},
+		{"DW 0x10000", 1, "out of range"},
+		{"LD V0, 12z", 1, `invalid value "12z"`},
+		{"JP nowhere", 1, `invalid value "nowhere"`},
+		{"here: LD V0, here", 1, "value here out of range (max 0xFF)"},
+		{"DB", 1, "DB needs at least one value"},
+		{"1st: CLS", 1, `invalid label "1st"`},
+		{"V0: CLS", 1, `invalid label "V0"`},
+		{"a: CLS\n\na: RET", 3, `duplicate label "a"`},
+		{"DB " + strings.Repeat("0, ", 3584) + "0", 0, "larger than the 3584 bytes available"},
+	}
+	for _, tt := range tests {
+		_, err := Assemble(tt.src)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%.20q: got %v, want %q", tt.src, err, tt.want)
+			continue
+		}
+		line := 0
+		if e, ok := err.(*Error); ok {
+			line = e.Line
+		}
+		if line != tt.line {
+			t.Errorf("%.20q: error on line %d, want %d", tt.src, line, tt.line)
+		}
+	}
+}
+
+// TestRoundTrip assembles the disassembly of every opcode back into the same opcode
+func TestRoundTrip(t *testing.T) {
+	var src strings.Builder
+	rom := make([]byte, 0, 2*0x10000)
+	for op := 0; op <= 0xFFFF; op++ {
+		fmt.Fprintln(&src, disasm.Decode(Origin, uint16(op)).Text())
+		rom = append(rom, byte(op>>8), byte(op))
+	}
+	// In chunks that fit in memory
+	lines := strings.SplitAfter(src.String(), "\n")
+	const chunk = 0x600
+	for start := 0; start < 0x10000; start += chunk {
+		end := start + chunk
+		if end > 0x10000 {
+			end = 0x10000
+		}
+		got, err := Assemble(strings.Join(lines[start:end], ""))
+		if err != nil {
+			t.Fatalf("opcodes %04X-%04X: %v", start, end-1, err)
+		}
+		if want := rom[2*start : 2*end]; !bytes.Equal(got, want) {
+			for i := 0; i < len(got) && i < len(want); i += 2 {
+				if got[i] != want[i] || got[i+1] != want[i+1] {
+					t.Fatalf("%q assembled to %02X%02X, want %02X%02X", strings.TrimSpace(lines[start+i/2]), got[i], got[i+1], want[i], want[i+1])
+				}
+			}
+			t.Fatalf("opcodes %04X-%04X: got %d bytes, want %d", start, end-1, len(got), len(want))
+		}
+	}
+}
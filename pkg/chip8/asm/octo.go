@@ -0,0 +1,784 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+/*
+Octo:
+
+AssembleOcto compiles the source of John Earnest's Octo (.o8 files), the language most modern
+CHIP-8, SCHIP and XO-CHIP programs are written in:
+
+	# comments start with a hash
+	: main                      # labels start with a colon, execution starts at main
+		v0 := 10  i := sprite
+		loop
+			sprite v0 v1 5
+			v0 += 1
+			if v0 == 20 then v0 := 0
+			while v2 != 0
+		again
+	: sprite 0xF0 0x90 0x90 0x90 0xF0
+
+A program runs from main. Unless it starts with main, a jump to main is put at 0x200.
+
+Supported are all the instructions, if ... then, if ... begin / else / end, loop / while / again
+(with the < > <= >= comparisons, which use vF), bare numbers as data, calling a subroutine by
+naming it, and the :const, :alias, :org, :next, :unpack, :byte and :call directives.
+:breakpoint and :monitor are accepted and ignored. Macros (:macro, :calc, :stringmode) aren't
+supported and are reported as errors.
+*/
+
+// octoMaxAddr is the end of XO-CHIP's 64K address space, reached with i := long
+const octoMaxAddr = 0x10000
+
+type octoToken struct {
+	text string
+	line int
+}
+
+// octoRefKind is what kind of address a forward reference patches in
+type octoRefKind int
+
+const (
+	refAddr   octoRefKind = iota // The nnn of an instruction
+	refLong                      // The 16-bit word after i := long
+	refUnpack                    // :unpack, the low nibble of v0's byte and all of v1's
+)
+
+type octoRef struct {
+	addr int
+	kind octoRefKind
+	tok  octoToken
+}
+
+// octoBlock is an open if ... begin, else or loop
+type octoBlock struct {
+	kind   string
+	addr   int   // Of the jump to patch for if and else, of the start for loop
+	whiles []int // Jumps out of a loop to patch at again
+}
+
+type octoAssembler struct {
+	toks    []octoToken
+	pos     int
+	rom     []byte // From Origin
+	here    int
+	labels  map[string]int
+	consts  map[string]int
+	aliases map[string]uint16
+	refs    []octoRef
+	blocks  []*octoBlock
+}
+
+// AssembleOcto assembles Octo source into a ROM image that can be passed to Chip8.LoadRomBytes
+func AssembleOcto(src string) ([]byte, error) {
+	rom, _, err := AssembleOctoSymbols(src)
+	return rom, err
+}
+
+// AssembleOctoSymbols is AssembleOcto, also returning the labels for debugging
+func AssembleOctoSymbols(src string) ([]byte, *symbols.Table, error) {
+	a := &octoAssembler{
+		toks:    octoTokens(src),
+		here:    Origin,
+		labels:  map[string]int{},
+		consts:  map[string]int{},
+		aliases: map[string]uint16{},
+	}
+	// Programs start with a jump to main, unless that's where they begin anyway
+	jumpToMain := len(a.toks) < 2 || a.toks[0].text != ":" || a.toks[1].text != "main"
+	if jumpToMain {
+		a.emit(0x10, 0x00)
+	}
+	for a.pos < len(a.toks) {
+		if err := a.statement(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(a.blocks) > 0 {
+		b := a.blocks[len(a.blocks)-1]
+		if b.kind == "loop" {
+			return nil, nil, fmt.Errorf("loop without again")
+		}
+		return nil, nil, fmt.Errorf("if ... begin without end")
+	}
+	main, ok := a.labels["main"]
+	if !ok {
+		return nil, nil, fmt.Errorf("the program doesn't define main")
+	}
+	if jumpToMain {
+		a.jumpTo(Origin, main)
+	}
+	for _, ref := range a.refs {
+		addr, ok := a.labels[ref.tok.text]
+		if !ok {
+			return nil, nil, &Error{ref.tok.line, fmt.Sprintf("undefined label %q", ref.tok.text)}
+		}
+		if err := a.patch(ref, addr); err != nil {
+			return nil, nil, err
+		}
+	}
+	return a.rom, symbols.FromMap(a.labels), nil
+}
+
+// octoTokens splits src at whitespace, dropping comments
+func octoTokens(src string) []octoToken {
+	var toks []octoToken
+	for i, line := range strings.Split(src, "\n") {
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		for _, f := range strings.Fields(line) {
+			toks = append(toks, octoToken{f, i + 1})
+		}
+	}
+	return toks
+}
+
+func (a *octoAssembler) next() (octoToken, error) {
+	if a.pos >= len(a.toks) {
+		line := 0
+		if len(a.toks) > 0 {
+			line = a.toks[len(a.toks)-1].line
+		}
+		return octoToken{}, &Error{line, "unexpected end of program"}
+	}
+	t := a.toks[a.pos]
+	a.pos++
+	return t, nil
+}
+
+// expect consumes the token s
+func (a *octoAssembler) expect(s string) error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	if t.text != s {
+		return &Error{t.line, fmt.Sprintf("expected %q, got %q", s, t.text)}
+	}
+	return nil
+}
+
+func (a *octoAssembler) emit(b ...byte) error {
+	if a.here+len(b) > octoMaxAddr {
+		return fmt.Errorf("program runs past the end of memory")
+	}
+	end := a.here - Origin + len(b)
+	if end > len(a.rom) {
+		a.rom = append(a.rom, make([]byte, end-len(a.rom))...)
+	}
+	copy(a.rom[a.here-Origin:], b)
+	a.here += len(b)
+	return nil
+}
+
+func (a *octoAssembler) op(op uint16) error {
+	return a.emit(byte(op>>8), byte(op))
+}
+
+// opAddr emits an nnn instruction for the address in t, patched later if it's a forward reference
+func (a *octoAssembler) opAddr(base uint16, t octoToken) error {
+	ref := octoRef{addr: a.here, kind: refAddr, tok: t}
+	if err := a.op(base); err != nil {
+		return err
+	}
+	return a.resolve(ref)
+}
+
+// resolve patches ref now when its address is known, or records it for the end
+func (a *octoAssembler) resolve(ref octoRef) error {
+	if v, ok := a.number(ref.tok.text); ok {
+		return a.patch(ref, v)
+	}
+	if v, ok := a.labels[ref.tok.text]; ok {
+		return a.patch(ref, v)
+	}
+	if !isOctoIdent(ref.tok.text) {
+		return &Error{ref.tok.line, fmt.Sprintf("invalid address %q", ref.tok.text)}
+	}
+	a.refs = append(a.refs, ref)
+	return nil
+}
+
+func (a *octoAssembler) patch(ref octoRef, addr int) error {
+	i := ref.addr - Origin
+	switch ref.kind {
+	case refAddr:
+		if addr < 0 || addr > 0xFFF {
+			return &Error{ref.tok.line, fmt.Sprintf("address %s (0x%X) doesn't fit in 12 bits", ref.tok.text, addr)}
+		}
+		a.rom[i] = a.rom[i]&0xF0 | byte(addr>>8)
+		a.rom[i+1] = byte(addr)
+	case refLong:
+		if addr < 0 || addr >= octoMaxAddr {
+			return &Error{ref.tok.line, fmt.Sprintf("address %s out of range", ref.tok.text)}
+		}
+		a.rom[i], a.rom[i+1] = byte(addr>>8), byte(addr)
+	case refUnpack:
+		a.rom[i+1] |= byte(addr >> 8 & 0xF)
+		a.rom[i+3] = byte(addr)
+	}
+	return nil
+}
+
+// number parses a numeric literal or a constant
+func (a *octoAssembler) number(s string) (int, bool) {
+	if v, ok := a.consts[s]; ok {
+		return v, true
+	}
+	n, err := strconv.ParseInt(s, 0, 32)
+	return int(n), err == nil
+}
+
+// value reads a number or constant that has to fit in min..max
+func (a *octoAssembler) value(min, max int) (int, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	v, ok := a.number(t.text)
+	if !ok {
+		return 0, &Error{t.line, fmt.Sprintf("invalid value %q", t.text)}
+	}
+	if v < min || v > max {
+		return 0, &Error{t.line, fmt.Sprintf("value %s out of range", t.text)}
+	}
+	return v, nil
+}
+
+// byteValue reads a value for an 8-bit field, which may be negative
+func (a *octoAssembler) byteValue() (uint16, error) {
+	v, err := a.value(-128, 255)
+	return uint16(byte(v)), err
+}
+
+func (a *octoAssembler) register(t octoToken) (uint16, bool) {
+	if r, ok := a.aliases[t.text]; ok {
+		return r, true
+	}
+	s := strings.ToLower(t.text)
+	if len(s) != 2 || s[0] != 'v' {
+		return 0, false
+	}
+	r, err := strconv.ParseUint(s[1:], 16, 8)
+	return uint16(r), err == nil
+}
+
+// nextRegister reads a register operand
+func (a *octoAssembler) nextRegister() (uint16, error) {
+	t, err := a.next()
+	if err != nil {
+		return 0, err
+	}
+	r, ok := a.register(t)
+	if !ok {
+		return 0, &Error{t.line, fmt.Sprintf("expected a register, got %q", t.text)}
+	}
+	return r, nil
+}
+
+func (a *octoAssembler) define(t octoToken) error {
+	if !isOctoIdent(t.text) {
+		return &Error{t.line, fmt.Sprintf("invalid name %q", t.text)}
+	}
+	if _, ok := a.register(t); ok {
+		return &Error{t.line, fmt.Sprintf("%q is a register", t.text)}
+	}
+	_, isLabel := a.labels[t.text]
+	_, isConst := a.consts[t.text]
+	if isLabel || isConst {
+		return &Error{t.line, fmt.Sprintf("%q is already defined", t.text)}
+	}
+	return nil
+}
+
+// lineErr attaches t's line to errors that don't have one yet
+func lineErr(t octoToken, err error) error {
+	if _, ok := err.(*Error); err == nil || ok {
+		return err
+	}
+	return &Error{t.line, err.Error()}
+}
+
+func (a *octoAssembler) statement() error {
+	t, err := a.next()
+	if err != nil {
+		return err
+	}
+	return lineErr(t, a.statementAt(t))
+}
+
+func (a *octoAssembler) statementAt(t octoToken) error {
+	if r, ok := a.register(t); ok {
+		return a.assign(r)
+	}
+	if v, ok := a.number(t.text); ok {
+		if v < -128 || v > 255 {
+			return fmt.Errorf("byte %s out of range", t.text)
+		}
+		return a.emit(byte(v))
+	}
+
+	switch t.text {
+	case ":":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if err := a.define(name); err != nil {
+			return err
+		}
+		a.labels[name.text] = a.here
+		return nil
+	case ":const":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if err := a.define(name); err != nil {
+			return err
+		}
+		v, err := a.value(-0x8000, 0xFFFF)
+		a.consts[name.text] = v
+		return err
+	case ":alias":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		r, err := a.nextRegister()
+		a.aliases[name.text] = r
+		return err
+	case ":org":
+		v, err := a.value(Origin, octoMaxAddr-1)
+		a.here = v
+		return err
+	case ":next":
+		name, err := a.next()
+		if err != nil {
+			return err
+		}
+		if err := a.define(name); err != nil {
+			return err
+		}
+		// The second byte of the next instruction, for self-modifying code
+		a.labels[name.text] = a.here + 1
+		return nil
+	case ":unpack":
+		nibble, err := a.value(0, 0xF)
+		if err != nil {
+			return err
+		}
+		label, err := a.next()
+		if err != nil {
+			return err
+		}
+		ref := octoRef{addr: a.here, kind: refUnpack, tok: label}
+		if err := a.emit(0x60, byte(nibble<<4), 0x61, 0); err != nil {
+			return err
+		}
+		return a.resolve(ref)
+	case ":byte":
+		v, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.emit(byte(v))
+	case ":call":
+		target, err := a.next()
+		if err != nil {
+			return err
+		}
+		return a.opAddr(0x2000, target)
+	case ":breakpoint":
+		_, err := a.next()
+		return err
+	case ":monitor":
+		if _, err := a.next(); err != nil {
+			return err
+		}
+		_, err := a.next()
+		return err
+	case ":macro", ":calc", ":stringmode", ":assert", ":pointer", ":proto":
+		return fmt.Errorf("%s is not supported", t.text)
+
+	case ";", "return":
+		return a.op(0x00EE)
+	case "clear":
+		return a.op(0x00E0)
+	case "hires":
+		return a.op(0x00FF)
+	case "lores":
+		return a.op(0x00FE)
+	case "scroll-right":
+		return a.op(0x00FB)
+	case "scroll-left":
+		return a.op(0x00FC)
+	case "exit":
+		return a.op(0x00FD)
+	case "audio":
+		return a.op(0xF002)
+	case "scroll-down", "scroll-up":
+		n, err := a.value(0, 0xF)
+		if err != nil {
+			return err
+		}
+		if t.text == "scroll-up" {
+			return a.op(0x00D0 | uint16(n))
+		}
+		return a.op(0x00C0 | uint16(n))
+	case "plane":
+		n, err := a.value(0, 0xF)
+		if err != nil {
+			return err
+		}
+		return a.op(0xF001 | uint16(n)<<8)
+	case "bcd", "saveflags", "loadflags":
+		r, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		return a.op(map[string]uint16{"bcd": 0xF033, "saveflags": 0xF075, "loadflags": 0xF085}[t.text] | r<<8)
+	case "save", "load":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		if a.pos < len(a.toks) && a.toks[a.pos].text == "-" {
+			a.pos++
+			y, err := a.nextRegister()
+			if err != nil {
+				return err
+			}
+			if t.text == "save" {
+				return a.op(0x5002 | x<<8 | y<<4)
+			}
+			return a.op(0x5003 | x<<8 | y<<4)
+		}
+		if t.text == "save" {
+			return a.op(0xF055 | x<<8)
+		}
+		return a.op(0xF065 | x<<8)
+	case "sprite":
+		x, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		y, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		n, err := a.value(0, 0xF)
+		if err != nil {
+			return err
+		}
+		return a.op(0xD000 | x<<8 | y<<4 | uint16(n))
+	case "jump", "jump0", "native":
+		target, err := a.next()
+		if err != nil {
+			return err
+		}
+		return a.opAddr(map[string]uint16{"jump": 0x1000, "jump0": 0xB000, "native": 0x0000}[t.text], target)
+	case "delay", "buzzer", "pitch":
+		if err := a.expect(":="); err != nil {
+			return err
+		}
+		r, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		return a.op(map[string]uint16{"delay": 0xF015, "buzzer": 0xF018, "pitch": 0xF03A}[t.text] | r<<8)
+	case "i":
+		return a.assignI()
+	case "if":
+		return a.ifStatement()
+	case "else":
+		b := a.top()
+		if b == nil || b.kind != "if" {
+			return fmt.Errorf("else without if ... begin")
+		}
+		jump := a.here
+		if err := a.op(0x1000); err != nil {
+			return err
+		}
+		a.jumpTo(b.addr, a.here)
+		b.kind, b.addr = "else", jump
+		return nil
+	case "end":
+		b := a.top()
+		if b == nil || (b.kind != "if" && b.kind != "else") {
+			return fmt.Errorf("end without if ... begin")
+		}
+		a.jumpTo(b.addr, a.here)
+		a.blocks = a.blocks[:len(a.blocks)-1]
+		return nil
+	case "loop":
+		a.blocks = append(a.blocks, &octoBlock{kind: "loop", addr: a.here})
+		return nil
+	case "while":
+		var loop *octoBlock
+		for i := len(a.blocks) - 1; i >= 0 && loop == nil; i-- {
+			if a.blocks[i].kind == "loop" {
+				loop = a.blocks[i]
+			}
+		}
+		if loop == nil {
+			return fmt.Errorf("while outside a loop")
+		}
+		if err := a.condition(true); err != nil {
+			return err
+		}
+		loop.whiles = append(loop.whiles, a.here)
+		return a.op(0x1000)
+	case "again":
+		b := a.top()
+		if b == nil || b.kind != "loop" {
+			return fmt.Errorf("again without loop")
+		}
+		if err := a.op(0x1000 | uint16(b.addr&0xFFF)); err != nil {
+			return err
+		}
+		for _, w := range b.whiles {
+			a.jumpTo(w, a.here)
+		}
+		a.blocks = a.blocks[:len(a.blocks)-1]
+		return nil
+	}
+
+	if !isOctoIdent(t.text) {
+		return fmt.Errorf("unexpected %q", t.text)
+	}
+	// A bare name calls the subroutine
+	return a.opAddr(0x2000, t)
+}
+
+func (a *octoAssembler) top() *octoBlock {
+	if len(a.blocks) == 0 {
+		return nil
+	}
+	return a.blocks[len(a.blocks)-1]
+}
+
+// jumpTo points the jump at addr to target
+func (a *octoAssembler) jumpTo(addr, target int) {
+	i := addr - Origin
+	a.rom[i] = 0x10 | byte(target>>8&0xF)
+	a.rom[i+1] = byte(target)
+}
+
+// assign compiles the statements starting with a register: vx := ..., vx += ... and so on
+func (a *octoAssembler) assign(x uint16) error {
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+	rhs, err := a.next()
+	if err != nil {
+		return err
+	}
+	if y, ok := a.register(rhs); ok {
+		ops := map[string]uint16{":=": 0x8000, "|=": 0x8001, "&=": 0x8002, "^=": 0x8003, "+=": 0x8004,
+			"-=": 0x8005, ">>=": 0x8006, "=-": 0x8007, "<<=": 0x800E}
+		base, ok := ops[opTok.text]
+		if !ok {
+			return &Error{opTok.line, fmt.Sprintf("unknown operator %q", opTok.text)}
+		}
+		return a.op(base | x<<8 | y<<4)
+	}
+
+	switch opTok.text + " " + rhs.text {
+	case ":= key":
+		return a.op(0xF00A | x<<8)
+	case ":= delay":
+		return a.op(0xF007 | x<<8)
+	case ":= random":
+		n, err := a.byteValue()
+		if err != nil {
+			return err
+		}
+		return a.op(0xC000 | x<<8 | n)
+	}
+	a.pos-- // rhs is a value
+	n, err := a.byteValue()
+	if err != nil {
+		return err
+	}
+	switch opTok.text {
+	case ":=":
+		return a.op(0x6000 | x<<8 | n)
+	case "+=":
+		return a.op(0x7000 | x<<8 | n)
+	case "-=":
+		return a.op(0x7000 | x<<8 | uint16(byte(-n)))
+	}
+	return &Error{opTok.line, fmt.Sprintf("operator %q needs a register", opTok.text)}
+}
+
+// assignI compiles i := addr, i := long addr, i := hex vx, i := bighex vx and i += vx
+func (a *octoAssembler) assignI() error {
+	opTok, err := a.next()
+	if err != nil {
+		return err
+	}
+	if opTok.text == "+=" {
+		r, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		return a.op(0xF01E | r<<8)
+	}
+	if opTok.text != ":=" {
+		return &Error{opTok.line, fmt.Sprintf("unknown operator %q", opTok.text)}
+	}
+	rhs, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch rhs.text {
+	case "hex", "bighex":
+		r, err := a.nextRegister()
+		if err != nil {
+			return err
+		}
+		if rhs.text == "hex" {
+			return a.op(0xF029 | r<<8)
+		}
+		return a.op(0xF030 | r<<8)
+	case "long":
+		target, err := a.next()
+		if err != nil {
+			return err
+		}
+		if err := a.op(0xF000); err != nil {
+			return err
+		}
+		ref := octoRef{addr: a.here, kind: refLong, tok: target}
+		if err := a.op(0); err != nil {
+			return err
+		}
+		return a.resolve(ref)
+	}
+	return a.opAddr(0xA000, rhs)
+}
+
+// ifStatement compiles if ... then statement and if ... begin
+func (a *octoAssembler) ifStatement() error {
+	// Find out which form this is before compiling the condition
+	form := ""
+	for i := a.pos; i < len(a.toks) && i < a.pos+4; i++ {
+		if s := a.toks[i].text; s == "then" || s == "begin" {
+			form = s
+			break
+		}
+	}
+	if form == "" {
+		return fmt.Errorf("if without then or begin")
+	}
+	if err := a.condition(form == "begin"); err != nil {
+		return err
+	}
+	if err := a.expect(form); err != nil {
+		return err
+	}
+	if form == "then" {
+		return a.statement()
+	}
+	a.blocks = append(a.blocks, &octoBlock{kind: "if", addr: a.here})
+	return a.op(0x1000)
+}
+
+// condition compiles a comparison into instructions ending in a skip. The skip is taken when the
+// condition is false, or when it's true if skipIfTrue is set (the jump out of if ... begin and while).
+func (a *octoAssembler) condition(skipIfTrue bool) error {
+	x, err := a.nextRegister()
+	if err != nil {
+		return err
+	}
+	cmp, err := a.next()
+	if err != nil {
+		return err
+	}
+	switch cmp.text {
+	case "key", "-key":
+		if (cmp.text == "key") != skipIfTrue {
+			return a.op(0xE0A1 | x<<8) // Skip if not pressed
+		}
+		return a.op(0xE09E | x<<8)
+	}
+
+	rhs, err := a.next()
+	if err != nil {
+		return err
+	}
+	y, isReg := a.register(rhs)
+	var n uint16
+	if !isReg {
+		a.pos--
+		if n, err = a.byteValue(); err != nil {
+			return err
+		}
+	}
+
+	switch cmp.text {
+	case "==", "!=":
+		// 3xnn / 5xy0 skip when equal, 4xnn / 9xy0 when not
+		skipIfEqual := (cmp.text == "==") == skipIfTrue
+		switch {
+		case isReg && skipIfEqual:
+			return a.op(0x5000 | x<<8 | y<<4)
+		case isReg:
+			return a.op(0x9000 | x<<8 | y<<4)
+		case skipIfEqual:
+			return a.op(0x3000 | x<<8 | n)
+		default:
+			return a.op(0x4000 | x<<8 | n)
+		}
+	case "<", ">", "<=", ">=":
+		// vF := y, then a subtraction leaves the comparison in the carry flag
+		if isReg {
+			err = a.op(0x8F00 | y<<4)
+		} else {
+			err = a.op(0x6F00 | n)
+		}
+		if err != nil {
+			return err
+		}
+		trueWhenCarry := cmp.text == ">=" || cmp.text == "<="
+		if cmp.text == "<" || cmp.text == ">=" {
+			err = a.op(0x8F07 | x<<4) // vF = vx - y, carry when vx >= y
+		} else {
+			err = a.op(0x8F05 | x<<4) // vF = y - vx, carry when y >= vx
+		}
+		if err != nil {
+			return err
+		}
+		if trueWhenCarry == skipIfTrue {
+			return a.op(0x3F01)
+		}
+		return a.op(0x3F00)
+	}
+	return &Error{cmp.line, fmt.Sprintf("unknown comparison %q", cmp.text)}
+}
+
+// isOctoIdent reports whether s can name a label or constant. Octo allows dashes and dots.
+func isOctoIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r == '-', r == '.', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,45 @@
+package chip8
+
+import (
+	"math"
+
+	"github.com/dustinbowers/chip8emu/pkg/display"
+)
+
+/*
+XO-CHIP audio:
+
+F002 loads a 16 byte pattern from I into the audio buffer and Fx3A sets the pitch from Vx.
+While the sound timer is active the pattern is played as a loop of 128 1-bit samples at
+4000 * 2^((pitch - 64) / 48) samples per second, so the default pitch of 64 plays at 4000Hz.
+Until a ROM loads a pattern the plain beep is used.
+*/
+
+// DefaultPitch is the XO-CHIP pitch register after a reset
+const DefaultPitch = 64
+
+// PatternRate returns the playback rate in samples per second for an XO-CHIP pitch
+func PatternRate(pitch uint8) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// AudioPattern returns the XO-CHIP audio pattern, pitch and whether a pattern has been loaded
+func (ch *Chip8) AudioPattern() (pattern [16]byte, pitch uint8, loaded bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.pattern, ch.pitch, ch.patternLoaded
+}
+
+// updatePattern tells the audio sink about the current pattern, must be called with ch.mu held
+func (ch *Chip8) updatePattern() {
+	p, ok := ch.audio.(display.PatternPlayer)
+	if !ok {
+		return
+	}
+	if !ch.patternLoaded {
+		p.SetPattern(nil, 0)
+		return
+	}
+	ch.log.Debug(LogAudio, "audio pattern loaded", "pitch", ch.pitch)
+	p.SetPattern(append([]byte(nil), ch.pattern[:]...), PatternRate(ch.pitch))
+}
@@ -0,0 +1,135 @@
+package chip8
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+// benchLoop is a tight loop of common instructions: arithmetic, a skip, memory access and a draw
+var benchLoop = []byte{
+	0x60, 0x05, // 200: LD V0, 5
+	0x61, 0x0A, // 202: LD V1, 10
+	0x70, 0x01, // 204: ADD V0, 1
+	0x80, 0x14, // 206: ADD V0, V1
+	0x30, 0xFF, // 208: SE V0, 0xFF
+	0xA0, 0x50, // 20A: LD I, 0x050
+	0xF1, 0x33, // 20C: LD B, V1
+	0xF1, 0x65, // 20E: LD V1, [I]
+	0xD0, 0x15, // 210: DRW V0, V1, 5
+	0x12, 0x04, // 212: JP 0x204
+}
+
+func newBenchChip8(rom []byte) *Chip8 {
+	ch := NewChip8()
+	ch.SeedRand(1)
+	ch.LoadRomBytes(rom)
+	return ch
+}
+
+func BenchmarkEmulateCycle(b *testing.B) {
+	ch := newBenchChip8(benchLoop)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ch.EmulateCycle(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStep(b *testing.B) {
+	ch := newBenchChip8(benchLoop)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ch.Step(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkStepTraceRing is BenchmarkStep with the frontend's crash trace kept
+func BenchmarkStepTraceRing(b *testing.B) {
+	ch := newBenchChip8(benchLoop)
+	ch.SetTraceWriter(ioutil.Discard)
+	ch.SetTraceRing(32)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ch.Step(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkOpcode runs each instruction on its own, from a fixed state
+func BenchmarkOpcode(b *testing.B) {
+	ops := []struct {
+		name string
+		op   uint16
+	}{
+		{"00E0_CLS", 0x00E0},
+		{"00EE_RET", 0x00EE},
+		{"1nnn_JP", 0x1200},
+		{"2nnn_CALL", 0x2200},
+		{"3xkk_SE", 0x3000},
+		{"6xkk_LD", 0x6012},
+		{"7xkk_ADD", 0x7001},
+		{"8xy4_ADD", 0x8014},
+		{"8xy6_SHR", 0x8016},
+		{"Annn_LD_I", 0xA050},
+		{"Cxkk_RND", 0xC0FF},
+		{"Dxyn_DRW", 0xD01F},
+		{"Ex9E_SKP", 0xE09E},
+		{"Fx1E_ADD_I", 0xF01E},
+		{"Fx33_LD_B", 0xF033},
+		{"Fx55_LD_I", 0xFF55},
+		{"Fx65_LD_Vx", 0xFF65},
+	}
+	for _, op := range ops {
+		b.Run(op.name, func(b *testing.B) {
+			ch := newBenchChip8([]byte{byte(op.op >> 8), byte(op.op)})
+			ch.V[1] = 3
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// Undo whatever the instruction did to the control flow
+				ch.PC = 0x200
+				ch.SP = 1
+				ch.Stack[0] = 0x200
+				ch.I = 0x300
+				if _, err := ch.emulateCycle(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDrawFullScreen covers the whole 64x32 screen with 8x11 sprites, 24 draws per iteration
+func BenchmarkDrawFullScreen(b *testing.B) {
+	var rom []byte
+	for y := 0; y < 32; y += 11 {
+		for x := 0; x < 64; x += 8 {
+			rom = append(rom, 0x60, byte(x), 0x61, byte(y), 0xD0, 0x1B)
+		}
+	}
+	rom = append(rom, 0x12, 0x00) // JP 0x200
+	instructions := len(rom) / 2
+	ch := newBenchChip8(rom)
+	ch.I = 0x050
+	ch.SetInstructionsPerFrame(instructions)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ch.RunFor(instructions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRunFrame runs whole frames at the default speed, timers and frame bookkeeping included
+func BenchmarkRunFrame(b *testing.B) {
+	ch := newBenchChip8(benchLoop)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ch.RunFrame(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
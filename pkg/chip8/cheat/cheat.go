@@ -0,0 +1,274 @@
+// Package cheat freezes and patches memory to cheat at games, e.g. keeping a lives counter full.
+//
+// A cheat file has one cheat per line, a name and the patches it applies every frame:
+//
+//	# Comments start with '#'
+//	Infinite lives: 3F0=03
+//	Skip to wave 5: 3F4?00=05
+//	-Invincible: 300=01, 301=01
+//
+// ADDR=VV freezes the byte at ADDR at VV, ADDR?CC=VV only writes VV while the byte is CC.
+// Addresses and values are hex. Cheats are on when loaded unless the name starts with '-'.
+//
+// A file can also say where the game keeps its score, so frontends can track high scores:
+//
+//	@score: 3F0-3F1         a big-endian number in 3F0 and 3F1
+//	@score: 3F0-3F3 digits  one decimal digit per byte, most significant first
+package cheat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// Patch writes Value to Addr, only while the byte there is Compare if Conditional
+type Patch struct {
+	Addr        uint16
+	Value       byte
+	Compare     byte
+	Conditional bool
+}
+
+func (p Patch) String() string {
+	if p.Conditional {
+		return fmt.Sprintf("%03X?%02X=%02X", p.Addr, p.Compare, p.Value)
+	}
+	return fmt.Sprintf("%03X=%02X", p.Addr, p.Value)
+}
+
+// Cheat is a named set of patches that's turned on and off together
+type Cheat struct {
+	Name    string
+	Patches []Patch
+	Enabled bool
+}
+
+// Score is where a game keeps its score
+type Score struct {
+	Addr   uint16
+	Size   int  // In bytes
+	Digits bool // One decimal digit per byte instead of a big-endian number
+}
+
+// maxScoreSize limits a score to what fits in a uint64, twice as many bytes for digits
+const maxScoreSize = 8
+
+func (s Score) String() string {
+	r := fmt.Sprintf("%03X-%03X", s.Addr, int(s.Addr)+s.Size-1)
+	if s.Digits {
+		r += " digits"
+	}
+	return r
+}
+
+// Value decodes the score from the Size bytes at Addr, false when a digit isn't 0-9
+func (s Score) Value(b []byte) (uint64, bool) {
+	if len(b) != s.Size {
+		return 0, false
+	}
+	var v uint64
+	for _, c := range b {
+		if !s.Digits {
+			v = v<<8 | uint64(c)
+			continue
+		}
+		if c > 9 {
+			return 0, false
+		}
+		v = v*10 + uint64(c)
+	}
+	return v, true
+}
+
+// Read returns emu's current score, false when it doesn't hold one
+func (s Score) Read(emu *chip8.Chip8) (uint64, bool) {
+	return s.Value(emu.ReadMemory(s.Addr, s.Size))
+}
+
+// List is a ROM's cheats. It's safe to toggle them while another goroutine applies them.
+type List struct {
+	mu     sync.Mutex
+	cheats []Cheat
+	score  *Score
+}
+
+// Load reads the cheat file at path
+func Load(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("cheat: %s: %v", path, err)
+	}
+	return l, nil
+}
+
+// Parse reads a cheat file, see the package documentation for the format
+func Parse(r io.Reader) (*List, error) {
+	l := &List{}
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if rest := strings.TrimSpace(line); strings.HasPrefix(rest, "@score:") {
+			score, err := parseScore(strings.TrimSpace(strings.TrimPrefix(rest, "@score:")))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			l.score = &score
+			continue
+		}
+		c, err := parseCheat(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		l.cheats = append(l.cheats, c)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func parseCheat(line string) (Cheat, error) {
+	colon := strings.LastIndexByte(line, ':')
+	if colon < 0 {
+		return Cheat{}, fmt.Errorf("missing ':' after the cheat's name")
+	}
+	c := Cheat{Name: strings.TrimSpace(line[:colon]), Enabled: true}
+	if strings.HasPrefix(c.Name, "-") {
+		c.Name, c.Enabled = strings.TrimSpace(c.Name[1:]), false
+	}
+	if c.Name == "" {
+		return Cheat{}, fmt.Errorf("missing cheat name")
+	}
+	for _, field := range strings.Split(line[colon+1:], ",") {
+		p, err := parsePatch(strings.TrimSpace(field))
+		if err != nil {
+			return Cheat{}, fmt.Errorf("%s: %v", c.Name, err)
+		}
+		c.Patches = append(c.Patches, p)
+	}
+	return c, nil
+}
+
+// parseScore parses ADDR-ADDR, optionally followed by "digits"
+func parseScore(s string) (Score, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 || len(fields) > 2 || (len(fields) == 2 && fields[1] != "digits") {
+		return Score{}, fmt.Errorf("bad score %q (expected ADDR-ADDR, then optionally digits)", s)
+	}
+	var score Score
+	bounds := strings.SplitN(fields[0], "-", 2)
+	first, err := strconv.ParseUint(bounds[0], 16, 16)
+	if err != nil {
+		return Score{}, fmt.Errorf("bad score address in %q", s)
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, err = strconv.ParseUint(bounds[1], 16, 16); err != nil || last < first {
+			return Score{}, fmt.Errorf("bad score address in %q", s)
+		}
+	}
+	score.Addr, score.Size, score.Digits = uint16(first), int(last-first)+1, len(fields) == 2
+	if (score.Size > maxScoreSize && !score.Digits) || score.Size > 2*maxScoreSize {
+		return Score{}, fmt.Errorf("score %q is too long", s)
+	}
+	return score, nil
+}
+
+// parsePatch parses ADDR=VV or ADDR?CC=VV
+func parsePatch(s string) (Patch, error) {
+	eq := strings.IndexByte(s, '=')
+	if eq < 0 {
+		return Patch{}, fmt.Errorf("bad patch %q (expected ADDR=VV or ADDR?CC=VV)", s)
+	}
+	var p Patch
+	addr := s[:eq]
+	if q := strings.IndexByte(addr, '?'); q >= 0 {
+		cmp, err := strconv.ParseUint(strings.TrimSpace(addr[q+1:]), 16, 8)
+		if err != nil {
+			return Patch{}, fmt.Errorf("bad compare value in %q", s)
+		}
+		p.Compare, p.Conditional = byte(cmp), true
+		addr = addr[:q]
+	}
+	a, err := strconv.ParseUint(strings.TrimSpace(addr), 16, 16)
+	if err != nil {
+		return Patch{}, fmt.Errorf("bad address in %q", s)
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(s[eq+1:]), 16, 8)
+	if err != nil {
+		return Patch{}, fmt.Errorf("bad value in %q", s)
+	}
+	p.Addr, p.Value = uint16(a), byte(v)
+	return p, nil
+}
+
+// Cheats returns a copy of the cheats in file order
+func (l *List) Cheats() []Cheat {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Cheat(nil), l.cheats...)
+}
+
+// Score returns where the game keeps its score, false when the file doesn't say
+func (l *List) Score() (Score, bool) {
+	if l.score == nil {
+		return Score{}, false
+	}
+	return *l.score, true
+}
+
+// Len returns the number of cheats
+func (l *List) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.cheats)
+}
+
+// Toggle turns cheat i on or off, returning whether it's now on
+func (l *List) Toggle(i int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if i < 0 || i >= len(l.cheats) {
+		return false
+	}
+	l.cheats[i].Enabled = !l.cheats[i].Enabled
+	return l.cheats[i].Enabled
+}
+
+// Apply writes the patches of the enabled cheats to emu's memory. Call it once a frame,
+// between frames.
+func (l *List) Apply(emu *chip8.Chip8) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, c := range l.cheats {
+		if !c.Enabled {
+			continue
+		}
+		for _, p := range c.Patches {
+			if p.Conditional {
+				if cur := emu.ReadMemory(p.Addr, 1); len(cur) == 0 || cur[0] != p.Compare {
+					continue
+				}
+			}
+			emu.WriteMemory(p.Addr, []byte{p.Value})
+		}
+	}
+}
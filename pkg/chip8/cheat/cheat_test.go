@@ -0,0 +1,104 @@
+package cheat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+const testFile = `# Test cheats
+Infinite lives: 3F0=03
+Skip to wave 5: 3F4?00=05  # Only at the start of a wave
+-Invincible: 300=01, 301=1
+`
+
+func TestParse(t *testing.T) {
+	l, err := Parse(strings.NewReader(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheats := l.Cheats()
+	if len(cheats) != 3 {
+		t.Fatalf("got %d cheats, want 3", len(cheats))
+	}
+	var got []string
+	for _, c := range cheats {
+		var patches []string
+		for _, p := range c.Patches {
+			patches = append(patches, p.String())
+		}
+		got = append(got, c.Name+": "+strings.Join(patches, ", ")+map[bool]string{true: " on", false: " off"}[c.Enabled])
+	}
+	want := []string{"Infinite lives: 3F0=03 on", "Skip to wave 5: 3F4?00=05 on", "Invincible: 300=01, 301=01 off"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, bad := range []string{"3F0=03", ": 3F0=03", "Lives: 3F0", "Lives: XYZ=03", "Lives: 3F0=100", "Lives: 3F0?=01"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("%q: got error %v", bad, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	l, err := Parse(strings.NewReader(testFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := chip8.NewChip8()
+	emu.Memory[0x3F4] = 0x02
+	l.Apply(emu)
+	if emu.Memory[0x3F0] != 0x03 || emu.Memory[0x3F4] != 0x02 || emu.Memory[0x300] != 0 {
+		t.Errorf("after Apply: [3F0] = %02X, [3F4] = %02X, [300] = %02X", emu.Memory[0x3F0], emu.Memory[0x3F4], emu.Memory[0x300])
+	}
+
+	emu.Memory[0x3F0], emu.Memory[0x3F4] = 0x01, 0x00
+	if !l.Toggle(2) || l.Toggle(0) {
+		t.Fatal("Toggle returned the wrong state")
+	}
+	l.Apply(emu)
+	if emu.Memory[0x3F0] != 0x01 || emu.Memory[0x3F4] != 0x05 || emu.Memory[0x300] != 0x01 || emu.Memory[0x301] != 0x01 {
+		t.Errorf("after toggling: [3F0] = %02X, [3F4] = %02X, [300] = %02X, [301] = %02X",
+			emu.Memory[0x3F0], emu.Memory[0x3F4], emu.Memory[0x300], emu.Memory[0x301])
+	}
+}
+
+func TestScore(t *testing.T) {
+	l, err := Parse(strings.NewReader("@score: 3F0-3F1\nLives: 3F4=03\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	score, ok := l.Score()
+	if !ok || score.String() != "3F0-3F1" || l.Len() != 1 {
+		t.Fatalf("got score %v, %v with %d cheats", score, ok, l.Len())
+	}
+	emu := chip8.NewChip8()
+	emu.Memory[0x3F0], emu.Memory[0x3F1] = 0x01, 0x02
+	if v, ok := score.Read(emu); !ok || v != 0x102 {
+		t.Errorf("got %d, %v, want 258", v, ok)
+	}
+
+	l, _ = Parse(strings.NewReader("@score: 3F0-3F2 digits"))
+	score, _ = l.Score()
+	emu.Memory[0x3F2] = 0x07
+	if v, ok := score.Read(emu); !ok || v != 127 {
+		t.Errorf("got %d, %v, want 127", v, ok)
+	}
+	emu.Memory[0x3F1] = 0x0A
+	if _, ok := score.Read(emu); ok {
+		t.Errorf("read a score with a digit of 10")
+	}
+
+	if l, _ := Parse(strings.NewReader(testFile)); l != nil {
+		if _, ok := l.Score(); ok {
+			t.Errorf("got a score from a file without one")
+		}
+	}
+	for _, bad := range []string{"@score:", "@score: 3F0-3E0", "@score: 3F0-3F9", "@score: 3F0 bcd", "@score: XYZ"} {
+		if _, err := Parse(strings.NewReader(bad)); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
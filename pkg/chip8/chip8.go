@@ -0,0 +1,598 @@
+package chip8
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+	"github.com/dustinbowers/chip8emu/pkg/display"
+	"github.com/dustinbowers/chip8emu/pkg/input"
+)
+
+var fontSet = [80]byte{
+	0xF0, 0x90, 0x90, 0x90, 0xF0, // 0
+	0x20, 0x60, 0x20, 0x20, 0x70, // 1
+	0xF0, 0x10, 0xF0, 0x80, 0xF0, // 2
+	0xF0, 0x10, 0xF0, 0x10, 0xF0, // 3
+	0x90, 0x90, 0xF0, 0x10, 0x10, // 4
+	0xF0, 0x80, 0xF0, 0x10, 0xF0, // 5
+	0xF0, 0x80, 0xF0, 0x90, 0xF0, // 6
+	0xF0, 0x10, 0x20, 0x40, 0x40, // 7
+	0xF0, 0x90, 0xF0, 0x90, 0xF0, // 8
+	0xF0, 0x90, 0xF0, 0x10, 0xF0, // 9
+	0xF0, 0x90, 0xF0, 0x90, 0x90, // A
+	0xE0, 0x90, 0xE0, 0x90, 0xE0, // B
+	0xF0, 0x80, 0x80, 0x80, 0xF0, // C
+	0xE0, 0x90, 0x90, 0x90, 0xE0, // D
+	0xF0, 0x80, 0xF0, 0x80, 0xF0, // E
+	0xF0, 0x80, 0xF0, 0x80, 0x80, // F
+}
+
+/*
+Memory Map:
++---------------+= 0xFFF (4095) End of Chip-8 RAM
+|               |
+|               |
+|               |
+|               |
+|               |
+| 0x200 to 0xFFF|
+|     Chip-8    |
+| Program / Data|
+|     Space     |
+|               |
+|               |
+|               |
++- - - - - - - -+= 0x600 (1536) Start of ETI 660 Chip-8 programs
+|               |
+|               |
+|               |
++---------------+= 0x200 (512) Start of most Chip-8 programs
+| 0x000 to 0x1FF|
+| Reserved for  |
+|  interpreter  |
++---------------+= 0x000 (0) Start of Chip-8 RAM
+
+*/
+
+/*
+Concurrency:
+
+A Chip8 is safe to drive from one goroutine (RunFrame / Step) while another goroutine
+feeds it input, pauses it or reads its state through its methods. The exported fields
+(Screen, Memory, V, ...) are only safe to touch directly while nothing else is running the machine;
+frontends running the machine on its own goroutine should use SetFrameChannel() or SnapshotScreen()
+instead of Screen.
+*/
+
+type Chip8 struct {
+	mu sync.Mutex // Guards everything below
+
+	quirks  Quirks  // Interpreter behaviours that differ between CHIP-8 variants
+	profile Profile // See profile.go
+
+	Screen *Framebuffer // 64x32 (64x64 in hires CHIP-8), one plane
+	Memory [4096]byte   // Program entry point is typically 0x200
+	V      [16]byte     // 16 8-bit registers (note VF is a carry-flag register)
+	PC     uint16       // Program/Instruction counter
+	I      uint16       // Index register
+	SP     uint16       // Stack pointer
+	Stack  [16]uint16   // :pancakes:
+	DT     uint8        // Delay timer
+	ST     uint8        // Sound timer
+
+	rng rand.Source // Source for Cxkk - RND
+
+	audio      display.AudioSink         // Optional, told when the sound timer starts and stops
+	display    display.Display           // Optional, receives the screen after each Step() that drew to it
+	keys       input.Keypad              // Optional, polled for input at the start of each Step()
+	memWatcher MemoryWatchFunc           // Optional, observes memory accesses made by instructions
+	trace      *tracer                   // Optional, see SetTraceWriter
+	frameOut   chan Frame                // Optional, see SetFrameChannel
+	frameDirty DirtyBlocks               // Changed since the last frame was published
+	runDirty   DirtyBlocks               // Changed since Run last called back with a frame
+	drawFlag   bool                      // The screen changed since the last Draw / SnapshotScreen
+	onDraw     func(screen *Framebuffer) // Optional, see SetDrawHandler
+	rewind     *rewindBuffer             // Optional, see SetRewindBuffer
+	steps      *stepHistory              // Optional, see SetStepHistory
+	syms       *symbols.Table            // Optional, see SetSymbols
+	hooks      Hooks                     // Optional, see hooks.go
+	preExec    []preExecHook             // See AddPreExecHook
+	preExecID  uint64                    // Last id handed to a pre-exec hook
+	flagStore  FlagStore                 // Optional, see rpl.go
+
+	runCallbacks RunCallbacks    // Optional, see run.go
+	log          ComponentLogger // Optional, see log.go
+
+	/*
+		Input: 16 keys, 0 to F (8, 4, 6, 2 are used for direction input)
+		1	2	3	C
+		4	5	6	D
+		7	8	9	E
+		A	0	B	F
+	*/
+	keyboard [16]bool  // Keys range from 0-F in a 4x4 grid
+	pushed   [16]uint8 // Frames until PushKey releases each key
+	rom      []byte    // Image of the loaded ROM, re-copied into memory by Reset
+	romAddr  uint16    // Where the ROM is loaded and execution starts
+	romInfo  RomInfo   // Size and checksums of rom
+	hires    bool      // 64x64 hires CHIP-8, see hires.go
+	mega     *megaChip // See megachip.go, nil unless the profile is MegaChip
+	rplFlags [16]byte  // SCHIP RPL user flags, see rpl.go. Survive Reset like the HP-48's did
+
+	// internals for easier opcode processing (See: func fetchOpcode())
+	opcode      uint16  // Stores the current 2byte opcode
+	x, y, n, kk uint8   // various parts of the current opcode, used for easier processing
+	nnn         uint16  // Stores addresses from opcodes
+	exec        opFunc  // Executes the current opcode
+	pure        bool    // The current opcode has no side effects, see finish.go
+	decoded     []instr // Decode cache, indexed by address
+	loop        loopWatch
+	idle        idleWatch
+
+	paused  bool
+	resumed *sync.Cond // Signalled by Resume, on mu
+	keyWait keyWait    // State of a pending Fx0A - LD Vx, K
+
+	unknownOpcodes UnknownOpcodePolicy // See unknown.go
+	halt           *EmuError           // Why UnknownOpcodeHalt paused the machine
+
+	pattern       [16]byte // XO-CHIP audio pattern, see audio.go
+	pitch         uint8
+	patternLoaded bool
+
+	instructionsPerFrame int    // See timing.go
+	frameCycles          int    // Instructions executed so far in the current frame
+	frames               uint64 // Frames completed
+}
+
+func (ch *Chip8) Inspect() (state string) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	state += fmt.Sprintf("Opcode: 0x%x\n", ch.opcode)
+	state += fmt.Sprintf("V     : %v\n", ch.V)
+	state += fmt.Sprintf("Stack : %v\n", ch.Stack)
+	state += fmt.Sprintf("SP    : %v\n", ch.SP)
+	state += fmt.Sprintf("I     : %v\n", ch.I)
+	state += fmt.Sprintf("PC    : %v\n", ch.PC)
+	state += fmt.Sprintf("ST    : %v\n", ch.ST)
+	state += fmt.Sprintf("DT    : %v\n", ch.DT)
+	return state
+}
+
+// Registers is a copy of the CPU state, see Chip8.Registers
+type Registers struct {
+	V     [16]byte
+	PC    uint16
+	I     uint16
+	SP    uint16
+	Stack [16]uint16
+	DT    uint8
+	ST    uint8
+}
+
+// Registers returns a copy of the CPU registers, safe to call while another goroutine runs the machine
+func (ch *Chip8) Registers() Registers {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return Registers{V: ch.V, PC: ch.PC, I: ch.I, SP: ch.SP, Stack: ch.Stack, DT: ch.DT, ST: ch.ST}
+}
+
+func NewChip8() *Chip8 {
+	var ch Chip8
+
+	// Note: Spec says font sprites start at 0x050. Some emus start at 0x0, see Profile
+	ch.setProfile(defaultProfile)
+
+	ch.Screen = NewFramebuffer(display.LoResWidth, display.LoResHeight, 1)
+	ch.pitch = DefaultPitch
+	ch.rng = newSplitMix(time.Now().UnixNano())
+
+	// Set Entrypoint
+	ch.romAddr = ch.profile.EntryPoint
+	ch.PC = ch.romAddr
+	ch.resumed = sync.NewCond(&ch.mu)
+
+	return &ch
+}
+
+// Reset restarts the loaded ROM, like the reset switch on a real machine. Memory is cleared and
+// the ROM re-copied, registers, stack, timers, screen and keyboard are cleared and a pending
+// Fx0A key wait is cancelled. The profile, quirks, clock speed and attached devices are kept.
+func (ch *Chip8) Reset() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.reset()
+	copy(ch.Memory[ch.romAddr:], ch.rom)
+}
+
+func (ch *Chip8) reset() {
+	if ch.mega != nil {
+		if ch.mega.on {
+			ch.setMegaMode(false)
+		}
+		ch.mega.reset()
+	}
+	ch.Screen.Clear()
+	for i, _ := range ch.Memory {
+		ch.Memory[i] = 0
+	}
+	ch.loadFont()
+	for i, _ := range ch.V {
+		ch.V[i] = 0
+	}
+	ch.PC = ch.romAddr
+	ch.I = 0
+	ch.SP = 0
+	for i, _ := range ch.Stack {
+		ch.Stack[i] = 0
+	}
+	ch.DT = 0
+	ch.ST = 0
+	ch.invalidate(AllDirty) // Frontends need to clear whatever was on screen before
+	ch.publishFrame()
+	for i, _ := range ch.keyboard {
+		ch.keyboard[i] = false
+	}
+	ch.pushed = [16]uint8{}
+	ch.keyWait = keyWait{}
+	ch.halt = nil
+	ch.loop = loopWatch{}
+	ch.idle = idleWatch{}
+	ch.pattern = [16]byte{}
+	ch.pitch = DefaultPitch
+	ch.patternLoaded = false
+	ch.updatePattern()
+	ch.frameCycles = 0
+}
+
+// SetQuirks replaces the active quirk set. See Quirks for what each flag controls
+func (ch *Chip8) SetQuirks(q Quirks) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.quirks = q
+}
+
+// Quirks returns the active quirk set
+func (ch *Chip8) Quirks() Quirks {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.quirks
+}
+
+// SetBeepHandler is shorthand for SetAudioSink(display.BeepFunc(callback))
+func (ch *Chip8) SetBeepHandler(callback func(bool)) {
+	if callback == nil {
+		ch.SetAudioSink(nil)
+		return
+	}
+	ch.SetAudioSink(display.BeepFunc(callback))
+}
+
+// Pause stops the machine before its next instruction: Step, RunFor, RunFrame and EmulateCycle
+// block until Resume. Pausing a paused machine does nothing.
+func (ch *Chip8) Pause() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.pause()
+}
+
+// pause must be called with ch.mu held
+func (ch *Chip8) pause() {
+	ch.paused = true
+}
+
+// Resume lets a paused machine carry on and clears HaltReason. Resuming a running machine does nothing.
+func (ch *Chip8) Resume() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.halt = nil
+	if ch.paused {
+		ch.paused = false
+		ch.resumed.Broadcast()
+	}
+}
+
+// IsPaused reports whether Pause() is in effect
+func (ch *Chip8) IsPaused() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.paused
+}
+
+// Paused is kept for compatibility, see IsPaused
+func (ch *Chip8) Paused() bool {
+	return ch.IsPaused()
+}
+
+// Break cancels a pending Fx0A key wait, leaving Vx unchanged
+func (ch *Chip8) Break() {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.keyWait.waiting {
+		ch.keyWait = keyWait{}
+	}
+}
+
+// LoadRom loads a ROM from a file, an http(s):// URL or a .zip archive, see ReadRom
+func (ch *Chip8) LoadRom(filepath string) (RomInfo, error) {
+	data, err := ReadRom(filepath)
+	if err != nil {
+		return RomInfo{}, err
+	}
+	return ch.LoadRomBytes(data)
+}
+
+// LoadRomBytes loads a ROM at the profile's entry point (0x200, or 0x600 on the ETI-660) and resets the machine.
+// Empty ROMs and ROMs too large for memory are refused with a *RomError, leaving the machine as it was.
+func (ch *Chip8) LoadRomBytes(bytes []byte) (RomInfo, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.loadRomAt(bytes, ch.profile.EntryPoint)
+}
+
+// LoadRomAt loads a ROM at addr and resets the machine to start executing there
+func (ch *Chip8) LoadRomAt(data []byte, addr uint16) (RomInfo, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if addr < 0x200 || int(addr) >= ch.profile.MemorySize {
+		return RomInfo{}, fmt.Errorf("loadRomAt: address %#03x is outside program memory", addr)
+	}
+	return ch.loadRomAt(data, addr)
+}
+
+// MaxRomSize returns the largest ROM LoadRomBytes accepts with the current profile
+func (ch *Chip8) MaxRomSize() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.maxRomSize(ch.profile.EntryPoint)
+}
+
+// maxRomSize is how many bytes of ROM fit from addr on. MegaChip ROMs can fill its 16MB address space.
+func (ch *Chip8) maxRomSize(addr uint16) int {
+	if ch.mega != nil {
+		return megaMemorySize - int(addr)
+	}
+	return ch.profile.MemorySize - int(addr)
+}
+
+// RomInfo describes the loaded ROM
+func (ch *Chip8) RomInfo() RomInfo {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.romInfo
+}
+
+func (ch *Chip8) loadRomAt(data []byte, addr uint16) (RomInfo, error) {
+	if len(data) == 0 {
+		return RomInfo{}, &RomError{Err: ErrRomEmpty, Addr: addr, Max: ch.maxRomSize(addr)}
+	}
+	if max := ch.maxRomSize(addr); len(data) > max {
+		return RomInfo{}, &RomError{Err: ErrRomTooLarge, Size: len(data), Addr: addr, Max: max}
+	}
+	ch.romAddr = addr
+	ch.setHires(ch.profile.Hires || (addr == 0x200 && hiresEntry(data)))
+	ch.reset()
+	copy(ch.Memory[addr:], data)
+	ch.rom = append([]byte(nil), data...)
+	ch.romInfo = newRomInfo(ch.rom, addr)
+	ch.loadFlags()
+	ch.log.Info(LogCPU, "ROM loaded", "size", len(data), "addr", fmt.Sprintf("%#03x", addr), "sha1", ch.romInfo.SHA1)
+	return ch.romInfo, nil
+}
+
+func (ch *Chip8) EmulateCycle() (bool, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.waitWhilePaused()
+	return ch.emulateCycle()
+}
+
+// waitWhilePaused blocks until Resume() is called, must be called with ch.mu held.
+// The lock is released while waiting so other goroutines can get at the machine.
+func (ch *Chip8) waitWhilePaused() {
+	for ch.paused {
+		ch.resumed.Wait()
+	}
+}
+
+// emulateCycle must be called with ch.mu held
+func (ch *Chip8) emulateCycle() (bool, error) {
+	if ch.steps != nil {
+		ch.captureStep()
+	}
+	if ch.trace != nil {
+		ch.trace.before(ch)
+	}
+	pc := ch.PC
+	err := ch.fetchOpcode()
+	if err == nil && ch.hooks.Instruction != nil {
+		ch.hooks.Instruction(pc, ch.opcode)
+	}
+	if err == nil && (len(ch.preExec) == 0 || ch.runPreExecHooks(pc)) {
+		err = ch.executeOpcode()
+	}
+	if f, ok := err.(*EmuError); ok {
+		f.PC = pc
+		if f.Err != ErrPCOutOfBounds {
+			f.Opcode = ch.opcode
+		}
+	}
+	if ch.trace != nil {
+		ch.trace.after(ch, err)
+	}
+	if err != nil {
+		return false, err
+	}
+	ch.watchLoop(pc)
+	ch.idle.executed(pc)
+	ch.countCycle()
+
+	return true, nil
+}
+
+// Step polls the KeyProvider (if any), executes a single instruction and
+// hands the screen to the Display (if any) when the instruction drew to it
+func (ch *Chip8) Step() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.waitWhilePaused()
+	return ch.step()
+}
+
+// step must be called with ch.mu held
+func (ch *Chip8) step() error {
+	ch.pollKeys()
+
+	pc := ch.PC
+	if _, err := ch.emulateCycle(); err != nil {
+		return err
+	}
+
+	if ch.display != nil && ch.drawFlag {
+		if err := ch.display.Draw(ch.Screen); err != nil {
+			return &EmuError{Category: CategoryIO, Err: err, PC: pc, Opcode: ch.opcode, Detail: "display draw failed"}
+		}
+		ch.drawFlag = false
+	}
+	return nil
+}
+
+// RunFor executes up to `cycles` instructions, stopping early on the first error or when an
+// unknown opcode halts the machine (see HaltReason)
+func (ch *Chip8) RunFor(cycles int) error {
+	for i := 0; i < cycles; i++ {
+		if err := ch.Step(); err != nil {
+			return err
+		}
+		if ch.HaltReason() != nil {
+			return nil
+		}
+	}
+	return nil
+}
+
+// fetchOpcode decodes the instruction at PC, or takes it from the decode cache when the
+// opcode there hasn't changed since it was last decoded
+func (ch *Chip8) fetchOpcode() error {
+	if int(ch.PC)+1 >= ch.profile.MemorySize {
+		return fault(ErrPCOutOfBounds, "past the end of memory")
+	}
+	if ch.PC < 0x200 {
+		return fault(ErrPCOutOfBounds, "inside the interpreter area")
+	}
+	// Each opcode is 2 bytes
+	op := uint16(ch.Memory[ch.PC])<<8 | uint16(ch.Memory[ch.PC+1])
+	if ch.decoded == nil {
+		ch.decoded = make([]instr, len(ch.Memory))
+	}
+	in := &ch.decoded[ch.PC]
+	if in.exec == nil || in.opcode != op {
+		*in = decode(op)
+	}
+	ch.opcode, ch.x, ch.y, ch.n, ch.kk, ch.nnn = in.opcode, in.x, in.y, in.n, in.kk, in.nnn
+	ch.exec = in.exec
+	ch.pure = in.pure
+
+	ch.PC += 2 // Advance the program counter after we have the internals set for processing
+	return nil
+}
+
+// executeOpcode runs the instruction decoded by fetchOpcode, see ops.go
+func (ch *Chip8) executeOpcode() error {
+	return ch.exec(ch)
+}
+
+func (ch *Chip8) KeyDown(key uint8) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.keyDown(key)
+}
+
+func (ch *Chip8) KeyUp(key uint8) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.keyUp(key)
+}
+
+// pushKeyFrames is how long PushKey holds a key down
+const pushKeyFrames = 3
+
+// PushKey taps key: it's pressed now and released a few frames later, long enough for the program
+// to see it whether it checks the keys with Ex9E / ExA1 or waits for one with Fx0A.
+// As with KeyDown, an attached KeyProvider decides again on the next Step.
+func (ch *Chip8) PushKey(key uint8) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	key &= 0xF
+	ch.keyDown(key)
+	ch.pushed[key] = pushKeyFrames
+}
+
+// releasePushed releases the keys PushKey pressed once their time is up, must be called with ch.mu held
+func (ch *Chip8) releasePushed() {
+	for k, n := range ch.pushed {
+		if n == 0 {
+			continue
+		}
+		ch.pushed[k]--
+		if n == 1 {
+			ch.keyUp(uint8(k))
+		}
+	}
+}
+
+// PressedKeys returns which keypad keys are currently held down
+func (ch *Chip8) PressedKeys() [16]bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.keyboard
+}
+
+func (ch *Chip8) keyDown(key uint8) {
+	ch.pushed[key] = 0 // Pressed for real, PushKey doesn't release it anymore
+	if !ch.keyboard[key] {
+		ch.markActive()
+		if ch.hooks.Key != nil {
+			defer ch.hooks.Key(key, true)
+		}
+	}
+	ch.keyboard[key] = true
+	if ch.keyWait.waiting && !ch.keyWait.pressed {
+		ch.keyWait.key = key
+		ch.keyWait.pressed = true
+	}
+}
+
+func (ch *Chip8) keyUp(key uint8) {
+	ch.pushed[key] = 0
+	if ch.keyboard[key] {
+		ch.markActive()
+		if ch.hooks.Key != nil {
+			defer ch.hooks.Key(key, false)
+		}
+	}
+	ch.keyboard[key] = false
+	if ch.keyWait.waiting && ch.keyWait.pressed && ch.keyWait.key == key {
+		ch.keyWait.released = true
+	}
+}
+
+// Timers run at 60hz and 'deactivate' at 0
+func (ch *Chip8) decrementTimers() {
+	if ch.ST > 0 {
+		ch.ST--
+		if ch.ST == 0 {
+			ch.log.Debug(LogTimer, "sound timer expired")
+			if ch.audio != nil {
+				ch.audio.Beep(false)
+			}
+		}
+	}
+	if ch.DT > 0 {
+		ch.DT--
+	}
+}
@@ -0,0 +1,165 @@
+// Package chip8test helps tests lock in what a ROM draws. Run a ROM headlessly, then compare
+// the screen with a golden file:
+//
+//	screen := chip8test.Run(t, rom, 100, chip8.Quirks{ClipSprites: true})
+//	chip8test.Golden(t, "testdata/clip.txt", screen)
+//
+// Goldens are text (.txt, one row per line with '#' for lit pixels) or images (.png, drawn with
+// chip8.ImagePalette, 64x32 screens at any whole scale), for 64x32 and 128x64 screens. Run the
+// tests with -update to (re)write them from the screens they got, then review the changes
+// before committing them.
+package chip8test
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/testsuite"
+)
+
+var update = flag.Bool("update", false, "rewrite golden screens from the test results")
+
+// Run loads rom into a new machine with quirks q and a fixed random seed, executes cycles
+// instructions and returns the screen. Errors from the machine fail the test.
+func Run(t testing.TB, rom []byte, cycles int, q chip8.Quirks) *chip8.Framebuffer {
+	t.Helper()
+	emu := chip8.NewChip8()
+	emu.SeedRand(1)
+	emu.SetQuirks(q)
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		t.Fatalf("chip8test: %v", err)
+	}
+	if err := emu.RunFor(cycles); err != nil {
+		t.Fatalf("chip8test: ROM failed: %v", err)
+	}
+	screen, _ := emu.SnapshotScreen()
+	return screen
+}
+
+// Golden compares screen with the golden file at path, or writes it there when the tests run with -update
+func Golden(t testing.TB, path string, screen *chip8.Framebuffer) {
+	t.Helper()
+	if *update {
+		if err := writeGolden(path, screen); err != nil {
+			t.Fatalf("chip8test: failed writing golden: %v", err)
+		}
+		return
+	}
+
+	want, err := readGolden(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("chip8test: no golden %v, run the test with -update to create it. Got:\n%s", path, testsuite.FormatScreen(screen))
+	}
+	if err != nil {
+		t.Fatalf("chip8test: %v: %v", path, err)
+	}
+	if diff := Diff(screen, want); diff != "" {
+		t.Errorf("screen doesn't match %v ('+' lit but shouldn't be, '-' dark but should be lit):\n%s", path, diff)
+	}
+}
+
+// Diff returns "" when got and want match, otherwise got drawn as text with the differences
+// marked: '+' for a pixel that is lit but shouldn't be, '-' for one that should be lit.
+// Screens of different sizes are only reported as such.
+func Diff(got, want *chip8.Framebuffer) string {
+	if got.Width != want.Width || got.Height != want.Height {
+		return fmt.Sprintf("got a %dx%d screen, want %dx%d\n", got.Width, got.Height, want.Width, want.Height)
+	}
+	if string(got.Pix) == string(want.Pix) {
+		return ""
+	}
+	var sb strings.Builder
+	for y := 0; y < got.Height; y++ {
+		for x := 0; x < got.Width; x++ {
+			g, w := got.At(x, y), want.At(x, y)
+			switch {
+			case g == w && g != 0:
+				sb.WriteByte('#')
+			case g == w:
+				sb.WriteByte('.')
+			case g != 0 && w == 0:
+				sb.WriteByte('+')
+			case g == 0:
+				sb.WriteByte('-')
+			default:
+				sb.WriteByte('*') // Lit in both, but in different planes
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func writeGolden(path string, screen *chip8.Framebuffer) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if !isPNG(path) {
+		return ioutil.WriteFile(path, []byte(testsuite.FormatScreen(screen)), 0644)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, chip8.ScreenImage(screen, chip8.ImagePalette, 1)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readGolden(path string) (*chip8.Framebuffer, error) {
+	if !isPNG(path) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return testsuite.ParseScreen(string(data))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return screenFromImage(img)
+}
+
+// screenFromImage reads back an image drawn by chip8.ScreenImage, sampling the top left corner
+// of each pixel. A 128x64 image is a hires screen, a square one a 64x64 hires CHIP-8 screen at some
+// scale and anything else a 64x32 one at some scale.
+func screenFromImage(img image.Image) (*chip8.Framebuffer, error) {
+	b := img.Bounds()
+	width, height := 64, 32
+	if b.Dx() == 128 && b.Dy() == 64 {
+		width, height = 128, 64
+	} else if b.Dx() == b.Dy() {
+		width, height = 64, 64
+	}
+	scale := b.Dx() / width
+	if scale < 1 || b.Dx() != width*scale || b.Dy() != height*scale {
+		return nil, fmt.Errorf("expected a 128x64 image, or a 64x32 or 64x64 one at any whole scale, got %dx%d", b.Dx(), b.Dy())
+	}
+	screen := chip8.NewFramebuffer(width, height, 2)
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			screen.Set(x, y, uint8(chip8.ImagePalette.Index(img.At(b.Min.X+x*scale, b.Min.Y+y*scale))))
+		}
+	}
+	return screen, nil
+}
+
+func isPNG(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".png")
+}
@@ -0,0 +1,53 @@
+package chip8test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestGoldenRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chip8test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, size := range [][2]int{{64, 32}, {64, 64}, {128, 64}} {
+		screen := chip8.NewFramebuffer(size[0], size[1], 1)
+		screen.Set(0, 0, 1)
+		screen.Set(size[0]-1, size[1]-1, 1)
+		screen.Set(10, 20, 1)
+		for _, name := range []string{"screen.txt", "screen.png"} {
+			path := filepath.Join(dir, name)
+			if err := writeGolden(path, screen); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readGolden(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := Diff(got, screen); diff != "" {
+				t.Errorf("%dx%d %v changed in a round trip:\n%s", size[0], size[1], name, diff)
+			}
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got, want := chip8.NewFramebuffer(64, 32, 1), chip8.NewFramebuffer(64, 32, 1)
+	got.Set(1, 0, 1)
+	want.Set(2, 0, 1)
+	got.Set(3, 0, 1)
+	want.Set(3, 0, 1)
+	diff := Diff(got, want)
+	if len(diff) != 65*32 || diff[:5] != ".+-#." {
+		t.Errorf("Diff starts with %q, want \".+-#.\"", diff[:5])
+	}
+	if Diff(got, got) != "" {
+		t.Error("Diff of a screen with itself isn't empty")
+	}
+}
@@ -0,0 +1,84 @@
+// Package compat is a small database of known ROMs and the interpreter settings they need.
+//
+// Most CHIP-8 ROMs were written against one interpreter (the COSMAC VIP original, or the
+// later CHIP-48 / SCHIP calculator ports) and misbehave under the quirks of another.
+// Looking a ROM up by its SHA-1 lets frontends pick the right settings automatically.
+package compat
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// Platform is the interpreter a ROM was written for
+type Platform string
+
+const (
+	PlatformCHIP8    Platform = "chip8"    // COSMAC VIP CHIP-8
+	PlatformSCHIP    Platform = "schip"    // CHIP-48 / SUPER-CHIP
+	PlatformXOCHIP   Platform = "xochip"   // Octo's XO-CHIP
+	PlatformMegaChip Platform = "megachip" // Revival Studios' MegaChip8
+)
+
+// profileNames maps each platform to the chip8 machine profile that runs it
+var profileNames = map[Platform]string{
+	PlatformCHIP8:    "vip",
+	PlatformSCHIP:    "schip",
+	PlatformXOCHIP:   "xochip",
+	PlatformMegaChip: "megachip",
+}
+
+// Profile returns the chip8 machine profile that runs p
+func (p Platform) Profile() (chip8.Profile, bool) {
+	profile, err := chip8.LookupProfile(profileNames[p])
+	return profile, err == nil
+}
+
+// Entry describes a known ROM
+type Entry struct {
+	Title                string
+	Platform             Platform
+	Quirks               chip8.Quirks
+	InstructionsPerFrame int                       // 0 keeps the emulator's current speed
+	UnknownOpcodes       chip8.UnknownOpcodePolicy // For ROMs with stray data words in their code
+}
+
+// databaseMu guards database against Register
+var databaseMu sync.RWMutex
+
+// Lookup finds rom in the database
+func Lookup(rom []byte) (Entry, bool) {
+	databaseMu.RLock()
+	defer databaseMu.RUnlock()
+	e, ok := database[romKey(rom)]
+	return e, ok
+}
+
+// Register adds rom to the database for the rest of the run, replacing any entry it had.
+// It's for settings that come with a ROM, like the options of an Octo cartridge.
+func Register(rom []byte, e Entry) {
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	database[romKey(rom)] = e
+}
+
+func romKey(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// Apply configures emu with the settings from e: the profile for its platform, then its own quirks
+// and speed on top. Call it before LoadRomBytes.
+func Apply(emu *chip8.Chip8, e Entry) {
+	if profile, ok := e.Platform.Profile(); ok {
+		emu.SetProfile(profile)
+	}
+	emu.SetQuirks(e.Quirks)
+	if e.InstructionsPerFrame > 0 {
+		emu.SetInstructionsPerFrame(e.InstructionsPerFrame)
+	}
+	emu.SetUnknownOpcodePolicy(e.UnknownOpcodes)
+}
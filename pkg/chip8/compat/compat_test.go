@@ -0,0 +1,119 @@
+package compat
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestRegister(t *testing.T) {
+	rom := []byte{0x12, 0x00, 0xC0, 0x3F} // Not in the database
+	if _, ok := Lookup(rom); ok {
+		t.Fatal("found an unregistered ROM")
+	}
+	e := Entry{Title: "Test", Platform: PlatformCHIP8, Quirks: chip8.Quirks{VFReset: true}, InstructionsPerFrame: 7}
+	Register(rom, e)
+	if got, ok := Lookup(append([]byte{}, rom...)); !ok || got != e {
+		t.Errorf("got %+v, %v, want %+v", got, ok, e)
+	}
+	sum := sha1.Sum(rom)
+	if got := database[hex.EncodeToString(sum[:])]; got != e {
+		t.Errorf("not stored under the ROM's SHA-1: got %+v", got)
+	}
+	if _, ok := Lookup(rom[:2]); ok {
+		t.Error("found a ROM by a prefix of a registered one")
+	}
+
+	// Registering again replaces the entry
+	e.Title = "Replaced"
+	Register(rom, e)
+	if got, _ := Lookup(rom); got.Title != "Replaced" {
+		t.Errorf("got %q after replacing", got.Title)
+	}
+}
+
+func TestDatabase(t *testing.T) {
+	for key, e := range database {
+		if len(key) != 40 {
+			t.Errorf("%s: not a hex SHA-1", e.Title)
+		}
+		if _, ok := e.Platform.Profile(); !ok {
+			t.Errorf("%s: no profile for platform %q", e.Title, e.Platform)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	q := chip8.Quirks{ClipSprites: true}
+	emu := chip8.NewChip8()
+	Apply(emu, Entry{Platform: PlatformCHIP8, Quirks: q, InstructionsPerFrame: 30, UnknownOpcodes: chip8.UnknownOpcodeSkip})
+	if got := emu.Profile().Name; got != "vip" {
+		t.Errorf("profile %q, want vip", got)
+	}
+	if emu.Quirks() != q {
+		t.Errorf("quirks %+v, want %+v", emu.Quirks(), q)
+	}
+	if emu.InstructionsPerFrame() != 30 {
+		t.Errorf("%d instructions per frame, want 30", emu.InstructionsPerFrame())
+	}
+	if emu.UnknownOpcodePolicy() != chip8.UnknownOpcodeSkip {
+		t.Errorf("unknown opcode policy %v", emu.UnknownOpcodePolicy())
+	}
+
+	// No speed keeps the profile's
+	vip := emu.Profile().InstructionsPerFrame
+	Apply(emu, Entry{Platform: PlatformCHIP8})
+	if emu.InstructionsPerFrame() != vip {
+		t.Errorf("%d instructions per frame, want the profile's %d", emu.InstructionsPerFrame(), vip)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  []byte
+		want Platform
+	}{
+		{"empty", nil, PlatformCHIP8},
+		{"plain", []byte{0x00, 0xE0, 0xD0, 0x15, 0x12, 0x00}, PlatformCHIP8},
+		{"hires", []byte{0x00, 0xFF, 0x12, 0x00}, PlatformSCHIP},
+		{"16x16 sprite", []byte{0xD0, 0x10}, PlatformSCHIP},
+		{"RPL flags", []byte{0xF3, 0x75}, PlatformSCHIP},
+		{"SCHIP then XO-CHIP", []byte{0x00, 0xFF, 0xF0, 0x00, 0x12, 0x34}, PlatformXOCHIP},
+		{"plane", []byte{0xF2, 0x01}, PlatformXOCHIP},
+		{"MegaChip", []byte{0x00, 0x11}, PlatformMegaChip},
+		{"odd offset", []byte{0x00, 0x00, 0xFF, 0x00}, PlatformCHIP8}, // 00FF straddles two words
+		{"trailing byte", []byte{0x00, 0xE0, 0x00}, PlatformCHIP8},
+	}
+	for _, tt := range tests {
+		if got := Detect(tt.rom); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	hints := Scan([]byte{0x00, 0xE0, 0x00, 0xC4})
+	if len(hints) != 1 || hints[0].Addr != 0x202 || hints[0].Opcode != 0x00C4 || hints[0].Platform != PlatformSCHIP {
+		t.Errorf("got hints %v", hints)
+	}
+}
+
+func TestHiresEntry(t *testing.T) {
+	tests := []struct {
+		rom  []byte
+		want bool
+	}{
+		{[]byte{0x12, 0x60, 0x00, 0xE0}, true},
+		{[]byte{0x12, 0x60}, true},
+		{[]byte{0x12}, false},
+		{nil, false},
+		{[]byte{0x12, 0x00}, false},
+		{[]byte{0x00, 0xE0, 0x12, 0x60}, false}, // Only the first instruction counts
+	}
+	for _, tt := range tests {
+		if got := HiresEntry(tt.rom); got != tt.want {
+			t.Errorf("% X: got %v, want %v", tt.rom, got, tt.want)
+		}
+	}
+}
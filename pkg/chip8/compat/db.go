@@ -0,0 +1,50 @@
+package compat
+
+import "github.com/dustinbowers/chip8emu/pkg/chip8"
+
+var (
+	// cosmac matches the original COSMAC VIP interpreter
+	cosmac = chip8.Quirks{ShiftUsesVy: true, LoadStoreIncrementsI: true, VFReset: true, DisplayWait: true}
+	// schip matches CHIP-48 / SUPER-CHIP, which is also what this emulator defaults to
+	schip = chip8.Quirks{}
+)
+
+// database maps the hex SHA-1 of a ROM to its settings
+var database = map[string]Entry{
+	// COSMAC VIP era
+	"3d1d029d6e31206d245c0ba881c0d1f003953bad": {Title: "Rocket [Joseph Weisbecker, 1978]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"ed829190e37815771e7a8c675ba0074996a2ddb0": {Title: "Space Intercept [Joseph Weisbecker, 1978]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"1bd92042717c3bc4f7f34cab34be2887145a6704": {Title: "Spooky Spot [Joseph Weisbecker, 1978]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"d666688a8fce468a7d88b536bc1ef5f35ba12031": {Title: "Wipe Off [Joseph Weisbecker]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"193915dcde1365ae054c4eaa21a35baa27cd3356": {Title: "Breakout [Carmelo Cortez, 1979]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"4031dae5c7545a1adc160a661be36f19fc1d47b2": {Title: "Nim [Carmelo Cortez, 1978]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"dbb52193db4063149c3d8768ab47dd740d90955c": {Title: "Hi-Lo [Jef Winsor, 1978]", Platform: PlatformCHIP8, Quirks: cosmac},
+	"72e8f3a10a32bd7fb91322ecab87249f95e81e57": {Title: "Lunar Lander (Udo Pernisz, 1979)", Platform: PlatformCHIP8, Quirks: cosmac},
+	// Has a stray 0000 right after its first DRW
+	"ac7c8db7865beb22c9ec9001c9c0319e02f5d5c2": {Title: "Framed MK1 [GV Samways, 1980]", Platform: PlatformCHIP8, Quirks: cosmac, UnknownOpcodes: chip8.UnknownOpcodeSkip},
+
+	// Written for (or on) the HP48 interpreters
+	"d40abc54374e4343639f993e897e00904ddf85d9": {Title: "Blinky [Hans Christian Egeberg, 1991]", Platform: PlatformSCHIP, Quirks: schip},
+	"f4169141735d8d60e51409ca7e73f4adedcefef2": {Title: "Blinky [Hans Christian Egeberg] (alt)", Platform: PlatformSCHIP, Quirks: schip},
+	"5c28a5f85289c9d859f95fd5eadbdcb1c30bb08b": {Title: "Space Invaders [David Winter]", Platform: PlatformSCHIP, Quirks: schip},
+	"f100197f0f2f05b4f3c8c31ab9c2c3930d3e9571": {Title: "Space Invaders [David Winter] (alt)", Platform: PlatformSCHIP, Quirks: schip},
+	"5f518084744bf3cb8733f6e5454dfd1634320563": {Title: "Tetris [Fran Dachille, 1991]", Platform: PlatformSCHIP, Quirks: schip},
+	"b232ef880bd6060fb45fa6effed7edf0ae95670e": {Title: "Pong [Paul Vervalin, 1990]", Platform: PlatformSCHIP, Quirks: schip},
+	"91442577a6bbf8c3267f2df95fdfc50baebe176d": {Title: "Brick (Brix hack, 1990)", Platform: PlatformSCHIP, Quirks: schip},
+	"237756a4014fb3aa82a29246a7cdd534f8dc2dbb": {Title: "Breakout (Brix hack) [David Winter, 1997]", Platform: PlatformSCHIP, Quirks: schip},
+	"da710f631f8e35534d0b9170bcf892a60f49c43d": {Title: "Vertical Brix [Paul Robson, 1996]", Platform: PlatformSCHIP, Quirks: schip},
+	"6f6509f38220e057a7e32ebb22dd353c1078e3e7": {Title: "Blitz [David Winter]", Platform: PlatformSCHIP, Quirks: chip8.Quirks{ClipSprites: true}},
+	"2d10c07b532f4fa7c07a07324ba26ca39fe484fd": {Title: "Connect 4 [David Winter]", Platform: PlatformSCHIP, Quirks: schip},
+	"050f07a54371da79f924dd0227b89d07b4f2aed0": {Title: "Hidden [David Winter, 1996]", Platform: PlatformSCHIP, Quirks: schip},
+	"0d0cc129dad3c45ba672f85fec71a668232212cc": {Title: "Missile [David Winter]", Platform: PlatformSCHIP, Quirks: schip},
+	"a58ec7cc63707f9e7274026de27c15ec1d9945bd": {Title: "Squash [David Winter]", Platform: PlatformSCHIP, Quirks: schip},
+	"bdb92475acfe11bc7814a2f5eade13fcd09b756a": {Title: "UFO [Lutz V, 1992]", Platform: PlatformSCHIP, Quirks: schip},
+	"1bdb4ddaa7049266fa3226851f28855a365cfd12": {Title: "Syzygy [Roy Trevino, 1990]", Platform: PlatformSCHIP, Quirks: schip},
+	"ade839585ddeb0e3633177df03c1d91589e629eb": {Title: "Vers [JMN, 1991]", Platform: PlatformSCHIP, Quirks: schip},
+
+	// Modern homebrew
+	"775e82a36c93f1b41b42eca94b55acbc4a48cebe": {Title: "Tapeworm [JDR, 1999]", Platform: PlatformSCHIP, Quirks: schip},
+	"ac621d9fcada302ba6965768229ef130630bc525": {Title: "Astro Dodge [Revival Studios, 2008]", Platform: PlatformSCHIP, Quirks: schip},
+	"a1c1e0e7b01004be3ee77c69030e6b536cb316e6": {Title: "Worm V4 [RB-Revival Studios, 2007]", Platform: PlatformSCHIP, Quirks: schip},
+	"4639f86beb0a203ae512b85d3b56d813b2dea7b4": {Title: "Rush Hour [Hap, 2006]", Platform: PlatformSCHIP, Quirks: schip},
+}
@@ -0,0 +1,84 @@
+package compat
+
+import "fmt"
+
+// Hint is an opcode that suggests a ROM was written for an extended platform.
+// Scanning can't tell code from data, so hints are only a guess.
+type Hint struct {
+	Addr     uint16
+	Opcode   uint16
+	Platform Platform
+	Reason   string
+}
+
+func (h Hint) String() string {
+	return fmt.Sprintf("0x%03X  %04X  %-6s %s", h.Addr, h.Opcode, h.Platform, h.Reason)
+}
+
+// Scan looks through every word of rom (loaded at 0x200) for SCHIP, XO-CHIP and MegaChip opcodes
+func Scan(rom []byte) []Hint {
+	var hints []Hint
+	for i := 0; i+1 < len(rom); i += 2 {
+		op := uint16(rom[i])<<8 | uint16(rom[i+1])
+		if platform, reason := classify(op); reason != "" {
+			hints = append(hints, Hint{Addr: 0x200 + uint16(i), Opcode: op, Platform: platform, Reason: reason})
+		}
+	}
+	return hints
+}
+
+// Detect guesses the platform rom was written for from its opcodes, see Scan
+func Detect(rom []byte) Platform {
+	platform := PlatformCHIP8
+	for _, h := range Scan(rom) {
+		if h.Platform == PlatformXOCHIP || h.Platform == PlatformMegaChip {
+			return h.Platform
+		}
+		platform = h.Platform
+	}
+	return platform
+}
+
+// HiresEntry reports whether rom starts with the 1260 jump used by the 64x64 "hires" CHIP-8 variant
+func HiresEntry(rom []byte) bool {
+	return len(rom) >= 2 && rom[0] == 0x12 && rom[1] == 0x60
+}
+
+// classify returns the platform op belongs to if it isn't plain CHIP-8
+func classify(op uint16) (Platform, string) {
+	switch {
+	case op&0xFFF0 == 0x00C0 && op != 0x00C0:
+		return PlatformSCHIP, "scroll down"
+	case op&0xFFF0 == 0x00D0 && op != 0x00D0:
+		return PlatformXOCHIP, "scroll up"
+	case op == 0x00FB:
+		return PlatformSCHIP, "scroll right"
+	case op == 0x00FC:
+		return PlatformSCHIP, "scroll left"
+	case op == 0x00FD:
+		return PlatformSCHIP, "exit interpreter"
+	case op == 0x00FE:
+		return PlatformSCHIP, "low resolution mode"
+	case op == 0x00FF:
+		return PlatformSCHIP, "high resolution mode"
+	case op&0xF00F == 0xD000:
+		return PlatformSCHIP, "16x16 sprite"
+	case op&0xF0FF == 0xF030:
+		return PlatformSCHIP, "large font"
+	case op&0xF0FF == 0xF075, op&0xF0FF == 0xF085:
+		return PlatformSCHIP, "RPL user flags"
+	case op&0xF00F == 0x5002, op&0xF00F == 0x5003:
+		return PlatformXOCHIP, "save / load register range"
+	case op == 0xF000:
+		return PlatformXOCHIP, "long I load"
+	case op&0xF0FF == 0xF001:
+		return PlatformXOCHIP, "select drawing plane"
+	case op == 0xF002:
+		return PlatformXOCHIP, "load audio pattern"
+	case op&0xF0FF == 0xF03A:
+		return PlatformXOCHIP, "set audio pitch"
+	case op == 0x0011:
+		return PlatformMegaChip, "MegaChip mode"
+	}
+	return "", ""
+}
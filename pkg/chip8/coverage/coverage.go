@@ -0,0 +1,145 @@
+// Package coverage reports which instructions of a ROM a run executed, and which way its skip
+// instructions went, from the counts of a profiler.Profiler.
+//
+// The code of a ROM is what pkg/chip8/analyze finds reachable plus whatever was executed, the rest
+// is taken to be data. Reports are written as an annotated disassembly or in lcov's tracefile
+// format, where line numbers are the instructions' addresses.
+package coverage
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/analyze"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/profiler"
+)
+
+// Line is an instruction or data word of the ROM
+type Line struct {
+	disasm.Instruction
+	Code   bool
+	Count  uint64           // Times executed
+	Branch *profiler.Branch // The outcomes, for an executed skip instruction
+}
+
+// Report is the coverage of a ROM
+type Report struct {
+	Lines          []Line
+	Found, Hit     int // Instructions, and those executed
+	Branches, Took int // Outcomes of skip instructions (two each), and those seen
+}
+
+// New builds the report for rom loaded at origin from the counts p collected
+func New(rom []byte, origin uint16, p *profiler.Profiler) *Report {
+	counts, branches := p.Counts(), p.Branches()
+	code := map[uint16]bool{}
+	if origin == 0x200 { // Where analyze assumes the ROM is
+		for _, addr := range analyze.Analyze(rom).Reachable {
+			code[addr] = true
+		}
+	}
+
+	// Code that runs at odd addresses is decoded there, so walk the ROM rather than disassembling words
+	r := &Report{}
+	end := int(origin) + len(rom)
+	for addr := int(origin); addr < end; {
+		a := uint16(addr)
+		var in disasm.Instruction
+		if addr+1 < end {
+			in = disasm.Decode(a, uint16(rom[addr-int(origin)])<<8|uint16(rom[addr+1-int(origin)]))
+		} else {
+			in = disasm.Disassemble(rom[addr-int(origin):], a)[0]
+		}
+		line := Line{Instruction: in, Code: code[a] || counts[a] > 0, Count: counts[a]}
+		// Code that starts on the second byte of this word takes over from there
+		size := in.Size
+		if size == 2 && !line.Code && (code[a+1] || counts[a+1] > 0) {
+			size = 1
+		}
+		if line.Code {
+			r.Found++
+			if line.Count > 0 {
+				r.Hit++
+			}
+			if profiler.IsSkip(in.Opcode) {
+				r.Branches += 2
+				if b, ok := branches[a]; ok {
+					line.Branch = &b
+					r.Took += took(b)
+				}
+			}
+		}
+		r.Lines = append(r.Lines, line)
+		addr += size
+	}
+	return r
+}
+
+func took(b profiler.Branch) int {
+	n := 0
+	if b.NotTaken > 0 {
+		n++
+	}
+	if b.Taken > 0 {
+		n++
+	}
+	return n
+}
+
+// WriteAnnotated writes the disassembly with each instruction's count, in the style of gcov:
+// "#####" marks code that never ran and "-" data. Skips show how often they skipped, and
+// a summary follows.
+func (r *Report) WriteAnnotated(w io.Writer) error {
+	for _, l := range r.Lines {
+		count := "-"
+		if l.Code {
+			count = "#####"
+			if l.Count > 0 {
+				count = fmt.Sprint(l.Count)
+			}
+		}
+		branch := ""
+		if l.Branch != nil {
+			branch = fmt.Sprintf("skip %d/%d", l.Branch.Taken, l.Branch.Taken+l.Branch.NotTaken)
+		}
+		if _, err := fmt.Fprintf(w, "%9s: %-12s %s\n", count, branch, l.Instruction); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\nInstructions: %d of %d executed (%.1f%%)\nBranches:     %d of %d taken (%.1f%%)\n",
+		r.Hit, r.Found, percent(r.Hit, r.Found), r.Took, r.Branches, percent(r.Took, r.Branches))
+	return err
+}
+
+// WriteLcov writes the report as an lcov tracefile for source, with addresses for line numbers
+func (r *Report) WriteLcov(w io.Writer, source string) error {
+	if _, err := fmt.Fprintf(w, "TN:\nSF:%s\n", source); err != nil {
+		return err
+	}
+	for _, l := range r.Lines {
+		if !l.Code || !profiler.IsSkip(l.Opcode) {
+			continue
+		}
+		taken, notTaken := "-", "-"
+		if l.Branch != nil {
+			taken, notTaken = fmt.Sprint(l.Branch.Taken), fmt.Sprint(l.Branch.NotTaken)
+		}
+		fmt.Fprintf(w, "BRDA:%d,0,0,%s\nBRDA:%d,0,1,%s\n", l.Addr, notTaken, l.Addr, taken)
+	}
+	fmt.Fprintf(w, "BRF:%d\nBRH:%d\n", r.Branches, r.Took)
+	for _, l := range r.Lines {
+		if l.Code {
+			fmt.Fprintf(w, "DA:%d,%d\n", l.Addr, l.Count)
+		}
+	}
+	_, err := fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", r.Found, r.Hit)
+	return err
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
@@ -0,0 +1,81 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/profiler"
+)
+
+var rom = []byte{
+	0x60, 0x01, // 200: LD V0, 1
+	0x30, 0x01, // 202: SE V0, 1
+	0x60, 0x02, // 204: LD V0, 2, skipped
+	0x12, 0x06, // 206: JP 0x206
+	0xFF, 0xFF, // 208: data
+}
+
+func run(t *testing.T) *Report {
+	emu := chip8.NewChip8()
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	p := profiler.New(emu)
+	for i := 0; i < 6; i++ {
+		if err := emu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(rom, 0x200, p)
+}
+
+func TestAnnotated(t *testing.T) {
+	var sb strings.Builder
+	if err := run(t).WriteAnnotated(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"        1:              0x200  6001  LD    V0, 0x01",
+		"        1: skip 1/1     0x202  3001  SE    V0, 0x01",
+		"    #####:              0x204  6002  LD    V0, 0x02",
+		"        4:              0x206  1206  JP    0x206",
+		"        -:              0x208  FFFF",
+		"",
+		"Instructions: 3 of 4 executed (75.0%)",
+		"Branches:     1 of 2 taken (50.0%)",
+	}
+	got := strings.Split(strings.TrimSuffix(sb.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("got\n%s", sb.String())
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("line %d: got %q, want %q...", i+1, got[i], want[i])
+		}
+	}
+}
+
+func TestLcov(t *testing.T) {
+	var sb strings.Builder
+	if err := run(t).WriteLcov(&sb, "game.ch8"); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:game.ch8
+BRDA:514,0,0,0
+BRDA:514,0,1,1
+BRF:2
+BRH:1
+DA:512,1
+DA:514,1
+DA:516,0
+DA:518,4
+LF:4
+LH:3
+end_of_record
+`
+	if sb.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,712 @@
+// Package debugger adds breakpoints, single-stepping and register/memory editing on top of a chip8.Chip8.
+//
+// The emulation loop calls Debugger.Step() in place of Chip8.Step(). Whenever the machine halts
+// (breakpoint, single-step, unknown opcode or an explicit `halt`) Step() blocks until a command
+// received by Exec (or the stdin REPL started with RunREPL) resumes it.
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+const helpText = `Commands (addresses and values are hex, 0x prefix optional, and addresses can be
+labels when the ROM has symbols):
+  c, continue          resume execution
+  n, next              execute a single instruction
+  o, over              like next, but runs a CALL until it returns
+  halt                 stop execution at the next instruction
+  b, break <addr> [if <expr>] [after <n>]
+                       set a breakpoint, only halting when expr is true and from its
+                       nth hit on. * for addr checks expr before every instruction
+  d, delete <addr>     clear a breakpoint (* for the one on every instruction)
+  bl                   list breakpoints
+  watch <addr>[-<end>] [r|w|rw]
+                       halt after an instruction reads/writes the range (default: w)
+  unwatch <addr>       clear the watchpoint starting at addr
+  wl                   list watchpoints
+  i, inspect           dump registers and display expressions
+  p, print <expr>      evaluate an expression, e.g. V[3] + I or mem[0x300] (numbers are
+                       decimal unless 0x-prefixed)
+  display [expr]       show expr every time the machine halts, changes marked with *
+                       (lists them without expr)
+  undisplay <n>        stop showing display expression n
+  l, list [addr] [n]   disassemble n instructions from addr (default: PC)
+  x <addr> [n]         dump n bytes of memory (default: 16)
+  set <reg> <value>    set V0-VF, I, PC, SP, DT or ST
+  rewind [n]           go back n frames (default: 1), needs Chip8.SetRewindBuffer
+  back [n]             step back n instructions (default: 1), timers and screen included
+  w <addr> <byte>...   write bytes to memory
+  h, help              show this help
+`
+
+// stepHistory is how many instructions `back` can undo
+const stepHistory = 1000
+
+// Debugger controls execution of a Chip8
+type Debugger struct {
+	emu *chip8.Chip8
+	out io.Writer
+
+	// BreakOnUnknown halts the machine instead of returning an error when an unknown opcode is executed
+	BreakOnUnknown bool
+
+	mu          sync.Mutex
+	breakpoints map[int]*breakpoint // By address, anyAddr for the one checked everywhere
+	watchpoints []watchpoint
+	watchHit    string // Description of the last watchpoint hit, halts before the next instruction
+	displays    []*display
+	displayID   int     // Number of the last display expression added
+	stepOver    *uint16 // Temporary breakpoint used by `over`
+	halted      bool
+	haltReq     bool // Set by `halt`, honoured before the next instruction
+	stepping    bool // Halt again before the next instruction
+	skipBreak   bool // Ignore a breakpoint at the current PC when resuming from it
+	detached    bool // Never halt again, see Detach
+	resume      chan struct{}
+}
+
+// anyAddr is the key of the breakpoint checked before every instruction, `b *`
+const anyAddr = -1
+
+// breakpoint halts when PC reaches addr, cond (if any) is true and it has been hit after times
+type breakpoint struct {
+	addr  int
+	name  string // The label addr was given as, if any
+	cond  *Expr
+	after int
+	hits  int
+}
+
+func (b *breakpoint) String() string {
+	s := "*"
+	if b.addr != anyAddr {
+		s = fmt.Sprintf("0x%03X", b.addr)
+	}
+	if b.name != "" {
+		s += " " + b.name
+	}
+	if b.cond != nil {
+		s += " if " + b.cond.String()
+	}
+	if b.after > 1 {
+		s += fmt.Sprintf(" after %d", b.after)
+	}
+	return s + fmt.Sprintf(" (hits: %d)", b.hits)
+}
+
+// hit counts a hit if PC is at b and its condition holds, and reports whether to halt
+func (b *breakpoint) hit(emu *chip8.Chip8) bool {
+	if b.cond != nil && b.cond.Eval(emu) == 0 {
+		return false
+	}
+	b.hits++
+	return b.hits >= b.after
+}
+
+// watchpoint covers the inclusive address range start..end
+type watchpoint struct {
+	start, end  uint16
+	read, write bool
+}
+
+func (w watchpoint) String() string {
+	mode := ""
+	if w.read {
+		mode += "r"
+	}
+	if w.write {
+		mode += "w"
+	}
+	return fmt.Sprintf("0x%03X-0x%03X %s", w.start, w.end, mode)
+}
+
+// display is an expression shown each time the machine halts
+type display struct {
+	id    int
+	expr  *Expr
+	last  int
+	shown bool // last is set
+}
+
+// New attaches a debugger to emu. Status messages are written to out.
+// The machine starts halted so breakpoints can be set before the program runs. A history of
+// snapshots is kept on emu for `back` until Detach.
+func New(emu *chip8.Chip8, out io.Writer) *Debugger {
+	d := &Debugger{
+		emu:            emu,
+		out:            out,
+		BreakOnUnknown: true,
+		breakpoints:    map[int]*breakpoint{},
+		haltReq:        true,
+		resume:         make(chan struct{}),
+	}
+	emu.SetMemoryWatcher(d.onMemoryAccess)
+	emu.SetStepHistory(stepHistory)
+	return d
+}
+
+// onMemoryAccess runs on the emulation goroutine in the middle of an instruction,
+// so it only records the hit and Step() halts once the instruction has finished
+func (d *Debugger) onMemoryAccess(addr uint16, value byte, write bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.watchHit != "" {
+		return
+	}
+	for _, w := range d.watchpoints {
+		if addr < w.start || addr > w.end || (write && !w.write) || (!write && !w.read) {
+			continue
+		}
+		verb := "read"
+		if write {
+			verb = "write"
+		}
+		d.watchHit = fmt.Sprintf("watchpoint %s: %s 0x%02X at 0x%03X by 0x%03X", w, verb, value, addr, d.emu.PC-2)
+		return
+	}
+}
+
+// Step executes a single instruction unless the machine should halt first,
+// in which case it blocks until execution is resumed
+func (d *Debugger) Step() error {
+	pc := d.emu.Registers().PC
+	d.mu.Lock()
+	reason := ""
+	breakHit := ""
+	if !d.detached && !d.skipBreak {
+		breakHit = d.checkBreakpoints(pc)
+	}
+	switch {
+	case d.detached:
+	case d.haltReq:
+		reason = "halted"
+	case d.watchHit != "":
+		reason = d.watchHit
+	case d.stepping:
+		reason = "step"
+	case d.stepOver != nil && *d.stepOver == pc:
+		reason = "step over"
+	case breakHit != "":
+		reason = breakHit
+	}
+	d.skipBreak = false
+	if reason != "" {
+		d.halt(reason)
+	}
+	d.mu.Unlock()
+
+	if reason != "" {
+		<-d.resume
+		d.mu.Lock()
+		d.skipBreak = false // Only needed when an error halted, the instruction here runs next anyway
+		d.mu.Unlock()
+	}
+
+	err := d.emu.Step()
+	if err != nil && d.BreakOnUnknown {
+		d.mu.Lock()
+		if d.detached {
+			d.mu.Unlock()
+			return err
+		}
+		d.halt(err.Error())
+		d.mu.Unlock()
+		<-d.resume
+		return nil
+	}
+	return err
+}
+
+// checkBreakpoints counts hits on the breakpoints at pc and everywhere, and describes the one to
+// halt on, if any. Must be called with d.mu held.
+func (d *Debugger) checkBreakpoints(pc uint16) string {
+	reason := ""
+	for _, addr := range []int{int(pc), anyAddr} {
+		if b := d.breakpoints[addr]; b != nil && b.hit(d.emu) && reason == "" {
+			reason = "breakpoint " + b.String()
+		}
+	}
+	return reason
+}
+
+// Detach stops halting the machine and resumes it if it's halted, so the goroutine running it
+// can finish its frame and be shut down
+func (d *Debugger) Detach() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.detached = true
+	d.emu.SetStepHistory(0)
+	if d.halted {
+		d.cont()
+	}
+}
+
+// RunFrame is the debugger's equivalent of Chip8.RunFrame, stepping through
+// the rest of the current 60Hz frame while honouring breakpoints
+func (d *Debugger) RunFrame() error {
+	frame := d.emu.Frames()
+	for d.emu.Frames() == frame {
+		if err := d.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// halt must be called with d.mu held
+func (d *Debugger) halt(reason string) {
+	d.halted = true
+	d.haltReq = false
+	d.watchHit = ""
+	d.stepping = false
+	d.stepOver = nil
+	d.emu.Pause() // Stop the timers while halted
+	fmt.Fprintf(d.out, "[%s] %s\n%s(dbg) ", reason, d.current(), d.showDisplays(d.displays, true))
+}
+
+// showDisplays evaluates ds, marking the ones that changed since they were last shown.
+// update records the values for next time.
+func (d *Debugger) showDisplays(ds []*display, update bool) string {
+	var sb strings.Builder
+	for _, x := range ds {
+		v := x.expr.Eval(d.emu)
+		mark := " "
+		if x.shown && v != x.last {
+			mark = "*"
+		}
+		fmt.Fprintf(&sb, "%s %d: %s = %s\n", mark, x.id, x.expr, formatValue(v))
+		if update {
+			x.last, x.shown = v, true
+		}
+	}
+	return sb.String()
+}
+
+func formatValue(v int) string {
+	if v < 0 {
+		return fmt.Sprintf("%d", v)
+	}
+	return fmt.Sprintf("0x%X (%d)", v, v)
+}
+
+// Inspect returns the machine's registers like Chip8.Inspect, followed by the display
+// expressions. It's safe to call while the machine runs.
+func (d *Debugger) Inspect() string {
+	d.mu.Lock()
+	ds := make([]*display, len(d.displays))
+	for i, x := range d.displays {
+		c := *x
+		ds[i] = &c
+	}
+	d.mu.Unlock() // Evaluating locks the machine, which may be waiting for d.mu in onMemoryAccess
+	return d.emu.Inspect() + d.showDisplays(ds, false)
+}
+
+// cont must be called with d.mu held
+func (d *Debugger) cont() {
+	d.halted = false
+	d.skipBreak = true
+	d.emu.Resume()
+	d.resume <- struct{}{}
+}
+
+// current disassembles the instruction at PC, after the label it's under if there are symbols
+func (d *Debugger) current() string {
+	pc := d.emu.Registers().PC
+	op := append(d.emu.ReadMemory(pc, 2), 0, 0) // Zero past the end of memory
+	syms := d.emu.Symbols()
+	line := disasm.Decode(pc, uint16(op[0])<<8|uint16(op[1])).WithSymbols(syms).String()
+	if loc := syms.Locate(pc); loc != "" {
+		line = loc + ": " + line
+	}
+	return line
+}
+
+// RunREPL reads commands from in until it is closed
+func (d *Debugger) RunREPL(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		out := d.Exec(scanner.Text())
+		if out != "" {
+			fmt.Fprint(d.out, out)
+		}
+		d.mu.Lock()
+		if d.halted {
+			fmt.Fprint(d.out, "(dbg) ")
+		}
+		d.mu.Unlock()
+	}
+}
+
+// Exec runs a single debugger command and returns its output
+func (d *Debugger) Exec(line string) string {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return ""
+	}
+
+	syms := d.emu.Symbols() // Before d.mu, see Inspect
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "h", "help":
+		return helpText
+	case "halt":
+		if d.halted {
+			return "already halted\n"
+		}
+		d.haltReq = true
+		return ""
+	case "b", "break":
+		b, err := parseBreakpoint(args, syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		d.breakpoints[b.addr] = b
+		return ""
+	case "d", "delete":
+		if len(args) != 1 {
+			return "usage: delete <addr>\n"
+		}
+		addr := anyAddr
+		if args[0] != "*" {
+			a, err := parseAddr(args[0], syms)
+			if err != nil {
+				return err.Error() + "\n"
+			}
+			addr = a
+		}
+		delete(d.breakpoints, addr)
+		return ""
+	case "watch":
+		if len(args) < 1 || len(args) > 2 {
+			return "usage: watch <addr>[-<end>] [r|w|rw]\n"
+		}
+		w, err := parseWatchpoint(args, syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		d.watchpoints = append(d.watchpoints, w)
+		return ""
+	case "unwatch":
+		if len(args) != 1 {
+			return "usage: unwatch <addr>\n"
+		}
+		addr, err := parseAddr(args[0], syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		kept := d.watchpoints[:0]
+		for _, w := range d.watchpoints {
+			if int(w.start) != addr {
+				kept = append(kept, w)
+			}
+		}
+		d.watchpoints = kept
+		return ""
+	case "wl":
+		var sb strings.Builder
+		for _, w := range d.watchpoints {
+			sb.WriteString(w.String() + "\n")
+		}
+		return sb.String()
+	case "display":
+		if len(args) == 0 && d.halted {
+			return d.showDisplays(d.displays, false)
+		}
+		if len(args) == 0 { // Evaluating could deadlock with onMemoryAccess while running
+			var sb strings.Builder
+			for _, x := range d.displays {
+				fmt.Fprintf(&sb, "  %d: %s\n", x.id, x.expr)
+			}
+			return sb.String()
+		}
+		e, err := parseExpr(strings.Join(args, " "), syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		d.displayID++
+		d.displays = append(d.displays, &display{id: d.displayID, expr: e})
+		return ""
+	case "undisplay":
+		if len(args) != 1 {
+			return "usage: undisplay <n>\n"
+		}
+		id, err := strconv.Atoi(args[0])
+		kept := d.displays[:0]
+		for _, x := range d.displays {
+			if x.id != id {
+				kept = append(kept, x)
+			}
+		}
+		if err != nil || len(kept) == len(d.displays) {
+			return fmt.Sprintf("no display expression %q\n", args[0])
+		}
+		d.displays = kept
+		return ""
+	case "bl":
+		var addrs []int
+		for a := range d.breakpoints {
+			addrs = append(addrs, a)
+		}
+		sort.Ints(addrs) // * first
+		var sb strings.Builder
+		for _, a := range addrs {
+			sb.WriteString(d.breakpoints[a].String() + "\n")
+		}
+		return sb.String()
+	}
+
+	// Everything below needs a stopped machine
+	if !d.halted {
+		return "running, use `halt` first\n"
+	}
+
+	switch cmd {
+	case "c", "continue":
+		d.cont()
+	case "n", "next":
+		d.stepping = true
+		d.cont()
+	case "o", "over":
+		pc := d.emu.Registers().PC
+		if op := d.emu.ReadMemory(pc, 1); len(op) == 1 && op[0]&0xF0 == 0x20 { // 2nnn - CALL addr
+			ret := pc + 2
+			d.stepOver = &ret
+		} else {
+			d.stepping = true
+		}
+		d.cont()
+	case "i", "inspect":
+		return d.emu.Inspect() + d.showDisplays(d.displays, false)
+	case "p", "print":
+		if len(args) == 0 {
+			return "usage: print <expr>\n"
+		}
+		e, err := parseExpr(strings.Join(args, " "), syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		return formatValue(e.Eval(d.emu)) + "\n"
+	case "l", "list":
+		addr, n := int(d.emu.Registers().PC), 10
+		if len(args) > 0 {
+			a, err := parseAddr(args[0], syms)
+			if err != nil {
+				return err.Error() + "\n"
+			}
+			addr = a
+		}
+		if len(args) > 1 {
+			c, err := strconv.Atoi(args[1])
+			if err != nil {
+				return fmt.Sprintf("invalid count %q\n", args[1])
+			}
+			n = c
+		}
+		return disasm.ListingSymbols(d.emu.ReadMemory(uint16(addr), 2*n), uint16(addr), syms)
+	case "x":
+		if len(args) < 1 {
+			return "usage: x <addr> [n]\n"
+		}
+		addr, err := parseAddr(args[0], syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		n := 16
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Sprintf("invalid count %q\n", args[1])
+			}
+		}
+		return hexDump(d.emu.ReadMemory(0, addr+n), addr, n)
+	case "rewind":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil {
+				return fmt.Sprintf("invalid count %q\n", args[0])
+			}
+		}
+		if err := d.emu.Rewind(n); err != nil {
+			return err.Error() + "\n"
+		}
+		return d.current() + "\n"
+	case "back":
+		n := 1
+		if len(args) > 0 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+				return fmt.Sprintf("invalid count %q\n", args[0])
+			}
+		}
+		if avail := d.emu.StepBackAvailable(); n > avail {
+			return fmt.Sprintf("can only step back %d instructions\n", avail)
+		}
+		for i := 0; i < n; i++ {
+			if err := d.emu.StepBack(); err != nil {
+				return err.Error() + "\n"
+			}
+		}
+		return d.current() + "\n" + d.showDisplays(d.displays, true)
+	case "set":
+		if len(args) != 2 {
+			return "usage: set <reg> <value>\n"
+		}
+		return d.setRegister(args[0], args[1])
+	case "w":
+		if len(args) < 2 {
+			return "usage: w <addr> <byte>...\n"
+		}
+		addr, err := parseAddr(args[0], syms)
+		if err != nil {
+			return err.Error() + "\n"
+		}
+		data := make([]byte, len(args)-1)
+		for i, s := range args[1:] {
+			b, err := parseHex(s, 0xFF)
+			if err != nil {
+				return err.Error() + "\n"
+			}
+			data[i] = byte(b)
+		}
+		if err := d.emu.WriteMemory(uint16(addr), data); err != nil {
+			return "write past end of memory\n"
+		}
+	default:
+		return fmt.Sprintf("unknown command %q, try `help`\n", cmd)
+	}
+	return ""
+}
+
+func (d *Debugger) setRegister(name string, value string) string {
+	reg, err := chip8.ParseRegister(name)
+	if err != nil {
+		return fmt.Sprintf("unknown register %q\n", strings.ToUpper(name))
+	}
+	v, err := parseHex(value, 0xFFFF)
+	if err != nil {
+		return err.Error() + "\n"
+	}
+	if err := d.emu.SetRegister(reg, v); err != nil {
+		return err.Error() + "\n"
+	}
+	return ""
+}
+
+// parseBreakpoint parses the arguments of `break`: <addr|*> [if <expr>] [after <n>]
+func parseBreakpoint(args []string, syms *symbols.Table) (*breakpoint, error) {
+	usage := fmt.Errorf("usage: break <addr> [if <expr>] [after <n>]")
+	if len(args) == 0 {
+		return nil, usage
+	}
+	b := &breakpoint{addr: anyAddr, after: 1}
+	if args[0] != "*" {
+		addr, err := parseAddr(args[0], syms)
+		if err != nil {
+			return nil, err
+		}
+		b.addr = addr
+		if _, ok := syms.Addr(args[0]); ok {
+			b.name = args[0]
+		}
+	}
+	args = args[1:]
+	if n := len(args); n >= 2 && args[n-2] == "after" {
+		after, err := strconv.Atoi(args[n-1])
+		if err != nil || after < 1 {
+			return nil, fmt.Errorf("invalid hit count %q", args[n-1])
+		}
+		b.after, args = after, args[:n-2]
+	}
+	if len(args) > 0 {
+		if args[0] != "if" || len(args) == 1 {
+			return nil, usage
+		}
+		cond, err := parseExpr(strings.Join(args[1:], " "), syms)
+		if err != nil {
+			return nil, err
+		}
+		b.cond = cond
+	}
+	if b.addr == anyAddr && b.cond == nil {
+		return nil, fmt.Errorf("a breakpoint on every instruction needs a condition")
+	}
+	return b, nil
+}
+
+func parseWatchpoint(args []string, syms *symbols.Table) (watchpoint, error) {
+	var w watchpoint
+	bounds := strings.SplitN(args[0], "-", 2)
+	start, err := parseAddr(bounds[0], syms)
+	if err != nil {
+		return w, err
+	}
+	end := start
+	if len(bounds) == 2 {
+		if end, err = parseAddr(bounds[1], syms); err != nil {
+			return w, err
+		}
+	}
+	if end < start {
+		return w, fmt.Errorf("invalid range %q", args[0])
+	}
+	w.start, w.end = uint16(start), uint16(end)
+
+	mode := "w"
+	if len(args) == 2 {
+		mode = args[1]
+	}
+	switch mode {
+	case "r":
+		w.read = true
+	case "w":
+		w.write = true
+	case "rw", "wr":
+		w.read, w.write = true, true
+	default:
+		return w, fmt.Errorf("invalid watch mode %q, expected r, w or rw", mode)
+	}
+	return w, nil
+}
+
+// parseAddr parses an address in hex or, when syms has it, a label
+func parseAddr(s string, syms *symbols.Table) (int, error) {
+	if addr, ok := syms.Addr(s); ok {
+		return int(addr), nil
+	}
+	return parseHex(s, 0xFFF)
+}
+
+func parseHex(s string, max int) (int, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+	if err != nil || int(v) > max {
+		return 0, fmt.Errorf("invalid value %q (max 0x%X)", s, max)
+	}
+	return int(v), nil
+}
+
+func hexDump(mem []byte, addr int, n int) string {
+	var sb strings.Builder
+	for row := addr; row < addr+n && row < len(mem); row += 16 {
+		fmt.Fprintf(&sb, "0x%03X ", row)
+		for i := row; i < row+16 && i < addr+n && i < len(mem); i++ {
+			fmt.Fprintf(&sb, " %02X", mem[i])
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
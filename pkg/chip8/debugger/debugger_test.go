@@ -0,0 +1,176 @@
+package debugger
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestDetach(t *testing.T) {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x12, 0x00})
+	d := New(emu, ioutil.Discard) // Starts halted
+
+	done := make(chan error)
+	go func() { done <- d.RunFrame() }()
+	select {
+	case <-done:
+		t.Fatal("RunFrame didn't halt")
+	case <-time.After(20 * time.Millisecond):
+	}
+	d.Detach()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("RunFrame still halted after Detach")
+	}
+	if err := d.RunFrame(); err != nil || emu.Paused() {
+		t.Errorf("got err = %v, paused = %v after Detach", err, emu.Paused())
+	}
+}
+
+func TestExpr(t *testing.T) {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x12, 0x00})
+	emu.SetRegister(chip8.RegV(3), 0x10)
+	emu.SetRegister(chip8.RegI, 0x300)
+	emu.WriteMemory(0x300, []byte{0xAB})
+
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"V[3] + I", 0x310},
+		{"v3 + i", 0x310},
+		{"mem[0x300]", 0xAB},
+		{"mem[I] == 0xAB && V[1 + 2] > 15", 1},
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"-1 + !0", 0},
+		{"PC >> 4 | 1", 0x21},
+		{"10 / 0", 0},
+		{"mem[0x10000]", 0},
+	}
+	for _, tt := range tests {
+		e, err := ParseExpr(tt.src)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got := e.Eval(emu); got != tt.want {
+			t.Errorf("%q = %#x, want %#x", tt.src, got, tt.want)
+		}
+	}
+	for _, src := range []string{"", "V3 +", "(1", "foo", "mem 3", "1 = 2", "0xZZ"} {
+		if _, err := ParseExpr(src); err == nil {
+			t.Errorf("%q parsed, want an error", src)
+		}
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x70, 0x01, 0x12, 0x00}) // ADD V0, 1; JP 0x200
+	out := &syncBuffer{}
+	d := New(emu, out)
+	if got := d.Exec("display V0 * 2"); got != "" {
+		t.Fatalf("display: %q", got)
+	}
+	go d.RunFrame()
+	out.waitFor(t, 1)
+	d.Exec("n")
+	out.waitFor(t, 2)
+	if !strings.Contains(out.String(), "* 1: V0 * 2 = 0x2 (2)\n(dbg) ") {
+		t.Errorf("change not marked:\n%s", out)
+	}
+	d.Exec("n")
+	out.waitFor(t, 3)
+	if !strings.HasSuffix(out.String(), "  1: V0 * 2 = 0x2 (2)\n(dbg) ") {
+		t.Errorf("unchanged value marked:\n%s", out)
+	}
+	if got := d.Exec("p V0 + 0x10"); got != "0x11 (17)\n" {
+		t.Errorf("print = %q", got)
+	}
+	if got := d.Inspect(); !strings.HasSuffix(got, "  1: V0 * 2 = 0x2 (2)\n") {
+		t.Errorf("Inspect doesn't end with the display expression:\n%s", got)
+	}
+	if got := d.Exec("undisplay 1"); got != "" || d.Exec("display") != "" {
+		t.Errorf("undisplay: %q", got)
+	}
+	d.Detach()
+}
+
+// syncBuffer collects the debugger's output, which is written from the goroutine running it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// waitFor waits until the debugger has halted n times
+func (b *syncBuffer) waitFor(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); strings.Count(b.String(), "(dbg) ") < n; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for halt %d:\n%s", n, b)
+		}
+	}
+}
+
+func TestConditionalBreakpoints(t *testing.T) {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x70, 0x01, 0x12, 0x00}) // ADD V0, 1; JP 0x200
+	out := &syncBuffer{}
+	d := New(emu, out)
+	for _, cmd := range []string{"b 200 if", "b * after 2", "b 202 after 0", "b 202 if V0 ==", "b 202 when V0"} {
+		if got := d.Exec(cmd); got == "" {
+			t.Errorf("%q was accepted", cmd)
+		}
+	}
+	if got := d.Exec("b 0x200 if V0==5"); got != "" {
+		t.Fatalf("break: %q", got)
+	}
+	go func() {
+		for i := 0; i < 100; i++ { // Plenty of frames, they go by quickly once detached
+			d.RunFrame()
+		}
+	}()
+	out.waitFor(t, 1)
+	d.Exec("c")
+	out.waitFor(t, 2)
+	if regs := emu.Registers(); regs.V[0] != 5 || regs.PC != 0x200 {
+		t.Fatalf("halted at PC %#x with V0 = %d, want 0x200 and 5:\n%s", regs.PC, regs.V[0], out)
+	}
+
+	d.Exec("d 200")
+	d.Exec("b 202 after 3")
+	d.Exec("b * if I > 0")
+	d.Exec("c")
+	out.waitFor(t, 3)
+	if regs := emu.Registers(); regs.V[0] != 8 || regs.PC != 0x202 {
+		t.Errorf("halted at PC %#x with V0 = %d, want 0x202 and 8", regs.PC, regs.V[0])
+	}
+	if got, want := d.Exec("bl"), "* if I > 0 (hits: 0)\n0x202 after 3 (hits: 3)\n"; got != want {
+		t.Errorf("bl = %q, want %q", got, want)
+	}
+	d.Detach()
+}
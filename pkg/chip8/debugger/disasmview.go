@@ -0,0 +1,42 @@
+package debugger
+
+import (
+	"fmt"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+)
+
+// Disassembly is what the disassembly pane shows: the instructions around PC
+type Disassembly struct {
+	PC      uint16
+	Paused  bool
+	Lines   []string // "0200  6A02  LD VA, 0x02", one per instruction, addresses named after any symbols
+	Current int      // Index of the line at PC in Lines
+}
+
+// Disassemble decodes rows instructions with the one at PC in the middle, or as near it as the
+// ends of memory allow. Safe to call while another goroutine runs the machine.
+func Disassemble(emu *chip8.Chip8, rows int) Disassembly {
+	regs := emu.Registers()
+	d := Disassembly{PC: regs.PC, Paused: emu.Paused()}
+	size := emu.Profile().MemorySize
+	start := int(regs.PC) - rows/2*2
+	if last := size - rows*2; start > last {
+		start = last - int(regs.PC)%2 // Keep in step with PC
+	}
+	for start < 0 {
+		start += 2
+	}
+	mem := emu.ReadMemory(uint16(start), rows*2)
+	syms := emu.Symbols()
+	for i := 0; i+1 < len(mem); i += 2 {
+		addr := start + i
+		in := disasm.Decode(uint16(addr), uint16(mem[i])<<8|uint16(mem[i+1])).WithSymbols(syms)
+		if addr == int(regs.PC) {
+			d.Current = len(d.Lines)
+		}
+		d.Lines = append(d.Lines, fmt.Sprintf("%04X  %04X  %s", addr, in.Opcode, in.Text()))
+	}
+	return d
+}
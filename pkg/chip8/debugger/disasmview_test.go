@@ -0,0 +1,36 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestDisassemble(t *testing.T) {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x60, 0x01, 0x61, 0x02, 0x62, 0x03, 0x12, 0x00})
+	emu.SetRegister(chip8.RegPC, 0x204)
+
+	d := Disassemble(emu, 5)
+	if len(d.Lines) != 5 || d.Current != 2 || d.Lines[2] != "0204  6203  LD V2, 0x03" {
+		t.Fatalf("got current %d of %q, want 0204 in the middle of 5", d.Current, d.Lines)
+	}
+	if d.Lines[0] != "0200  6001  LD V0, 0x01" {
+		t.Errorf("first line %q", d.Lines[0])
+	}
+
+	// Near the ends of memory the window stops at them, still in step with PC
+	emu.SetRegister(chip8.RegPC, 0x201)
+	if d := Disassemble(emu, 5); d.Lines[0][:4] != "01FD" || d.Current != 2 {
+		t.Errorf("odd PC: current %d of %q", d.Current, d.Lines)
+	}
+	emu.SetRegister(chip8.RegPC, 0x002)
+	if d := Disassemble(emu, 5); d.Lines[0][:4] != "0000" || d.Current != 1 {
+		t.Errorf("PC 0x002: current %d of %q", d.Current, d.Lines)
+	}
+	size := emu.Profile().MemorySize
+	emu.SetRegister(chip8.RegPC, size-2)
+	if d := Disassemble(emu, 5); len(d.Lines) != 5 || d.Current != 4 {
+		t.Errorf("PC at the end: current %d of %q", d.Current, d.Lines)
+	}
+}
@@ -0,0 +1,287 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+// Expr is an expression over the machine's state, e.g. `V[3] + I` or `mem[0x300] == 0xFF`,
+// used by watch expressions.
+//
+// Operands are numbers (decimal, or hex with a 0x prefix), the registers V0-VF, I, PC, SP, DT
+// and ST, V[n] for the V register picked by an expression, mem[addr] for the byte at addr and,
+// in the debugger, the labels of the ROM's symbols for their addresses.
+// The operators are Go's, on ints: unary - ! ^, then * / % << >> &, + - | ^, comparisons,
+// && and ||. Comparisons and logic give 1 for true and 0 for false.
+type Expr struct {
+	src  string
+	eval evalFunc
+}
+
+// state is what an Expr is evaluated against
+type state struct {
+	regs chip8.Registers
+	emu  *chip8.Chip8 // For memory, read only when the expression asks for it
+}
+
+type evalFunc func(s *state) int
+
+// ParseExpr parses src into an Expr
+func ParseExpr(src string) (*Expr, error) {
+	return parseExpr(src, nil)
+}
+
+// parseExpr parses src, taking the labels in syms as their addresses
+func parseExpr(src string, syms *symbols.Table) (*Expr, error) {
+	p := &exprParser{src: src, syms: syms}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	eval, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in %q", p.tokens[p.pos], src)
+	}
+	return &Expr{src: strings.TrimSpace(src), eval: eval}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates e against emu's current state. Reading memory past its end gives 0.
+func (e *Expr) Eval(emu *chip8.Chip8) int {
+	return e.eval(&state{regs: emu.Registers(), emu: emu})
+}
+
+type exprParser struct {
+	src    string
+	syms   *symbols.Table
+	tokens []string
+	pos    int
+}
+
+// binaryOps by precedence, loosest first, like Go's
+var binaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-", "|", "^"},
+	{"*", "/", "%", "<<", ">>", "&"},
+}
+
+var twoCharOps = []string{"==", "!=", "<=", ">=", "<<", ">>", "&&", "||"}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isAlnum(c):
+			j := i
+			for j < len(s) && isAlnum(s[j]) {
+				j++
+			}
+			p.tokens = append(p.tokens, s[i:j])
+			i = j
+		case i+1 < len(s) && contains(twoCharOps, s[i:i+2]):
+			p.tokens = append(p.tokens, s[i:i+2])
+			i += 2
+		case strings.IndexByte("+-*/%&|^<>!()[]", c) >= 0:
+			p.tokens = append(p.tokens, s[i:i+1])
+			i++
+		default:
+			return fmt.Errorf("unexpected %q in %q", c, s)
+		}
+	}
+	if len(p.tokens) == 0 {
+		return fmt.Errorf("empty expression")
+	}
+	return nil
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) expect(tok string) error {
+	if p.peek() != tok {
+		return fmt.Errorf("expected %q in %q", tok, p.src)
+	}
+	p.pos++
+	return nil
+}
+
+// parse parses a binary expression whose operators bind at least as tightly as binaryOps[level]
+func (p *exprParser) parse(level int) (evalFunc, error) {
+	if level == len(binaryOps) {
+		return p.unary()
+	}
+	lhs, err := p.parse(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !contains(binaryOps[level], op) {
+			return lhs, nil
+		}
+		p.pos++
+		rhs, err := p.parse(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		lhs = binary(op, lhs, rhs)
+	}
+}
+
+func contains(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func binary(op string, a, b evalFunc) evalFunc {
+	switch op {
+	case "||":
+		return func(s *state) int { return truth(a(s) != 0 || b(s) != 0) }
+	case "&&":
+		return func(s *state) int { return truth(a(s) != 0 && b(s) != 0) }
+	case "==":
+		return func(s *state) int { return truth(a(s) == b(s)) }
+	case "!=":
+		return func(s *state) int { return truth(a(s) != b(s)) }
+	case "<":
+		return func(s *state) int { return truth(a(s) < b(s)) }
+	case "<=":
+		return func(s *state) int { return truth(a(s) <= b(s)) }
+	case ">":
+		return func(s *state) int { return truth(a(s) > b(s)) }
+	case ">=":
+		return func(s *state) int { return truth(a(s) >= b(s)) }
+	case "+":
+		return func(s *state) int { return a(s) + b(s) }
+	case "-":
+		return func(s *state) int { return a(s) - b(s) }
+	case "|":
+		return func(s *state) int { return a(s) | b(s) }
+	case "^":
+		return func(s *state) int { return a(s) ^ b(s) }
+	case "*":
+		return func(s *state) int { return a(s) * b(s) }
+	case "/", "%":
+		return func(s *state) int {
+			d := b(s)
+			if d == 0 {
+				return 0 // Rather than crash the debugger
+			}
+			if op == "/" {
+				return a(s) / d
+			}
+			return a(s) % d
+		}
+	case "<<":
+		return func(s *state) int { return a(s) << uint(b(s)&63) }
+	case ">>":
+		return func(s *state) int { return a(s) >> uint(b(s)&63) }
+	}
+	return func(s *state) int { return a(s) & b(s) }
+}
+
+func truth(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *exprParser) unary() (evalFunc, error) {
+	switch op := p.peek(); op {
+	case "-", "!", "^":
+		p.pos++
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "-":
+			return func(s *state) int { return -x(s) }, nil
+		case "!":
+			return func(s *state) int { return truth(x(s) == 0) }, nil
+		}
+		return func(s *state) int { return ^x(s) }, nil
+	}
+	return p.operand()
+}
+
+func (p *exprParser) operand() (evalFunc, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, fmt.Errorf("unexpected end of %q", p.src)
+	}
+	p.pos++
+	switch {
+	case tok == "(":
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case tok[0] >= '0' && tok[0] <= '9':
+		n, err := strconv.ParseInt(tok, 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in %q", tok, p.src)
+		}
+		return func(*state) int { return int(n) }, nil
+	case strings.EqualFold(tok, "mem"), strings.EqualFold(tok, "v") && p.peek() == "[":
+		if err := p.expect("["); err != nil {
+			return nil, err
+		}
+		x, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(tok, "v") {
+			return func(s *state) int { return int(s.regs.V[x(s)&0xF]) }, nil
+		}
+		return func(s *state) int {
+			addr := x(s)
+			if addr < 0 || addr > 0xFFFF {
+				return 0
+			}
+			if b := s.emu.ReadMemory(uint16(addr), 1); len(b) == 1 {
+				return int(b[0])
+			}
+			return 0
+		}, nil
+	}
+	r, err := chip8.ParseRegister(tok)
+	if err == nil {
+		return func(s *state) int { return s.regs.Get(r) }, nil
+	}
+	if addr, ok := p.syms.Addr(tok); ok {
+		return func(*state) int { return int(addr) }, nil
+	}
+	return nil, fmt.Errorf("unknown name %q in %q", tok, p.src)
+}
@@ -0,0 +1,139 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// MemoryView is a scrollable hex dump of memory with a cursor, for frontends to draw as a
+// hex editor. Bytes are typed in as two hex digits and only while the machine is paused.
+type MemoryView struct {
+	emu    *chip8.Chip8
+	rows   int
+	top    int // Address of the first row
+	cursor int
+	high   int // The first digit typed at the cursor, or -1
+}
+
+// MemoryViewColumns is the number of bytes on each row of a MemoryView
+const MemoryViewColumns = 16
+
+// Mark kinds of a MemoryView's Lines
+const (
+	MarkCursor = iota
+	MarkPC     // The two bytes of the next instruction
+	MarkI
+)
+
+// Mark highlights Len characters of line Line starting at column Col
+type Mark struct {
+	Line, Col, Len int
+	Kind           int
+}
+
+// NewMemoryView returns a view of rows rows of emu's memory, starting at the ROM
+func NewMemoryView(emu *chip8.Chip8, rows int) *MemoryView {
+	v := &MemoryView{emu: emu, rows: rows, high: -1}
+	v.Goto(0x200)
+	return v
+}
+
+// Cursor returns the address under the cursor
+func (v *MemoryView) Cursor() uint16 {
+	return uint16(v.cursor)
+}
+
+// Goto moves the cursor to addr, scrolling its row to the top of the view
+func (v *MemoryView) Goto(addr uint16) {
+	v.Move(int(addr) - v.cursor)
+	v.top = v.cursor - v.cursor%MemoryViewColumns
+	if last := v.emu.Profile().MemorySize - v.rows*MemoryViewColumns; v.top > last && last >= 0 {
+		v.top = last
+	}
+}
+
+// Move moves the cursor by delta bytes, e.g. -MemoryViewColumns for a row up, stopping at
+// the ends of memory
+func (v *MemoryView) Move(delta int) {
+	size := v.emu.Profile().MemorySize
+	v.cursor += delta
+	if v.cursor < 0 {
+		v.cursor = 0
+	}
+	if v.cursor >= size {
+		v.cursor = size - 1
+	}
+	v.high = -1
+	row := v.cursor - v.cursor%MemoryViewColumns
+	if row < v.top {
+		v.top = row
+	}
+	if last := v.top + (v.rows-1)*MemoryViewColumns; row > last {
+		v.top = row - (v.rows-1)*MemoryViewColumns
+	}
+}
+
+// Type enters a hex digit at the cursor. The second digit of a byte writes it to memory and
+// moves on to the next byte.
+func (v *MemoryView) Type(digit rune) error {
+	var n int
+	switch {
+	case digit >= '0' && digit <= '9':
+		n = int(digit - '0')
+	case digit >= 'a' && digit <= 'f':
+		n = int(digit-'a') + 10
+	case digit >= 'A' && digit <= 'F':
+		n = int(digit-'A') + 10
+	default:
+		return fmt.Errorf("%q is not a hex digit", digit)
+	}
+	if !v.emu.Paused() {
+		return fmt.Errorf("pause to edit memory")
+	}
+	if v.high < 0 {
+		v.high = n
+		return nil
+	}
+	v.emu.WriteMemory(uint16(v.cursor), []byte{byte(v.high<<4 | n)})
+	v.Move(1)
+	return nil
+}
+
+// Lines renders the view: a title with the registers and whether the machine is paused, then one line per row of memory
+// as "0200: 00 E0 ...", and the marks to highlight on them
+func (v *MemoryView) Lines() ([]string, []Mark) {
+	regs := v.emu.Registers()
+	mem := v.emu.ReadMemory(uint16(v.top), v.rows*MemoryViewColumns)
+	title := fmt.Sprintf("MEMORY %04X  PC %04X  I %04X", v.cursor, regs.PC, regs.I)
+	if v.emu.Paused() {
+		title += "  PAUSED"
+	}
+	lines := []string{title}
+	var marks []Mark
+	for r := 0; r*MemoryViewColumns < len(mem); r++ {
+		var sb strings.Builder
+		row := v.top + r*MemoryViewColumns
+		fmt.Fprintf(&sb, "%04X:", row)
+		for c := 0; c < MemoryViewColumns && r*MemoryViewColumns+c < len(mem); c++ {
+			addr, col := row+c, sb.Len()+1
+			b := fmt.Sprintf("%02X", mem[r*MemoryViewColumns+c])
+			if addr == v.cursor && v.high >= 0 {
+				b = fmt.Sprintf("%X_", v.high)
+			}
+			sb.WriteString(" " + b)
+			mark := func(kind int) { marks = append(marks, Mark{Line: len(lines), Col: col, Len: 2, Kind: kind}) }
+			switch {
+			case addr == v.cursor:
+				mark(MarkCursor)
+			case addr == int(regs.PC) || addr == int(regs.PC)+1:
+				mark(MarkPC)
+			case addr == int(regs.I):
+				mark(MarkI)
+			}
+		}
+		lines = append(lines, sb.String())
+	}
+	return lines, marks
+}
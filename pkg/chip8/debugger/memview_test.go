@@ -0,0 +1,52 @@
+package debugger
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestMemoryView(t *testing.T) {
+	emu := chip8.NewChip8()
+	if _, err := emu.LoadRomBytes([]byte{0xA2, 0x10, 0x00, 0xE0}); err != nil {
+		t.Fatal(err)
+	}
+	v := NewMemoryView(emu, 2)
+	v.Move(3)
+	lines, marks := v.Lines()
+	want := []string{
+		"MEMORY 0203  PC 0200  I 0000",
+		"0200: A2 10 00 E0 00 00 00 00 00 00 00 00 00 00 00 00",
+		"0210: 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got lines %q, want %q", lines, want)
+	}
+	wantMarks := []Mark{{1, 6, 2, MarkPC}, {1, 9, 2, MarkPC}, {1, 15, 2, MarkCursor}}
+	if !reflect.DeepEqual(marks, wantMarks) {
+		t.Errorf("got marks %v, want %v", marks, wantMarks)
+	}
+
+	if err := v.Type('4'); err == nil {
+		t.Error("Type edited memory while running")
+	}
+	emu.Pause()
+	for _, d := range "4f" {
+		if err := v.Type(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if emu.Memory[0x203] != 0x4F || v.Cursor() != 0x204 {
+		t.Errorf("after typing 4F: [0x203] = %02X, cursor %03X", emu.Memory[0x203], v.Cursor())
+	}
+
+	v.Move(2 * MemoryViewColumns)
+	if lines, _ := v.Lines(); lines[1][:5] != "0210:" {
+		t.Errorf("moving past the last row didn't scroll: %q", lines[1])
+	}
+	v.Goto(0xFFFF)
+	if v.Cursor() != 0xFFF {
+		t.Errorf("Goto past the end: cursor %03X", v.Cursor())
+	}
+}
@@ -0,0 +1,55 @@
+package debugger
+
+import "github.com/dustinbowers/chip8emu/pkg/chip8"
+
+// DefaultSpriteHeight is the height a SpriteSheet uses when PC isn't at a Dxyn
+const DefaultSpriteHeight = 8
+
+// spritesAround is how many sprites a SpriteSheet shows on either side of I
+const spritesAround = 2
+
+// Sprite is memory decoded the way Dxyn draws it: a byte per row, the leftmost pixel in bit 7
+type Sprite struct {
+	Addr uint16
+	Rows []byte
+}
+
+// SpriteSheet is what the sprite viewer shows: the sprite at I, the sprites next to it in memory
+// and the font
+type SpriteSheet struct {
+	I       uint16
+	Height  int      // Rows per sprite: the n of the Dxyn at PC, or DefaultSpriteHeight
+	Drawing bool     // PC is at a Dxyn, so Sprites[Current] is about to be drawn
+	Sprites []Sprite // Consecutive sprites of Height rows, I's among them
+	Current int      // Index of the sprite at I in Sprites
+	Font    []Sprite // The 16 hex digits of the small font
+}
+
+// Sprites decodes the sprites around I, safe to call while another goroutine runs the machine
+func Sprites(emu *chip8.Chip8) SpriteSheet {
+	regs := emu.Registers()
+	sheet := SpriteSheet{I: regs.I, Height: DefaultSpriteHeight}
+	if op := emu.ReadMemory(regs.PC, 2); len(op) == 2 && op[0]&0xF0 == 0xD0 && op[1]&0x0F != 0 {
+		sheet.Height, sheet.Drawing = int(op[1]&0x0F), true
+	}
+	for i := -spritesAround; i <= spritesAround; i++ {
+		addr := int(regs.I) + i*sheet.Height
+		if addr < 0 {
+			continue
+		}
+		if addr >= len(emu.Memory) {
+			break
+		}
+		rows := emu.ReadMemory(uint16(addr), sheet.Height)
+		if i == 0 {
+			sheet.Current = len(sheet.Sprites)
+		}
+		sheet.Sprites = append(sheet.Sprites, Sprite{Addr: uint16(addr), Rows: rows})
+	}
+	font := emu.Profile().FontAddress
+	for d := uint16(0); d < 16; d++ {
+		addr := font + d*5
+		sheet.Font = append(sheet.Font, Sprite{Addr: addr, Rows: emu.ReadMemory(addr, 5)})
+	}
+	return sheet
+}
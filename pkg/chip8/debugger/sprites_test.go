@@ -0,0 +1,33 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestSprites(t *testing.T) {
+	emu := chip8.NewChip8()
+	// LD I, 0x206; DRW V0, V0, 3; then the sprite
+	if _, err := emu.LoadRomBytes([]byte{0xA2, 0x06, 0xD0, 0x03, 0x12, 0x04, 0x18, 0x3C, 0x7E}); err != nil {
+		t.Fatal(err)
+	}
+	sheet := Sprites(emu)
+	if sheet.Drawing || sheet.Height != DefaultSpriteHeight || sheet.I != 0 {
+		t.Errorf("before LD I: got I %03X, height %d, drawing %v", sheet.I, sheet.Height, sheet.Drawing)
+	}
+	if err := emu.Step(); err != nil {
+		t.Fatal(err)
+	}
+	sheet = Sprites(emu)
+	if !sheet.Drawing || sheet.Height != 3 || len(sheet.Sprites) != 5 {
+		t.Fatalf("at DRW: got height %d, drawing %v, %d sprites", sheet.Height, sheet.Drawing, len(sheet.Sprites))
+	}
+	cur := sheet.Sprites[sheet.Current]
+	if cur.Addr != 0x206 || string(cur.Rows) != "\x18\x3C\x7E" || sheet.Sprites[0].Addr != 0x200 {
+		t.Errorf("got current sprite %03X % X, first at %03X", cur.Addr, cur.Rows, sheet.Sprites[0].Addr)
+	}
+	if len(sheet.Font) != 16 || sheet.Font[0].Rows[0] != 0xF0 {
+		t.Errorf("got font %v", sheet.Font)
+	}
+}
@@ -0,0 +1,64 @@
+package chip8
+
+import "image"
+
+// BlockSize is the size in pixels of the square blocks DirtyBlocks tracks
+const BlockSize = 8
+
+const (
+	blockCols = 64 / BlockSize
+	blockRows = 32 / BlockSize
+)
+
+// DirtyBlocks is the set of BlockSize x BlockSize blocks of the screen that changed, one bit
+// per block in row-major order: bit 0 is the top left block and bit 31 the bottom right one.
+// Renderers use it to only redraw the parts of the screen that changed.
+type DirtyBlocks uint32
+
+// AllDirty marks the whole screen as changed
+const AllDirty DirtyBlocks = 1<<(blockCols*blockRows) - 1
+
+// BlockAt returns the block containing pixel (x, y) of a 64x32 screen
+func BlockAt(x, y int) DirtyBlocks {
+	return 1 << (y/BlockSize*blockCols + x/BlockSize)
+}
+
+// BlockOf returns the block containing pixel (x, y) of screen. The blocks cover the same
+// parts of the screen at every resolution, so they're larger in pixels at 128x64.
+func BlockOf(screen *Framebuffer, x, y int) DirtyBlocks {
+	return BlockAt(x*64/screen.Width, y*32/screen.Height)
+}
+
+// Contains reports whether pixel (x, y) is in a dirty block
+func (d DirtyBlocks) Contains(x, y int) bool {
+	return d&BlockAt(x, y) != 0
+}
+
+// Rects returns the dirty blocks as rectangles in pixels of a 64x32 screen, joining
+// neighbouring blocks on the same row
+func (d DirtyBlocks) Rects() []image.Rectangle {
+	var rects []image.Rectangle
+	for by := 0; by < blockRows; by++ {
+		for bx := 0; bx < blockCols; {
+			if d&(1<<(by*blockCols+bx)) == 0 {
+				bx++
+				continue
+			}
+			start := bx
+			for bx < blockCols && d&(1<<(by*blockCols+bx)) != 0 {
+				bx++
+			}
+			rects = append(rects, image.Rect(start*BlockSize, by*BlockSize, bx*BlockSize, (by+1)*BlockSize))
+		}
+	}
+	return rects
+}
+
+// RectsOf is Rects in pixels of screen
+func (d DirtyBlocks) RectsOf(screen *Framebuffer) []image.Rectangle {
+	rects := d.Rects()
+	for i, r := range rects {
+		rects[i] = image.Rect(r.Min.X*screen.Width/64, r.Min.Y*screen.Height/32, r.Max.X*screen.Width/64, r.Max.Y*screen.Height/32)
+	}
+	return rects
+}
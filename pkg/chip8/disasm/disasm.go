@@ -0,0 +1,243 @@
+// Package disasm converts CHIP-8 machine code into annotated assembly.
+//
+// Mnemonics follow Cowgod's Chip-8 Technical Reference.
+// See: http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#3.1
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+// Instruction is a single decoded opcode
+type Instruction struct {
+	Addr     uint16 // Address the opcode was read from
+	Opcode   uint16 // Raw 2 byte opcode (or the lone trailing byte of an odd-sized ROM)
+	Size     int    // Number of ROM bytes consumed, 2 for opcodes and 1 for a trailing DB
+	Mnemonic string // e.g. "LD"
+	Operands string // e.g. "V1, 0x02"
+	Comment  string // Plain english description of what the opcode does
+	Known    bool   // false if the opcode isn't a valid CHIP-8 instruction
+}
+
+// String renders the instruction as a single listing line:
+//
+//	0x200  6A02  LD    VA, 0x02          ; VA = 0x02
+func (in Instruction) String() string {
+	raw := fmt.Sprintf("%04X", in.Opcode)
+	if in.Size == 1 {
+		raw = fmt.Sprintf("%02X  ", in.Opcode)
+	}
+	line := fmt.Sprintf("0x%03X  %s  %-5s %-16s", in.Addr, raw, in.Mnemonic, in.Operands)
+	if in.Comment != "" {
+		line += " ; " + in.Comment
+	}
+	return strings.TrimRight(line, " ")
+}
+
+// Text renders just the assembly, e.g. "LD VA, 0x02"
+func (in Instruction) Text() string {
+	if in.Operands == "" {
+		return in.Mnemonic
+	}
+	return in.Mnemonic + " " + in.Operands
+}
+
+// Disassemble decodes every 2 byte word of rom, assuming it's loaded at origin (typically 0x200).
+// Data embedded in the program (e.g. sprites) is decoded as well, unknown words are emitted as DW.
+func Disassemble(rom []byte, origin uint16) []Instruction {
+	out := make([]Instruction, 0, len(rom)/2+1)
+	for i := 0; i < len(rom); i += 2 {
+		addr := origin + uint16(i)
+		if i+1 >= len(rom) {
+			out = append(out, Instruction{
+				Addr:     addr,
+				Opcode:   uint16(rom[i]),
+				Size:     1,
+				Mnemonic: "DB",
+				Operands: fmt.Sprintf("0x%02X", rom[i]),
+				Comment:  "trailing byte",
+			})
+			break
+		}
+		out = append(out, Decode(addr, uint16(rom[i])<<8|uint16(rom[i+1])))
+	}
+	return out
+}
+
+// Listing renders a full program listing, one instruction per line
+func Listing(rom []byte, origin uint16) string {
+	var sb strings.Builder
+	for _, in := range Disassemble(rom, origin) {
+		sb.WriteString(in.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ListingSymbols is Listing with the labels in t: each labelled address gets a "name:" line
+// and jumps, calls and LD I to one name it
+func ListingSymbols(rom []byte, origin uint16, t *symbols.Table) string {
+	var sb strings.Builder
+	for _, in := range Disassemble(rom, origin) {
+		if name, ok := t.Name(in.Addr); ok {
+			sb.WriteString(name + ":\n")
+		}
+		sb.WriteString(in.WithSymbols(t).String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// WithSymbols names the address operand of JP, CALL, LD I and JP V0 after its label in t, if it has one
+func (in Instruction) WithSymbols(t *symbols.Table) Instruction {
+	if in.Size != 2 {
+		return in
+	}
+	switch in.Opcode & 0xF000 {
+	case 0x1000, 0x2000, 0xA000, 0xB000:
+		nnn := in.Opcode & 0x0FFF
+		if name, ok := t.Name(nnn); ok {
+			in.Operands = strings.Replace(in.Operands, fmt.Sprintf("0x%03X", nnn), name, 1)
+		}
+	}
+	return in
+}
+
+// Decode decodes a single opcode located at addr
+func Decode(addr uint16, opcode uint16) Instruction {
+	x := (opcode >> 8) & 0x0F
+	y := (opcode >> 4) & 0x0F
+	n := opcode & 0x000F
+	kk := opcode & 0x00FF
+	nnn := opcode & 0x0FFF
+
+	in := Instruction{Addr: addr, Opcode: opcode, Size: 2, Known: true}
+	set := func(mnemonic, comment, operands string, args ...interface{}) {
+		in.Mnemonic = mnemonic
+		in.Operands = fmt.Sprintf(operands, args...)
+		in.Comment = comment
+	}
+
+	switch opcode & 0xF000 {
+	case 0x0000:
+		switch opcode {
+		case 0x00E0:
+			set("CLS", "clear the screen", "")
+		case 0x00EE:
+			set("RET", "return from subroutine", "")
+		default:
+			set("SYS", "machine code routine (unsupported)", "0x%03X", nnn)
+			in.Known = false
+		}
+	case 0x1000:
+		set("JP", "", "0x%03X", nnn)
+		if nnn == addr {
+			in.Comment = "infinite loop"
+		}
+	case 0x2000:
+		set("CALL", "", "0x%03X", nnn)
+	case 0x3000:
+		set("SE", fmt.Sprintf("skip next if V%X == 0x%02X", x, kk), "V%X, 0x%02X", x, kk)
+	case 0x4000:
+		set("SNE", fmt.Sprintf("skip next if V%X != 0x%02X", x, kk), "V%X, 0x%02X", x, kk)
+	case 0x5000:
+		if n != 0 {
+			in.Known = false
+			break
+		}
+		set("SE", fmt.Sprintf("skip next if V%X == V%X", x, y), "V%X, V%X", x, y)
+	case 0x6000:
+		set("LD", fmt.Sprintf("V%X = 0x%02X", x, kk), "V%X, 0x%02X", x, kk)
+	case 0x7000:
+		set("ADD", fmt.Sprintf("V%X += 0x%02X", x, kk), "V%X, 0x%02X", x, kk)
+	case 0x8000:
+		switch n {
+		case 0x0:
+			set("LD", fmt.Sprintf("V%X = V%X", x, y), "V%X, V%X", x, y)
+		case 0x1:
+			set("OR", fmt.Sprintf("V%X |= V%X", x, y), "V%X, V%X", x, y)
+		case 0x2:
+			set("AND", fmt.Sprintf("V%X &= V%X", x, y), "V%X, V%X", x, y)
+		case 0x3:
+			set("XOR", fmt.Sprintf("V%X ^= V%X", x, y), "V%X, V%X", x, y)
+		case 0x4:
+			set("ADD", fmt.Sprintf("V%X += V%X, VF = carry", x, y), "V%X, V%X", x, y)
+		case 0x5:
+			set("SUB", fmt.Sprintf("V%X -= V%X, VF = not borrow", x, y), "V%X, V%X", x, y)
+		case 0x6:
+			set("SHR", fmt.Sprintf("V%X >>= 1, VF = lsb", x), "V%X, V%X", x, y)
+		case 0x7:
+			set("SUBN", fmt.Sprintf("V%X = V%X - V%X, VF = not borrow", x, y, x), "V%X, V%X", x, y)
+		case 0xE:
+			set("SHL", fmt.Sprintf("V%X <<= 1, VF = msb", x), "V%X, V%X", x, y)
+		default:
+			in.Known = false
+		}
+	case 0x9000:
+		if n != 0 {
+			in.Known = false
+			break
+		}
+		set("SNE", fmt.Sprintf("skip next if V%X != V%X", x, y), "V%X, V%X", x, y)
+	case 0xA000:
+		set("LD", "", "I, 0x%03X", nnn)
+	case 0xB000:
+		set("JP", fmt.Sprintf("jump to 0x%03X + V0", nnn), "V0, 0x%03X", nnn)
+	case 0xC000:
+		set("RND", fmt.Sprintf("V%X = random & 0x%02X", x, kk), "V%X, 0x%02X", x, kk)
+	case 0xD000:
+		set("DRW", fmt.Sprintf("draw %d byte sprite at (V%X, V%X), VF = collision", n, x, y), "V%X, V%X, %d", x, y, n)
+	case 0xE000:
+		switch kk {
+		case 0x9E:
+			set("SKP", fmt.Sprintf("skip next if key V%X is down", x), "V%X", x)
+		case 0xA1:
+			set("SKNP", fmt.Sprintf("skip next if key V%X is up", x), "V%X", x)
+		default:
+			in.Known = false
+		}
+	case 0xF000:
+		switch kk {
+		case 0x02:
+			if x != 0 {
+				in.Known = false
+				break
+			}
+			set("AUDIO", "XO-CHIP: load the 16 byte audio pattern at I", "")
+		case 0x07:
+			set("LD", fmt.Sprintf("V%X = delay timer", x), "V%X, DT", x)
+		case 0x0A:
+			set("LD", fmt.Sprintf("wait for a key press, store it in V%X", x), "V%X, K", x)
+		case 0x15:
+			set("LD", fmt.Sprintf("delay timer = V%X", x), "DT, V%X", x)
+		case 0x18:
+			set("LD", fmt.Sprintf("sound timer = V%X", x), "ST, V%X", x)
+		case 0x1E:
+			set("ADD", fmt.Sprintf("I += V%X", x), "I, V%X", x)
+		case 0x29:
+			set("LD", fmt.Sprintf("I = font sprite for digit V%X", x), "F, V%X", x)
+		case 0x33:
+			set("LD", fmt.Sprintf("store BCD of V%X at I..I+2", x), "B, V%X", x)
+		case 0x55:
+			set("LD", fmt.Sprintf("store V0..V%X at I", x), "[I], V%X", x)
+		case 0x3A:
+			set("LD", fmt.Sprintf("XO-CHIP: audio pitch = V%X", x), "PITCH, V%X", x)
+		case 0x65:
+			set("LD", fmt.Sprintf("load V0..V%X from I", x), "V%X, [I]", x)
+		case 0x75:
+			set("LD", fmt.Sprintf("SCHIP: store V0..V%X in the RPL user flags", x), "R, V%X", x)
+		case 0x85:
+			set("LD", fmt.Sprintf("SCHIP: load V0..V%X from the RPL user flags", x), "V%X, R", x)
+		default:
+			in.Known = false
+		}
+	}
+
+	if !in.Known && in.Mnemonic == "" {
+		set("DW", "unknown opcode / data", "0x%04X", opcode)
+	}
+	return in
+}
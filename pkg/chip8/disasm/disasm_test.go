@@ -0,0 +1,117 @@
+package disasm
+
+import (
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		opcode  uint16
+		text    string
+		known   bool
+		comment string // Checked when not empty
+	}{
+		{0x00E0, "CLS", true, "clear the screen"},
+		{0x00EE, "RET", true, ""},
+		{0x0123, "SYS 0x123", false, "machine code routine (unsupported)"},
+		{0x1202, "JP 0x202", true, ""},
+		{0x1200, "JP 0x200", true, "infinite loop"}, // Decoded at 0x200
+		{0x2ABC, "CALL 0xABC", true, ""},
+		{0x3A02, "SE VA, 0x02", true, "skip next if VA == 0x02"},
+		{0x4B03, "SNE VB, 0x03", true, ""},
+		{0x5120, "SE V1, V2", true, "skip next if V1 == V2"},
+		{0x5121, "DW 0x5121", false, "unknown opcode / data"},
+		{0x6A02, "LD VA, 0x02", true, "VA = 0x02"},
+		{0x7FFF, "ADD VF, 0xFF", true, ""},
+		{0x8120, "LD V1, V2", true, ""},
+		{0x8121, "OR V1, V2", true, ""},
+		{0x8122, "AND V1, V2", true, ""},
+		{0x8123, "XOR V1, V2", true, ""},
+		{0x8124, "ADD V1, V2", true, "V1 += V2, VF = carry"},
+		{0x8125, "SUB V1, V2", true, ""},
+		{0x8126, "SHR V1, V2", true, ""},
+		{0x8127, "SUBN V1, V2", true, "V1 = V2 - V1, VF = not borrow"},
+		{0x812E, "SHL V1, V2", true, ""},
+		{0x8128, "DW 0x8128", false, ""},
+		{0x9120, "SNE V1, V2", true, ""},
+		{0x9121, "DW 0x9121", false, ""},
+		{0xA300, "LD I, 0x300", true, ""},
+		{0xB300, "JP V0, 0x300", true, "jump to 0x300 + V0"},
+		{0xC10F, "RND V1, 0x0F", true, ""},
+		{0xD125, "DRW V1, V2, 5", true, "draw 5 byte sprite at (V1, V2), VF = collision"},
+		{0xE39E, "SKP V3", true, ""},
+		{0xE3A1, "SKNP V3", true, ""},
+		{0xE3A2, "DW 0xE3A2", false, ""},
+		{0xF307, "LD V3, DT", true, ""},
+		{0xF30A, "LD V3, K", true, ""},
+		{0xF315, "LD DT, V3", true, ""},
+		{0xF318, "LD ST, V3", true, ""},
+		{0xF31E, "ADD I, V3", true, ""},
+		{0xF329, "LD F, V3", true, ""},
+		{0xF333, "LD B, V3", true, ""},
+		{0xF355, "LD [I], V3", true, ""},
+		{0xF365, "LD V3, [I]", true, ""},
+		{0xF375, "LD R, V3", true, "SCHIP: store V0..V3 in the RPL user flags"},
+		{0xF385, "LD V3, R", true, ""},
+		{0xF002, "AUDIO", true, "XO-CHIP: load the 16 byte audio pattern at I"},
+		{0xF102, "DW 0xF102", false, ""},
+		{0xF33A, "LD PITCH, V3", true, ""},
+		{0xF3FF, "DW 0xF3FF", false, ""},
+	}
+	for _, tt := range tests {
+		in := Decode(0x200, tt.opcode)
+		if in.Text() != tt.text || in.Known != tt.known || in.Size != 2 || in.Addr != 0x200 || in.Opcode != tt.opcode {
+			t.Errorf("%04X: got %q known %v size %d, want %q known %v", tt.opcode, in.Text(), in.Known, in.Size, tt.text, tt.known)
+		}
+		if tt.comment != "" && in.Comment != tt.comment {
+			t.Errorf("%04X: got comment %q, want %q", tt.opcode, in.Comment, tt.comment)
+		}
+	}
+}
+
+func TestListing(t *testing.T) {
+	rom := []byte{0x6A, 0x02, 0x00, 0xE0, 0x12, 0x04, 0xFF}
+	want := "0x200  6A02  LD    VA, 0x02         ; VA = 0x02\n" +
+		"0x202  00E0  CLS                    ; clear the screen\n" +
+		"0x204  1204  JP    0x204            ; infinite loop\n" +
+		"0x206  FF    DB    0xFF             ; trailing byte\n"
+	if got := Listing(rom, 0x200); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if ins := Disassemble(rom, 0x600); len(ins) != 4 || ins[3].Addr != 0x606 || ins[3].Size != 1 {
+		t.Errorf("Disassemble at 0x600: %+v", ins)
+	}
+}
+
+func TestWithSymbols(t *testing.T) {
+	syms := symbols.FromMap(map[string]int{"main": 0x200, "draw": 0x20A, "sprite": 0x300})
+	tests := []struct {
+		opcode uint16
+		text   string
+	}{
+		{0x1200, "JP main"},
+		{0x220A, "CALL draw"},
+		{0xA300, "LD I, sprite"},
+		{0xB300, "JP V0, sprite"},
+		{0x2204, "CALL 0x204"},  // No label there
+		{0x6A02, "LD VA, 0x02"}, // Not an address
+		{0x0300, "SYS 0x300"},
+	}
+	for _, tt := range tests {
+		if got := Decode(0x200, tt.opcode).WithSymbols(syms).Text(); got != tt.text {
+			t.Errorf("%04X: got %q, want %q", tt.opcode, got, tt.text)
+		}
+	}
+	if got := Decode(0x200, 0x1200).WithSymbols(nil).Text(); got != "JP 0x200" {
+		t.Errorf("without symbols: got %q", got)
+	}
+
+	want := "main:\n" +
+		"0x200  220A  CALL  draw\n" +
+		"0x202  1200  JP    main\n"
+	if got := ListingSymbols([]byte{0x22, 0x0A, 0x12, 0x00}, 0x200, syms); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,18 @@
+// Package chip8 is the emulator core: a CHIP-8 / SUPER-CHIP / XO-CHIP / MegaChip interpreter with
+// no SDL, cgo or operating system dependencies, so it can be embedded in any frontend or run
+// headless in tests.
+//
+// The package layout, for code importing this module:
+//
+//	pkg/chip8      the interpreter (this package)
+//	pkg/chip8/...  optional tools built on the core: asm, disasm, debugger, movie, netplay, api, ...
+//	pkg/display    what frontends present: Display, AudioSink and the Framebuffer screen
+//	pkg/input      what frontends read keys from: Keypad
+//	ui/...         the SDL, terminal and browser frontends
+//	cmd/chip8emu   the emulator program
+//
+// Load a ROM with LoadRomBytes, attach devices with SetDisplay, SetKeyProvider and SetAudioSink,
+// then drive the machine with RunFrame 60 times a second, or let Run keep the time. Read its
+// state through methods such as Registers, ReadMemory and SnapshotScreen, which are safe to
+// call while another goroutine runs the machine; the exported fields are not.
+package chip8
@@ -0,0 +1,47 @@
+package chip8_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/asm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/chip8test"
+)
+
+// TestDrawEdges locks in how Dxyn treats sprites crossing the screen edges, with and without
+// the ClipSprites quirk. Goldens are in testdata/, regenerate them with go test -update.
+func TestDrawEdges(t *testing.T) {
+	tests := []struct {
+		golden string
+		x, y   int
+		quirks chip8.Quirks
+	}{
+		{"draw-inside.txt", 28, 13, chip8.Quirks{}},
+		{"draw-wrap-right.txt", 60, 10, chip8.Quirks{}},
+		{"draw-wrap-bottom.txt", 20, 30, chip8.Quirks{}},
+		{"draw-wrap-corner.png", 60, 30, chip8.Quirks{}},
+		{"draw-wrap-start.txt", 64 + 60, 32 + 30, chip8.Quirks{}},
+		{"draw-clip-right.txt", 60, 10, chip8.Quirks{ClipSprites: true}},
+		{"draw-clip-bottom.txt", 20, 30, chip8.Quirks{ClipSprites: true}},
+		{"draw-clip-corner.png", 60, 30, chip8.Quirks{ClipSprites: true}},
+		{"draw-clip-start.txt", 64 + 60, 32 + 30, chip8.Quirks{ClipSprites: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			rom, err := asm.Assemble(fmt.Sprintf(`
+				LD  V0, %d
+				LD  V1, %d
+				LD  I, sprite
+				DRW V0, V1, 6
+			loop:	JP  loop
+			sprite:	db  0xFF, 0x81, 0xA5, 0x81, 0xBD, 0xFF
+			`, tt.x, tt.y))
+			if err != nil {
+				t.Fatal(err)
+			}
+			screen := chip8test.Run(t, rom, 5, tt.quirks)
+			chip8test.Golden(t, "testdata/"+tt.golden, screen)
+		})
+	}
+}
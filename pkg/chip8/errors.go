@@ -0,0 +1,100 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned (wrapped in an *EmuError) when the machine can't execute an instruction.
+// Test for them with errors.Is.
+var (
+	ErrUnknownOpcode     = errors.New("unknown opcode")
+	ErrStackOverflow     = errors.New("stack overflow")       // CALL with all 16 levels in use
+	ErrStackUnderflow    = errors.New("stack underflow")      // RET without a CALL
+	ErrMemoryOutOfBounds = errors.New("memory out of bounds") // An access through I past 0xFFF
+	ErrPCOutOfBounds     = errors.New("PC out of bounds")     // Execution past 0xFFF or into the interpreter area below 0x200
+)
+
+// Errors returned (wrapped in a *RomError) when a ROM can't be loaded
+var (
+	ErrRomEmpty    = errors.New("ROM is empty")
+	ErrRomTooLarge = errors.New("ROM too large")
+)
+
+// RomError describes a ROM that couldn't be loaded
+type RomError struct {
+	Err  error  // ErrRomEmpty or ErrRomTooLarge
+	Size int    // Of the ROM, in bytes
+	Addr uint16 // Where it was to be loaded
+	Max  int    // The largest ROM that fits at Addr
+}
+
+func (e *RomError) Error() string {
+	if e.Err == ErrRomTooLarge {
+		return fmt.Sprintf("loadRom: %v: %d bytes, at most %d fit at %#03x", e.Err, e.Size, e.Max, e.Addr)
+	}
+	return fmt.Sprintf("loadRom: %v", e.Err)
+}
+
+func (e *RomError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCategory classifies an *EmuError, so frontends can react to a class of errors without
+// knowing every cause
+type ErrorCategory int
+
+const (
+	CategoryDecode ErrorCategory = iota // ErrUnknownOpcode
+	CategoryMemory                      // ErrMemoryOutOfBounds and ErrPCOutOfBounds
+	CategoryStack                       // ErrStackOverflow and ErrStackUnderflow
+	CategoryIO                          // A device failed, Err is what it returned
+)
+
+var categoryNames = [...]string{"decode", "memory", "stack", "io"}
+
+func (c ErrorCategory) String() string {
+	if c < 0 || int(c) >= len(categoryNames) {
+		return fmt.Sprintf("ErrorCategory(%d)", int(c))
+	}
+	return categoryNames[c]
+}
+
+// EmuError describes an instruction that couldn't be executed. Its registers and memory are left
+// untouched, though PC may already point past it.
+type EmuError struct {
+	Category ErrorCategory
+	Err      error  // One of the Err* values above, or the device's error for CategoryIO
+	PC       uint16 // Address of the instruction
+	Opcode   uint16 // The instruction, 0 if it couldn't be fetched
+	Detail   string // What exactly went wrong, may be empty
+}
+
+// Fault is kept for compatibility, see EmuError
+type Fault = EmuError
+
+func (e *EmuError) Error() string {
+	switch {
+	case e.Category == CategoryIO:
+		return fmt.Sprintf("%s at %#03x: %v", e.Detail, e.PC, e.Err)
+	case e.Detail == "":
+		return fmt.Sprintf("%v at %#03x", e.Err, e.PC)
+	}
+	return fmt.Sprintf("%v at %#03x: %s", e.Err, e.PC, e.Detail)
+}
+
+func (e *EmuError) Unwrap() error {
+	return e.Err
+}
+
+// fault returns an *EmuError for the current instruction. emulateCycle fills in PC and Opcode.
+func fault(err error, format string, args ...interface{}) *EmuError {
+	c := CategoryMemory
+	switch err {
+	case ErrUnknownOpcode:
+		c = CategoryDecode
+	case ErrStackOverflow, ErrStackUnderflow:
+		c = CategoryStack
+	}
+	return &EmuError{Category: c, Err: err, Detail: fmt.Sprintf(format, args...)}
+}
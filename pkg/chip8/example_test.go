@@ -0,0 +1,102 @@
+package chip8_test
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// Running a ROM headless and looking at what it drew, as a test would
+func Example() {
+	emu := chip8.NewChip8()
+	rom := []byte{
+		0x60, 0x00, // LD V0, 0
+		0xF0, 0x29, // LD F, V0
+		0xD0, 0x05, // DRW V0, V0, 5
+	}
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := emu.RunFor(3); err != nil {
+		fmt.Println(err)
+		return
+	}
+	screen, _ := emu.SnapshotScreen()
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 4; x++ {
+			if screen.At(x, y) != 0 {
+				fmt.Print("#")
+			} else {
+				fmt.Print(".")
+			}
+		}
+		fmt.Println()
+	}
+	// Output:
+	// ####
+	// #..#
+	// #..#
+	// #..#
+	// ####
+}
+
+// countingDisplay is the smallest display.Display
+type countingDisplay struct{ draws int }
+
+func (d *countingDisplay) Draw(screen *chip8.Framebuffer) error {
+	d.draws++
+	return nil
+}
+
+func ExampleChip8_SetDisplay() {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x00, 0xE0, 0x00, 0xE0, 0x12, 0x04}) // CLS; CLS; JP 0x204
+	d := &countingDisplay{}
+	emu.SetDisplay(d)
+	emu.RunFor(10)
+	fmt.Println(d.draws, "draws")
+	// Output: 2 draws
+}
+
+func ExampleChip8_Run() {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0x60, 0x2A, 0x12, 0x02}) // LD V0, 42; JP 0x202
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	emu.SetRunCallbacks(chip8.RunCallbacks{
+		Finished: func(pc uint16) {
+			fmt.Printf("finished at %#03x with V0 = %d\n", pc, emu.Registers().V[0])
+			cancel()
+		},
+	})
+	err := emu.Run(ctx, 1000)
+	fmt.Println(err)
+	// Output:
+	// finished at 0x202 with V0 = 42
+	// context canceled
+}
+
+// tappingKeypad is an input.Keypad tapping a key: down on its second poll, up again on its fourth
+type tappingKeypad struct {
+	key   int
+	polls int
+}
+
+func (k *tappingKeypad) Keys() (keys [16]bool) {
+	k.polls++
+	keys[k.key] = k.polls == 2 || k.polls == 3
+	return keys
+}
+
+func ExampleChip8_SetKeyProvider() {
+	emu := chip8.NewChip8()
+	emu.LoadRomBytes([]byte{0xF3, 0x0A}) // LD V3, K
+	emu.SetKeyProvider(&tappingKeypad{key: 7})
+	for emu.Registers().PC == 0x200 {
+		emu.Step()
+	}
+	fmt.Println("V3 =", emu.Registers().V[3])
+	// Output: V3 = 7
+}
@@ -0,0 +1,74 @@
+package chip8
+
+import "fmt"
+
+/*
+Finish detection:
+
+Many programs, test ROMs especially, end in a JP to itself or a few instructions looping forever.
+The machine notices once it arrives at the same backward JP twice with the same registers and
+only pure instructions ran in between: nothing that draws, beeps, writes memory or the timers,
+or reads keys, DT or RND. Nothing can change from then on, so the program has finished and
+Finished reports where. Frontends can pause it rather than burn CPU spinning in the loop.
+
+Loops checking DT or the keys never count: they're waiting for something, not finished.
+*/
+
+// loopWatch follows backward jumps for finish detection
+type loopWatch struct {
+	pc       uint16 // Of the last backward JP taken, 0 for none
+	regs     loopRegs
+	impure   bool // An impure instruction ran since
+	finished bool
+}
+
+// loopRegs is the state a pure loop can change
+type loopRegs struct {
+	V     [16]byte
+	I     uint16
+	SP    uint16
+	Stack [16]uint16
+}
+
+// pureOp reports whether op only reads memory and changes registers
+func pureOp(op uint16) bool {
+	switch op >> 12 {
+	case 0x1, 0x2, 0x3, 0x4, 0x6, 0x7, 0x8, 0xA, 0xB:
+		return true
+	case 0x5, 0x9:
+		return op&0xF == 0
+	case 0x0:
+		return op == 0x00EE
+	case 0xF:
+		switch op & 0xFF {
+		case 0x1E, 0x29, 0x30, 0x65, 0x85:
+			return true
+		}
+	}
+	return false
+}
+
+// watchLoop is called after the instruction at pc ran, must be called with ch.mu held
+func (ch *Chip8) watchLoop(pc uint16) {
+	w := &ch.loop
+	if !ch.pure {
+		w.impure = true
+	}
+	if ch.opcode>>12 != 0x1 || ch.nnn > pc {
+		return
+	}
+	regs := loopRegs{V: ch.V, I: ch.I, SP: ch.SP, Stack: ch.Stack}
+	finished := w.pc == pc && !w.impure && w.regs == regs
+	if finished && !w.finished {
+		ch.log.Info(LogCPU, "program finished", "pc", fmt.Sprintf("%#03x", pc))
+	}
+	*w = loopWatch{pc: pc, regs: regs, finished: finished}
+}
+
+// Finished reports whether the program has finished in an endless loop, and the address of the
+// loop's JP. Reset, LoadState and loading a ROM start over.
+func (ch *Chip8) Finished() (pc uint16, finished bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.loop.pc, ch.loop.finished
+}
@@ -0,0 +1,151 @@
+package chip8
+
+import (
+	"github.com/dustinbowers/chip8emu/pkg/display"
+	"github.com/dustinbowers/chip8emu/pkg/input"
+)
+
+// Framebuffer is the screen, see display.Framebuffer
+type Framebuffer = display.Framebuffer
+
+// NewFramebuffer returns a dark width x height framebuffer with the given number of planes
+func NewFramebuffer(width, height, planes int) *Framebuffer {
+	return display.NewFramebuffer(width, height, planes)
+}
+
+// Display is kept for compatibility, see display.Display
+type Display = display.Display
+
+// KeyProvider is kept for compatibility, see input.Keypad
+type KeyProvider = input.Keypad
+
+// SetDisplay attaches a Display, or detaches it when d is nil.
+// Step() calls Draw whenever an instruction changed the screen.
+// Frontends that would rather poll SnapshotScreen themselves can leave it unset.
+func (ch *Chip8) SetDisplay(d display.Display) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.display = d
+}
+
+// SetKeyProvider attaches a Keypad, or detaches it when k is nil.
+// Step() polls it before every instruction and translates changes into KeyDown / KeyUp calls.
+func (ch *Chip8) SetKeyProvider(k input.Keypad) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.keys = k
+}
+
+// SetAudioSink attaches an AudioSink, or detaches it when a is nil
+func (ch *Chip8) SetAudioSink(a display.AudioSink) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.audio = a
+	ch.updatePattern()
+}
+
+// Frame is a completed frame published by SetFrameChannel or passed to RunCallbacks.Frame
+type Frame struct {
+	Screen *Framebuffer // A copy the machine doesn't touch again
+	Dirty  DirtyBlocks  // Parts of the screen that changed since the previous frame
+}
+
+// SetFrameChannel publishes a copy of the screen on c at the end of every frame that changed it,
+// so a UI on another goroutine only ever sees completed frames rather than half drawn sprites.
+// Pass nil to stop. Publishing never blocks the machine: c should be buffered, and when the UI
+// falls behind the oldest frame waiting in c is dropped to make room for the new one (its
+// dirty blocks carry over to the new frame).
+func (ch *Chip8) SetFrameChannel(c chan Frame) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.frameOut = c
+}
+
+// SetDrawHandler calls f every time the screen may have changed: after each CLS and Dxyn, and
+// when a reset or loaded state replaces it. Pass nil to remove it.
+//
+// f runs on the goroutine running the machine, with the machine locked, so it must not call
+// back into the Chip8. screen is only valid until f returns, copy it to keep it.
+func (ch *Chip8) SetDrawHandler(f func(screen *Framebuffer)) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.onDraw = f
+}
+
+// DrawFlag reports whether the screen changed since it was last drawn by the Display or
+// taken by SnapshotScreen, without clearing it
+func (ch *Chip8) DrawFlag() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.drawFlag
+}
+
+// invalidate marks blocks of the screen as changed, must be called with ch.mu held
+func (ch *Chip8) invalidate(blocks DirtyBlocks) {
+	ch.drawFlag = true
+	ch.frameDirty |= blocks
+	ch.runDirty |= blocks
+	ch.markActive()
+	if ch.onDraw != nil {
+		ch.onDraw(ch.Screen)
+	}
+}
+
+// publishFrame sends the screen to the frame channel if it changed, must be called with ch.mu held
+func (ch *Chip8) publishFrame() {
+	if ch.frameOut == nil || ch.frameDirty == 0 {
+		return
+	}
+	f := Frame{Screen: ch.Screen.Clone(), Dirty: ch.frameDirty}
+	ch.frameDirty = 0
+	select {
+	case ch.frameOut <- f:
+		return
+	default:
+	}
+	select {
+	case old := <-ch.frameOut: // Full, drop the oldest frame
+		f.Dirty |= old.Dirty
+	default:
+	}
+	select {
+	case ch.frameOut <- f:
+	default: // Unbuffered and nobody waiting, the frame is lost
+	}
+}
+
+// SnapshotScreen returns a copy of the screen and whether it changed since the last snapshot,
+// clearing the draw flag. Safe to call while another goroutine runs the machine.
+func (ch *Chip8) SnapshotScreen() (screen *Framebuffer, changed bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	changed = ch.drawFlag
+	ch.drawFlag = false
+	return ch.Screen.Clone(), changed
+}
+
+// PeekScreen returns a copy of the screen without clearing the draw flag, so it can be used
+// alongside a frontend that relies on SnapshotScreen
+func (ch *Chip8) PeekScreen() *Framebuffer {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.Screen.Clone()
+}
+
+// pollKeys must be called with ch.mu held
+func (ch *Chip8) pollKeys() {
+	if ch.keys == nil {
+		return
+	}
+	keys := ch.keys.Keys()
+	for k, down := range keys {
+		if down == ch.keyboard[k] {
+			continue
+		}
+		if down {
+			ch.keyDown(uint8(k))
+		} else {
+			ch.keyUp(uint8(k))
+		}
+	}
+}
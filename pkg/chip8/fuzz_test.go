@@ -0,0 +1,80 @@
+//go:build go1.18
+// +build go1.18
+
+package chip8
+
+import (
+	"errors"
+	"testing"
+)
+
+// FuzzOpcode executes a single instruction from an arbitrary machine state. It must either
+// run or return a *Fault, never panic.
+func FuzzOpcode(f *testing.F) {
+	f.Add(uint16(0x00EE), uint16(0), uint8(0), uint16(0), []byte{})                // RET with an empty stack
+	f.Add(uint16(0x2200), uint16(0), uint8(15), uint16(0), []byte{})               // CALL with a full stack
+	f.Add(uint16(0xFF55), uint16(0xFFA), uint8(0), uint16(0), []byte{})            // LD [I], VF past the end of memory
+	f.Add(uint16(0xFF65), uint16(0xFFFF), uint8(0), uint16(0), []byte{})           // LD VF, [I] with I wrapping around
+	f.Add(uint16(0xF033), uint16(0xFFE), uint8(0), uint16(0), []byte{0xFF})        // LD B, V0 past the end of memory
+	f.Add(uint16(0xD01F), uint16(0xFF8), uint8(0), uint16(0), []byte{})            // DRW past the end of memory
+	f.Add(uint16(0xF002), uint16(0xFF8), uint8(0), uint16(0), []byte{})            // AUDIO past the end of memory
+	f.Add(uint16(0xE09E), uint16(0), uint8(0), uint16(0), []byte{0xFF})            // SKP with V0 > 0xF
+	f.Add(uint16(0xB0FF), uint16(0), uint8(0), uint16(0), []byte{0xFF})            // JP V0 past the end of memory
+	f.Add(uint16(0x1FFF), uint16(0), uint8(0), uint16(0), []byte{})                // JP to the last byte
+	f.Add(uint16(0x8FF4), uint16(0), uint8(0), uint16(0xFFFF), []byte{0xFF, 0xFF}) // ADD VF, VF
+	f.Fuzz(func(t *testing.T, op, i uint16, sp uint8, keys uint16, v []byte) {
+		ch := NewChip8()
+		ch.SeedRand(1)
+		ch.LoadRomBytes([]byte{byte(op >> 8), byte(op), byte(op >> 8), byte(op)})
+		ch.I = i
+		ch.SP = uint16(sp)
+		copy(ch.V[:], v)
+		for k := range ch.keyboard {
+			ch.keyboard[k] = keys&(1<<k) != 0
+		}
+		// Twice, so the instruction also runs from wherever the first one left the machine
+		for n := 0; n < 2; n++ {
+			if _, err := ch.EmulateCycle(); err != nil {
+				requireFault(t, err)
+				return
+			}
+		}
+	})
+}
+
+// FuzzProgram runs an arbitrary memory image from 0x200 for a while. It must either run or
+// stop with a *Fault, never panic.
+func FuzzProgram(f *testing.F) {
+	f.Add([]byte{0x22, 0x00})                         // Recurse until the stack overflows
+	f.Add([]byte{0x00, 0xEE})                         // Return without a call
+	f.Add([]byte{0xAF, 0xFF, 0xF2, 0x33, 0x12, 0x00}) // BCD at the end of memory
+	f.Add([]byte{0x6F, 0xFF, 0xBF, 0x00})             // Jump out of memory
+	f.Add([]byte{0xF0, 0x1E, 0xFF, 0x65, 0x12, 0x00}) // Walk I off the end of memory
+	f.Fuzz(func(t *testing.T, image []byte) {
+		ch := NewChip8()
+		ch.SeedRand(1)
+		if len(image) > len(ch.Memory)-0x200 {
+			image = image[:len(ch.Memory)-0x200]
+		}
+		ch.LoadRomBytes(image)
+		ch.KeyDown(5)
+		for n := 0; n < 1000; n++ {
+			if ch.WaitingForKey() {
+				ch.KeyUp(5)
+				ch.KeyDown(5)
+			}
+			if _, err := ch.EmulateCycle(); err != nil {
+				requireFault(t, err)
+				return
+			}
+		}
+	})
+}
+
+func requireFault(t *testing.T, err error) {
+	t.Helper()
+	var f *Fault
+	if !errors.As(err, &f) {
+		t.Fatalf("got %T %v, want a *Fault", err, err)
+	}
+}
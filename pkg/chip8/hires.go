@@ -0,0 +1,49 @@
+package chip8
+
+import "github.com/dustinbowers/chip8emu/pkg/display"
+
+/*
+Hires CHIP-8:
+
+A 1978 modification of the COSMAC VIP interpreter that used two display pages for a 64x64 screen.
+Its programs start with a 1260 jump into the patched interpreter at 0x260, which switched the
+display mode and went on to the program proper at 0x2C0. 0230 cleared the 64x64 screen.
+
+Rather than run the 1802 patch, the 1260 jump is taken straight to 0x2C0. Hires mode is picked
+on load, either because the profile asks for it or because the ROM starts with the 1260 jump.
+*/
+
+// HiresHeight is the height of the hires CHIP-8 screen, it is as wide as the normal one
+const HiresHeight = 64
+
+// hiresEntry reports whether rom starts with the 1260 jump of hires CHIP-8
+func hiresEntry(rom []byte) bool {
+	return len(rom) >= 2 && rom[0] == 0x12 && rom[1] == 0x60
+}
+
+// Hires reports whether the loaded ROM runs in the 64x64 hires CHIP-8 mode
+func (ch *Chip8) Hires() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.hires
+}
+
+// setHires switches between the 64x32 and 64x64 screens
+func (ch *Chip8) setHires(on bool) {
+	ch.hires = on
+	height := display.LoResHeight
+	if on {
+		height = HiresHeight
+	}
+	if ch.Screen.Width != display.LoResWidth || ch.Screen.Height != height {
+		ch.Screen = NewFramebuffer(display.LoResWidth, height, 1)
+	}
+}
+
+// 0230 - CLS in hires CHIP-8. The machine code call it replaces is unknown elsewhere
+func opHiresCLS(ch *Chip8) error {
+	if !ch.hires || ch.opcode != 0x0230 {
+		return opUnknown(ch)
+	}
+	return opCLS(ch)
+}
@@ -0,0 +1,99 @@
+package chip8
+
+// Hooks are callbacks that follow the machine as it runs, for scripts (see pkg/chip8/script) and tools.
+// Any of them may be nil.
+//
+// They're called on the goroutine running the machine while it's locked: they may read and change
+// the exported fields (V, I, PC, Memory, ...) and call HookKey and HookSetRegister, but no other
+// methods.
+type Hooks struct {
+	Frame       func(frame uint64)            // At the end of every 60Hz frame
+	Instruction func(pc, opcode uint16)       // Before each instruction executes
+	MemoryWrite func(addr uint16, value byte) // After an instruction writes to memory
+	Key         func(key uint8, down bool)    // When a key is pressed or released
+}
+
+// SetHooks installs h, replacing the hooks set before. SetHooks(Hooks{}) removes them.
+func (ch *Chip8) SetHooks(h Hooks) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.hooks = h
+}
+
+// HookKey presses or releases a key from inside a hook, where KeyDown and KeyUp would deadlock
+func (ch *Chip8) HookKey(key uint8, down bool) {
+	if down {
+		ch.keyDown(key & 0xF)
+	} else {
+		ch.keyUp(key & 0xF)
+	}
+}
+
+// HookSetRegister is SetRegister from inside a hook
+func (ch *Chip8) HookSetRegister(r Register, value int) error {
+	return ch.setRegister(r, value)
+}
+
+// HookAction tells the machine what to do with the instruction a PreExecHook was shown.
+// The zero value executes it as usual.
+type HookAction struct {
+	Skip     bool   // Don't execute it, carry on with the next instruction
+	Redirect bool   // Don't execute it, carry on at PC instead
+	PC       uint16 // Where to go when Redirect is set
+	Replace  bool   // Execute Opcode in its place
+	Opcode   uint16 // What to execute when Replace is set
+}
+
+// PreExecHook is called before the instruction at pc executes, with the machine locked like Hooks
+type PreExecHook func(pc, opcode uint16) HookAction
+
+type preExecHook struct {
+	id uint64
+	fn PreExecHook
+}
+
+// AddPreExecHook calls h before every instruction, after the hooks set by SetHooks. Hooks added
+// earlier are called first and the first one to return an action other than the zero value
+// decides; the rest aren't called for that instruction. The function returned removes h, like
+// the other methods it can't be called from inside a hook.
+func (ch *Chip8) AddPreExecHook(h PreExecHook) (remove func()) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.preExecID++
+	id := ch.preExecID
+	ch.preExec = append(ch.preExec, preExecHook{id, h})
+	return func() {
+		ch.mu.Lock()
+		defer ch.mu.Unlock()
+		for i, p := range ch.preExec {
+			if p.id == id {
+				ch.preExec = append(ch.preExec[:i:i], ch.preExec[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// runPreExecHooks asks the pre-exec hooks about the instruction fetched from pc and applies
+// their answer. It reports whether the instruction should still execute.
+func (ch *Chip8) runPreExecHooks(pc uint16) bool {
+	for _, p := range ch.preExec {
+		a := p.fn(pc, ch.opcode)
+		switch {
+		case a.Skip:
+			ch.pure = false
+			return false
+		case a.Redirect:
+			ch.PC = a.PC
+			ch.pure = false
+			return false
+		case a.Replace:
+			in := decode(a.Opcode)
+			ch.opcode, ch.x, ch.y, ch.n, ch.kk, ch.nnn = in.opcode, in.x, in.y, in.n, in.kk, in.nnn
+			ch.exec = in.exec
+			ch.pure = in.pure
+			return true
+		}
+	}
+	return true
+}
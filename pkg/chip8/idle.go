@@ -0,0 +1,51 @@
+package chip8
+
+// idleLoopSize is the most bytes of code a program can go round in and still count as idle
+const idleLoopSize = 64
+
+// idleWatch tracks how long the machine has been idle, see IdleFrames
+type idleWatch struct {
+	frames         uint64 // Idle frames in a row
+	active         bool   // Something happened this frame
+	lo, hi         uint16 // Addresses executed this frame
+	prevLo, prevHi uint16 // ...and the frame before
+}
+
+// IdleFrames returns how many 60Hz frames in a row the machine has been idle: nothing was drawn,
+// both timers stayed at 0, no key was pressed or released and the program kept going round the
+// same few instructions, e.g. a static title screen waiting for a key. Frontends can poll for
+// input and redraw less often while it's idle. Any activity starts the count over.
+func (ch *Chip8) IdleFrames() uint64 {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.idle.frames
+}
+
+// markActive is called when something happens that a frontend should keep up with, must be
+// called with ch.mu held
+func (ch *Chip8) markActive() {
+	ch.idle.active = true
+	ch.idle.frames = 0
+}
+
+// executed is called with the address of every executed instruction
+func (w *idleWatch) executed(pc uint16) {
+	if w.lo == 0 || pc < w.lo {
+		w.lo = pc
+	}
+	if pc > w.hi {
+		w.hi = pc
+	}
+}
+
+// endFrame is called at the end of every frame, timers tells whether DT or ST is running
+func (w *idleWatch) endFrame(timers bool) {
+	same := w.lo == w.prevLo && w.hi == w.prevHi && w.hi-w.lo < idleLoopSize
+	if w.active || timers || !same {
+		w.frames = 0
+	} else {
+		w.frames++
+	}
+	w.prevLo, w.prevHi = w.lo, w.hi
+	w.lo, w.hi, w.active = 0, 0, false
+}
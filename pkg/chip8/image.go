@@ -0,0 +1,43 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// ImagePalette is the white on black palette used by RenderToImage. Entries 2 and 3 are for
+// XO-CHIP's second bit plane.
+var ImagePalette = color.Palette{
+	color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+	color.RGBA{0x55, 0x55, 0x55, 0xFF},
+}
+
+// RenderToImage returns the screen as a white on black image, one image pixel per screen pixel.
+// Safe to call while another goroutine runs the machine.
+func (ch *Chip8) RenderToImage() image.Image {
+	return ScreenImage(ch.PeekScreen(), ImagePalette, 1)
+}
+
+// ScreenImage draws screen with the 4 color palette p, each pixel scaled up to a scale x scale block.
+// A screen with its own palette (MegaChip) is drawn in its own colors instead.
+func ScreenImage(screen *Framebuffer, p color.Palette, scale int) *image.Paletted {
+	if scale < 1 {
+		scale = 1
+	}
+	var mask uint8 = 3
+	if screen.Palette != nil {
+		p, mask = make(color.Palette, len(screen.Palette)), 0xFF
+		for i, c := range screen.Palette {
+			p[i] = color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xFF}
+		}
+	}
+	img := image.NewPaletted(image.Rect(0, 0, screen.Width*scale, screen.Height*scale), p)
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			img.SetColorIndex(x, y, screen.At(x/scale, y/scale)&mask)
+		}
+	}
+	return img
+}
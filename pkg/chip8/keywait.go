@@ -0,0 +1,41 @@
+package chip8
+
+import "fmt"
+
+/*
+Fx0A - LD Vx, K
+
+The original hardware waits for a key to be pressed *and released* before storing it in Vx.
+Rather than blocking inside executeOpcode, Fx0A rewinds PC so it executes again on the next cycle
+until KeyDown / KeyUp have seen a full press and release. Control always returns to the caller,
+so timers keep counting down, Pause works and frontends stay responsive while a ROM waits for input.
+*/
+
+// keyWait tracks a pending Fx0A
+type keyWait struct {
+	waiting  bool
+	pressed  bool  // A key went down while waiting
+	released bool  // ...and came back up
+	key      uint8 // The key that went down
+}
+
+// WaitingForKey reports whether the machine is blocked on Fx0A
+func (ch *Chip8) WaitingForKey() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.keyWait.waiting
+}
+
+func (ch *Chip8) waitForKey() {
+	if ch.keyWait.released {
+		ch.log.Debug(LogCPU, "key received", "key", ch.keyWait.key)
+		ch.V[ch.x] = ch.keyWait.key
+		ch.keyWait = keyWait{}
+		return
+	}
+	if !ch.keyWait.waiting {
+		ch.log.Debug(LogCPU, "waiting for key", "pc", fmt.Sprintf("%#03x", ch.PC-2))
+	}
+	ch.keyWait.waiting = true
+	ch.PC -= 2 // Run Fx0A again next cycle
+}
@@ -0,0 +1,106 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Logger receives log messages from the core and frontends. It's the Debug, Info and Warn
+// methods of *slog.Logger, so one can be passed as is: args are alternating keys and values.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// LogComponents is a set of the parts of the emulator that log
+type LogComponents uint8
+
+const (
+	LogCPU   LogComponents = 1 << iota // Loading ROMs, Fx0A, unknown opcodes
+	LogTimer                           // The sound timer and clock speed
+	LogUI                              // The frontend
+	LogAudio                           // Audio devices and XO-CHIP patterns
+	LogAll   = LogCPU | LogTimer | LogUI | LogAudio
+)
+
+var logComponentNames = []string{"cpu", "timer", "ui", "audio"}
+
+func (c LogComponents) String() string {
+	var names []string
+	for i, name := range logComponentNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseLogComponents parses a comma separated list of components, or "all"
+func ParseLogComponents(s string) (LogComponents, error) {
+	var c LogComponents
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			c |= LogAll
+			continue
+		}
+		found := false
+		for i, n := range logComponentNames {
+			if n == name {
+				c |= 1 << i
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown log component %q (expected one of: all, %v)", name, strings.Join(logComponentNames, ", "))
+		}
+	}
+	return c, nil
+}
+
+// ComponentLogger tags messages with the component they come from ("component", "cpu") and
+// drops those of components that aren't enabled. The zero value logs nothing.
+type ComponentLogger struct {
+	Logger  Logger
+	Enabled LogComponents
+}
+
+func (l ComponentLogger) Debug(c LogComponents, msg string, args ...interface{}) {
+	if l.on(c) {
+		l.Logger.Debug(msg, tag(c, args)...)
+	}
+}
+
+func (l ComponentLogger) Info(c LogComponents, msg string, args ...interface{}) {
+	if l.on(c) {
+		l.Logger.Info(msg, tag(c, args)...)
+	}
+}
+
+func (l ComponentLogger) Warn(c LogComponents, msg string, args ...interface{}) {
+	if l.on(c) {
+		l.Logger.Warn(msg, tag(c, args)...)
+	}
+}
+
+func (l ComponentLogger) on(c LogComponents) bool {
+	return l.Logger != nil && l.Enabled&c != 0
+}
+
+func tag(c LogComponents, args []interface{}) []interface{} {
+	return append([]interface{}{"component", c.String()}, args...)
+}
+
+// SetLogger sends the core's log messages for the enabled components to l. Pass nil to stop
+// logging, which is the default: the core never writes to the log package's logger.
+//
+// l is called on the goroutine running the machine while it's locked, so it must not call back into the Chip8.
+func (ch *Chip8) SetLogger(l Logger, enabled LogComponents) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.log = ComponentLogger{Logger: l, Enabled: enabled}
+}
@@ -0,0 +1,243 @@
+// Package macro adds keyboard macros and autofire on top of a keypad, for games that want
+// rapid or precisely timed tapping.
+//
+// A macro is a sequence of steps separated by spaces. A step presses CHIP-8 keys (hex digits,
+// several joined with +) for a number of frames, 2 unless given after a colon, then releases
+// them for Gap frames so the ROM sees separate presses. _N waits N more frames:
+//
+//	5 5 5          tap 5 three times
+//	4+5:10 _30 6   hold 4 and 5 for 10 frames, wait half a second, tap 6
+//
+// An autofire key is pressed and released every few frames while it's held down.
+package macro
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultHold is how many frames a step presses its keys unless it says otherwise
+	DefaultHold = 2
+	// Gap is how many frames a step's keys are released before the next step
+	Gap = 2
+	// maxFrames limits a step to ten seconds
+	maxFrames = 600
+)
+
+// Step presses Keys (a bitmask, bit n for key n) for Frames frames. A step without keys waits.
+type Step struct {
+	Keys   uint16
+	Frames int
+}
+
+// Macro is a sequence of steps
+type Macro []Step
+
+// Parse reads a macro, see the package comment for the syntax
+func Parse(s string) (Macro, error) {
+	var m Macro
+	for _, field := range strings.Fields(s) {
+		if strings.HasPrefix(field, "_") {
+			n, err := parseFrames(field[1:])
+			if err != nil {
+				return nil, fmt.Errorf("macro: bad wait %q: %v", field, err)
+			}
+			m = append(m, Step{Frames: n})
+			continue
+		}
+		keys, frames := field, ""
+		if i := strings.IndexByte(field, ':'); i >= 0 {
+			keys, frames = field[:i], field[i+1:]
+		}
+		step := Step{Frames: DefaultHold}
+		for _, k := range strings.Split(keys, "+") {
+			n, err := strconv.ParseUint(k, 16, 4)
+			if err != nil || len(k) != 1 {
+				return nil, fmt.Errorf("macro: bad key %q in %q (expected 0-F)", k, field)
+			}
+			step.Keys |= 1 << n
+		}
+		if frames != "" {
+			n, err := parseFrames(frames)
+			if err != nil {
+				return nil, fmt.Errorf("macro: bad hold %q: %v", field, err)
+			}
+			step.Frames = n
+		}
+		m = append(m, step, Step{Frames: Gap})
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("macro: empty")
+	}
+	return m, nil
+}
+
+func parseFrames(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxFrames {
+		return 0, fmt.Errorf("expected 1-%d frames", maxFrames)
+	}
+	return n, nil
+}
+
+// String formats m so that Parse reads it back
+func (m Macro) String() string {
+	var steps []string
+	for i := 0; i < len(m); i++ {
+		s := m[i]
+		if s.Keys == 0 {
+			steps = append(steps, fmt.Sprintf("_%d", s.Frames))
+			continue
+		}
+		var keys []string
+		for k := 0; k < 16; k++ {
+			if s.Keys&(1<<k) != 0 {
+				keys = append(keys, fmt.Sprintf("%X", k))
+			}
+		}
+		step := strings.Join(keys, "+")
+		if s.Frames != DefaultHold {
+			step += fmt.Sprintf(":%d", s.Frames)
+		}
+		steps = append(steps, step)
+		// The gap after a press is implied, a longer wait is written as the rest of it
+		if i+1 < len(m) && m[i+1].Keys == 0 {
+			i++
+			if wait := m[i].Frames - Gap; wait > 0 {
+				steps = append(steps, fmt.Sprintf("_%d", wait))
+			}
+		}
+	}
+	return strings.Join(steps, " ")
+}
+
+// Frames returns the keys m holds in each frame it takes
+func (m Macro) Frames() []uint16 {
+	var frames []uint16
+	for _, s := range m {
+		for i := 0; i < s.Frames; i++ {
+			frames = append(frames, s.Keys)
+		}
+	}
+	return frames
+}
+
+// Keypad receives key presses, e.g. a chip8.Chip8 or a movie.Recorder
+type Keypad interface {
+	KeyDown(key uint8)
+	KeyUp(key uint8)
+}
+
+// Player sits between the player's input and a keypad, adding autofire and macros. Call
+// Frame before every frame.
+type Player struct {
+	keys Keypad
+
+	mu       sync.Mutex
+	autofire [16]int    // Frames each autofire key spends pressed, then released
+	started  [16]uint64 // Frame each held autofire key went down
+	held     uint16     // Keys the player holds
+	down     uint16     // Keys pressed on keys
+	queue    []uint16   // Keys the running macro holds in each coming frame
+	frame    uint64
+}
+
+// NewPlayer creates a Player passing keys on to keys
+func NewPlayer(keys Keypad) *Player {
+	return &Player{keys: keys}
+}
+
+// SetAutofire makes key fire every 2*frames frames while held, or turns it off when frames is 0
+func (p *Player) SetAutofire(key uint8, frames int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.autofire[key&0xF] = frames
+	p.update()
+}
+
+// Autofire returns the autofire rate of key in frames, 0 when it's off
+func (p *Player) Autofire(key uint8) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.autofire[key&0xF]
+}
+
+// KeyDown is called when the player presses a key
+func (p *Player) KeyDown(key uint8) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key &= 0xF
+	if p.held&(1<<key) == 0 {
+		p.started[key] = p.frame
+	}
+	p.held |= 1 << key
+	p.update()
+}
+
+// KeyUp is called when the player releases a key
+func (p *Player) KeyUp(key uint8) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.held &^= 1 << (key & 0xF)
+	p.update()
+}
+
+// Play starts m, replacing the macro that's running
+func (p *Player) Play(m Macro) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = m.Frames()
+	p.update()
+}
+
+// Playing reports whether a macro is running
+func (p *Player) Playing() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue) > 0
+}
+
+// Stop ends the running macro
+func (p *Player) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = nil
+	p.update()
+}
+
+// Frame moves autofire and the running macro on to the next frame
+func (p *Player) Frame() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.frame++
+	if len(p.queue) > 0 {
+		p.queue = p.queue[1:]
+	}
+	p.update()
+}
+
+// update presses and releases keys to match what the player, autofire and the macro hold
+func (p *Player) update() {
+	want := p.held
+	for k, rate := range p.autofire {
+		if rate > 0 && p.held&(1<<k) != 0 && (p.frame-p.started[k])/uint64(rate)%2 == 1 {
+			want &^= 1 << k
+		}
+	}
+	if len(p.queue) > 0 {
+		want |= p.queue[0]
+	}
+	for k := uint8(0); k < 16; k++ {
+		bit := uint16(1) << k
+		switch {
+		case want&bit != 0 && p.down&bit == 0:
+			p.keys.KeyDown(k)
+		case want&bit == 0 && p.down&bit != 0:
+			p.keys.KeyUp(k)
+		}
+	}
+	p.down = want
+}
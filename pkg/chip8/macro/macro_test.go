@@ -0,0 +1,101 @@
+package macro
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	m, err := Parse("5 4+6:10 _30 A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Macro{
+		{1 << 5, 2}, {0, Gap},
+		{1<<4 | 1<<6, 10}, {0, Gap},
+		{0, 30},
+		{1 << 0xA, 2}, {0, Gap},
+	}
+	if fmt.Sprint(m) != fmt.Sprint(want) || len(m) != len(want) {
+		t.Fatalf("got %v, want %v", []Step(m), []Step(want))
+	}
+	for i := range want {
+		if m[i] != want[i] {
+			t.Errorf("step %d: got %v, want %v", i, m[i], want[i])
+		}
+	}
+	if got := m.String(); got != "5 4+6:10 _30 A" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := len(m.Frames()); got != 2+2+10+2+30+2+2 {
+		t.Errorf("got %d frames", got)
+	}
+
+	for _, bad := range []string{"", "G", "12", "5:0", "5:x", "_", "_0", "5+:2"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) succeeded", bad)
+		}
+	}
+}
+
+// keypad logs the presses it gets, one line per frame
+type keypad struct {
+	log   []string
+	frame []string
+}
+
+func (k *keypad) KeyDown(key uint8) { k.frame = append(k.frame, fmt.Sprintf("+%X", key)) }
+func (k *keypad) KeyUp(key uint8)   { k.frame = append(k.frame, fmt.Sprintf("-%X", key)) }
+
+func (k *keypad) next() {
+	k.log = append(k.log, strings.Join(k.frame, " "))
+	k.frame = nil
+}
+
+func TestAutofire(t *testing.T) {
+	k := &keypad{}
+	p := NewPlayer(k)
+	p.SetAutofire(5, 2)
+	p.KeyDown(5)
+	p.KeyDown(1) // Not autofire, stays down
+	for i := 0; i < 6; i++ {
+		k.next()
+		p.Frame()
+	}
+	p.KeyUp(5)
+	k.next()
+	want := []string{"+5 +1", "", "-5", "", "+5", "", "-5"}
+	if strings.Join(k.log, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", k.log, want)
+	}
+}
+
+func TestPlay(t *testing.T) {
+	k := &keypad{}
+	p := NewPlayer(k)
+	m, _ := Parse("5 5:1 _1 6")
+	p.Play(m)
+	for p.Playing() {
+		k.next()
+		p.Frame()
+	}
+	k.next()
+	want := []string{"+5", "", "-5", "", "+5", "-5", "", "", "+6", "", "-6", "", ""}
+	if strings.Join(k.log, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", k.log, want)
+	}
+
+	// A key the player holds stays down through the macro
+	k.log = nil
+	p.KeyDown(5)
+	p.Play(m)
+	for p.Playing() {
+		p.Frame()
+	}
+	p.KeyUp(5)
+	k.next()
+	if k.log[0] != "+5 +6 -6 -5" {
+		t.Errorf("got %q, want 5 pressed once", k.log)
+	}
+}
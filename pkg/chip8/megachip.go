@@ -0,0 +1,292 @@
+package chip8
+
+import "github.com/dustinbowers/chip8emu/pkg/display"
+
+/*
+MegaChip:
+
+Revival Studios' 2007 extension of SCHIP, only available with the megachip profile. A MegaChip ROM
+starts out as plain CHIP-8 and switches to a 256x192 display of 256 colors with 0011:
+
+	0010       MEGAoff   back to the 64x32 CHIP-8 display
+	0011       MEGAon    256x192 display with a palette of 255 colors and transparent index 0
+	01nn nnnn  LDHI      I = nnnnnn, a 24-bit address reaching the whole ROM
+	02nn       LDPAL     load nn ARGB colors from I into palette entries 1..nn
+	03nn       SPRW      sprite width, 0 is 256
+	04nn       SPRH      sprite height, 0 is 256
+	05nn       ALPHA     screen alpha (recorded only)
+	060n       DIGISND   play the digitized sound at I, looped when n is 0
+	0700       STOPSND   stop it
+	080n       BMODE     sprite blend mode (recorded only, sprites are drawn opaque)
+	09nn       CCOL      the color index Dxyn reports collisions with
+
+In MegaChip mode Dxyn draws a SPRW x SPRH sprite from I with one palette index per byte, skipping
+index 0, into a back buffer. 00E0 shows the back buffer and clears it. Font sprites (I below 0x200)
+are still drawn a bit per pixel, in color 255.
+
+Memory above 0x1000 is read straight from the ROM image, so LDHI reaches ROMs of up to 16MB.
+Instructions other than the MegaChip ones keep working on the first 4K; only Fx1E carries into
+the upper bits of I. Save states don't include the MegaChip registers or palette.
+*/
+
+// Size of the MegaChip display
+const MegaWidth, MegaHeight = 256, 192
+
+// megaMemorySize is the 24-bit address space LDHI reaches
+const megaMemorySize = 1 << 24
+
+// megaChip is the state added by the MegaChip extension
+type megaChip struct {
+	on        bool
+	ihi       uint8        // Bits 16-23 of I, set by LDHI
+	palette   [256]uint32  // 0xAARRGGBB
+	back      *Framebuffer // Dxyn draws here, 00E0 shows it
+	spriteW   int
+	spriteH   int
+	alpha     uint8
+	blend     uint8
+	collision uint8
+	samples   []byte // Reused by DIGISND
+}
+
+func newMegaChip() *megaChip {
+	m := &megaChip{back: NewFramebuffer(MegaWidth, MegaHeight, 8)}
+	m.reset()
+	return m
+}
+
+func (m *megaChip) reset() {
+	m.on = false
+	m.ihi = 0
+	for i := range m.palette {
+		m.palette[i] = 0xFF000000
+	}
+	m.palette[255] = 0xFFFFFFFF
+	m.back.Clear()
+	m.spriteW, m.spriteH = 0, 0
+	m.alpha, m.blend, m.collision = 0xFF, 0, 0
+}
+
+// MegaChip reports whether a MegaChip ROM has switched to its 256x192 color display
+func (ch *Chip8) MegaChip() bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.mega != nil && ch.mega.on
+}
+
+// setMegaMode switches between the CHIP-8 and MegaChip displays
+func (ch *Chip8) setMegaMode(on bool) {
+	ch.mega.on = on
+	if on {
+		ch.Screen = NewFramebuffer(MegaWidth, MegaHeight, 8)
+		ch.Screen.Palette = append([]uint32(nil), ch.mega.palette[:]...)
+	} else {
+		ch.Screen = NewFramebuffer(display.LoResWidth, display.LoResHeight, 1)
+	}
+	ch.mega.back.Clear()
+	ch.invalidate(AllDirty)
+}
+
+// megaI is the full 24-bit I register
+func (ch *Chip8) megaI() uint32 {
+	return uint32(ch.mega.ihi)<<16 | uint32(ch.I)
+}
+
+// megaRead reads a byte of the 16MB MegaChip address space. The first 4K is memory as usual,
+// beyond that the ROM image, and past the end of the ROM reads as 0.
+func (ch *Chip8) megaRead(addr uint32) byte {
+	if addr < uint32(len(ch.Memory)) {
+		return ch.Memory[addr]
+	}
+	if i := int(addr) - int(ch.romAddr); i < len(ch.rom) {
+		return ch.rom[i]
+	}
+	return 0
+}
+
+// megaOps are the MegaChip instructions 01nn to 09nn, by their second nibble
+var megaOps = [16]opFunc{
+	0x1: opLDHI, 0x2: opLDPAL, 0x3: opSPRW, 0x4: opSPRH, 0x5: opALPHA, 0x6: opDIGISND, 0x7: opSTOPSND,
+	0x8: opBMODE, 0x9: opCCOL,
+}
+
+// opMega runs a 0xnn instruction (x not 0). Only MegaChip has these, elsewhere they are
+// looked up as 00nn like they always have been.
+func opMega(ch *Chip8) error {
+	if ch.mega == nil || megaOps[ch.x] == nil {
+		if f := sysOps[ch.kk]; f != nil {
+			return f(ch)
+		}
+		return opUnknown(ch)
+	}
+	return megaOps[ch.x](ch)
+}
+
+// 0010 - MEGAoff
+func opMEGAOFF(ch *Chip8) error {
+	if ch.mega == nil {
+		return opUnknown(ch)
+	}
+	ch.setMegaMode(false)
+	return nil
+}
+
+// 0011 - MEGAon
+func opMEGAON(ch *Chip8) error {
+	if ch.mega == nil {
+		return opUnknown(ch)
+	}
+	ch.setMegaMode(true)
+	return nil
+}
+
+// 01nn nnnn - LDHI I, nnnnnn
+func opLDHI(ch *Chip8) error {
+	if int(ch.PC)+1 >= len(ch.Memory) {
+		return fault(ErrPCOutOfBounds, "LDHI runs past the end of memory")
+	}
+	ch.mega.ihi = ch.kk
+	ch.I = uint16(ch.Memory[ch.PC])<<8 | uint16(ch.Memory[ch.PC+1])
+	ch.PC += 2
+	return nil
+}
+
+// 02nn - LDPAL nn
+func opLDPAL(ch *Chip8) error {
+	addr := ch.megaI()
+	for i := 0; i < int(ch.kk) && i < 255; i++ {
+		a := addr + uint32(i)*4
+		ch.mega.palette[i+1] = uint32(ch.megaRead(a))<<24 | uint32(ch.megaRead(a+1))<<16 |
+			uint32(ch.megaRead(a+2))<<8 | uint32(ch.megaRead(a+3))
+	}
+	return nil
+}
+
+// 03nn - SPRW nn
+func opSPRW(ch *Chip8) error {
+	ch.mega.spriteW = int(ch.kk)
+	return nil
+}
+
+// 04nn - SPRH nn
+func opSPRH(ch *Chip8) error {
+	ch.mega.spriteH = int(ch.kk)
+	return nil
+}
+
+// 05nn - ALPHA nn
+func opALPHA(ch *Chip8) error {
+	ch.mega.alpha = ch.kk
+	return nil
+}
+
+// 060n - DIGISND. The sound at I starts with a 6 byte header: the sample rate (16 bits), the
+// number of samples (24 bits) and a reserved byte, followed by unsigned 8-bit samples.
+func opDIGISND(ch *Chip8) error {
+	p, ok := ch.audio.(display.SamplePlayer)
+	if !ok {
+		return nil
+	}
+	addr := ch.megaI()
+	rate := int(ch.megaRead(addr))<<8 | int(ch.megaRead(addr+1))
+	n := uint32(ch.megaRead(addr+2))<<16 | uint32(ch.megaRead(addr+3))<<8 | uint32(ch.megaRead(addr+4))
+	start := addr + 6
+	if uint64(start)+uint64(n) > megaMemorySize {
+		return fault(ErrMemoryOutOfBounds, "DIGISND: %d samples at %#06x run past the 16MB address space", n, start)
+	}
+	// Past memory and the ROM image everything reads as 0, which isn't worth copying
+	end := uint32(len(ch.Memory))
+	if romEnd := uint32(ch.romAddr) + uint32(len(ch.rom)); romEnd > end {
+		end = romEnd
+	}
+	switch {
+	case start >= end:
+		n = 0
+	case start+n > end:
+		n = end - start
+	}
+	samples := ch.mega.samples[:0]
+	for i := uint32(0); i < n; i++ {
+		samples = append(samples, ch.megaRead(start+i))
+	}
+	ch.mega.samples = samples
+	p.PlaySamples(samples, rate, ch.n == 0)
+	return nil
+}
+
+// 0700 - STOPSND
+func opSTOPSND(ch *Chip8) error {
+	if p, ok := ch.audio.(display.SamplePlayer); ok {
+		p.PlaySamples(nil, 0, false)
+	}
+	return nil
+}
+
+// 080n - BMODE n
+func opBMODE(ch *Chip8) error {
+	ch.mega.blend = ch.n
+	return nil
+}
+
+// 09nn - CCOL nn
+func opCCOL(ch *Chip8) error {
+	ch.mega.collision = ch.kk
+	return nil
+}
+
+// megaCLS is 00E0 in MegaChip mode: show the back buffer and clear it for the next frame
+func (ch *Chip8) megaCLS() {
+	m := ch.mega
+	copy(ch.Screen.Pix, m.back.Pix)
+	ch.Screen.Palette = append(ch.Screen.Palette[:0], m.palette[:]...)
+	m.back.Clear()
+	ch.invalidate(AllDirty)
+}
+
+// megaDRW is Dxyn in MegaChip mode, drawing into the back buffer
+func (ch *Chip8) megaDRW() error {
+	m := ch.mega
+	col, row := int(ch.V[ch.x]), int(ch.V[ch.y])
+	ch.V[0xF] = 0
+	addr := ch.megaI()
+	if addr < 0x200 {
+		// Font sprites are 8 pixels wide, a bit each
+		for dy := 0; dy < int(ch.n); dy++ {
+			b := ch.megaRead(addr + uint32(dy))
+			for dx := 0; dx < 8; dx++ {
+				if b&(0x80>>dx) != 0 {
+					ch.megaPlot(col+dx, row+dy, 255)
+				}
+			}
+		}
+		return nil
+	}
+	w, h := m.spriteW, m.spriteH
+	if w == 0 {
+		w = 256
+	}
+	if h == 0 {
+		h = 256
+	}
+	for dy := 0; dy < h; dy++ {
+		for dx := 0; dx < w; dx++ {
+			if c := ch.megaRead(addr); c != 0 {
+				ch.megaPlot(col+dx, row+dy, c)
+			}
+			addr++
+		}
+	}
+	return nil
+}
+
+// megaPlot sets a back buffer pixel, clipping at the edges and checking for collisions
+func (ch *Chip8) megaPlot(x, y int, c uint8) {
+	back := ch.mega.back
+	if x >= back.Width || y >= back.Height {
+		return
+	}
+	if back.At(x, y) == ch.mega.collision && ch.mega.collision != 0 {
+		ch.V[0xF] = 1
+	}
+	back.Set(x, y, c)
+}
@@ -0,0 +1,71 @@
+package chip8
+
+import "fmt"
+
+// MemoryWatchFunc is called for every memory read and write made while executing an instruction.
+// Opcode fetches are not reported.
+type MemoryWatchFunc func(addr uint16, value byte, write bool)
+
+// SetMemoryWatcher installs a function that observes instruction memory accesses, or removes it when f is nil
+func (ch *Chip8) SetMemoryWatcher(f MemoryWatchFunc) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.memWatcher = f
+}
+
+// ReadMemory returns a copy of n bytes of memory starting at addr, cut short at the end of memory.
+// Safe to call while another goroutine runs the machine.
+func (ch *Chip8) ReadMemory(addr uint16, n int) []byte {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if int(addr) >= len(ch.Memory) || n <= 0 {
+		return nil
+	}
+	end := int(addr) + n
+	if end > len(ch.Memory) {
+		end = len(ch.Memory)
+	}
+	return append([]byte(nil), ch.Memory[addr:end]...)
+}
+
+// WriteMemory copies data into memory starting at addr. Nothing is written when it doesn't all fit.
+// Safe to call while another goroutine runs the machine.
+func (ch *Chip8) WriteMemory(addr uint16, data []byte) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if int(addr)+len(data) > len(ch.Memory) {
+		return fmt.Errorf("writeMemory: %d bytes at %#03x run past the end of memory", len(data), addr)
+	}
+	copy(ch.Memory[addr:], data)
+	ch.loop = loopWatch{} // The loop may not be the same anymore
+	return nil
+}
+
+// checkMem returns an error unless the n bytes starting at addr are all inside memory.
+// Instructions call it before accessing memory through I.
+func (ch *Chip8) checkMem(addr uint16, n int) error {
+	if int(addr)+n > ch.profile.MemorySize {
+		return fault(ErrMemoryOutOfBounds, "%d bytes at I=%#03x", n, addr)
+	}
+	return nil
+}
+
+// readMem is used by instructions for all memory reads so they can be watched
+func (ch *Chip8) readMem(addr uint16) byte {
+	b := ch.Memory[addr]
+	if ch.memWatcher != nil {
+		ch.memWatcher(addr, b, false)
+	}
+	return b
+}
+
+// writeMem is used by instructions for all memory writes so they can be watched
+func (ch *Chip8) writeMem(addr uint16, b byte) {
+	ch.Memory[addr] = b
+	if ch.memWatcher != nil {
+		ch.memWatcher(addr, b, true)
+	}
+	if ch.hooks.MemoryWrite != nil {
+		ch.hooks.MemoryWrite(addr, b)
+	}
+}
@@ -0,0 +1,200 @@
+// Package movie records and replays keypad input frame by frame (TAS-style input movies).
+//
+// A movie starts from power-on with a known RNG seed, machine profile, clock speed and quirk set, and
+// applies every key event at the start of the 60Hz frame it was recorded on.
+// Replaying it against the same ROM therefore reproduces the run exactly.
+package movie
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+/*
+Movie file layout (.c8m, all multi-byte values are big-endian):
+
+	"C8MV"                 4 byte magic
+	version                uint16
+	seed                   int64
+	instructionsPerFrame   uint16
+	quirks                 uint8 bitmask, see quirkBits
+	platformLen            uint8, version 2 and up
+	platform               platformLen bytes, the name of the machine profile
+	romHash                20 byte SHA-1 of the ROM
+	frames                 uint32, length of the movie
+	eventCount             uint32
+	events                 eventCount * (frame uint32, key uint8, down uint8)
+*/
+
+var movieMagic = [4]byte{'C', '8', 'M', 'V'}
+
+const movieVersion uint16 = 2
+
+// eventSize is the encoded size of an Event
+const eventSize = 6
+
+// Event is a single key transition, applied at the start of Frame
+type Event struct {
+	Frame uint32
+	Key   uint8
+	Down  bool
+}
+
+// Movie is everything needed to replay a run
+type Movie struct {
+	Seed                 int64
+	Platform             string // Name of the machine profile, "" in version 1 movies, which keep whatever is set
+	InstructionsPerFrame int
+	Quirks               chip8.Quirks
+	ROMHash              [20]byte
+	Frames               uint32 // Number of frames recorded
+	Events               []Event
+}
+
+// quirkBits lists the quirks in bitmask order. New quirks are only ever appended.
+func quirkBits(q *chip8.Quirks) []*bool {
+	return []*bool{&q.ShiftUsesVy, &q.LoadStoreIncrementsI, &q.JumpUsesVx, &q.VFReset, &q.ClipSprites, &q.DisplayWait}
+}
+
+// Load reads a movie file
+func Load(path string) (*Movie, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("movie: failed reading file: %v", err)
+	}
+	m := &Movie{}
+	if err := m.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Save writes the movie to path
+func (m *Movie) Save(path string) error {
+	data, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+func (m *Movie) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(movieMagic[:])
+
+	var quirks uint8
+	for i, b := range quirkBits(&m.Quirks) {
+		if *b {
+			quirks |= 1 << i
+		}
+	}
+	header := []interface{}{movieVersion, m.Seed, uint16(m.InstructionsPerFrame), quirks, uint8(len(m.Platform)), []byte(m.Platform), m.ROMHash, m.Frames, uint32(len(m.Events))}
+	for _, v := range header {
+		_ = binary.Write(&buf, binary.BigEndian, v)
+	}
+	for _, e := range m.Events {
+		down := uint8(0)
+		if e.Down {
+			down = 1
+		}
+		_ = binary.Write(&buf, binary.BigEndian, e.Frame)
+		buf.WriteByte(e.Key)
+		buf.WriteByte(down)
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Movie) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || magic != movieMagic {
+		return fmt.Errorf("movie: not a movie file")
+	}
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return fmt.Errorf("movie: failed reading version: %v", err)
+	}
+	if version < 1 || version > movieVersion {
+		return fmt.Errorf("movie: unsupported version %d", version)
+	}
+
+	var ipf uint16
+	var quirks uint8
+	var count uint32
+	for _, v := range []interface{}{&m.Seed, &ipf, &quirks} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return fmt.Errorf("movie: failed reading header: %v", err)
+		}
+	}
+	m.Platform = ""
+	if version >= 2 {
+		var n uint8
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+			return fmt.Errorf("movie: failed reading header: %v", err)
+		}
+		platform := make([]byte, n)
+		if _, err := io.ReadFull(r, platform); err != nil {
+			return fmt.Errorf("movie: failed reading header: %v", err)
+		}
+		m.Platform = string(platform)
+	}
+	for _, v := range []interface{}{&m.ROMHash, &m.Frames, &count} {
+		if err := binary.Read(r, binary.BigEndian, v); err != nil {
+			return fmt.Errorf("movie: failed reading header: %v", err)
+		}
+	}
+	m.InstructionsPerFrame = int(ipf)
+	for i, b := range quirkBits(&m.Quirks) {
+		*b = quirks&(1<<i) != 0
+	}
+
+	if int64(count) > int64(r.Len())/eventSize {
+		return fmt.Errorf("movie: %d events don't fit in the file", count)
+	}
+	m.Events = make([]Event, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var raw struct {
+			Frame uint32
+			Key   uint8
+			Down  uint8
+		}
+		if err := binary.Read(r, binary.BigEndian, &raw); err != nil {
+			return fmt.Errorf("movie: failed reading event %d: %v", i, err)
+		}
+		if raw.Key > 0xF {
+			return fmt.Errorf("movie: event %d has invalid key %d", i, raw.Key)
+		}
+		m.Events = append(m.Events, Event{Frame: raw.Frame, Key: raw.Key, Down: raw.Down != 0})
+	}
+	return nil
+}
+
+// powerOn puts emu into the movie's starting state
+func (m *Movie) powerOn(emu *chip8.Chip8, rom []byte) error {
+	if m.Platform != "" {
+		p := chip8.DefaultProfile()
+		if m.Platform != p.Name {
+			var err error
+			if p, err = chip8.LookupProfile(m.Platform); err != nil {
+				return err
+			}
+		}
+		emu.SetProfile(p)
+	}
+	emu.SetQuirks(m.Quirks)
+	emu.SetInstructionsPerFrame(m.InstructionsPerFrame)
+	emu.SeedRand(m.Seed)
+	_, err := emu.LoadRomBytes(rom)
+	return err
+}
+
+func hashROM(rom []byte) [20]byte {
+	return sha1.Sum(rom)
+}
@@ -0,0 +1,134 @@
+package movie
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+var rom = []byte{
+	0xC0, 0x3F, // 200: RND V0, 0x3F
+	0xE1, 0x9E, // 202: SKP V1, key 0
+	0x72, 0x01, // 204: ADD V2, 1
+	0xF2, 0x29, // 206: LD F, V2
+	0x00, 0xE0, // 208: CLS
+	0xD0, 0x05, // 20A: DRW V0, V0, 5
+	0x12, 0x00, // 20C: JP 0x200
+}
+
+// record runs rom on a machine with profile p for frames, holding key 0 down for a while, and
+// returns the machine and its movie
+func record(t *testing.T, p chip8.Profile, frames uint32) (*chip8.Chip8, *Movie) {
+	t.Helper()
+	emu := chip8.NewChip8()
+	emu.SetProfile(p)
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRecorder(emu, rom, 42)
+	for f := uint32(0); f < frames; f++ {
+		switch f {
+		case 20, 70:
+			r.KeyDown(0)
+		case 40, 90:
+			r.KeyUp(0)
+		}
+		if err := r.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return emu, r.Movie()
+}
+
+func TestReplay(t *testing.T) {
+	for _, platform := range []string{"default", "vip", "schip"} {
+		p := chip8.DefaultProfile()
+		if platform != p.Name {
+			p, _ = chip8.LookupProfile(platform)
+		}
+		recorded, m := record(t, p, 120)
+		data, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded := &Movie{}
+		if err := loaded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded, m) {
+			t.Fatalf("%s: decoded %+v, want %+v", platform, loaded, m)
+		}
+
+		// Played back on a machine set up differently, the movie brings its own settings
+		emu := chip8.NewChip8()
+		emu.SetQuirks(chip8.Quirks{JumpUsesVx: true})
+		player, err := NewPlayer(emu, rom, loaded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for !player.Done() {
+			if err := player.RunFrame(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got, want := emu.Profile().Name, platform; got != want {
+			t.Errorf("replayed as %s, want %s", got, want)
+		}
+		if got, want := emu.Registers(), recorded.Registers(); got != want {
+			t.Errorf("%s: replay ended with registers %+v, want %+v", platform, got, want)
+		}
+		got, _ := emu.SnapshotScreen()
+		want, _ := recorded.SnapshotScreen()
+		if !got.Equal(want) {
+			t.Errorf("%s: replay ended with a different screen", platform)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	_, m := record(t, chip8.DefaultProfile(), 10)
+	good, _ := m.MarshalBinary()
+	badKey := *m
+	badKey.Events = []Event{{Frame: 1, Key: 0x10, Down: true}}
+	badKeyData, _ := badKey.MarshalBinary()
+	empty := *m
+	empty.Events = nil
+	hugeCount, _ := empty.MarshalBinary()
+	binary.BigEndian.PutUint32(hugeCount[len(hugeCount)-4:], 0xFFFFFFFF)
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"magic", append([]byte("C8XX"), good[4:]...), "not a movie file"},
+		{"version", append(append([]byte{}, good[:4]...), append([]byte{0xFF, 0xFF}, good[6:]...)...), "unsupported version"},
+		{"truncated", good[:20], "failed reading header"},
+		{"key", badKeyData, "invalid key 16"},
+		{"count", hugeCount, "don't fit"},
+	}
+	for _, tt := range tests {
+		err := (&Movie{}).UnmarshalBinary(tt.data)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestROMMismatch(t *testing.T) {
+	_, m := record(t, chip8.DefaultProfile(), 10)
+	if _, err := NewPlayer(chip8.NewChip8(), []byte{0x12, 0x00}, m); err == nil {
+		t.Error("the movie played against a different ROM")
+	}
+}
+
+func TestUnknownPlatform(t *testing.T) {
+	_, m := record(t, chip8.DefaultProfile(), 10)
+	m.Platform = "pdp8"
+	if _, err := NewPlayer(chip8.NewChip8(), rom, m); err == nil || !strings.Contains(err.Error(), "pdp8") {
+		t.Errorf("got error %v, want an unknown platform", err)
+	}
+}
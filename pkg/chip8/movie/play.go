@@ -0,0 +1,60 @@
+package movie
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// Player replays a Movie. Frontends call RunFrame in place of Chip8.RunFrame and should ignore
+// live input until Done reports true, after which RunFrame simply keeps running the machine.
+type Player struct {
+	emu   *chip8.Chip8
+	movie *Movie
+
+	mu    sync.Mutex
+	frame uint32
+	next  int // Index of the next event to apply
+}
+
+// NewPlayer powers emu on with rom in the movie's starting state.
+// The ROM must be the one the movie was recorded with.
+func NewPlayer(emu *chip8.Chip8, rom []byte, m *Movie) (*Player, error) {
+	if hashROM(rom) != m.ROMHash {
+		return nil, fmt.Errorf("movie: recorded with a different ROM (sha1 %x)", m.ROMHash)
+	}
+	if err := m.powerOn(emu, rom); err != nil {
+		return nil, fmt.Errorf("movie: %v", err)
+	}
+	return &Player{emu: emu, movie: m}, nil
+}
+
+// RunFrame applies the input recorded for the current frame and runs it
+func (p *Player) RunFrame() error {
+	p.mu.Lock()
+	for p.next < len(p.movie.Events) && p.movie.Events[p.next].Frame <= p.frame {
+		applyEvent(p.emu, p.movie.Events[p.next])
+		p.next++
+	}
+	if p.frame < p.movie.Frames {
+		p.frame++
+	}
+	p.mu.Unlock()
+
+	return p.emu.RunFrame()
+}
+
+// Done reports whether the whole movie has been played back
+func (p *Player) Done() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.frame >= p.movie.Frames
+}
+
+// Frame returns the current playback frame
+func (p *Player) Frame() uint32 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.frame
+}
@@ -0,0 +1,81 @@
+package movie
+
+import (
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// Recorder captures key events into a Movie. Frontends send input to the Recorder instead of the Chip8
+// and call RunFrame in place of Chip8.RunFrame; queued events are applied at the start of the next frame.
+type Recorder struct {
+	emu   *chip8.Chip8
+	movie Movie
+
+	mu      sync.Mutex
+	pending []Event
+}
+
+// NewRecorder powers emu on with rom and starts recording. The current machine profile, quirks and clock
+// speed are kept.
+func NewRecorder(emu *chip8.Chip8, rom []byte, seed int64) *Recorder {
+	r := &Recorder{
+		emu: emu,
+		movie: Movie{
+			Seed:                 seed,
+			Platform:             emu.Profile().Name,
+			InstructionsPerFrame: emu.InstructionsPerFrame(),
+			Quirks:               emu.Quirks(),
+			ROMHash:              hashROM(rom),
+		},
+	}
+	// rom is the one emu is already running, so it loads
+	_ = r.movie.powerOn(emu, rom)
+	return r
+}
+
+func (r *Recorder) KeyDown(key uint8) {
+	r.queue(key, true)
+}
+
+func (r *Recorder) KeyUp(key uint8) {
+	r.queue(key, false)
+}
+
+func (r *Recorder) queue(key uint8, down bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pending = append(r.pending, Event{Key: key, Down: down})
+}
+
+// RunFrame applies queued input, records it and runs a single frame
+func (r *Recorder) RunFrame() error {
+	r.mu.Lock()
+	for _, e := range r.pending {
+		e.Frame = r.movie.Frames
+		r.movie.Events = append(r.movie.Events, e)
+		applyEvent(r.emu, e)
+	}
+	r.pending = r.pending[:0]
+	r.movie.Frames++
+	r.mu.Unlock()
+
+	return r.emu.RunFrame()
+}
+
+// Movie returns a copy of everything recorded so far
+func (r *Recorder) Movie() *Movie {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.movie
+	m.Events = append([]Event(nil), r.movie.Events...)
+	return &m
+}
+
+func applyEvent(emu *chip8.Chip8, e Event) {
+	if e.Down {
+		emu.KeyDown(e.Key)
+	} else {
+		emu.KeyUp(e.Key)
+	}
+}
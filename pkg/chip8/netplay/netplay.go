@@ -0,0 +1,579 @@
+// Package netplay runs the same ROM on two machines in lockstep over TCP, so two players can
+// play a two-player ROM (Pong 2, Tank, ...) from different computers.
+//
+// Both machines power on from the same settings and RNG seed, which the host sends when the
+// guest connects. Each frame, each side sends the keys its player holds. The keypad of both
+// machines is the union of the two players' keys, and a player's input takes effect a few
+// frames after it's sent (the input delay) to give it time to arrive. When it arrives late,
+// the machine runs on with the other player's last known keys and, if the guess was wrong,
+// rolls back to a save state from before the frame and runs the frames again with the real
+// input. Every second the two sides compare checksums of their state to catch desyncs.
+package netplay
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+const (
+	// DefaultDelay is the default input delay in frames
+	DefaultDelay = 2
+	// MaxRollback is how many frames a machine may run ahead of the other player's input
+	// before it waits for it
+	MaxRollback = 12
+	// syncInterval is how many frames apart the state checksums are compared
+	syncInterval = chip8.FrameRate
+	// timeout is how long to wait for the other player before giving up
+	timeout = 10 * time.Second
+)
+
+// sendTimeout is how long send waits for room in the outgoing queue, a variable for the tests
+var sendTimeout = timeout
+
+// ErrPeerLeft is returned by RunFrame after the other player closed their session
+var ErrPeerLeft = errors.New("netplay: the other player left")
+
+/*
+Protocol (all multi-byte values are big-endian). The host sends
+
+	"C8NP"     4 byte magic
+	version    uint16
+	romHash    20 byte SHA-1 of the ROM
+	seed       int64
+	ipf        uint16, instructions per frame
+	quirks     uint8 bitmask, see quirkBits
+	delay      uint8, input delay in frames
+	unknown    uint8, the chip8.UnknownOpcodePolicy
+	platform   uint8 length and the name of the machine profile
+
+and the guest answers with the magic, version, its ROM's hash and its unknown opcode policy.
+The policy isn't the host's to pick: both sides refuse to play when they differ. From then on both send
+messages of a type byte, a frame number (uint32) and a value (uint32): msgInput with the keys
+held as a bitmask for that frame, msgSync with the CRC-32 of the state at the start of that
+frame and msgBye when leaving.
+*/
+
+var magic = [4]byte{'C', '8', 'N', 'P'}
+
+const version uint16 = 2
+
+const (
+	msgInput byte = iota + 1
+	msgSync
+	msgBye
+)
+
+type message struct {
+	kind  byte
+	frame uint32
+	value uint32
+}
+
+// quirkBits lists the quirks in bitmask order. New quirks are only ever appended.
+func quirkBits(q *chip8.Quirks) []*bool {
+	return []*bool{&q.ShiftUsesVy, &q.LoadStoreIncrementsI, &q.JumpUsesVx, &q.VFReset, &q.ClipSprites, &q.DisplayWait}
+}
+
+// Options configure the host's session. The guest gets them from the host.
+type Options struct {
+	Seed  int64 // Seed for the RNG
+	Delay int   // Input delay in frames, DefaultDelay when 0
+}
+
+// Session is one side of a netplay game. Frontends call RunFrame in place of Chip8.RunFrame and
+// send the local player's keys to KeyDown and KeyUp instead of the machine.
+type Session struct {
+	emu   *chip8.Chip8
+	conn  net.Conn
+	host  bool
+	delay uint32
+	out   chan message
+	in    chan message
+	errs  chan error
+
+	keyMu sync.Mutex // Apart from mu, which RunFrame holds while a paused machine blocks it
+	keys  uint16     // Keys the local player holds
+
+	mu        sync.Mutex
+	frame     uint32            // The next frame to run
+	confirmed uint32            // Frames before this have the other player's input
+	local     map[uint32]uint16 // The local player's input by frame
+	remote    map[uint32]uint16 // The other player's input by frame, as far as it's known
+	used      map[uint32]uint16 // The other player's input each frame not yet confirmed ran with
+	states    map[uint32][]byte // The state at the start of each frame that may be rolled back to
+	sums      map[uint32]uint32 // Our checksums the other player hasn't confirmed
+	peerSums  map[uint32]uint32 // Their checksums we haven't got to yet
+	synced    uint32            // The next frame to send a checksum for
+	rollback  int               // Frames run again, for statistics
+	err       error
+	closeOnce sync.Once
+}
+
+// Host waits for a guest to connect on addr (e.g. ":7000"), then powers emu on with rom.
+// emu should already be configured (platform, quirks and clock speed), the guest gets its settings.
+func Host(emu *chip8.Chip8, rom []byte, addr string, opts Options) (*Session, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("netplay: %v", err)
+	}
+	defer l.Close()
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("netplay: %v", err)
+	}
+	return Start(emu, rom, conn, true, opts)
+}
+
+// Join connects to a host at addr (e.g. "example.com:7000") and powers emu on with rom and the
+// host's settings
+func Join(emu *chip8.Chip8, rom []byte, addr string) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("netplay: %v", err)
+	}
+	return Start(emu, rom, conn, false, Options{})
+}
+
+// Start runs the handshake over conn as the host or the guest and powers emu on with rom.
+// Host and Join use it with a TCP connection, anything else reliable and ordered works too.
+func Start(emu *chip8.Chip8, rom []byte, conn net.Conn, host bool, opts Options) (*Session, error) {
+	s := &Session{
+		emu:      emu,
+		conn:     conn,
+		host:     host,
+		out:      make(chan message, 256),
+		in:       make(chan message, 256),
+		errs:     make(chan error, 2), // One each from read and write
+		local:    map[uint32]uint16{},
+		remote:   map[uint32]uint16{},
+		used:     map[uint32]uint16{},
+		states:   map[uint32][]byte{},
+		sums:     map[uint32]uint32{},
+		peerSums: map[uint32]uint32{},
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	var err error
+	if host {
+		err = s.hostHandshake(rom, opts)
+	} else {
+		err = s.guestHandshake(rom)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("netplay: %v", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	// Neither player has input for the frames before the delay
+	s.confirmed = s.delay
+	go s.read()
+	go s.write()
+	return s, nil
+}
+
+func (s *Session) hostHandshake(rom []byte, opts Options) error {
+	if opts.Delay <= 0 {
+		opts.Delay = DefaultDelay
+	}
+	if opts.Delay > MaxRollback {
+		return fmt.Errorf("input delay %d is over %d frames", opts.Delay, MaxRollback)
+	}
+	var quirks uint8
+	q := s.emu.Quirks()
+	for i, b := range quirkBits(&q) {
+		if *b {
+			quirks |= 1 << i
+		}
+	}
+	platform := s.emu.Profile().Name
+	ipf := s.emu.InstructionsPerFrame()
+	unknown := s.emu.UnknownOpcodePolicy()
+
+	w := bufio.NewWriter(s.conn)
+	w.Write(magic[:])
+	for _, v := range []interface{}{version, sha1.Sum(rom), opts.Seed, uint16(ipf), quirks, uint8(opts.Delay), uint8(unknown), uint8(len(platform))} {
+		_ = binary.Write(w, binary.BigEndian, v)
+	}
+	w.WriteString(platform)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	var reply struct {
+		Magic   [4]byte
+		Version uint16
+		ROMHash [20]byte
+		Unknown uint8
+	}
+	if err := binary.Read(s.conn, binary.BigEndian, &reply); err != nil {
+		return fmt.Errorf("reading the guest's reply: %v", err)
+	}
+	if reply.Magic != magic || reply.Version != version {
+		return fmt.Errorf("the guest isn't running a compatible version")
+	}
+	if reply.ROMHash != sha1.Sum(rom) {
+		return fmt.Errorf("the guest loaded a different ROM (sha1 %x)", reply.ROMHash)
+	}
+	if guest := chip8.UnknownOpcodePolicy(reply.Unknown); guest != unknown {
+		return fmt.Errorf("the guest's unknown opcode policy (%v) differs from the host's (%v)", guest, unknown)
+	}
+	s.delay = uint32(opts.Delay)
+	return s.powerOn(rom, platform, q, ipf, opts.Seed)
+}
+
+func (s *Session) guestHandshake(rom []byte) error {
+	var hello struct {
+		Magic    [4]byte
+		Version  uint16
+		ROMHash  [20]byte
+		Seed     int64
+		IPF      uint16
+		Quirks   uint8
+		Delay    uint8
+		Unknown  uint8
+		NameSize uint8
+	}
+	if err := binary.Read(s.conn, binary.BigEndian, &hello); err != nil {
+		return fmt.Errorf("reading the host's settings: %v", err)
+	}
+	if hello.Magic != magic || hello.Version != version {
+		return fmt.Errorf("the host isn't running a compatible version")
+	}
+	// The host checks its own delay, but may be running another build
+	if hello.Delay == 0 || hello.Delay > MaxRollback {
+		return fmt.Errorf("the host's input delay %d isn't between 1 and %d frames", hello.Delay, MaxRollback)
+	}
+	name := make([]byte, hello.NameSize)
+	if _, err := io.ReadFull(s.conn, name); err != nil {
+		return fmt.Errorf("reading the host's settings: %v", err)
+	}
+
+	w := bufio.NewWriter(s.conn)
+	w.Write(magic[:])
+	_ = binary.Write(w, binary.BigEndian, version)
+	hash := sha1.Sum(rom)
+	w.Write(hash[:])
+	unknown := s.emu.UnknownOpcodePolicy()
+	w.WriteByte(uint8(unknown))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if hello.ROMHash != hash {
+		return fmt.Errorf("the host loaded a different ROM (sha1 %x)", hello.ROMHash)
+	}
+	if host := chip8.UnknownOpcodePolicy(hello.Unknown); host != unknown {
+		return fmt.Errorf("the host's unknown opcode policy (%v) differs from the guest's (%v)", host, unknown)
+	}
+
+	var q chip8.Quirks
+	for i, b := range quirkBits(&q) {
+		*b = hello.Quirks&(1<<i) != 0
+	}
+	s.delay = uint32(hello.Delay)
+	return s.powerOn(rom, string(name), q, int(hello.IPF), hello.Seed)
+}
+
+// powerOn puts emu in the starting state both players share
+func (s *Session) powerOn(rom []byte, platform string, q chip8.Quirks, ipf int, seed int64) error {
+	p := chip8.DefaultProfile()
+	if platform != p.Name {
+		var err error
+		if p, err = chip8.LookupProfile(platform); err != nil {
+			return err
+		}
+	}
+	s.emu.SetProfile(p)
+	s.emu.SetQuirks(q)
+	s.emu.SetInstructionsPerFrame(ipf)
+	s.emu.SeedRand(seed)
+	if _, err := s.emu.LoadRomBytes(rom); err != nil {
+		return err
+	}
+	s.setKeys(0)
+	return nil
+}
+
+// Host reports whether this side is the host, player 1
+func (s *Session) Host() bool {
+	return s.host
+}
+
+// Rollbacks returns how many frames have been run again because of late input
+func (s *Session) Rollbacks() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rollback
+}
+
+// KeyDown presses a key of the local player
+func (s *Session) KeyDown(key uint8) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.keys |= 1 << (key & 0xF)
+}
+
+// KeyUp releases a key of the local player
+func (s *Session) KeyUp(key uint8) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.keys &^= 1 << (key & 0xF)
+}
+
+// RunFrame sends the local player's keys, rolls back if the other player's input proved a
+// guess wrong, and runs the next frame. It waits for the other player when they fall more than
+// MaxRollback frames behind, and returns an error when they leave, time out or desync.
+func (s *Session) RunFrame() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+
+	s.keyMu.Lock()
+	keys := s.keys
+	s.keyMu.Unlock()
+	f := s.frame + s.delay
+	s.local[f] = keys
+	if err := s.send(message{msgInput, f, uint32(keys)}); err != nil {
+		s.err = err
+		return err
+	}
+
+	rollbackTo, err := s.receive()
+	if err != nil {
+		s.err = err
+		return err
+	}
+	if rollbackTo < s.frame {
+		if err := s.emu.LoadState(s.states[rollbackTo]); err != nil {
+			s.err = fmt.Errorf("netplay: rolling back: %v", err)
+			return s.err
+		}
+		for g := rollbackTo; g < s.frame; g++ {
+			s.rollback++
+			if err := s.runFrame(g); err != nil {
+				s.err = err
+				return err
+			}
+		}
+	}
+	if err := s.runFrame(s.frame); err != nil {
+		s.err = err
+		return err
+	}
+	s.frame++
+
+	if err := s.checkSync(); err != nil {
+		s.err = err
+		return err
+	}
+	// States from before the last confirmed frame can't be rolled back to anymore
+	keep := s.confirmed
+	if s.frame < keep {
+		keep = s.frame
+	}
+	for g := range s.states {
+		if g < keep {
+			delete(s.states, g)
+			delete(s.used, g)
+			delete(s.local, g)
+			delete(s.remote, g)
+		}
+	}
+	return nil
+}
+
+// runFrame runs frame f with the input known or guessed for it, saving the state at its start
+func (s *Session) runFrame(f uint32) error {
+	state, err := s.emu.SaveState()
+	if err != nil {
+		return fmt.Errorf("netplay: %v", err)
+	}
+	s.states[f] = state
+	remote := s.remoteInput(f)
+	s.used[f] = remote
+	s.setKeys(s.local[f] | remote)
+	return s.emu.RunFrame()
+}
+
+// remoteInput returns the other player's input for frame f, or the last known one as a guess
+func (s *Session) remoteInput(f uint32) uint16 {
+	if f < s.confirmed {
+		return s.remote[f]
+	}
+	if s.confirmed == s.delay {
+		return 0
+	}
+	return s.remote[s.confirmed-1]
+}
+
+// setKeys makes the machine's keypad match keys
+func (s *Session) setKeys(keys uint16) {
+	pressed := s.emu.PressedKeys()
+	for k := uint8(0); k < 16; k++ {
+		down := keys&(1<<k) != 0
+		switch {
+		case down && !pressed[k]:
+			s.emu.KeyDown(k)
+		case !down && pressed[k]:
+			s.emu.KeyUp(k)
+		}
+	}
+}
+
+// receive handles the messages that have arrived, waiting for more while the other player is
+// too far behind. It returns the first frame that ran with a wrong guess, or s.frame.
+func (s *Session) receive() (uint32, error) {
+	rollbackTo := s.frame
+	for {
+		wait := s.frame >= s.confirmed+MaxRollback
+		var m message
+		if wait {
+			select {
+			case m = <-s.in:
+			case err := <-s.errs:
+				return 0, err
+			case <-time.After(timeout):
+				return 0, fmt.Errorf("netplay: the other player stopped responding")
+			}
+		} else {
+			select {
+			case m = <-s.in:
+			case err := <-s.errs:
+				return 0, err
+			default:
+				return rollbackTo, nil
+			}
+		}
+
+		switch m.kind {
+		case msgInput:
+			if m.frame != s.confirmed {
+				return 0, fmt.Errorf("netplay: got input for frame %d, expected %d", m.frame, s.confirmed)
+			}
+			keys := uint16(m.value)
+			s.remote[m.frame] = keys
+			s.confirmed++
+			if used, ok := s.used[m.frame]; ok && used != keys && m.frame < rollbackTo {
+				rollbackTo = m.frame
+			}
+		case msgSync:
+			s.peerSums[m.frame] = m.value
+		case msgBye:
+			return 0, ErrPeerLeft
+		}
+	}
+}
+
+// checkSync sends the checksums of the frames that became final and compares the other player's
+func (s *Session) checkSync() error {
+	for ; s.synced < s.confirmed && s.synced < s.frame; s.synced++ {
+		if s.synced%syncInterval != 0 {
+			continue
+		}
+		sum := crc32.ChecksumIEEE(s.states[s.synced])
+		s.sums[s.synced] = sum
+		if err := s.send(message{msgSync, s.synced, sum}); err != nil {
+			return err
+		}
+	}
+	for f, sum := range s.sums {
+		peer, ok := s.peerSums[f]
+		if !ok {
+			continue
+		}
+		if peer != sum {
+			return fmt.Errorf("netplay: the machines went out of sync at frame %d", f)
+		}
+		delete(s.sums, f)
+		delete(s.peerSums, f)
+	}
+	return nil
+}
+
+// send queues m for the writer. It fails when the queue stays full for sendTimeout, because the
+// connection stopped taking data: a dropped input would only show up later as a desync.
+func (s *Session) send(m message) error {
+	select {
+	case s.out <- m:
+		return nil
+	default:
+	}
+	t := time.NewTimer(sendTimeout)
+	defer t.Stop()
+	select {
+	case s.out <- m:
+		return nil
+	case <-t.C:
+		return fmt.Errorf("netplay: the connection stopped sending, %d messages are waiting", len(s.out))
+	}
+}
+
+func (s *Session) write() {
+	w := bufio.NewWriter(s.conn)
+	for m := range s.out {
+		var buf [9]byte
+		buf[0] = m.kind
+		binary.BigEndian.PutUint32(buf[1:], m.frame)
+		binary.BigEndian.PutUint32(buf[5:], m.value)
+		w.Write(buf[:])
+		if len(s.out) == 0 {
+			if err := w.Flush(); err != nil {
+				s.errs <- fmt.Errorf("netplay: %v", err)
+				// Keep taking messages so send doesn't wait on a writer that's gone
+				for range s.out {
+				}
+				return
+			}
+		}
+		if m.kind == msgBye {
+			return
+		}
+	}
+}
+
+func (s *Session) read() {
+	r := bufio.NewReader(s.conn)
+	for {
+		var buf [9]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			if err == io.EOF {
+				err = ErrPeerLeft
+			} else {
+				err = fmt.Errorf("netplay: %v", err)
+			}
+			s.errs <- err
+			return
+		}
+		m := message{buf[0], binary.BigEndian.Uint32(buf[1:]), binary.BigEndian.Uint32(buf[5:])}
+		s.in <- m
+		if m.kind == msgBye {
+			return
+		}
+	}
+}
+
+// Close tells the other player we're leaving and closes the connection
+func (s *Session) Close() error {
+	s.closeOnce.Do(func() {
+		select {
+		case s.out <- message{kind: msgBye}:
+		default:
+			// The writer is stuck, closing the connection tells the other player anyway
+		}
+		close(s.out)
+	})
+	// Give the writer a moment to get the goodbye out
+	time.Sleep(50 * time.Millisecond)
+	return s.conn.Close()
+}
@@ -0,0 +1,187 @@
+package netplay
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+var rom = []byte{
+	0xC0, 0xFF, // 200: RND V0, 0xFF
+	0xE1, 0x9E, // 202: SKP V1, key 0
+	0x72, 0x01, // 204: ADD V2, 1
+	0x12, 0x00, // 206: JP 0x200
+}
+
+func start(t *testing.T) (*Session, *Session) {
+	t.Helper()
+	a, b := net.Pipe()
+	type result struct {
+		s   *Session
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		s, err := Start(chip8.NewChip8(), rom, b, false, Options{})
+		done <- result{s, err}
+	}()
+	host, err := Start(chip8.NewChip8(), rom, a, true, Options{Seed: 42, Delay: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-done
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	return host, r.s
+}
+
+// play runs frames on both sides at once, the host slower so the guest has to guess its input.
+// press is called with each frame the host runs.
+func play(t *testing.T, host, guest *Session, frames int, press func(f int)) (hostErr, guestErr error) {
+	t.Helper()
+	done := make(chan error)
+	go func() {
+		for f := 0; f < frames; f++ {
+			if err := guest.RunFrame(); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	for f := 0; f < frames; f++ {
+		press(f)
+		if hostErr = host.RunFrame(); hostErr != nil {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return hostErr, <-done
+}
+
+func TestLockstep(t *testing.T) {
+	host, guest := start(t)
+	defer host.Close()
+	defer guest.Close()
+	hostErr, guestErr := play(t, host, guest, 200, func(f int) {
+		switch f {
+		case 30:
+			host.KeyDown(0)
+		case 50:
+			host.KeyUp(0)
+		}
+	})
+	if hostErr != nil || guestErr != nil {
+		t.Fatalf("host: %v, guest: %v", hostErr, guestErr)
+	}
+	if guest.Rollbacks() == 0 {
+		t.Error("the guest never rolled back")
+	}
+
+	a, _ := host.emu.SaveState()
+	b, _ := guest.emu.SaveState()
+	if !bytes.Equal(a, b) {
+		t.Errorf("the machines ended in different states: V2 %d and %d", host.emu.Registers().V[2], guest.emu.Registers().V[2])
+	}
+}
+
+func TestDesync(t *testing.T) {
+	host, guest := start(t)
+	defer host.Close()
+	defer guest.Close()
+	hostErr, guestErr := play(t, host, guest, 300, func(f int) {
+		if f == 10 {
+			host.emu.WriteMemory(0x300, []byte{1})
+		}
+	})
+	if hostErr == nil && guestErr == nil {
+		t.Fatal("the desync went unnoticed")
+	}
+}
+
+func TestROMMismatch(t *testing.T) {
+	a, b := net.Pipe()
+	done := make(chan error)
+	go func() {
+		_, err := Start(chip8.NewChip8(), []byte{0x12, 0x00}, b, false, Options{})
+		done <- err
+	}()
+	if _, err := Start(chip8.NewChip8(), rom, a, true, Options{}); err == nil {
+		t.Error("the host accepted a different ROM")
+	}
+	if err := <-done; err == nil {
+		t.Error("the guest accepted a different ROM")
+	}
+}
+
+func TestUnknownOpcodeMismatch(t *testing.T) {
+	a, b := net.Pipe()
+	done := make(chan error)
+	go func() {
+		emu := chip8.NewChip8()
+		emu.SetUnknownOpcodePolicy(chip8.UnknownOpcodeSkip)
+		_, err := Start(emu, rom, b, false, Options{})
+		done <- err
+	}()
+	if _, err := Start(chip8.NewChip8(), rom, a, true, Options{}); err == nil || !strings.Contains(err.Error(), "policy (skip) differs") {
+		t.Errorf("host: got error %v", err)
+	}
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "policy (error) differs") {
+		t.Errorf("guest: got error %v", err)
+	}
+}
+
+func TestGuestDelay(t *testing.T) {
+	for _, delay := range []uint8{0, MaxRollback + 1, 0xFF} {
+		a, b := net.Pipe()
+		go func() {
+			// A host that skips its own delay check
+			a.Write(magic[:])
+			for _, v := range []interface{}{version, sha1.Sum(rom), int64(42), uint16(15), uint8(0), delay, uint8(0), uint8(0)} {
+				_ = binary.Write(a, binary.BigEndian, v)
+			}
+			io.Copy(ioutil.Discard, a)
+		}()
+		_, err := Start(chip8.NewChip8(), rom, b, false, Options{})
+		if err == nil || !strings.Contains(err.Error(), "input delay") {
+			t.Errorf("delay %d: got error %v", delay, err)
+		}
+		a.Close()
+	}
+}
+
+func TestSendStuck(t *testing.T) {
+	defer func(d time.Duration) { sendTimeout = d }(sendTimeout)
+	sendTimeout = 10 * time.Millisecond
+
+	// No writer takes from the queue
+	s := &Session{out: make(chan message, 1)}
+	if err := s.send(message{msgInput, 0, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.send(message{msgInput, 1, 1}); err == nil || !strings.Contains(err.Error(), "stopped sending") {
+		t.Errorf("got error %v, want the full queue reported", err)
+	}
+}
+
+func TestPeerLeft(t *testing.T) {
+	host, guest := start(t)
+	guest.Close()
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		err = host.RunFrame()
+		time.Sleep(time.Millisecond)
+	}
+	if err != ErrPeerLeft {
+		t.Errorf("got %v, want ErrPeerLeft", err)
+	}
+}
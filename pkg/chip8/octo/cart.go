@@ -0,0 +1,132 @@
+package octo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Cartridges made by Octo are GIFs
+	"image/png"
+	"io"
+)
+
+/*
+Cartridges:
+
+An Octo cartridge is an image with a program hidden in the low bits of its pixels. The payload is
+a 32-bit big-endian length followed by that many bytes of JSON, {"program": source, "options":
+{...}}. Each byte takes two pixels, the high nibble first, in row order from the top left. A
+nibble's bits are stored in the lowest bit of red, the lowest bit of green and the two lowest
+bits of blue, from the most significant down.
+
+Cartridges are written as PNG, which (unlike Octo's GIFs) keeps the colors exactly without
+needing a palette built around the payload. ReadCartridge reads either.
+*/
+
+// cartWidth is the width of a cartridge without a label
+const cartWidth = 128
+
+// cartBackground fills the parts of a cartridge the label doesn't cover
+var cartBackground = color.NRGBA{0x99, 0x66, 0x00, 0xFF}
+
+// Cartridge is a program saved by Octo with its options
+type Cartridge struct {
+	Program string  `json:"program"`
+	Options Options `json:"options"`
+}
+
+// IsCartridge reports whether data is an image that could be a cartridge
+func IsCartridge(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) || bytes.HasPrefix(data, []byte("GIF8"))
+}
+
+// ROM compiles the cartridge's program
+func (c *Cartridge) ROM() ([]byte, error) {
+	return Compile(c.Program)
+}
+
+// ReadCartridge decodes a cartridge image
+func ReadCartridge(r io.Reader) (*Cartridge, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("octo: failed to decode cartridge: %v", err)
+	}
+	b := img.Bounds()
+	readByte := func(i int) byte {
+		var v byte
+		for p := 2 * i; p < 2*i+2; p++ {
+			x, y := b.Min.X+p%b.Dx(), b.Min.Y+p/b.Dx()
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			v = v<<4 | (c.R&1)<<3 | (c.G&1)<<2 | c.B&3
+		}
+		return v
+	}
+
+	capacity := b.Dx() * b.Dy() / 2
+	if capacity < 4 {
+		return nil, fmt.Errorf("octo: image too small to be a cartridge")
+	}
+	var header [4]byte
+	for i := range header {
+		header[i] = readByte(i)
+	}
+	n := int(binary.BigEndian.Uint32(header[:]))
+	if n <= 0 || n > capacity-4 {
+		return nil, fmt.Errorf("octo: not a cartridge (payload length %d, room for %d)", n, capacity-4)
+	}
+	payload := make([]byte, n)
+	for i := range payload {
+		payload[i] = readByte(4 + i)
+	}
+
+	c := &Cartridge{Options: DefaultOptions()}
+	if err := json.Unmarshal(payload, c); err != nil {
+		return nil, fmt.Errorf("octo: failed to decode cartridge payload: %v", err)
+	}
+	return c, nil
+}
+
+// WriteCartridge writes c as a PNG cartridge. The label is the picture it shows, and may be nil.
+// The image is the label's size, or taller when the payload doesn't fit.
+func WriteCartridge(w io.Writer, c *Cartridge, label image.Image) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	payload := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(payload, uint32(len(data)))
+	payload = append(payload, data...)
+
+	width, height := cartWidth, 0
+	if label != nil {
+		width, height = label.Bounds().Dx(), label.Bounds().Dy()
+	}
+	if need := (2*len(payload) + width - 1) / width; need > height {
+		height = need
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := cartBackground
+			if label != nil && y < label.Bounds().Dy() {
+				lb := label.Bounds()
+				c = color.NRGBAModel.Convert(label.At(lb.Min.X+x, lb.Min.Y+y)).(color.NRGBA)
+			}
+			if p := y*width + x; p < 2*len(payload) {
+				nibble := payload[p/2] >> 4
+				if p%2 == 1 {
+					nibble = payload[p/2] & 0xF
+				}
+				c.R = c.R&^1 | nibble>>3
+				c.G = c.G&^1 | nibble>>2&1
+				c.B = c.B&^3 | nibble&3
+			}
+			c.A = 0xFF
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,136 @@
+// Package octo reads and writes the formats of Octo, John Earnest's CHIP-8 IDE: .o8 source
+// (compiled with asm.AssembleOcto) and "octocart" cartridges, images that carry a program's
+// source and its interpreter options hidden in their pixels.
+package octo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/asm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/compat"
+)
+
+// Values of Options.MaxSize for the platforms Octo knows
+const (
+	MaxSizeVIP    = 3216
+	MaxSizeSCHIP  = 3583
+	MaxSizeOcto   = 3584
+	MaxSizeXOCHIP = 65024
+)
+
+// Options are the interpreter settings Octo saves with a program
+type Options struct {
+	TickRate        int    `json:"tickrate"` // Instructions per frame
+	FillColor       string `json:"fillColor"`
+	FillColor2      string `json:"fillColor2"`
+	BlendColor      string `json:"blendColor"`
+	BackgroundColor string `json:"backgroundColor"`
+	BuzzColor       string `json:"buzzColor"`
+	QuietColor      string `json:"quietColor"`
+	ShiftQuirks     bool   `json:"shiftQuirks"`     // 8xy6 / 8xyE shift vx in place
+	LoadStoreQuirks bool   `json:"loadStoreQuirks"` // Fx55 / Fx65 leave i alone
+	JumpQuirks      bool   `json:"jumpQuirks"`      // Bnnn is Bxnn
+	LogicQuirks     bool   `json:"logicQuirks"`     // 8xy1 / 8xy2 / 8xy3 reset vF
+	ClipQuirks      bool   `json:"clipQuirks"`      // Sprites are clipped at the screen edges
+	VBlankQuirks    bool   `json:"vBlankQuirks"`    // Dxyn waits for the vertical blank
+	ScreenRotation  int    `json:"screenRotation"`
+	MaxSize         int    `json:"maxSize"`
+	TouchInputMode  string `json:"touchInputMode"`
+	FontStyle       string `json:"fontStyle"`
+}
+
+// DefaultOptions are Octo's defaults
+func DefaultOptions() Options {
+	return Options{
+		TickRate:        20,
+		FillColor:       "#FFCC00",
+		FillColor2:      "#FF6600",
+		BlendColor:      "#662200",
+		BackgroundColor: "#996600",
+		BuzzColor:       "#FFAA00",
+		QuietColor:      "#000000",
+		MaxSize:         MaxSizeOcto,
+		TouchInputMode:  "none",
+		FontStyle:       "octo",
+	}
+}
+
+// Quirks converts the options to the emulator's quirks
+func (o Options) Quirks() chip8.Quirks {
+	return chip8.Quirks{
+		ShiftUsesVy:          !o.ShiftQuirks,
+		LoadStoreIncrementsI: !o.LoadStoreQuirks,
+		JumpUsesVx:           o.JumpQuirks,
+		VFReset:              o.LogicQuirks,
+		ClipSprites:          o.ClipQuirks,
+		DisplayWait:          o.VBlankQuirks,
+	}
+}
+
+// Platform guesses the platform from MaxSize. Octo's own default (MaxSizeOcto) isn't any of them.
+func (o Options) Platform() compat.Platform {
+	switch o.MaxSize {
+	case MaxSizeVIP:
+		return compat.PlatformCHIP8
+	case MaxSizeSCHIP:
+		return compat.PlatformSCHIP
+	case MaxSizeXOCHIP:
+		return compat.PlatformXOCHIP
+	}
+	return ""
+}
+
+// Entry converts the options to a compatibility database entry, see compat.Register
+func (o Options) Entry(title string) compat.Entry {
+	return compat.Entry{
+		Title:                title,
+		Platform:             o.Platform(),
+		Quirks:               o.Quirks(),
+		InstructionsPerFrame: o.TickRate,
+	}
+}
+
+// OptionsFor returns Octo's default options with the given quirks and speed
+func OptionsFor(q chip8.Quirks, ipf int) Options {
+	o := DefaultOptions()
+	o.TickRate = ipf
+	o.ShiftQuirks = !q.ShiftUsesVy
+	o.LoadStoreQuirks = !q.LoadStoreIncrementsI
+	o.JumpQuirks = q.JumpUsesVx
+	o.LogicQuirks = q.VFReset
+	o.ClipQuirks = q.ClipSprites
+	o.VBlankQuirks = q.DisplayWait
+	return o
+}
+
+// Compile assembles Octo source
+func Compile(src string) ([]byte, error) {
+	rom, err := asm.AssembleOcto(src)
+	if err != nil {
+		return nil, fmt.Errorf("octo: %v", err)
+	}
+	return rom, nil
+}
+
+// Source returns Octo source for a ROM image: main at 0x200, followed by the ROM as bytes.
+// Compiling it gives back the same image.
+func Source(rom []byte) string {
+	var b strings.Builder
+	b.WriteString(": main\n")
+	for i := 0; i < len(rom); i += 16 {
+		end := i + 16
+		if end > len(rom) {
+			end = len(rom)
+		}
+		for j, v := range rom[i:end] {
+			if j > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "0x%02X", v)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
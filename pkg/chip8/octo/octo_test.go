@@ -0,0 +1,163 @@
+package octo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/asm"
+)
+
+func TestCompile(t *testing.T) {
+	src := `
+		:const SPEED 3
+		:alias x v4
+		# the jump to main goes first
+		: sprite 0xF0 0x90 0xF0
+		: main
+			clear
+			x := SPEED  x += -1  x -= v1
+			i := sprite  sprite x v2 3
+			if x == 2 then x := random 0x0F
+			if v1 key begin
+				v1 := key
+			else
+				delay := v1
+			end
+			loop
+				draw
+				while v3 != v4
+			again
+			jump0 sprite
+		: draw
+			save v2 bcd v0 i += v1 i := hex v0
+			;`
+	want := `
+		JP main
+		sprite: db 0xF0, 0x90, 0xF0
+		main: CLS
+		LD V4, 3
+		ADD V4, 0xFF
+		SUB V4, V1
+		LD I, sprite
+		DRW V4, V2, 3
+		SNE V4, 2
+		RND V4, 0x0F
+		SKP V1
+		JP else
+		LD V1, K
+		JP end
+		else: LD DT, V1
+		end: CALL draw
+		SNE V3, V4
+		JP out
+		JP end
+		out: JP V0, sprite
+		draw: LD [I], V2
+		LD B, V0
+		ADD I, V1
+		LD F, V0
+		RET`
+	got, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantROM, err := asm.Assemble(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, wantROM) {
+		t.Errorf("Compile:\ngot  % X\nwant % X", got, wantROM)
+	}
+
+	for _, bad := range []string{": main jump nowhere", "v0 := 1", ": main loop", ": main :macro m { }", ": main v0 := 256"} {
+		if _, err := Compile(bad); err == nil {
+			t.Errorf("Compile(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// v2 counts the comparisons of 5 against v1 that came out true
+	src := `
+		: main
+			v1 := 4  test
+			v1 := 5  test
+			v1 := 6  test
+			v0 := 5  v5 := 0
+			if v0 <= 5 then v5 += 1
+			if v0 >= 6 then v5 += 1
+			loop again
+		: test
+			v0 := 5  v2 := 0
+			if v0 < v1 then v2 += 1
+			if v0 > v1 then v2 += 1
+			if v0 <= v1 then v2 += 0x10
+			if v0 >= v1 begin v2 += 0x10 end
+			i := counts  i += v1  v0 := v2  save v0
+			;
+		: counts 0 0 0 0 0 0 0 0`
+	rom, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emu := chip8.NewChip8()
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	if err := emu.RunFor(200); err != nil {
+		t.Fatal(err)
+	}
+	counts := 0x200 + len(rom) - 8
+	// 5 vs 4: > and >=. 5 vs 5: <= and >=. 5 vs 6: < and <=.
+	if got := emu.Memory[counts+4 : counts+7]; !bytes.Equal(got, []byte{0x11, 0x20, 0x11}) {
+		t.Errorf("comparisons: got % X, want 11 20 11", got)
+	}
+	if emu.V[5] != 1 {
+		t.Errorf("comparisons with a constant: got %d true, want 1", emu.V[5])
+	}
+}
+
+func TestSource(t *testing.T) {
+	rom := make([]byte, 37)
+	for i := range rom {
+		rom[i] = byte(i * 7)
+	}
+	got, err := Compile(Source(rom))
+	if err != nil || !bytes.Equal(got, rom) {
+		t.Errorf("Compile(Source(rom)) = % X, %v", got, err)
+	}
+}
+
+func TestCartridge(t *testing.T) {
+	q := chip8.Quirks{ShiftUsesVy: true, VFReset: true, ClipSprites: true}
+	cart := &Cartridge{Program: ": main\n\tloop again\n", Options: OptionsFor(q, 15)}
+	label := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	label.Set(63, 31, color.White)
+
+	for _, l := range []image.Image{nil, label} {
+		var buf bytes.Buffer
+		if err := WriteCartridge(&buf, cart, l); err != nil {
+			t.Fatal(err)
+		}
+		if !IsCartridge(buf.Bytes()) {
+			t.Errorf("IsCartridge = false for a written cartridge")
+		}
+		got, err := ReadCartridge(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, cart) {
+			t.Errorf("ReadCartridge = %+v, want %+v", got, cart)
+		}
+		if got.Options.Quirks() != q || got.Options.TickRate != 15 {
+			t.Errorf("options: got quirks %+v, tickrate %d", got.Options.Quirks(), got.Options.TickRate)
+		}
+		if rom, err := got.ROM(); err != nil || !bytes.Equal(rom, []byte{0x12, 0x00}) {
+			t.Errorf("ROM() = % X, %v", rom, err)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package chip8
+
+/*
+Instruction dispatch:
+
+Each opcode is decoded once into an instr holding its operand fields and the function that
+executes it, looked up from per-nibble tables. Decoded instructions are cached by address and
+reused for as long as the opcode at that address stays the same, so self-modifying code is
+simply decoded again.
+
+Opcode table reference: https://en.wikipedia.org/wiki/CHIP-8#Opcode_table
+*/
+
+// opFunc executes an instruction, the operand fields in ch are already set
+type opFunc func(ch *Chip8) error
+
+// instr is a decoded instruction
+type instr struct {
+	opcode      uint16
+	x, y, n, kk uint8
+	nnn         uint16
+	exec        opFunc
+	pure        bool // See pureOp
+}
+
+// opTable dispatches on the first nibble. Groups that share a first nibble have their own
+// table, indexed by the last nibble (8xyN) or the low byte (00kk, Exkk, Fxkk).
+var opTable [16]opFunc
+var sysOps, keyOps, miscOps [256]opFunc
+var mathOps [16]opFunc
+
+func init() {
+	opTable = [16]opFunc{
+		0x1: opJP, 0x2: opCALL, 0x3: opSEByte, 0x4: opSNEByte, 0x5: opSEReg, 0x6: opLDByte, 0x7: opADDByte,
+		0x9: opSNEReg, 0xA: opLDI, 0xB: opJPV0, 0xC: opRND, 0xD: opDRW,
+	}
+	sysOps[0xE0] = opCLS
+	sysOps[0xEE] = opRET
+	sysOps[0x30] = opHiresCLS
+	sysOps[0x10] = opMEGAOFF
+	sysOps[0x11] = opMEGAON
+	mathOps = [16]opFunc{
+		0x0: opLDReg, 0x1: opOR, 0x2: opAND, 0x3: opXOR, 0x4: opADDReg, 0x5: opSUB, 0x6: opSHR, 0x7: opSUBN, 0xE: opSHL,
+	}
+	keyOps[0x9E] = opSKP
+	keyOps[0xA1] = opSKNP
+	miscOps[0x02] = opAUDIO
+	miscOps[0x07] = opLDVxDT
+	miscOps[0x0A] = opLDK
+	miscOps[0x15] = opLDDT
+	miscOps[0x18] = opLDST
+	miscOps[0x1E] = opADDI
+	miscOps[0x29] = opLDF
+	miscOps[0x33] = opLDB
+	miscOps[0x3A] = opLDPITCH
+	miscOps[0x55] = opStore
+	miscOps[0x65] = opLoad
+	miscOps[0x75] = opStoreFlags
+	miscOps[0x85] = opLoadFlags
+}
+
+// decode splits op into its fields and finds the function executing it, opUnknown (see unknown.go) if there's none
+func decode(op uint16) instr {
+	in := instr{
+		opcode: op,
+		x:      uint8(op>>8) & 0x0F, // lower 4 bits of high byte
+		y:      uint8(op>>4) & 0x0F, // upper 4 bits of low byte
+		n:      uint8(op) & 0x0F,    // lower 4 bits of low byte
+		kk:     uint8(op),           // low byte
+		nnn:    op & 0x0FFF,         // lower 12 bits of opcode (for addresses into 2^12 bytes of memory)
+	}
+	switch op >> 12 {
+	case 0x0:
+		in.exec = sysOps[in.kk]
+		if in.x != 0 {
+			in.exec = opMega
+		}
+	case 0x8:
+		in.exec = mathOps[in.n]
+	case 0x9:
+		if in.n == 0 {
+			in.exec = opTable[0x9]
+		}
+	case 0xE:
+		in.exec = keyOps[in.kk]
+	case 0xF:
+		in.exec = miscOps[in.kk]
+	default:
+		in.exec = opTable[op>>12]
+	}
+	if in.exec == nil {
+		in.exec = opUnknown
+	}
+	in.pure = pureOp(op)
+	return in
+}
+
+// 00E0 - CLS
+func opCLS(ch *Chip8) error {
+	if ch.mega != nil && ch.mega.on {
+		ch.megaCLS()
+		return nil
+	}
+	ch.Screen.Clear()
+	ch.invalidate(AllDirty)
+	return nil
+}
+
+// 00EE - RET
+func opRET(ch *Chip8) error {
+	if ch.SP == 0 || int(ch.SP) > len(ch.Stack) {
+		return fault(ErrStackUnderflow, "RET with SP=%d", ch.SP)
+	}
+	ch.SP -= 1
+	ch.PC = ch.Stack[ch.SP]
+	return nil
+}
+
+// 1nnn - JP addr
+func opJP(ch *Chip8) error {
+	ch.PC = ch.nnn
+	if ch.hires && ch.nnn == 0x260 {
+		ch.PC = 0x2C0 // Past the hires interpreter patch, see hires.go
+	}
+	return nil
+}
+
+// 2nnn - CALL addr
+func opCALL(ch *Chip8) error {
+	if int(ch.SP) >= len(ch.Stack) {
+		return fault(ErrStackOverflow, "CALL %#03x with SP=%d", ch.nnn, ch.SP)
+	}
+	ch.Stack[ch.SP] = ch.PC
+	ch.SP++
+	ch.PC = ch.nnn
+	return nil
+}
+
+// 3xkk - SE Vx, byte (skip if equal)
+func opSEByte(ch *Chip8) error {
+	if ch.V[ch.x] == ch.kk {
+		ch.PC += 2
+	}
+	return nil
+}
+
+// 4xkk - SNE Vx, byte (skip if not equal)
+func opSNEByte(ch *Chip8) error {
+	if ch.V[ch.x] != ch.kk {
+		ch.PC += 2
+	}
+	return nil
+}
+
+// 5xy0 - SE Vx, Vy
+func opSEReg(ch *Chip8) error {
+	if ch.V[ch.x] == ch.V[ch.y] {
+		ch.PC += 2
+	}
+	return nil
+}
+
+// 6xkk - LD Vx, byte
+func opLDByte(ch *Chip8) error {
+	ch.V[ch.x] = ch.kk
+	return nil
+}
+
+// 7xkk - ADD Vx, byte
+func opADDByte(ch *Chip8) error {
+	ch.V[ch.x] = ch.V[ch.x] + ch.kk
+	return nil
+}
+
+// 8xy0 - LD Vx, Vy
+func opLDReg(ch *Chip8) error {
+	ch.V[ch.x] = ch.V[ch.y]
+	return nil
+}
+
+// 8xy1 - OR Vx, Vy
+func opOR(ch *Chip8) error {
+	ch.V[ch.x] = ch.V[ch.x] | ch.V[ch.y]
+	if ch.quirks.VFReset {
+		ch.V[0xF] = 0
+	}
+	return nil
+}
+
+// 8xy2 - AND Vx, Vy
+func opAND(ch *Chip8) error {
+	ch.V[ch.x] = ch.V[ch.x] & ch.V[ch.y]
+	if ch.quirks.VFReset {
+		ch.V[0xF] = 0
+	}
+	return nil
+}
+
+// 8xy3 - XOR Vx, Vy
+func opXOR(ch *Chip8) error {
+	ch.V[ch.x] = ch.V[ch.x] ^ ch.V[ch.y]
+	if ch.quirks.VFReset {
+		ch.V[0xF] = 0
+	}
+	return nil
+}
+
+// 8xy4 - ADD Vx, Vy
+//
+// For all of 8xy4 - 8xyE the flag is worked out from the operands first and VF is written last,
+// so the flag wins when Vx is VF
+func opADDReg(ch *Chip8) error {
+	sum := uint16(ch.V[ch.x]) + uint16(ch.V[ch.y])
+	ch.V[ch.x] = uint8(sum)
+	ch.V[0xF] = uint8(sum >> 8) // carry
+	return nil
+}
+
+// 8xy5 - SUB Vx, Vy
+func opSUB(ch *Chip8) error {
+	vx, vy := ch.V[ch.x], ch.V[ch.y]
+	ch.V[ch.x] = vx - vy
+	ch.V[0xF] = noBorrow(vx, vy)
+	return nil
+}
+
+// noBorrow is the VF value for a - b: 1 unless it borrows
+func noBorrow(a, b uint8) uint8 {
+	if a >= b {
+		return 1
+	}
+	return 0
+}
+
+// 8xy6 - SHR Vx {, Vy}
+func opSHR(ch *Chip8) error {
+	v := ch.V[ch.x]
+	if ch.quirks.ShiftUsesVy {
+		v = ch.V[ch.y]
+	}
+	ch.V[ch.x] = v >> 1
+	ch.V[0xF] = v & 0x1
+	return nil
+}
+
+// 8xy7 - SUBN Vx, Vy
+func opSUBN(ch *Chip8) error {
+	vx, vy := ch.V[ch.x], ch.V[ch.y]
+	ch.V[ch.x] = vy - vx
+	ch.V[0xF] = noBorrow(vy, vx)
+	return nil
+}
+
+// 8xyE - SHL Vx {, Vy}
+func opSHL(ch *Chip8) error {
+	v := ch.V[ch.x]
+	if ch.quirks.ShiftUsesVy {
+		v = ch.V[ch.y]
+	}
+	ch.V[ch.x] = v << 1
+	ch.V[0xF] = v >> 7
+	return nil
+}
+
+// 9xy0 - SNE Vx, Vy
+func opSNEReg(ch *Chip8) error {
+	if ch.V[ch.x] != ch.V[ch.y] {
+		ch.PC += 2
+	}
+	return nil
+}
+
+// Annn - LD I, addr
+func opLDI(ch *Chip8) error {
+	ch.I = ch.nnn
+	if ch.mega != nil {
+		ch.mega.ihi = 0
+	}
+	return nil
+}
+
+// Bnnn - JP V0, addr
+func opJPV0(ch *Chip8) error {
+	if ch.quirks.JumpUsesVx {
+		ch.PC = uint16(ch.V[ch.x]) + ch.nnn // Bxnn - JP Vx, addr
+	} else {
+		ch.PC = uint16(ch.V[0x0]) + ch.nnn
+	}
+	return nil
+}
+
+// Cxkk - RND Vx, byte
+func opRND(ch *Chip8) error {
+	ch.V[ch.x] = uint8(ch.rng.Int63()>>55) & ch.kk
+	return nil
+}
+
+// Dxyn - DRW Vx, Vy, nibble
+func opDRW(ch *Chip8) error {
+	if ch.mega != nil && ch.mega.on {
+		return ch.megaDRW()
+	}
+	screen := ch.Screen
+	col := int(ch.V[ch.x]) % screen.Width
+	row := int(ch.V[ch.y]) % screen.Height
+	ch.V[0xF] = 0 // reset carry flag
+	if err := ch.checkMem(ch.I, int(ch.n)); err != nil {
+		return err
+	}
+	var dirty DirtyBlocks
+	for byteInd := 0; byteInd < int(ch.n); byteInd++ {
+		spriteByte := ch.readMem(ch.I + uint16(byteInd))
+		for bitInd := 0; bitInd < 8; bitInd++ {
+			bit := (spriteByte >> bitInd) & 0x1
+
+			if bit == 0 {
+				continue
+			}
+			screenX, screenY, ok := screen.Locate(col+7-bitInd, row+byteInd, ch.quirks.ClipSprites)
+			if !ok {
+				continue
+			}
+			if screen.Flip(screenX, screenY, 1) {
+				ch.V[0xF] = 1 // set carry flag if a collision occurs
+			}
+			dirty |= BlockOf(screen, screenX, screenY)
+		}
+	}
+	ch.invalidate(dirty) // need a redraw
+	if ch.quirks.DisplayWait {
+		ch.waitForVBlank()
+	}
+	return nil
+}
+
+// Ex9E - SKP Vx (only the low nibble of Vx picks the key)
+func opSKP(ch *Chip8) error {
+	if ch.keyboard[ch.V[ch.x]&0xF] {
+		ch.PC += 2
+	}
+	return nil
+}
+
+// ExA1 - SKNP Vx (only the low nibble of Vx picks the key)
+func opSKNP(ch *Chip8) error {
+	if !ch.keyboard[ch.V[ch.x]&0xF] {
+		ch.PC += 2
+	}
+	return nil
+}
+
+// F002 - AUDIO (XO-CHIP)
+func opAUDIO(ch *Chip8) error {
+	if ch.x != 0 {
+		return opUnknown(ch)
+	}
+	if err := ch.checkMem(ch.I, len(ch.pattern)); err != nil {
+		return err
+	}
+	for i := range ch.pattern {
+		ch.pattern[i] = ch.readMem(ch.I + uint16(i))
+	}
+	ch.patternLoaded = true
+	ch.updatePattern()
+	return nil
+}
+
+// Fx07 - LD Vx, DT
+func opLDVxDT(ch *Chip8) error {
+	ch.V[ch.x] = ch.DT
+	return nil
+}
+
+// Fx0A - LD Vx, K
+func opLDK(ch *Chip8) error {
+	ch.waitForKey()
+	return nil
+}
+
+// Fx15 - LD DT, Vx
+func opLDDT(ch *Chip8) error {
+	ch.DT = ch.V[ch.x]
+	return nil
+}
+
+// Fx18 - LD ST, Vx
+func opLDST(ch *Chip8) error {
+	ch.ST = ch.V[ch.x]
+	if ch.ST > 0 {
+		ch.log.Debug(LogTimer, "sound timer started", "frames", ch.ST)
+		if ch.audio != nil {
+			ch.audio.Beep(true)
+		}
+	}
+	return nil
+}
+
+// Fx1E - ADD I, Vx
+func opADDI(ch *Chip8) error {
+	if ch.mega != nil {
+		// MegaChip's I is 24 bits wide, see megachip.go
+		i := ch.megaI() + uint32(ch.V[ch.x])
+		ch.mega.ihi, ch.I = uint8(i>>16), uint16(i)
+		return nil
+	}
+	ch.I += uint16(ch.V[ch.x])
+
+	// TODO: Add a flag for this?
+	// See: https://en.wikipedia.org/wiki/CHIP-8#cite_note-16
+	//if ch.I > 0xFFF {
+	//	ch.V[0xF] = 1
+	//} else {
+	//	ch.V[0xF] = 0
+	//}
+	return nil
+}
+
+// Fx29 - LD F, Vx
+func opLDF(ch *Chip8) error {
+	ch.I = uint16(ch.V[ch.x])*5 + ch.profile.FontAddress
+	if ch.mega != nil {
+		ch.mega.ihi = 0
+	}
+	return nil
+}
+
+// Fx33 - LD B, Vx
+func opLDB(ch *Chip8) error {
+	if err := ch.checkMem(ch.I, 3); err != nil {
+		return err
+	}
+	ch.writeMem(ch.I, uint8((uint16(ch.V[ch.x])%1000)/100)) // Hundreds place
+	ch.writeMem(ch.I+1, (ch.V[ch.x]%100)/10)                // Tens place
+	ch.writeMem(ch.I+2, ch.V[ch.x]%10)                      // Ones place
+	return nil
+}
+
+// Fx3A - LD PITCH, Vx (XO-CHIP)
+func opLDPITCH(ch *Chip8) error {
+	ch.pitch = ch.V[ch.x]
+	ch.updatePattern()
+	return nil
+}
+
+// Fx55 - LD [I], Vx
+func opStore(ch *Chip8) error {
+	if err := ch.checkMem(ch.I, int(ch.x)+1); err != nil {
+		return err
+	}
+	for a := 0; a <= int(ch.x); a++ {
+		ch.writeMem(ch.I+uint16(a), ch.V[a])
+	}
+	if ch.quirks.LoadStoreIncrementsI {
+		ch.I += uint16(ch.x) + 1
+	}
+	return nil
+}
+
+// Fx65 - LD Vx, [I]
+func opLoad(ch *Chip8) error {
+	if err := ch.checkMem(ch.I, int(ch.x)+1); err != nil {
+		return err
+	}
+	for a := 0; a <= int(ch.x); a++ {
+		ch.V[a] = ch.readMem(ch.I + uint16(a))
+	}
+	if ch.quirks.LoadStoreIncrementsI {
+		ch.I += uint16(ch.x) + 1
+	}
+	return nil
+}
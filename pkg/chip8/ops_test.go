@@ -0,0 +1,1332 @@
+package chip8
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/asm"
+)
+
+// opTest runs a single instruction at 0x200. The registers are expected to match their state
+// after setup with PC advanced past the instruction, plus whatever want changes.
+type opTest struct {
+	name   string
+	op     uint16
+	quirks Quirks
+	setup  func(ch *Chip8)
+	want   func(r *Registers)
+	check  func(t *testing.T, ch *Chip8) // Optional, for screen and memory effects
+}
+
+func runOpTests(t *testing.T, tests []opTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := NewChip8()
+			ch.SeedRand(1)
+			ch.LoadRomBytes([]byte{byte(tt.op >> 8), byte(tt.op)})
+			ch.SetQuirks(tt.quirks)
+			if tt.setup != nil {
+				tt.setup(ch)
+			}
+			want := ch.Registers()
+			want.PC += 2
+			if tt.want != nil {
+				tt.want(&want)
+			}
+
+			if _, err := ch.EmulateCycle(); err != nil {
+				t.Fatalf("%04X: %v", tt.op, err)
+			}
+			if got := ch.Registers(); got != want {
+				t.Errorf("%04X:\n got %+v\nwant %+v", tt.op, got, want)
+			}
+			if tt.check != nil {
+				tt.check(t, ch)
+			}
+		})
+	}
+}
+
+// regs sets V0, V1, ... to vs
+func regs(vs ...byte) func(ch *Chip8) {
+	return func(ch *Chip8) {
+		copy(ch.V[:], vs)
+	}
+}
+
+func TestFlowOpcodes(t *testing.T) {
+	runOpTests(t, []opTest{
+		{name: "00EE RET", op: 0x00EE,
+			setup: func(ch *Chip8) { ch.SP = 1; ch.Stack[0] = 0x246 },
+			want:  func(r *Registers) { r.PC = 0x246; r.SP = 0 }},
+		{name: "1nnn JP", op: 0x1ABC,
+			want: func(r *Registers) { r.PC = 0xABC }},
+		{name: "2nnn CALL", op: 0x2ABC,
+			want: func(r *Registers) { r.PC = 0xABC; r.SP = 1; r.Stack[0] = 0x202 }},
+		{name: "2nnn CALL nested", op: 0x2ABC,
+			setup: func(ch *Chip8) { ch.SP = 3 },
+			want:  func(r *Registers) { r.PC = 0xABC; r.SP = 4; r.Stack[3] = 0x202 }},
+		{name: "Bnnn JP V0", op: 0xB300, setup: regs(0x10, 0x20, 0x30, 0x40),
+			want: func(r *Registers) { r.PC = 0x310 }},
+		{name: "Bnnn JP V0 jump quirk", op: 0xB300, quirks: Quirks{JumpUsesVx: true}, setup: regs(0x10, 0x20, 0x30, 0x40),
+			want: func(r *Registers) { r.PC = 0x340 }},
+	})
+}
+
+func TestSkipOpcodes(t *testing.T) {
+	skip := func(r *Registers) { r.PC += 2 }
+	pressed := func(key uint8) func(ch *Chip8) {
+		return func(ch *Chip8) { ch.V[0] = key; ch.keyboard[key] = true }
+	}
+	runOpTests(t, []opTest{
+		{name: "3xkk SE equal", op: 0x3142, setup: regs(0, 0x42), want: skip},
+		{name: "3xkk SE not equal", op: 0x3142, setup: regs(0, 0x41)},
+		{name: "4xkk SNE equal", op: 0x4142, setup: regs(0, 0x42)},
+		{name: "4xkk SNE not equal", op: 0x4142, setup: regs(0, 0x41), want: skip},
+		{name: "5xy0 SE equal", op: 0x5120, setup: regs(0, 7, 7), want: skip},
+		{name: "5xy0 SE not equal", op: 0x5120, setup: regs(0, 7, 8)},
+		{name: "9xy0 SNE equal", op: 0x9120, setup: regs(0, 7, 7)},
+		{name: "9xy0 SNE not equal", op: 0x9120, setup: regs(0, 7, 8), want: skip},
+		{name: "Ex9E SKP pressed", op: 0xE09E, setup: pressed(0xA), want: skip},
+		{name: "Ex9E SKP released", op: 0xE09E, setup: regs(0xA)},
+		{name: "ExA1 SKNP pressed", op: 0xE0A1, setup: pressed(0xA)},
+		{name: "ExA1 SKNP released", op: 0xE0A1, setup: regs(0xA), want: skip},
+	})
+}
+
+func TestLoadOpcodes(t *testing.T) {
+	runOpTests(t, []opTest{
+		{name: "6xkk LD", op: 0x6A42,
+			want: func(r *Registers) { r.V[0xA] = 0x42 }},
+		{name: "7xkk ADD", op: 0x7A02, setup: regs(0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x40),
+			want: func(r *Registers) { r.V[0xA] = 0x42 }},
+		{name: "7xkk ADD wraps without touching VF", op: 0x7A02, setup: regs(0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xFF),
+			want: func(r *Registers) { r.V[0xA] = 0x01 }},
+		{name: "8xy0 LD", op: 0x8120, setup: regs(0, 1, 2),
+			want: func(r *Registers) { r.V[1] = 2 }},
+		{name: "Annn LD I", op: 0xA123,
+			want: func(r *Registers) { r.I = 0x123 }},
+		{name: "Fx07 LD Vx, DT", op: 0xF307, setup: func(ch *Chip8) { ch.DT = 0x33 },
+			want: func(r *Registers) { r.V[3] = 0x33 }},
+		{name: "Fx15 LD DT", op: 0xF315, setup: regs(0, 0, 0, 0x33),
+			want: func(r *Registers) { r.DT = 0x33 }},
+		{name: "Fx18 LD ST", op: 0xF318, setup: regs(0, 0, 0, 0x33),
+			want: func(r *Registers) { r.ST = 0x33 }},
+		{name: "Fx1E ADD I", op: 0xF31E, setup: func(ch *Chip8) { ch.I = 0x100; ch.V[3] = 0x33 },
+			want: func(r *Registers) { r.I = 0x133 }},
+		{name: "Fx1E ADD I leaves VF alone", op: 0xF31E, setup: func(ch *Chip8) { ch.I = 0xFFF; ch.V[3] = 2 },
+			want: func(r *Registers) { r.I = 0x1001 }},
+		{name: "Fx29 LD F", op: 0xF329, setup: regs(0, 0, 0, 0xA),
+			want: func(r *Registers) { r.I = 0x050 + 0xA*5 }},
+		{name: "Cxkk RND masked to zero", op: 0xC300, setup: regs(0, 0, 0, 0xFF),
+			want: func(r *Registers) { r.V[3] = 0 }},
+	})
+}
+
+func TestRandomOpcode(t *testing.T) {
+	ch := NewChip8()
+	ch.SeedRand(1)
+	ch.LoadRomBytes([]byte{0xC0, 0x0F, 0x12, 0x00}) // RND V0, 0x0F; JP 0x200
+	seen := map[byte]bool{}
+	for i := 0; i < 200; i++ {
+		if err := ch.RunFor(2); err != nil {
+			t.Fatal(err)
+		}
+		if ch.V[0]&^0x0F != 0 {
+			t.Fatalf("RND V0, 0x0F gave %#x", ch.V[0])
+		}
+		seen[ch.V[0]] = true
+	}
+	if len(seen) < 8 {
+		t.Errorf("RND gave %d distinct values in 200 tries, want at least 8", len(seen))
+	}
+}
+
+// The flag is computed from the operands before either register is written, and VF is written
+// last: with VF as Vx it ends up holding the flag, with VF as Vy its old value is the operand.
+func TestArithmeticOpcodes(t *testing.T) {
+	vf := func(vs ...byte) func(ch *Chip8) {
+		return func(ch *Chip8) { copy(ch.V[:], vs[1:]); ch.V[0xF] = vs[0] }
+	}
+	runOpTests(t, []opTest{
+		{name: "8xy1 OR", op: 0x8121, setup: vf(0x55, 0, 0x0C, 0x0A),
+			want: func(r *Registers) { r.V[1] = 0x0E }},
+		{name: "8xy2 AND", op: 0x8122, setup: vf(0x55, 0, 0x0C, 0x0A),
+			want: func(r *Registers) { r.V[1] = 0x08 }},
+		{name: "8xy3 XOR", op: 0x8123, setup: vf(0x55, 0, 0x0C, 0x0A),
+			want: func(r *Registers) { r.V[1] = 0x06 }},
+		{name: "8xy1 OR vfreset quirk", op: 0x8121, quirks: Quirks{VFReset: true}, setup: vf(0x55, 0, 0x0C, 0x0A),
+			want: func(r *Registers) { r.V[1] = 0x0E; r.V[0xF] = 0 }},
+		{name: "8xy2 AND vfreset quirk", op: 0x8122, quirks: Quirks{VFReset: true}, setup: vf(0x55, 0, 0x0C, 0x0A),
+			want: func(r *Registers) { r.V[1] = 0x08; r.V[0xF] = 0 }},
+		{name: "8xy3 XOR vfreset quirk", op: 0x8123, quirks: Quirks{VFReset: true}, setup: vf(0x55, 0, 0x0C, 0x0A),
+			want: func(r *Registers) { r.V[1] = 0x06; r.V[0xF] = 0 }},
+
+		{name: "8xy4 ADD", op: 0x8124, setup: vf(0x55, 0, 0x10, 0x20),
+			want: func(r *Registers) { r.V[1] = 0x30; r.V[0xF] = 0 }},
+		{name: "8xy4 ADD carry", op: 0x8124, setup: vf(0x55, 0, 0xF0, 0x20),
+			want: func(r *Registers) { r.V[1] = 0x10; r.V[0xF] = 1 }},
+		{name: "8xy4 ADD exactly 256", op: 0x8124, setup: vf(0x55, 0, 0xFF, 0x01),
+			want: func(r *Registers) { r.V[1] = 0x00; r.V[0xF] = 1 }},
+		{name: "8xy4 ADD into VF", op: 0x8F14, setup: vf(0xF0, 0, 0x20),
+			want: func(r *Registers) { r.V[0xF] = 1 }},
+		{name: "8xy4 ADD from VF", op: 0x81F4, setup: vf(0x05, 0, 0x10),
+			want: func(r *Registers) { r.V[1] = 0x15; r.V[0xF] = 0 }},
+
+		{name: "8xy5 SUB", op: 0x8125, setup: vf(0x55, 0, 0x30, 0x10),
+			want: func(r *Registers) { r.V[1] = 0x20; r.V[0xF] = 1 }},
+		{name: "8xy5 SUB borrow", op: 0x8125, setup: vf(0x55, 0, 0x10, 0x30),
+			want: func(r *Registers) { r.V[1] = 0xE0; r.V[0xF] = 0 }},
+		{name: "8xy5 SUB equal", op: 0x8125, setup: vf(0x55, 0, 0x10, 0x10),
+			want: func(r *Registers) { r.V[1] = 0x00; r.V[0xF] = 1 }},
+		{name: "8xy5 SUB into VF", op: 0x8F15, setup: vf(0x10, 0, 0x30),
+			want: func(r *Registers) { r.V[0xF] = 0 }},
+		{name: "8xy5 SUB from VF", op: 0x81F5, setup: vf(0x05, 0, 0x10),
+			want: func(r *Registers) { r.V[1] = 0x0B; r.V[0xF] = 1 }},
+
+		{name: "8xy7 SUBN", op: 0x8127, setup: vf(0x55, 0, 0x10, 0x30),
+			want: func(r *Registers) { r.V[1] = 0x20; r.V[0xF] = 1 }},
+		{name: "8xy7 SUBN borrow", op: 0x8127, setup: vf(0x55, 0, 0x30, 0x10),
+			want: func(r *Registers) { r.V[1] = 0xE0; r.V[0xF] = 0 }},
+		{name: "8xy7 SUBN equal", op: 0x8127, setup: vf(0x55, 0, 0x10, 0x10),
+			want: func(r *Registers) { r.V[1] = 0x00; r.V[0xF] = 1 }},
+		{name: "8xy7 SUBN into VF", op: 0x8F17, setup: vf(0x10, 0, 0x30),
+			want: func(r *Registers) { r.V[0xF] = 1 }},
+
+		{name: "8xy6 SHR", op: 0x8126, setup: vf(0x55, 0, 0x05, 0xF0),
+			want: func(r *Registers) { r.V[1] = 0x02; r.V[0xF] = 1 }},
+		{name: "8xy6 SHR no carry", op: 0x8126, setup: vf(0x55, 0, 0x04),
+			want: func(r *Registers) { r.V[1] = 0x02; r.V[0xF] = 0 }},
+		{name: "8xy6 SHR shift quirk", op: 0x8126, quirks: Quirks{ShiftUsesVy: true}, setup: vf(0x55, 0, 0x05, 0xF0),
+			want: func(r *Registers) { r.V[1] = 0x78; r.V[0xF] = 0 }},
+		{name: "8xy6 SHR VF", op: 0x8F06, setup: vf(0x03),
+			want: func(r *Registers) { r.V[0xF] = 1 }},
+		{name: "8xyE SHL", op: 0x812E, setup: vf(0x55, 0, 0x81, 0x01),
+			want: func(r *Registers) { r.V[1] = 0x02; r.V[0xF] = 1 }},
+		{name: "8xyE SHL no carry", op: 0x812E, setup: vf(0x55, 0, 0x41),
+			want: func(r *Registers) { r.V[1] = 0x82; r.V[0xF] = 0 }},
+		{name: "8xyE SHL shift quirk", op: 0x812E, quirks: Quirks{ShiftUsesVy: true}, setup: vf(0x55, 0, 0x81, 0x01),
+			want: func(r *Registers) { r.V[1] = 0x02; r.V[0xF] = 0 }},
+		{name: "8xyE SHL VF", op: 0x8F0E, setup: vf(0x40),
+			want: func(r *Registers) { r.V[0xF] = 0 }},
+	})
+}
+
+// wantMemory checks memory from addr on
+func wantMemory(addr uint16, bytes ...byte) func(t *testing.T, ch *Chip8) {
+	return func(t *testing.T, ch *Chip8) {
+		t.Helper()
+		for i, b := range bytes {
+			if got := ch.Memory[int(addr)+i]; got != b {
+				t.Errorf("memory[%#x] = %#x, want %#x", int(addr)+i, got, b)
+			}
+		}
+	}
+}
+
+func TestMemoryOpcodes(t *testing.T) {
+	bcd := func(v byte) func(ch *Chip8) {
+		return func(ch *Chip8) { ch.I = 0x300; ch.V[4] = v }
+	}
+	runOpTests(t, []opTest{
+		{name: "Fx33 LD B 0", op: 0xF433, setup: bcd(0), check: wantMemory(0x300, 0, 0, 0)},
+		{name: "Fx33 LD B 7", op: 0xF433, setup: bcd(7), check: wantMemory(0x300, 0, 0, 7)},
+		{name: "Fx33 LD B 42", op: 0xF433, setup: bcd(42), check: wantMemory(0x300, 0, 4, 2)},
+		{name: "Fx33 LD B 100", op: 0xF433, setup: bcd(100), check: wantMemory(0x300, 1, 0, 0)},
+		{name: "Fx33 LD B 255", op: 0xF433, setup: bcd(255), check: wantMemory(0x300, 2, 5, 5)},
+
+		{name: "Fx55 LD [I]", op: 0xF255,
+			setup: func(ch *Chip8) { ch.I = 0x300; copy(ch.V[:], []byte{1, 2, 3, 4}) },
+			check: wantMemory(0x300, 1, 2, 3, 0)},
+		{name: "Fx55 LD [I] loadstore quirk", op: 0xF255, quirks: Quirks{LoadStoreIncrementsI: true},
+			setup: func(ch *Chip8) { ch.I = 0x300; copy(ch.V[:], []byte{1, 2, 3, 4}) },
+			want:  func(r *Registers) { r.I = 0x303 },
+			check: wantMemory(0x300, 1, 2, 3, 0)},
+		{name: "Fx65 LD Vx", op: 0xF265,
+			setup: func(ch *Chip8) { ch.I = 0x300; copy(ch.Memory[0x300:], []byte{1, 2, 3, 4}) },
+			want:  func(r *Registers) { r.V[0], r.V[1], r.V[2] = 1, 2, 3 }},
+		{name: "Fx65 LD Vx loadstore quirk", op: 0xF265, quirks: Quirks{LoadStoreIncrementsI: true},
+			setup: func(ch *Chip8) { ch.I = 0x300; copy(ch.Memory[0x300:], []byte{1, 2, 3, 4}) },
+			want:  func(r *Registers) { r.V[0], r.V[1], r.V[2] = 1, 2, 3; r.I = 0x303 }},
+		{name: "Fx65 LD VF", op: 0xFF65,
+			setup: func(ch *Chip8) { ch.I = 0x300; ch.Memory[0x30F] = 0x99 },
+			want:  func(r *Registers) { r.V[0xF] = 0x99 }},
+		{name: "Fx75 LD R, Vx", op: 0xF275, setup: regs(1, 2, 3, 4),
+			check: func(t *testing.T, ch *Chip8) {
+				if got := ch.RPLFlags(); got != [16]byte{1, 2, 3} {
+					t.Errorf("got flags %v", got)
+				}
+			}},
+		{name: "Fx85 LD Vx, R", op: 0xF285,
+			setup: func(ch *Chip8) { ch.rplFlags = [16]byte{1, 2, 3, 4} },
+			want:  func(r *Registers) { r.V[0], r.V[1], r.V[2] = 1, 2, 3 }},
+	})
+}
+
+// wantPixels checks that exactly the listed pixels are lit
+func wantPixels(pixels ...[2]int) func(t *testing.T, ch *Chip8) {
+	return func(t *testing.T, ch *Chip8) {
+		t.Helper()
+		want := NewFramebuffer(64, 32, 1)
+		for _, p := range pixels {
+			want.Set(p[0], p[1], 1)
+		}
+		for x := 0; x < want.Width; x++ {
+			for y := 0; y < want.Height; y++ {
+				if ch.Screen.At(x, y) != want.At(x, y) {
+					t.Errorf("pixel (%d, %d) = %d, want %d", x, y, ch.Screen.At(x, y), want.At(x, y))
+				}
+			}
+		}
+		if !ch.DrawFlag() {
+			t.Error("DrawFlag not set")
+		}
+	}
+}
+
+func TestDisplayOpcodes(t *testing.T) {
+	// A 2 row sprite: a pixel at each end of the first row and one in the middle of the second
+	sprite := func(x, y byte) func(ch *Chip8) {
+		return func(ch *Chip8) {
+			ch.I = 0x300
+			copy(ch.Memory[0x300:], []byte{0x81, 0x10})
+			ch.V[1], ch.V[2] = x, y
+		}
+	}
+	runOpTests(t, []opTest{
+		{name: "00E0 CLS", op: 0x00E0,
+			setup: func(ch *Chip8) { ch.Screen.Set(3, 4, 1); ch.Screen.Set(63, 31, 1) },
+			check: wantPixels()},
+		{name: "Dxyn DRW", op: 0xD122, setup: sprite(10, 5),
+			want:  func(r *Registers) { r.V[0xF] = 0 },
+			check: wantPixels([2]int{10, 5}, [2]int{17, 5}, [2]int{13, 6})},
+		{name: "Dxyn DRW collision", op: 0xD122,
+			setup: func(ch *Chip8) { sprite(10, 5)(ch); ch.Screen.Set(10, 5, 1); ch.Screen.Set(0, 0, 1) },
+			want:  func(r *Registers) { r.V[0xF] = 1 },
+			check: wantPixels([2]int{0, 0}, [2]int{17, 5}, [2]int{13, 6})},
+		{name: "Dxyn DRW no collision clears VF", op: 0xD122,
+			setup: func(ch *Chip8) { sprite(10, 5)(ch); ch.V[0xF] = 1 },
+			want:  func(r *Registers) { r.V[0xF] = 0 },
+			check: wantPixels([2]int{10, 5}, [2]int{17, 5}, [2]int{13, 6})},
+		{name: "Dxyn DRW start wraps", op: 0xD122, setup: sprite(64+10, 32+5),
+			check: wantPixels([2]int{10, 5}, [2]int{17, 5}, [2]int{13, 6})},
+		{name: "Dxyn DRW edges wrap", op: 0xD122, setup: sprite(60, 31),
+			check: wantPixels([2]int{60, 31}, [2]int{3, 31}, [2]int{63, 0})},
+		{name: "Dxyn DRW edges clip quirk", op: 0xD122, quirks: Quirks{ClipSprites: true}, setup: sprite(60, 31),
+			check: wantPixels([2]int{60, 31})},
+		{name: "Dxyn DRW font", op: 0xD125,
+			setup: func(ch *Chip8) { ch.I = 0x050 + 5*1 }, // "1": 0x20 0x60 0x20 0x20 0x70
+			check: wantPixels([2]int{2, 0}, [2]int{1, 1}, [2]int{2, 1}, [2]int{2, 2}, [2]int{2, 3},
+				[2]int{1, 4}, [2]int{2, 4}, [2]int{3, 4})},
+	})
+}
+
+func TestKeyWaitOpcode(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0xF3, 0x0A}) // LD V3, K
+	for i := 0; i < 3; i++ {
+		if _, err := ch.EmulateCycle(); err != nil {
+			t.Fatal(err)
+		}
+		if ch.PC != 0x200 || !ch.WaitingForKey() {
+			t.Fatalf("cycle %d: PC = %#x, waiting = %v, want to keep waiting at 0x200", i, ch.PC, ch.WaitingForKey())
+		}
+	}
+
+	// The key is only taken once it's released
+	ch.KeyDown(0xB)
+	if _, err := ch.EmulateCycle(); err != nil {
+		t.Fatal(err)
+	}
+	if ch.PC != 0x200 {
+		t.Fatalf("PC = %#x after key down, want 0x200", ch.PC)
+	}
+	ch.KeyUp(0xB)
+	if _, err := ch.EmulateCycle(); err != nil {
+		t.Fatal(err)
+	}
+	if ch.PC != 0x202 || ch.V[3] != 0xB || ch.WaitingForKey() {
+		t.Errorf("PC = %#x, V3 = %#x, waiting = %v, want 0x202, 0xb, false", ch.PC, ch.V[3], ch.WaitingForKey())
+	}
+}
+
+func TestUnknownOpcodes(t *testing.T) {
+	for _, op := range []uint16{0x0000, 0x0123, 0x8128, 0x812F, 0x9121, 0xE19F, 0xF1FF, 0xF102} {
+		ch := NewChip8()
+		ch.LoadRomBytes([]byte{byte(op >> 8), byte(op)})
+		if ok, err := ch.EmulateCycle(); !errors.Is(err, ErrUnknownOpcode) || ok {
+			t.Errorf("%04X: got ok = %v, err = %v, want %v", op, ok, err, ErrUnknownOpcode)
+		}
+	}
+}
+
+func TestUnknownOpcodePolicy(t *testing.T) {
+	// An unknown word between two ADDs
+	rom := []byte{0x70, 0x01, 0x00, 0x00, 0x70, 0x01}
+
+	ch := NewChip8()
+	ch.SetUnknownOpcodePolicy(UnknownOpcodeSkip)
+	ch.LoadRomBytes(rom)
+	if err := ch.RunFor(3); err != nil {
+		t.Fatalf("skip: got %v", err)
+	}
+	if ch.V[0] != 2 || ch.PC != 0x206 {
+		t.Errorf("skip: got V0 = %d, PC = %#03x, want 2, 0x206", ch.V[0], ch.PC)
+	}
+
+	ch = NewChip8()
+	ch.SetUnknownOpcodePolicy(UnknownOpcodeHalt)
+	ch.LoadRomBytes(rom)
+	if err := ch.RunFor(3); err != nil {
+		t.Fatalf("halt: got %v", err)
+	}
+	var f *Fault
+	if !errors.As(ch.HaltReason(), &f) || f.Err != ErrUnknownOpcode || f.PC != 0x202 || f.Opcode != 0x0000 {
+		t.Fatalf("halt: got reason %v, want an unknown opcode fault at 0x202", ch.HaltReason())
+	}
+	if !ch.Paused() || ch.V[0] != 1 || ch.PC != 0x204 {
+		t.Errorf("halt: got paused = %v, V0 = %d, PC = %#03x, want true, 1, 0x204", ch.Paused(), ch.V[0], ch.PC)
+	}
+	ch.Resume()
+	if ch.HaltReason() != nil {
+		t.Errorf("halt: Resume didn't clear the reason")
+	}
+	if err := ch.RunFor(1); err != nil || ch.V[0] != 2 {
+		t.Errorf("halt: after Resume got err = %v, V0 = %d, want nil, 2", err, ch.V[0])
+	}
+
+	for _, name := range UnknownOpcodePolicyNames() {
+		if p, err := ParseUnknownOpcodePolicy(name); err != nil || p.String() != name {
+			t.Errorf("ParseUnknownOpcodePolicy(%q) = %v, %v", name, p, err)
+		}
+	}
+	if _, err := ParseUnknownOpcodePolicy("ignore"); err == nil {
+		t.Errorf("ParseUnknownOpcodePolicy(\"ignore\") succeeded")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	// LD F, V0 then LD V1, [I]: reads the first byte of the "1" sprite
+	rom := []byte{0x60, 0x01, 0xF0, 0x29, 0xF0, 0x65}
+	for _, name := range ProfileNames() {
+		p, err := LookupProfile(name)
+		if err != nil {
+			t.Fatalf("LookupProfile(%q): %v", name, err)
+		}
+		ch := NewChip8()
+		ch.SetProfile(p)
+		ch.LoadRomBytes(rom)
+		if err := ch.RunFor(3); err != nil {
+			t.Fatalf("%v: %v", name, err)
+		}
+		if want := p.FontAddress + 5; ch.I != want && ch.I != want+1 {
+			t.Errorf("%v: got I = %#03x, want the font at %#03x", name, ch.I, p.FontAddress)
+		}
+		if ch.V[0] != fontSet[5] {
+			t.Errorf("%v: got V0 = %#02x, want %#02x", name, ch.V[0], fontSet[5])
+		}
+		if ch.Quirks() != p.Quirks || ch.InstructionsPerFrame() != p.InstructionsPerFrame {
+			t.Errorf("%v: profile quirks and speed weren't applied", name)
+		}
+	}
+
+	// Moving the font clears the old copy
+	ch := NewChip8()
+	vip, _ := LookupProfile("vip")
+	ch.SetProfile(vip)
+	if ch.Memory[0x050+5] != 0 || ch.Memory[5] != fontSet[5] {
+		t.Errorf("font wasn't moved to 0x000")
+	}
+
+	// A smaller machine faults past the end of its memory
+	small := DefaultProfile()
+	small.MemorySize = 0x400
+	ch = NewChip8()
+	ch.SetProfile(small)
+	ch.LoadRomBytes([]byte{0xA3, 0xFF, 0xF1, 0x65})
+	if err := ch.RunFor(2); !errors.Is(err, ErrMemoryOutOfBounds) {
+		t.Errorf("small memory: got %v, want ErrMemoryOutOfBounds", err)
+	}
+
+	if _, err := LookupProfile("eti"); err == nil {
+		t.Errorf("LookupProfile accepted an unknown name")
+	}
+}
+
+// flagStore is a FlagStore in memory that counts saves
+type flagStore struct {
+	flags map[string][16]byte
+	saves int
+}
+
+func (s *flagStore) LoadFlags(sha1 string) [16]byte { return s.flags[sha1] }
+
+func (s *flagStore) SaveFlags(sha1 string, flags [16]byte) {
+	s.flags[sha1] = flags
+	s.saves++
+}
+
+func TestFlagStore(t *testing.T) {
+	// LD V0, 7; LD R, V0; LD R, V0 (unchanged, not saved again); JP back
+	rom := []byte{0x60, 0x07, 0xF0, 0x75, 0xF0, 0x75, 0x12, 0x06}
+	store := &flagStore{flags: map[string][16]byte{}}
+
+	ch := NewChip8()
+	ch.LoadRomBytes(rom)
+	ch.SetFlagStore(store)
+	if err := ch.RunFor(3); err != nil {
+		t.Fatal(err)
+	}
+	sha := ch.RomInfo().SHA1
+	if store.saves != 1 || store.flags[sha] != [16]byte{7} {
+		t.Fatalf("got %d saves of %v, want 1 of the flags set by Fx75", store.saves, store.flags[sha])
+	}
+	ch.Reset()
+	if ch.RPLFlags() != [16]byte{7} {
+		t.Errorf("Reset cleared the flags")
+	}
+
+	// Another machine running the same ROM picks the flags up, a different ROM doesn't
+	ch = NewChip8()
+	ch.SetFlagStore(store)
+	ch.LoadRomBytes(rom)
+	if ch.RPLFlags() != [16]byte{7} {
+		t.Errorf("got flags %v, want them loaded from the store", ch.RPLFlags())
+	}
+	ch.LoadRomBytes([]byte{0x12, 0x00})
+	if ch.RPLFlags() != [16]byte{} {
+		t.Errorf("got flags %v for another ROM", ch.RPLFlags())
+	}
+}
+
+func TestLoadRomAt(t *testing.T) {
+	// ADD V0, 1 then JP back to the start
+	rom := []byte{0x70, 0x01, 0x16, 0x00}
+
+	ch := NewChip8()
+	eti, _ := LookupProfile("eti660")
+	ch.SetProfile(eti)
+	ch.LoadRomBytes(rom)
+	if ch.PC != 0x600 || ch.Memory[0x600] != 0x70 {
+		t.Fatalf("eti660: got PC = %#03x, want the ROM at 0x600", ch.PC)
+	}
+	if err := ch.RunFor(4); err != nil || ch.V[0] != 2 {
+		t.Fatalf("eti660: got err = %v, V0 = %d, want nil, 2", err, ch.V[0])
+	}
+	ch.Reset()
+	if ch.PC != 0x600 || ch.Memory[0x602] != 0x16 || ch.V[0] != 0 {
+		t.Errorf("Reset didn't restart at 0x600")
+	}
+
+	ch = NewChip8()
+	if _, err := ch.LoadRomAt(rom, 0x600); err != nil || ch.PC != 0x600 {
+		t.Errorf("LoadRomAt(0x600): got err = %v, PC = %#03x", err, ch.PC)
+	}
+	if _, err := ch.LoadRomAt(rom, 0x100); err == nil {
+		t.Errorf("LoadRomAt accepted an address inside the interpreter area")
+	}
+	if _, err := ch.LoadRomAt(make([]byte, 0x200), 0xF00); err == nil {
+		t.Errorf("LoadRomAt accepted a ROM running past the end of memory")
+	}
+}
+
+func TestLoadRomErrors(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x12, 0x00})
+	if got := ch.MaxRomSize(); got != 3584 {
+		t.Fatalf("MaxRomSize() = %d, want 3584", got)
+	}
+
+	_, err := ch.LoadRomBytes(make([]byte, 3585))
+	var romErr *RomError
+	if !errors.Is(err, ErrRomTooLarge) || !errors.As(err, &romErr) || romErr.Size != 3585 || romErr.Max != 3584 {
+		t.Errorf("3585 byte ROM: got err = %v, want ErrRomTooLarge with Max 3584", err)
+	}
+	if _, err := ch.LoadRomBytes(nil); !errors.Is(err, ErrRomEmpty) {
+		t.Errorf("empty ROM: got err = %v, want ErrRomEmpty", err)
+	}
+	if ch.Memory[0x200] != 0x12 || ch.RomInfo().Size != 2 {
+		t.Errorf("a refused ROM replaced the loaded one")
+	}
+
+	info, err := ch.LoadRomBytes([]byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RomInfo{Size: 3, Addr: 0x200, SHA1: "a9993e364706816aba3e25717850c26c9cd0d89d", CRC32: 0x352441c2}
+	if info != want || ch.RomInfo() != want {
+		t.Errorf("LoadRomBytes(\"abc\") = %+v, want %+v", info, want)
+	}
+	if _, err := ch.LoadRomBytes(make([]byte, 3584)); err != nil {
+		t.Errorf("3584 byte ROM: %v", err)
+	}
+}
+
+func TestHires(t *testing.T) {
+	// The 1260 entry jump, then at 0x2C0: LD V0, 40; LD F, V1; DRW V0, V0, 5; 0230 (hires CLS)
+	rom := make([]byte, 0xC8)
+	copy(rom, []byte{0x12, 0x60})
+	copy(rom[0xC0:], []byte{0x60, 0x28, 0xF1, 0x29, 0xD0, 0x05, 0x02, 0x30})
+
+	ch := NewChip8()
+	ch.LoadRomBytes(rom)
+	if !ch.Hires() || ch.Screen.Width != 64 || ch.Screen.Height != 64 {
+		t.Fatalf("1260 entry didn't select hires, got a %dx%d screen", ch.Screen.Width, ch.Screen.Height)
+	}
+	if err := ch.RunFor(4); err != nil {
+		t.Fatal(err)
+	}
+	if ch.PC != 0x2C6 || ch.Screen.At(40, 40) == 0 {
+		t.Errorf("got PC = %#03x, pixel (40, 40) = %d, want 0x2c6 and a sprite below row 32", ch.PC, ch.Screen.At(40, 40))
+	}
+	if err := ch.RunFor(1); err != nil || ch.Screen.At(40, 40) != 0 {
+		t.Errorf("0230 didn't clear the screen: %v", err)
+	}
+
+	// Other ROMs keep the 64x32 screen, where 0230 is unknown
+	ch.LoadRomBytes([]byte{0x02, 0x30})
+	if ch.Hires() || ch.Screen.Height != 32 {
+		t.Fatalf("hires mode stuck after loading a normal ROM")
+	}
+	if err := ch.RunFor(1); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("0230 outside hires: got %v, want ErrUnknownOpcode", err)
+	}
+
+	hires, _ := LookupProfile("hires")
+	ch.SetProfile(hires)
+	ch.LoadRomBytes([]byte{0x00, 0xE0})
+	if !ch.Hires() {
+		t.Errorf("hires profile didn't select hires")
+	}
+}
+
+// samplePlayer records the digitized sound it's asked to play
+type samplePlayer struct {
+	samples []byte
+	rate    int
+	loop    bool
+}
+
+func (p *samplePlayer) Beep(on bool) {}
+
+func (p *samplePlayer) PlaySamples(samples []byte, rate int, loop bool) {
+	p.samples, p.rate, p.loop = append([]byte(nil), samples...), rate, loop
+}
+
+// sampleCounter counts the digitized sound it's asked to play, without keeping it
+type sampleCounter struct{ n int }
+
+func (p *sampleCounter) Beep(on bool) {}
+
+func (p *sampleCounter) PlaySamples(samples []byte, rate int, loop bool) {
+	p.n = len(samples)
+}
+
+func TestDIGISNDLength(t *testing.T) {
+	rom := make([]byte, 0xE08)
+	copy(rom, []byte{
+		0x01, 0x00, 0x10, 0x00, // LDHI 0x001000
+		0x06, 0x01, // DIGISND
+		0x12, 0x04, // JP 0x204
+	})
+	// At 0x1000: a header claiming 1M samples, with only 2 of them before the end of the ROM
+	copy(rom[0xE00:], []byte{0x1F, 0x40, 0x10, 0x00, 0x00, 0x00, 0x80, 0x81})
+
+	ch := NewChip8()
+	mega, _ := LookupProfile("megachip")
+	ch.SetProfile(mega)
+	audio := &sampleCounter{}
+	ch.SetAudioSink(audio)
+	if _, err := ch.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	if err := ch.RunFor(2); err != nil || audio.n != 2 {
+		t.Fatalf("got err = %v, %d samples, want 2", err, audio.n)
+	}
+	// Playing it again reuses the buffer
+	if allocs := testing.AllocsPerRun(100, func() { ch.RunFor(2) }); allocs != 0 {
+		t.Errorf("DIGISND allocated %v times a run", allocs)
+	}
+
+	// A sound that would run past the 16MB address space faults
+	rom[0xE02], rom[0xE03], rom[0xE04] = 0xFF, 0xFF, 0xFF
+	ch.LoadRomBytes(rom)
+	if err := ch.RunFor(2); !errors.Is(err, ErrMemoryOutOfBounds) {
+		t.Errorf("16M samples: got err = %v, want ErrMemoryOutOfBounds", err)
+	}
+}
+
+func TestMegaChip(t *testing.T) {
+	rom := make([]byte, 0xE10)
+	copy(rom, []byte{
+		0x00, 0x11, // MEGAon
+		0x01, 0x00, 0x10, 0x00, // LDHI 0x001000
+		0x02, 0x01, // LDPAL 1
+		0x01, 0x00, 0x10, 0x04, // LDHI 0x001004
+		0x03, 0x02, // SPRW 2
+		0x04, 0x01, // SPRH 1
+		0x60, 0x64, // LD V0, 100
+		0xD0, 0x05, // DRW V0, V0
+		0x00, 0xE0, // CLS, showing the sprite
+		0xD0, 0x05, // DRW V0, V0
+		0x09, 0x01, // CCOL 1
+		0xD0, 0x05, // DRW V0, V0, colliding with the last one
+		0x01, 0x00, 0xFF, 0xFF, // LDHI 0x00FFFF
+		0x61, 0x01, // LD V1, 1
+		0xF1, 0x1E, // ADD I, V1
+		0x01, 0x00, 0x10, 0x06, // LDHI 0x001006
+		0x06, 0x00, // DIGISND, looped
+		0x00, 0x10, // MEGAoff
+	})
+	// At 0x1000: one palette entry, a 2x1 sprite and a sound of 2 samples at 8000Hz
+	copy(rom[0xE00:], []byte{0xFF, 0x11, 0x22, 0x33, 0x01, 0x00, 0x1F, 0x40, 0x00, 0x00, 0x02, 0x00, 0x80, 0xFF})
+
+	ch := NewChip8()
+	mega, _ := LookupProfile("megachip")
+	ch.SetProfile(mega)
+	audio := &samplePlayer{}
+	ch.SetAudioSink(audio)
+	ch.LoadRomBytes(rom)
+
+	if err := ch.RunFor(1); err != nil || !ch.MegaChip() || ch.Screen.Width != MegaWidth || ch.Screen.Height != MegaHeight {
+		t.Fatalf("MEGAon: got err = %v, a %dx%d screen", err, ch.Screen.Width, ch.Screen.Height)
+	}
+	if err := ch.RunFor(8); err != nil {
+		t.Fatal(err)
+	}
+	if ch.Screen.At(100, 100) != 1 || ch.Screen.At(101, 100) != 0 || ch.Screen.Palette[1] != 0xFF112233 {
+		t.Errorf("CLS didn't show the sprite, got pixels %d %d and color %#08x",
+			ch.Screen.At(100, 100), ch.Screen.At(101, 100), ch.Screen.Palette[1])
+	}
+	if err := ch.RunFor(3); err != nil || ch.V[0xF] != 1 {
+		t.Errorf("collision: got err = %v, VF = %d, want nil, 1", err, ch.V[0xF])
+	}
+	if err := ch.RunFor(3); err != nil || ch.mega.ihi != 1 || ch.I != 0 {
+		t.Errorf("ADD I: got err = %v, I = %#02x%04x, want 0x010000", err, ch.mega.ihi, ch.I)
+	}
+	if err := ch.RunFor(2); err != nil || audio.rate != 8000 || !audio.loop || string(audio.samples) != "\x80\xff" {
+		t.Errorf("DIGISND: got err = %v, %v at %dHz", err, audio.samples, audio.rate)
+	}
+	if err := ch.RunFor(1); err != nil || ch.MegaChip() || ch.Screen.Width != 64 {
+		t.Errorf("MEGAoff: got err = %v, a %dx%d screen", err, ch.Screen.Width, ch.Screen.Height)
+	}
+
+	// Other machines don't have MegaChip instructions
+	ch = NewChip8()
+	ch.LoadRomBytes([]byte{0x00, 0x11})
+	if err := ch.RunFor(1); !errors.Is(err, ErrUnknownOpcode) {
+		t.Errorf("MEGAon without the profile: got %v, want ErrUnknownOpcode", err)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		rom    []byte
+		cycles int // Instructions that run before the fault
+		setup  func(ch *Chip8)
+		want   EmuError
+	}{
+		{name: "unknown opcode", rom: []byte{0x00, 0x00},
+			want: EmuError{Category: CategoryDecode, Err: ErrUnknownOpcode, PC: 0x200, Opcode: 0x0000}},
+		{name: "RET with an empty stack", rom: []byte{0x00, 0xEE},
+			want: EmuError{Category: CategoryStack, Err: ErrStackUnderflow, PC: 0x200, Opcode: 0x00EE}},
+		{name: "CALL with a full stack", rom: []byte{0x22, 0x00}, cycles: 16,
+			want: EmuError{Category: CategoryStack, Err: ErrStackOverflow, PC: 0x200, Opcode: 0x2200}},
+		{name: "LD [I] past the end of memory", rom: []byte{0xF2, 0x55}, setup: func(ch *Chip8) { ch.I = 0xFFE },
+			want: EmuError{Category: CategoryMemory, Err: ErrMemoryOutOfBounds, PC: 0x200, Opcode: 0xF255}},
+		{name: "LD Vx, [I] past the end of memory", rom: []byte{0xF2, 0x65}, setup: func(ch *Chip8) { ch.I = 0xFFFF },
+			want: EmuError{Category: CategoryMemory, Err: ErrMemoryOutOfBounds, PC: 0x200, Opcode: 0xF265}},
+		{name: "LD B past the end of memory", rom: []byte{0xF0, 0x33}, setup: func(ch *Chip8) { ch.I = 0xFFE },
+			want: EmuError{Category: CategoryMemory, Err: ErrMemoryOutOfBounds, PC: 0x200, Opcode: 0xF033}},
+		{name: "DRW past the end of memory", rom: []byte{0xD0, 0x05}, setup: func(ch *Chip8) { ch.I = 0xFFC },
+			want: EmuError{Category: CategoryMemory, Err: ErrMemoryOutOfBounds, PC: 0x200, Opcode: 0xD005}},
+		{name: "JP into the interpreter area", rom: []byte{0x10, 0x50}, cycles: 1,
+			want: EmuError{Category: CategoryMemory, Err: ErrPCOutOfBounds, PC: 0x050}},
+		{name: "JP past the end of memory", rom: []byte{0x1F, 0xFF}, cycles: 1,
+			want: EmuError{Category: CategoryMemory, Err: ErrPCOutOfBounds, PC: 0xFFF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := NewChip8()
+			ch.LoadRomBytes(tt.rom)
+			if tt.setup != nil {
+				tt.setup(ch)
+			}
+			if err := ch.RunFor(tt.cycles); err != nil {
+				t.Fatalf("before the fault: %v", err)
+			}
+			regs, mem := ch.Registers(), ch.Memory
+			_, err := ch.EmulateCycle()
+			var f *EmuError
+			if !errors.As(err, &f) {
+				t.Fatalf("got %v, want an *EmuError", err)
+			}
+			if f.Category != tt.want.Category || f.Err != tt.want.Err || f.PC != tt.want.PC || f.Opcode != tt.want.Opcode {
+				t.Errorf("got %+v, want %+v", *f, tt.want)
+			}
+			if !errors.Is(err, tt.want.Err) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.want.Err)
+			}
+			got := ch.Registers()
+			got.PC = regs.PC
+			if got != regs || ch.Memory != mem {
+				t.Errorf("the faulting instruction changed the machine")
+			}
+		})
+	}
+}
+
+type failingDisplay struct{ err error }
+
+func (d failingDisplay) Draw(screen *Framebuffer) error { return d.err }
+
+// States up to version 4 kept the stack from Stack[1]
+func TestOldStateStack(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x22, 0x04, 0x00, 0x00, 0x22, 0x08, 0x00, 0x00, 0x00, 0xEE})
+	if err := ch.RunFor(2); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := ch.SaveState()
+	old := append([]byte{}, state...)
+	binary.BigEndian.PutUint16(old[4:], 4)
+	stack := 4 + 2 + 4096 + 16 + 2 + 2 + 2 // Magic, version, memory, V, PC, I, SP
+	copy(old[stack+2:stack+32], state[stack:stack+30])
+	binary.BigEndian.PutUint16(old[stack:], 0)
+
+	for _, s := range [][]byte{state, old} {
+		ch := NewChip8()
+		if err := ch.LoadState(s); err != nil {
+			t.Fatal(err)
+		}
+		if regs := ch.Registers(); regs.SP != 2 || regs.Stack[0] != 0x202 || regs.Stack[1] != 0x206 {
+			t.Errorf("version %d: got SP=%d stack %v", binary.BigEndian.Uint16(s[4:]), regs.SP, regs.Stack)
+		}
+		if err := ch.RunFor(1); err != nil || ch.Registers().PC != 0x206 {
+			t.Errorf("RET went to 0x%03X (%v), want 0x206", ch.Registers().PC, err)
+		}
+	}
+}
+
+// Version 6 keeps the hires flag, the position in the frame and the RPL flags
+func TestStateMode(t *testing.T) {
+	// The 1260 entry jump, then at 0x2C0: LD V0, 40; LD R, V0; ADD V0, 1; JP 0x2C4
+	rom := make([]byte, 0xC8)
+	copy(rom, []byte{0x12, 0x60})
+	copy(rom[0xC0:], []byte{0x60, 0x28, 0xF0, 0x75, 0x70, 0x01, 0x12, 0xC4})
+	ch := NewChip8()
+	ch.SetInstructionsPerFrame(10)
+	ch.LoadRomBytes(rom)
+	if err := ch.RunFor(4); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := ch.SaveState()
+
+	other := NewChip8()
+	other.SetInstructionsPerFrame(10)
+	if err := other.LoadState(state); err != nil {
+		t.Fatal(err)
+	}
+	if !other.Hires() || other.Screen.Height != 64 || other.RPLFlags()[0] != 40 {
+		t.Errorf("got hires = %v on a %d line screen, flag 0 = %d, want a hires 64 line screen and 40",
+			other.Hires(), other.Screen.Height, other.RPLFlags()[0])
+	}
+	// 4 instructions into the frame, both end it 6 instructions later
+	frames := ch.Frames()
+	ch.RunFor(6)
+	other.RunFor(6)
+	if ch.Frames() != frames+1 || other.Frames() != 1 {
+		t.Errorf("ended %d and %d frames, want 1", ch.Frames()-frames, other.Frames())
+	}
+	a, _ := ch.SaveState()
+	b, _ := other.SaveState()
+	if !bytes.Equal(a, b) {
+		t.Errorf("the machines differ after resuming mid-frame")
+	}
+
+	// Version 5 didn't record hires mode, the 64x64 screen implies it
+	old := append([]byte{}, state[:len(state)-21]...)
+	binary.BigEndian.PutUint16(old[4:], 5)
+	other = NewChip8()
+	if err := other.LoadState(old); err != nil {
+		t.Fatal(err)
+	}
+	if !other.Hires() {
+		t.Errorf("a version 5 state with a 64x64 screen didn't select hires")
+	}
+}
+
+// Corrupt SP and PC values are refused on load, not on the first instruction
+func TestStateBounds(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x12, 0x00})
+	state, _ := ch.SaveState()
+	pc := 4 + 2 + 4096 + 16 // Magic, version, memory, V
+	for _, tt := range []struct {
+		name   string
+		offset int
+		value  uint16
+	}{
+		{"PC", pc, 0xFFF},
+		{"PC", pc, 0xF000},
+		{"SP", pc + 4, 17},
+	} {
+		bad := append([]byte{}, state...)
+		binary.BigEndian.PutUint16(bad[tt.offset:], tt.value)
+		if err := ch.LoadState(bad); err == nil {
+			t.Errorf("%s = %#x: loaded", tt.name, tt.value)
+		}
+	}
+	if regs := ch.Registers(); regs.PC != 0x200 || regs.SP != 0 {
+		t.Errorf("a refused state changed the machine: PC = %#x, SP = %d", regs.PC, regs.SP)
+	}
+}
+
+func TestDisplayError(t *testing.T) {
+	broken := errors.New("broken")
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x00, 0xE0}) // CLS
+	ch.SetDisplay(failingDisplay{broken})
+	err := ch.Step()
+	var e *EmuError
+	if !errors.As(err, &e) || e.Category != CategoryIO || e.PC != 0x200 || e.Opcode != 0x00E0 {
+		t.Fatalf("got %v, want an io *EmuError for CLS at 0x200", err)
+	}
+	if !errors.Is(err, broken) {
+		t.Errorf("errors.Is(%v, broken) = false", err)
+	}
+}
+
+// Self-modifying code must not run a stale decoded instruction
+func TestDecodeCacheSelfModifying(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{
+		0x60, 0x70, // 200: LD V0, 0x70
+		0x61, 0x05, // 202: LD V1, 5
+		0x62, 0x00, // 204: LD V2, 0
+		0xA2, 0x04, // 206: LD I, 0x204
+		0xF1, 0x55, // 208: LD [I], V1 (rewrites 204 as 7005: ADD V0, 5)
+		0x12, 0x04, // 20A: JP 0x204
+	})
+	if err := ch.RunFor(7); err != nil {
+		t.Fatal(err)
+	}
+	if ch.V[0] != 0x75 {
+		t.Errorf("V0 = %#x after the rewritten instruction, want 0x75", ch.V[0])
+	}
+}
+
+func TestRun(t *testing.T) {
+	// CLS, then JP to itself
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x00, 0xE0, 0x12, 0x02})
+	frames := make(chan Frame, 1)
+	ticks := 0
+	ch.SetRunCallbacks(RunCallbacks{
+		Tick: func() { ticks++ },
+		Frame: func(f Frame) {
+			select {
+			case frames <- f:
+			default:
+			}
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- ch.Run(ctx, 1000) }()
+	select {
+	case f := <-frames:
+		if f.Screen == nil || f.Dirty == 0 {
+			t.Errorf("got frame %+v, want the cleared screen", f)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no frame from Run")
+	}
+	ch.Pause()
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't stop while paused")
+	}
+	if ticks == 0 {
+		t.Errorf("Tick was never called")
+	}
+
+	// An unknown opcode halts the machine and stops Run
+	ch = NewChip8()
+	ch.SetUnknownOpcodePolicy(UnknownOpcodeHalt)
+	ch.LoadRomBytes([]byte{0x00, 0x00})
+	err := ch.Run(context.Background(), 1000)
+	var f *Fault
+	if !errors.As(err, &f) || f.Err != ErrUnknownOpcode {
+		t.Errorf("halt: got %v, want an unknown opcode fault", err)
+	}
+}
+
+type testLogger struct{ lines []string }
+
+func (l *testLogger) log(level, msg string, args []interface{}) {
+	l.lines = append(l.lines, strings.TrimSpace(fmt.Sprintln(append([]interface{}{level, msg}, args...)...)))
+}
+func (l *testLogger) Debug(msg string, args ...interface{}) { l.log("DEBUG", msg, args) }
+func (l *testLogger) Info(msg string, args ...interface{})  { l.log("INFO", msg, args) }
+func (l *testLogger) Warn(msg string, args ...interface{})  { l.log("WARN", msg, args) }
+
+func TestLogger(t *testing.T) {
+	l := &testLogger{}
+	ch := NewChip8()
+	ch.SetLogger(l, LogCPU)
+	ch.LoadRomBytes([]byte{0xF3, 0x0A, 0x63, 0x05, 0xF3, 0x18}) // LD V3, K; LD V3, 5; LD ST, V3
+	ch.RunFor(5)
+	ch.KeyDown(0xB)
+	ch.KeyUp(0xB)
+	ch.RunFor(3)
+	want := []string{
+		"INFO ROM loaded component cpu size 6 addr 0x200 sha1 " + ch.RomInfo().SHA1,
+		"DEBUG waiting for key component cpu pc 0x200", // Only once, not on every cycle spent waiting
+		"DEBUG key received component cpu key 11",      // The timer isn't enabled
+	}
+	if strings.Join(l.lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%v\nwant\n%v", strings.Join(l.lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	for _, s := range []string{"all", "cpu, timer", "ui,audio,ui"} {
+		c, err := ParseLogComponents(s)
+		if err != nil || c == 0 {
+			t.Errorf("ParseLogComponents(%q) = %v, %v", s, c, err)
+		}
+	}
+	if _, err := ParseLogComponents("gpu"); err == nil {
+		t.Errorf("ParseLogComponents(\"gpu\") succeeded")
+	}
+}
+
+func TestTraceRing(t *testing.T) {
+	var trace strings.Builder
+	ch := NewChip8()
+	ch.SetTraceWriter(&trace)
+	ch.SetTraceRing(2)
+	ch.LoadRomBytes([]byte{0x60, 0x05, 0x70, 0x01, 0xA3, 0x00, 0x00, 0x00}) // LD V0, 5; ADD V0, 1; LD I, 0x300; 0000
+	if err := ch.RunFor(3); err != nil || trace.Len() != 0 {
+		t.Fatalf("before the fault: %v, trace %q", err, trace.String())
+	}
+	err := ch.RunFor(1)
+	want := "--- last 2 instructions ---\n" +
+		"0x204  A300  LD I, 0x300          I=300\n" +
+		"0x206  0000  SYS 0x000  <- " + err.Error() + "\n"
+	if trace.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", trace.String(), want)
+	}
+}
+
+func TestFinished(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  []byte
+		want bool
+		pc   uint16
+	}{
+		{"JP to itself", []byte{0x00, 0xE0, 0x12, 0x02}, true, 0x202},
+		{"pure loop", []byte{0x60, 0x05, 0xA3, 0x00, 0x12, 0x00}, true, 0x204},
+		{"counting", []byte{0x70, 0x01, 0x12, 0x00}, false, 0},
+		{"waiting for DT", []byte{0xF0, 0x07, 0x12, 0x00}, false, 0},
+		{"waiting for a key", []byte{0xE0, 0x9E, 0x12, 0x00}, false, 0},
+		{"drawing", []byte{0xD0, 0x01, 0x12, 0x00}, false, 0},
+	}
+	for _, tt := range tests {
+		ch := NewChip8()
+		ch.LoadRomBytes(tt.rom)
+		if err := ch.RunFor(100); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		pc, finished := ch.Finished()
+		if finished != tt.want || (finished && pc != tt.pc) {
+			t.Errorf("%s: got %v at %#03x, want %v at %#03x", tt.name, finished, pc, tt.want, tt.pc)
+		}
+		if finished {
+			ch.Reset()
+			if _, finished := ch.Finished(); finished {
+				t.Errorf("%s: still finished after Reset", tt.name)
+			}
+		}
+	}
+}
+
+func TestIdleFrames(t *testing.T) {
+	// CLS, then wait for a key without drawing anything else
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x00, 0xE0, 0xF0, 0x0A, 0x12, 0x00})
+	for i := 0; i < 10; i++ {
+		ch.RunFrame()
+	}
+	if n := ch.IdleFrames(); n < 5 {
+		t.Fatalf("waiting for a key: got %d idle frames, want at least 5", n)
+	}
+	ch.KeyDown(1)
+	if n := ch.IdleFrames(); n != 0 {
+		t.Errorf("after a key press: got %d idle frames, want 0", n)
+	}
+
+	// A timer running keeps the machine busy: LD V0, 0xFF; LD DT, V0; JP 0x204
+	ch = NewChip8()
+	ch.LoadRomBytes([]byte{0x60, 0xFF, 0xF0, 0x15, 0x12, 0x04})
+	for i := 0; i < 10; i++ {
+		ch.RunFrame()
+	}
+	if n := ch.IdleFrames(); n != 0 {
+		t.Errorf("with DT running: got %d idle frames, want 0", n)
+	}
+}
+
+// The timers count frames, not wall clock time: exactly one per frame however the machine is
+// driven, and not at all while it's paused
+func TestTimersFollowFrames(t *testing.T) {
+	// LD V0, 100; LD DT, V0; LD ST, V0; then DRW in a loop to end frames early under DisplayWait
+	rom := []byte{0x60, 0x64, 0xF0, 0x15, 0xF0, 0x18, 0xD0, 0x01, 0x12, 0x06}
+	for _, q := range []Quirks{{}, {DisplayWait: true}} {
+		ch := NewChip8()
+		ch.SetQuirks(q)
+		ch.LoadRomBytes(rom)
+		ch.RunFor(3)
+		start, dt, st := ch.Frames(), ch.DT, ch.ST
+
+		ch.RunFrame()
+		ch.RunFor(ch.InstructionsPerFrame() * 3)
+		ch.Pause()
+		ch.AdvanceFrame()
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		ch.Run(ctx, 1000) // Paused, so no frames run
+		cancel()
+
+		frames := ch.Frames() - start
+		regs := ch.Registers()
+		if uint64(dt-regs.DT) != frames || uint64(st-regs.ST) != frames {
+			t.Errorf("%+v: DT %d -> %d and ST %d -> %d over %d frames", q, dt, regs.DT, st, regs.ST, frames)
+		}
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0x70, 0x01, 0x12, 0x00}) // ADD V0, 1; JP 0x200
+	ch.Resume()                                     // Without Pause, does nothing
+	ch.Pause()
+	ch.Pause()
+	if !ch.IsPaused() {
+		t.Fatal("not paused after Pause")
+	}
+
+	done := make(chan error)
+	go func() { done <- ch.Step() }()
+	select {
+	case <-done:
+		t.Fatal("Step ran while paused")
+	case <-time.After(20 * time.Millisecond):
+	}
+	ch.Resume() // Once undoes any number of Pause calls
+	select {
+	case err := <-done:
+		if err != nil || ch.V[0] != 1 {
+			t.Errorf("after Resume got err = %v, V0 = %d, want nil, 1", err, ch.V[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Step still blocked after Resume")
+	}
+	ch.Resume()
+	if ch.IsPaused() {
+		t.Error("paused after Resume")
+	}
+}
+
+func TestRegisterAccess(t *testing.T) {
+	ch := NewChip8()
+	for _, tt := range []struct {
+		name  string
+		value int
+		ok    bool
+	}{
+		{"v3", 0xFF, true}, {"VF", 0x100, false}, {"I", 0xFFFF, true}, {"PC", 0x300, true},
+		{"PC", 0x1000, false}, {"SP", 16, true}, {"SP", 17, false}, {"dt", 60, true}, {"ST", -1, false},
+	} {
+		r, err := ParseRegister(tt.name)
+		if err != nil {
+			t.Fatalf("ParseRegister(%q): %v", tt.name, err)
+		}
+		if err := ch.SetRegister(r, tt.value); (err == nil) != tt.ok {
+			t.Errorf("SetRegister(%v, %#x) = %v, want ok = %v", r, tt.value, err, tt.ok)
+		}
+	}
+	regs := ch.Registers()
+	if regs.V[3] != 0xFF || regs.I != 0xFFFF || regs.PC != 0x300 || regs.SP != 16 || regs.DT != 60 {
+		t.Errorf("got %+v", regs)
+	}
+	if _, err := ParseRegister("V10"); err == nil {
+		t.Error("ParseRegister(\"V10\") succeeded")
+	}
+
+	if err := ch.WriteMemory(0xFFE, []byte{1, 2, 3}); err == nil || ch.Memory[0xFFE] != 0 {
+		t.Errorf("WriteMemory past the end: got %v, Memory[0xFFE] = %d", err, ch.Memory[0xFFE])
+	}
+	if err := ch.WriteMemory(0xFFD, []byte{1, 2, 3}); err != nil || ch.Memory[0xFFF] != 3 {
+		t.Errorf("WriteMemory up to the end: got %v, Memory[0xFFF] = %d", err, ch.Memory[0xFFF])
+	}
+}
+
+func TestPushKey(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{0xF3, 0x0A, 0x12, 0x02}) // LD V3, K; JP 0x202
+	ch.RunFrame()
+	ch.PushKey(0xC)
+	if !ch.PressedKeys()[0xC] {
+		t.Fatal("PushKey didn't press the key")
+	}
+	for i := 0; i <= pushKeyFrames; i++ { // Fx0A sees the release in the frame after
+		ch.RunFrame()
+	}
+	if ch.PressedKeys()[0xC] || ch.V[3] != 0xC {
+		t.Errorf("got pressed = %v, V3 = %#x, want false, 0xc", ch.PressedKeys()[0xC], ch.V[3])
+	}
+}
+
+func TestPreExecHooks(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{
+		0x60, 0x01, // 200: LD V0, 1
+		0x61, 0x02, // 202: LD V1, 2
+		0x62, 0x03, // 204: LD V2, 3
+		0x63, 0x04, // 206: LD V3, 4
+		0x64, 0x05, // 208: LD V4, 5
+	})
+	var seen []uint16
+	ch.AddPreExecHook(func(pc, opcode uint16) HookAction {
+		seen = append(seen, pc)
+		switch pc {
+		case 0x200:
+			return HookAction{Replace: true, Opcode: 0x60AA}
+		case 0x202:
+			return HookAction{Skip: true}
+		case 0x204:
+			return HookAction{Redirect: true, PC: 0x208}
+		}
+		return HookAction{}
+	})
+	remove := ch.AddPreExecHook(func(pc, opcode uint16) HookAction {
+		if pc == 0x200 {
+			t.Error("second hook called after the first decided")
+		}
+		return HookAction{}
+	})
+	if err := ch.RunFor(4); err != nil {
+		t.Fatal(err)
+	}
+	if ch.V[0] != 0xAA || ch.V[1] != 0 || ch.V[2] != 0 || ch.V[3] != 0 || ch.V[4] != 5 {
+		t.Errorf("V0-V4 = % x, want aa 00 00 00 05", ch.V[:5])
+	}
+	if want := []uint16{0x200, 0x202, 0x204, 0x208}; fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Errorf("hook saw %#x, want %#x", seen, want)
+	}
+
+	remove()
+	remove()
+	if len(ch.preExec) != 1 {
+		t.Errorf("%d hooks left after remove, want 1", len(ch.preExec))
+	}
+}
+
+func TestStepBack(t *testing.T) {
+	ch := NewChip8()
+	ch.LoadRomBytes([]byte{
+		0x60, 0x05, // 200: LD V0, 5
+		0xF0, 0x15, // 202: LD DT, V0
+		0xD0, 0x15, // 204: DRW V0, V1, 5
+		0x71, 0x01, // 206: ADD V1, 1
+		0x12, 0x04, // 208: JP 0x204
+	})
+	ch.SetInstructionsPerFrame(3)
+	ch.SetRewindBuffer(10)
+	if err := ch.StepBack(); err == nil {
+		t.Error("StepBack worked without a step history")
+	}
+	ch.SetStepHistory(20)
+
+	type snapshot struct {
+		state  []byte
+		frames uint64
+	}
+	var history []snapshot
+	for i := 0; i < 10; i++ {
+		state, _ := ch.SaveState()
+		history = append(history, snapshot{state, ch.Frames()})
+		if err := ch.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ch.StepBackAvailable() != 10 {
+		t.Fatalf("%d steps available, want 10", ch.StepBackAvailable())
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if err := ch.StepBack(); err != nil {
+			t.Fatal(err)
+		}
+		state, _ := ch.SaveState()
+		if !bytes.Equal(state, history[i].state) || ch.Frames() != history[i].frames {
+			t.Fatalf("step back to %d: machine differs (frame %d, want %d)", i, ch.Frames(), history[i].frames)
+		}
+	}
+	if err := ch.StepBack(); err == nil || ch.RewindAvailable() != 0 {
+		t.Errorf("got err = %v, %d frames to rewind, want an error and 0", err, ch.RewindAvailable())
+	}
+
+	// Forward again the timers tick at the same points
+	for i := 0; i < 10; i++ {
+		ch.Step()
+	}
+	if ch.DT != 2 || ch.Frames() != 3 {
+		t.Errorf("DT = %d in frame %d after replaying, want 2 in 3", ch.DT, ch.Frames())
+	}
+}
+
+func TestSymbols(t *testing.T) {
+	rom, syms, err := asm.AssembleSymbols(`
+main:   CALL draw
+        JP   main
+draw:   LD   I, main
+        DW   0xFFFF`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := NewChip8()
+	if _, err := ch.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	ch.SetSymbols(syms)
+	var trace strings.Builder
+	ch.SetTraceWriter(&trace)
+	err = ch.RunFor(4)
+	if err == nil {
+		t.Fatal("the ROM didn't crash")
+	}
+	for _, want := range []string{"CALL draw", "LD I, main"} {
+		if !strings.Contains(trace.String(), want) {
+			t.Errorf("trace doesn't name %q:\n%s", want, trace.String())
+		}
+	}
+	pm := strings.Join(ch.PostMortem(err), "\n")
+	for _, want := range []string{"  draw:\n", "#1  draw, returns to main+2"} {
+		if !strings.Contains(pm, want) {
+			t.Errorf("post mortem doesn't have %q:\n%s", want, pm)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package chip8
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+)
+
+// PostMortem describes the machine after err stopped it, for crash screens and bug reports:
+// the error, the instructions around the one that failed (marked with '>'), the registers and
+// the call stack. Lines fit a 64 column screen. Safe to call while another goroutine runs the machine.
+func (ch *Chip8) PostMortem(err error) []string {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	pc := ch.PC
+	var f *EmuError
+	if errors.As(err, &f) {
+		pc = f.PC
+	}
+	lines := []string{err.Error(), ""}
+
+	for addr := int(pc) - 4; addr <= int(pc)+4; addr += 2 {
+		if addr < 0 || addr+1 >= len(ch.Memory) {
+			continue
+		}
+		op := uint16(ch.Memory[addr])<<8 | uint16(ch.Memory[addr+1])
+		mark := " "
+		if addr == int(pc) {
+			mark = ">"
+		}
+		if name, ok := ch.syms.Name(uint16(addr)); ok {
+			lines = append(lines, "  "+name+":")
+		}
+		lines = append(lines, fmt.Sprintf("%s 0x%03X  %04X  %s", mark, addr, op, disasm.Decode(uint16(addr), op).WithSymbols(ch.syms).Text()))
+	}
+	lines = append(lines, "")
+
+	for first := 0; first < len(ch.V); first += 8 {
+		var regs []string
+		for r := first; r < first+8; r++ {
+			regs = append(regs, fmt.Sprintf("V%X=%02X", r, ch.V[r]))
+		}
+		lines = append(lines, strings.Join(regs, " "))
+	}
+	lines = append(lines, fmt.Sprintf("PC=%03X I=%03X SP=%d DT=%02X ST=%02X", ch.PC, ch.I, ch.SP, ch.DT, ch.ST), "")
+
+	// Stack[0..SP-1] hold return addresses, the innermost call last
+	lines = append(lines, "Call stack:")
+	if ch.SP == 0 {
+		lines = append(lines, "  (empty)")
+	}
+	for level := int(ch.SP); level >= 1; level-- {
+		if level > len(ch.Stack) {
+			lines = append(lines, fmt.Sprintf("  #%d  (SP out of range)", level))
+			continue
+		}
+		ret := ch.Stack[level-1]
+		if ch.syms.Len() > 0 && ret >= 2 && int(ret) < len(ch.Memory) {
+			// Name the subroutine called and where it returns to
+			callee := uint16(ch.Memory[ret-2])<<8&0xF00 | uint16(ch.Memory[ret-1])
+			lines = append(lines, fmt.Sprintf("  #%d  %s, returns to %s", level, ch.locate(callee), ch.locate(ret)))
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("  #%d  returns to 0x%03X (CALL at 0x%03X)", level, ret, ret-2))
+	}
+	return lines
+}
+
+// locate names addr after the nearest label before it, or gives it in hex when there's none
+func (ch *Chip8) locate(addr uint16) string {
+	if name := ch.syms.Locate(addr); name != "" {
+		return name
+	}
+	return fmt.Sprintf("0x%03X", addr)
+}
@@ -0,0 +1,211 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Profile bundles the settings that make up one CHIP-8 machine: its quirks, how much memory it has,
+// roughly how fast it ran and where its interpreter kept the font
+type Profile struct {
+	Name                 string // Short name used on the command line, e.g. "vip"
+	Title                string
+	Quirks               Quirks
+	MemorySize           int // Bytes of addressable memory, at most len(Chip8.Memory)
+	InstructionsPerFrame int // Approximates the original clock speed, see timing.go
+	FontAddress          uint16
+	EntryPoint           uint16 // Where LoadRomBytes puts programs and execution starts
+	Hires                bool   // 64x64 hires CHIP-8, see hires.go. ROMs starting with its 1260 jump get it anyway
+	MegaChip             bool   // Revival Studios' MegaChip extension, see megachip.go
+}
+
+// profiles are the supported machines, in the order they appeared
+var profiles = []Profile{
+	{
+		Name:  "vip",
+		Title: "COSMAC VIP CHIP-8 (1977)",
+		Quirks: Quirks{
+			ShiftUsesVy:          true,
+			LoadStoreIncrementsI: true,
+			VFReset:              true,
+			ClipSprites:          true,
+			DisplayWait:          true,
+		},
+		MemorySize:           4096,
+		InstructionsPerFrame: 15,
+		FontAddress:          0x000,
+		EntryPoint:           0x200,
+	},
+	{
+		Name:  "hires",
+		Title: "COSMAC VIP hires CHIP-8, 64x64 (1978)",
+		Quirks: Quirks{
+			ShiftUsesVy:          true,
+			LoadStoreIncrementsI: true,
+			VFReset:              true,
+			ClipSprites:          true,
+			DisplayWait:          true,
+		},
+		MemorySize:           4096,
+		InstructionsPerFrame: 15,
+		FontAddress:          0x000,
+		EntryPoint:           0x200,
+		Hires:                true,
+	},
+	{
+		Name:  "eti660",
+		Title: "ETI-660 Learner's Microcomputer CHIP-8 (1981)",
+		Quirks: Quirks{
+			ShiftUsesVy:          true,
+			LoadStoreIncrementsI: true,
+			VFReset:              true,
+			ClipSprites:          true,
+			DisplayWait:          true,
+		},
+		MemorySize:           4096,
+		InstructionsPerFrame: 15,
+		FontAddress:          0x000,
+		EntryPoint:           0x600, // The ETI-660 kept its interpreter below 0x600
+	},
+	{
+		Name:  "chip48",
+		Title: "CHIP-48 for the HP-48 (1990)",
+		Quirks: Quirks{
+			LoadStoreIncrementsI: true,
+			JumpUsesVx:           true,
+			ClipSprites:          true,
+		},
+		MemorySize:           4096,
+		InstructionsPerFrame: 30,
+		FontAddress:          0x050,
+		EntryPoint:           0x200,
+	},
+	{
+		Name:  "schip",
+		Title: "SUPER-CHIP 1.1 (1991)",
+		Quirks: Quirks{
+			JumpUsesVx:  true,
+			ClipSprites: true,
+		},
+		MemorySize:           4096,
+		InstructionsPerFrame: 30,
+		FontAddress:          0x050,
+		EntryPoint:           0x200,
+	},
+	{
+		Name:  "megachip",
+		Title: "MegaChip8 (2007)",
+		Quirks: Quirks{
+			JumpUsesVx:  true,
+			ClipSprites: true,
+		},
+		MemorySize:           4096,
+		InstructionsPerFrame: 1000,
+		FontAddress:          0x050,
+		EntryPoint:           0x200,
+		MegaChip:             true,
+	},
+	{
+		Name:  "xochip",
+		Title: "Octo's XO-CHIP (2014)",
+		Quirks: Quirks{
+			ShiftUsesVy:          true,
+			LoadStoreIncrementsI: true,
+		},
+		// XO-CHIP addresses 64K, but only the first 4K fit in Chip8.Memory
+		MemorySize:           4096,
+		InstructionsPerFrame: 1000,
+		FontAddress:          0x050,
+		EntryPoint:           0x200,
+	},
+}
+
+// Profiles lists the supported machines
+func Profiles() []Profile {
+	return append([]Profile(nil), profiles...)
+}
+
+// ProfileNames lists the names accepted by LookupProfile
+func ProfileNames() []string {
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// LookupProfile finds the profile called name
+func LookupProfile(name string) (Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, fmt.Errorf("unknown platform %q (expected one of: %v)", name, strings.Join(ProfileNames(), ", "))
+}
+
+// DefaultProfile is what NewChip8 starts as: this emulator's historical SCHIP-flavoured settings.
+// It isn't any one real machine, so it isn't listed by Profiles.
+func DefaultProfile() Profile {
+	return defaultProfile
+}
+
+var defaultProfile = Profile{
+	Name:                 "default",
+	Title:                "chip8emu default",
+	Quirks:               DefaultQuirks(),
+	MemorySize:           4096,
+	InstructionsPerFrame: DefaultInstructionsPerFrame,
+	FontAddress:          0x050,
+	EntryPoint:           0x200,
+}
+
+// SetProfile switches the machine to p's quirks, clock speed, memory size, font location and entry point.
+// Call it before LoadRomBytes; the font is moved straight away but the rest of memory is untouched.
+func (ch *Chip8) SetProfile(p Profile) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.setProfile(p)
+}
+
+func (ch *Chip8) setProfile(p Profile) {
+	if p.MemorySize <= 0 || p.MemorySize > len(ch.Memory) {
+		p.MemorySize = len(ch.Memory)
+	}
+	if p.InstructionsPerFrame < 1 {
+		p.InstructionsPerFrame = 1
+	}
+	if int(p.FontAddress)+len(fontSet) > 0x200 {
+		p.FontAddress = defaultProfile.FontAddress
+	}
+	if p.EntryPoint < 0x200 || int(p.EntryPoint) >= p.MemorySize {
+		p.EntryPoint = defaultProfile.EntryPoint
+	}
+	for i := range fontSet {
+		ch.Memory[int(ch.profile.FontAddress)+i] = 0
+	}
+	ch.profile = p
+	ch.quirks = p.Quirks
+	switch {
+	case !p.MegaChip:
+		ch.mega = nil
+	case ch.mega == nil:
+		ch.mega = newMegaChip()
+	}
+	ch.instructionsPerFrame = p.InstructionsPerFrame
+	ch.loadFont()
+}
+
+// Profile returns the machine profile last set with SetProfile. Quirks and clock speed changed
+// since then are reported by Quirks and InstructionsPerFrame, not here.
+func (ch *Chip8) Profile() Profile {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.profile
+}
+
+// loadFont copies the font sprites (16 8bit*5 row sprites) to where the profile keeps them
+func (ch *Chip8) loadFont() {
+	copy(ch.Memory[ch.profile.FontAddress:], fontSet[:])
+}
@@ -0,0 +1,271 @@
+// Package profiler follows CALL and RET to keep a call stack and count the instructions each
+// subroutine runs, so ROM authors can find their hot loops.
+//
+// A subroutine's self count is the instructions executed in it, its total count includes the
+// subroutines it calls. Code outside any subroutine is counted under the entry point.
+package profiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// hotSpots is how many of the most executed addresses a Report lists
+const hotSpots = 10
+
+// Frame is an active subroutine call
+type Frame struct {
+	Addr   uint16 // The subroutine
+	Return uint16 // Where its RET goes back to
+	Cycles uint64 // Instructions executed since the call
+}
+
+// Subroutine is the profile of one subroutine
+type Subroutine struct {
+	Addr  uint16
+	Calls uint64
+	Self  uint64
+	Total uint64
+}
+
+// Spot is an address and how many times the instruction there executed
+type Spot struct {
+	Addr  uint16
+	Count uint64
+}
+
+// Branch counts the outcomes of a skip instruction (3xkk, 4xkk, 5xy0, 9xy0, Ex9E or ExA1)
+type Branch struct {
+	NotTaken, Taken uint64
+}
+
+// Report is a profile of the run so far
+type Report struct {
+	Cycles      uint64
+	Subroutines []Subroutine // By self count, highest first
+	Hot         []Spot       // The most executed addresses, highest first
+}
+
+type call struct {
+	addr, ret uint16
+	start     uint64 // Cycle count when called
+}
+
+// Profiler profiles a machine through its instruction hook
+type Profiler struct {
+	emu    *chip8.Chip8
+	remove func() // Removes the profiler's hook from emu
+	entry  uint16
+	mu     sync.Mutex
+	cycles uint64
+	stack  []call
+	subs   map[uint16]*Subroutine
+	counts map[uint16]uint64
+	skips  map[uint16]*Branch
+	skipPC int // Address of the skip instruction executed last, or -1
+}
+
+// New starts profiling emu, alongside any other hooks set on it. Stop ends profiling.
+func New(emu *chip8.Chip8) *Profiler {
+	p := &Profiler{
+		emu:    emu,
+		entry:  emu.Profile().EntryPoint,
+		subs:   map[uint16]*Subroutine{},
+		counts: map[uint16]uint64{},
+		skips:  map[uint16]*Branch{},
+		skipPC: -1,
+	}
+	p.remove = emu.AddPreExecHook(func(pc, opcode uint16) chip8.HookAction {
+		p.instruction(pc, opcode)
+		return chip8.HookAction{}
+	})
+	return p
+}
+
+// Stop removes the profiler's hook, the counts so far stay available
+func (p *Profiler) Stop() {
+	p.remove()
+}
+
+func (p *Profiler) sub(addr uint16) *Subroutine {
+	s := p.subs[addr]
+	if s == nil {
+		s = &Subroutine{Addr: addr}
+		p.subs[addr] = s
+	}
+	return s
+}
+
+// instruction is called with the machine locked, before the instruction at pc executes
+func (p *Profiler) instruction(pc, opcode uint16) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A reset, loaded state or rewind leaves the stack shallower than ours
+	if sp := int(p.emu.SP); sp < len(p.stack) {
+		p.stack = p.stack[:sp]
+	}
+
+	// Whether the last instruction skipped shows in where this one is
+	if p.skipPC >= 0 {
+		b := p.skips[uint16(p.skipPC)]
+		if int(pc) == p.skipPC+4 {
+			b.Taken++
+		} else {
+			b.NotTaken++
+		}
+		p.skipPC = -1
+	}
+	if IsSkip(opcode) {
+		if p.skips[pc] == nil {
+			p.skips[pc] = &Branch{}
+		}
+		p.skipPC = int(pc)
+	}
+
+	p.cycles++
+	p.counts[pc]++
+	current := p.entry
+	if len(p.stack) > 0 {
+		current = p.stack[len(p.stack)-1].addr
+	}
+	p.sub(current).Self++
+
+	switch {
+	case opcode&0xF000 == 0x2000: // CALL addr
+		addr := opcode & 0x0FFF
+		p.sub(addr).Calls++
+		p.stack = append(p.stack, call{addr: addr, ret: pc + 2, start: p.cycles})
+	case opcode == 0x00EE && len(p.stack) > 0: // RET
+		c := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		if !p.onStack(c.addr) { // A recursive call's time is already in the outer one
+			p.sub(c.addr).Total += p.cycles - c.start
+		}
+	}
+}
+
+// IsSkip reports whether opcode is a conditional skip, whose outcomes Branches counts
+func IsSkip(opcode uint16) bool {
+	switch opcode & 0xF000 {
+	case 0x3000, 0x4000:
+		return true
+	case 0x5000, 0x9000:
+		return opcode&0xF == 0
+	case 0xE000:
+		return opcode&0xFF == 0x9E || opcode&0xFF == 0xA1
+	}
+	return false
+}
+
+func (p *Profiler) onStack(addr uint16) bool {
+	for _, c := range p.stack {
+		if c.addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// CallStack returns the active calls, outermost first
+func (p *Profiler) CallStack() []Frame {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	frames := make([]Frame, len(p.stack))
+	for i, c := range p.stack {
+		frames[i] = Frame{Addr: c.addr, Return: c.ret, Cycles: p.cycles - c.start}
+	}
+	return frames
+}
+
+// Counts returns how many times the instruction at each address executed
+func (p *Profiler) Counts() map[uint16]uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[uint16]uint64, len(p.counts))
+	for addr, n := range p.counts {
+		counts[addr] = n
+	}
+	return counts
+}
+
+// Branches returns the outcomes of the skip instructions executed, by address. A skip's
+// outcome is known once the next instruction runs.
+func (p *Profiler) Branches() map[uint16]Branch {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	branches := make(map[uint16]Branch, len(p.skips))
+	for addr, b := range p.skips {
+		branches[addr] = *b
+	}
+	return branches
+}
+
+// Profile reports the counts so far. Subroutines still running count up to now.
+func (p *Profiler) Profile() Report {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r := Report{Cycles: p.cycles}
+	for _, s := range p.subs {
+		r.Subroutines = append(r.Subroutines, *s)
+	}
+	for i := range r.Subroutines {
+		s := &r.Subroutines[i]
+		if s.Addr == p.entry && s.Calls == 0 {
+			s.Total = p.cycles
+			continue
+		}
+		for _, c := range p.stack {
+			if c.addr == s.Addr {
+				s.Total += p.cycles - c.start
+				break // The outermost call covers the recursive ones
+			}
+		}
+	}
+	sort.Slice(r.Subroutines, func(i, j int) bool {
+		a, b := r.Subroutines[i], r.Subroutines[j]
+		if a.Self != b.Self {
+			return a.Self > b.Self
+		}
+		return a.Addr < b.Addr
+	})
+	for addr, n := range p.counts {
+		r.Hot = append(r.Hot, Spot{addr, n})
+	}
+	sort.Slice(r.Hot, func(i, j int) bool {
+		if r.Hot[i].Count != r.Hot[j].Count {
+			return r.Hot[i].Count > r.Hot[j].Count
+		}
+		return r.Hot[i].Addr < r.Hot[j].Addr
+	})
+	if len(r.Hot) > hotSpots {
+		r.Hot = r.Hot[:hotSpots]
+	}
+	return r
+}
+
+// String formats the report as tables: the flat and cumulative counts of each subroutine, then
+// the hot spots
+func (r Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d instructions\n\n", r.Cycles)
+	fmt.Fprintf(&sb, "%-6s %8s %10s %6s %10s %6s\n", "sub", "calls", "self", "self%", "total", "total%")
+	for _, s := range r.Subroutines {
+		fmt.Fprintf(&sb, "0x%03X  %8d %10d %5.1f%% %10d %5.1f%%\n", s.Addr, s.Calls, s.Self, percent(s.Self, r.Cycles), s.Total, percent(s.Total, r.Cycles))
+	}
+	fmt.Fprintf(&sb, "\n%-6s %10s %6s\n", "addr", "count", "%")
+	for _, h := range r.Hot {
+		fmt.Fprintf(&sb, "0x%03X  %10d %5.1f%%\n", h.Addr, h.Count, percent(h.Count, r.Cycles))
+	}
+	return sb.String()
+}
+
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
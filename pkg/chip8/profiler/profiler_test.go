@@ -0,0 +1,74 @@
+package profiler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestProfile(t *testing.T) {
+	emu := chip8.NewChip8()
+	rom := []byte{
+		0x22, 0x0A, // 200: CALL 0x20A
+		0x22, 0x0A, // 202: CALL 0x20A
+		0x12, 0x04, // 204: JP 0x204
+		0, 0, 0, 0,
+		0x70, 0x01, // 20A: ADD V0, 1
+		0x22, 0x10, // 20C: CALL 0x210
+		0x00, 0xEE, // 20E: RET
+		0x00, 0xEE, // 210: RET
+	}
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	p := New(emu)
+	step := func(n int) {
+		for i := 0; i < n; i++ {
+			if err := emu.Step(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	step(3)
+	want := []Frame{{Addr: 0x20A, Return: 0x202, Cycles: 2}, {Addr: 0x210, Return: 0x20E, Cycles: 0}}
+	if got := p.CallStack(); !reflect.DeepEqual(got, want) {
+		t.Errorf("call stack: got %+v, want %+v", got, want)
+	}
+
+	step(9)
+	r := p.Profile()
+	wantSubs := []Subroutine{
+		{Addr: 0x20A, Calls: 2, Self: 6, Total: 8},
+		{Addr: 0x200, Calls: 0, Self: 4, Total: 12},
+		{Addr: 0x210, Calls: 2, Self: 2, Total: 2},
+	}
+	if r.Cycles != 12 || !reflect.DeepEqual(r.Subroutines, wantSubs) {
+		t.Errorf("got %d cycles, subroutines %+v, want 12, %+v", r.Cycles, r.Subroutines, wantSubs)
+	}
+	if len(p.CallStack()) != 0 || r.Hot[0] != (Spot{0x204, 2}) || len(r.Hot) != 7 {
+		t.Errorf("got call stack %v, hot spots %v", p.CallStack(), r.Hot)
+	}
+	if s := r.String(); !strings.Contains(s, "0x20A         2          6  50.0%          8  66.7%") {
+		t.Errorf("report:\n%s", s)
+	}
+
+	// A reset empties the machine's stack, and with it the profiler's
+	emu.Reset()
+	step(2)
+	if stack := p.CallStack(); len(stack) != 1 || stack[0].Addr != 0x20A {
+		t.Errorf("after reset: got call stack %+v", stack)
+	}
+	emu.Reset()
+	step(1)
+	if stack := p.CallStack(); len(stack) != 1 || stack[0].Return != 0x202 {
+		t.Errorf("after second reset: got call stack %+v", stack)
+	}
+	p.Stop()
+	step(1)
+	if r := p.Profile(); r.Cycles != 15 {
+		t.Errorf("counted %d instructions after Stop, want 15", r.Cycles)
+	}
+}
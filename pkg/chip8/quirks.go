@@ -0,0 +1,93 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Quirks toggles the handful of opcode behaviours that differ between CHIP-8 interpreters.
+// Most ROMs were written against one particular interpreter, so a ROM that misbehaves
+// will usually start working once the matching combination is selected.
+//
+// See: https://github.com/Timendus/chip8-test-suite#quirks-test
+type Quirks struct {
+	// 8xy6 / 8xyE: the original COSMAC VIP shifts Vy and stores the result in Vx.
+	// CHIP-48 and SCHIP shift Vx in place and ignore Vy.
+	ShiftUsesVy bool
+
+	// Fx55 / Fx65: in the original CHIP-8 implementation, and also in CHIP-48,
+	// I is left incremented after this instruction had been executed.
+	// In SCHIP, I is left unmodified.
+	// See: https://en.wikipedia.org/wiki/CHIP-8#cite_note-increment-17
+	LoadStoreIncrementsI bool
+
+	// Bnnn: CHIP-48 and SCHIP (mis)interpret this as Bxnn, jumping to xnn + Vx instead of nnn + V0
+	JumpUsesVx bool
+
+	// 8xy1 / 8xy2 / 8xy3: the COSMAC VIP leaves VF set to 0 after the logic ops
+	VFReset bool
+
+	// Dxyn: when true, sprites are clipped at the screen edges instead of wrapping around.
+	// The starting coordinate always wraps.
+	ClipSprites bool
+
+	// Dxyn: the COSMAC VIP waits for the vertical blank interrupt before drawing,
+	// which limits a ROM to one sprite per 60Hz frame
+	DisplayWait bool
+}
+
+// DefaultQuirks matches the behaviour this emulator has always had (a SCHIP-flavoured interpreter
+// that wraps sprites around the screen edges)
+func DefaultQuirks() Quirks {
+	return Quirks{}
+}
+
+// quirkNames maps the short names used on the command line to each quirk
+var quirkNames = map[string]func(q *Quirks) *bool{
+	"shift":       func(q *Quirks) *bool { return &q.ShiftUsesVy },
+	"loadstore":   func(q *Quirks) *bool { return &q.LoadStoreIncrementsI },
+	"jump":        func(q *Quirks) *bool { return &q.JumpUsesVx },
+	"vfreset":     func(q *Quirks) *bool { return &q.VFReset },
+	"clip":        func(q *Quirks) *bool { return &q.ClipSprites },
+	"displaywait": func(q *Quirks) *bool { return &q.DisplayWait },
+}
+
+// QuirkNames lists the names accepted by ParseQuirks in alphabetical order
+func QuirkNames() []string {
+	names := make([]string, 0, len(quirkNames))
+	for name := range quirkNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseQuirks enables each quirk in a comma separated list of names, e.g. "shift,vfreset,clip".
+// Every other quirk is left disabled.
+func ParseQuirks(list string) (Quirks, error) {
+	var q Quirks
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		field, ok := quirkNames[name]
+		if !ok {
+			return Quirks{}, fmt.Errorf("unknown quirk %q (expected one of: %v)", name, strings.Join(QuirkNames(), ", "))
+		}
+		*field(&q) = true
+	}
+	return q, nil
+}
+
+// String lists the enabled quirks in the format accepted by ParseQuirks
+func (q Quirks) String() string {
+	var enabled []string
+	for _, name := range QuirkNames() {
+		if *quirkNames[name](&q) {
+			enabled = append(enabled, name)
+		}
+	}
+	return strings.Join(enabled, ",")
+}
@@ -0,0 +1,66 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+// SetRandSource replaces the source used by Cxkk - RND, making runs reproducible for tests,
+// input recordings and netplay. SaveState / LoadState only capture the source's state
+// if it implements encoding.BinaryMarshaler / encoding.BinaryUnmarshaler.
+func (ch *Chip8) SetRandSource(src rand.Source) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.rng = src
+}
+
+// SeedRand reseeds the default random source so Cxkk - RND produces a repeatable sequence
+func (ch *Chip8) SeedRand(seed int64) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.rng = newSplitMix(seed)
+}
+
+// splitMix is a tiny SplitMix64 generator used as the default source for Cxkk - RND.
+// Unlike the global math/rand source its entire state is a single uint64,
+// which lets SaveState / LoadState capture and restore it.
+//
+// See: https://prng.di.unimi.it/splitmix64.c
+type splitMix struct {
+	state uint64
+}
+
+func newSplitMix(seed int64) *splitMix {
+	return &splitMix{state: uint64(seed)}
+}
+
+func (s *splitMix) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *splitMix) Uint64() uint64 {
+	s.state += 0x9E3779B97F4A7C15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, s.state)
+	return buf, nil
+}
+
+func (s *splitMix) UnmarshalBinary(data []byte) error {
+	if len(data) != 8 {
+		return fmt.Errorf("splitMix: expected 8 bytes of state, got %d", len(data))
+	}
+	s.state = binary.BigEndian.Uint64(data)
+	return nil
+}
@@ -0,0 +1,113 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Register names a register for SetRegister. V0 to VF are Register(0) to Register(15).
+type Register uint8
+
+const (
+	RegI Register = 16 + iota
+	RegPC
+	RegSP
+	RegDT
+	RegST
+)
+
+// RegV returns Vx
+func RegV(x int) Register {
+	return Register(x & 0xF)
+}
+
+var registerNames = map[Register]string{RegI: "I", RegPC: "PC", RegSP: "SP", RegDT: "DT", RegST: "ST"}
+
+func (r Register) String() string {
+	if r < 16 {
+		return fmt.Sprintf("V%X", uint8(r))
+	}
+	if name, ok := registerNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Register(%d)", uint8(r))
+}
+
+// ParseRegister parses a register name: V0 to VF, I, PC, SP, DT or ST, in any case
+func ParseRegister(name string) (Register, error) {
+	name = strings.ToUpper(name)
+	if len(name) == 2 && name[0] == 'V' {
+		if x, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+			return RegV(int(x)), nil
+		}
+	}
+	for r, n := range registerNames {
+		if n == name {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown register %q (expected V0-VF, I, PC, SP, DT or ST)", name)
+}
+
+// Get returns the value of r in the copy, 0 for an unknown register
+func (regs Registers) Get(r Register) int {
+	switch r {
+	case RegI:
+		return int(regs.I)
+	case RegPC:
+		return int(regs.PC)
+	case RegSP:
+		return int(regs.SP)
+	case RegDT:
+		return int(regs.DT)
+	case RegST:
+		return int(regs.ST)
+	}
+	if r < 16 {
+		return int(regs.V[r])
+	}
+	return 0
+}
+
+// SetRegister sets r to value, refusing values that don't fit it: past a byte for V0-VF, DT and
+// ST, outside memory for PC, and past the top of the stack for SP. I takes any 16-bit value.
+// Safe to call while another goroutine runs the machine.
+func (ch *Chip8) SetRegister(r Register, value int) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.setRegister(r, value)
+}
+
+func (ch *Chip8) setRegister(r Register, value int) error {
+	max := 0xFF
+	switch r {
+	case RegI:
+		max = 0xFFFF
+	case RegPC:
+		max = ch.profile.MemorySize - 2
+	case RegSP:
+		max = len(ch.Stack)
+	}
+	if r > RegST {
+		return fmt.Errorf("setRegister: unknown register %v", r)
+	}
+	if value < 0 || value > max {
+		return fmt.Errorf("setRegister: %#x doesn't fit %v, at most %#x", value, r, max)
+	}
+	switch r {
+	case RegI:
+		ch.I = uint16(value)
+	case RegPC:
+		ch.PC = uint16(value)
+	case RegSP:
+		ch.SP = uint16(value)
+	case RegDT:
+		ch.DT = uint8(value)
+	case RegST:
+		ch.ST = uint8(value)
+	default:
+		ch.V[r] = uint8(value)
+	}
+	return nil
+}
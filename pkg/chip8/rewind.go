@@ -0,0 +1,102 @@
+package chip8
+
+import "fmt"
+
+// rewindBuffer is a ring of save states, one captured at the end of every frame
+type rewindBuffer struct {
+	states [][]byte
+	next   int  // Slot the next snapshot goes into
+	count  int  // Number of valid snapshots
+	latest bool // The newest snapshot was just taken, i.e. it matches the current state
+}
+
+// SetRewindBuffer keeps a snapshot of the last `frames` frames so Rewind can step back through them
+// (e.g. 10 * FrameRate for ten seconds). Passing 0 disables rewinding and frees the buffer.
+func (ch *Chip8) SetRewindBuffer(frames int) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if frames <= 0 {
+		ch.rewind = nil
+		return
+	}
+	ch.rewind = &rewindBuffer{states: make([][]byte, frames)}
+}
+
+// RewindAvailable returns how many frames Rewind can currently go back
+func (ch *Chip8) RewindAvailable() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.rewind == nil {
+		return 0
+	}
+	return ch.rewind.count
+}
+
+// Rewind restores the machine to how it was `frames` frame boundaries ago (clamped to the oldest snapshot).
+// Snapshots newer than the restored one are discarded, so calling Rewind(1) once per frame plays the game backwards.
+func (ch *Chip8) Rewind(frames int) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	rb := ch.rewind
+	if rb == nil {
+		return fmt.Errorf("rewind: rewinding is not enabled")
+	}
+	if rb.count == 0 {
+		return fmt.Errorf("rewind: no snapshots available")
+	}
+	if frames < 1 {
+		frames = 1
+	}
+	if rb.latest && ch.frameCycles == 0 && rb.count > 1 {
+		// Sitting exactly on a frame boundary the newest snapshot is identical to the current state
+		rb.next = (rb.next - 1 + len(rb.states)) % len(rb.states)
+		rb.states[rb.next] = nil
+		rb.count--
+	}
+	if frames > rb.count {
+		frames = rb.count
+	}
+
+	rb.next = (rb.next - frames + len(rb.states)) % len(rb.states)
+	rb.count -= frames
+	state := rb.states[rb.next]
+	rb.states[rb.next] = nil
+	rb.latest = false
+
+	if err := ch.loadState(state); err != nil {
+		return fmt.Errorf("rewind: %v", err)
+	}
+	ch.frameCycles = 0
+	return nil
+}
+
+// captureRewind must be called with ch.mu held
+func (ch *Chip8) captureRewind() {
+	rb := ch.rewind
+	state, err := ch.saveState()
+	if err != nil {
+		return
+	}
+	rb.push(state)
+	rb.latest = true
+}
+
+// push stores state in the next slot, over the oldest snapshot once the ring is full
+func (rb *rewindBuffer) push(state []byte) {
+	rb.states[rb.next] = state
+	rb.next = (rb.next + 1) % len(rb.states)
+	if rb.count < len(rb.states) {
+		rb.count++
+	}
+}
+
+// drop discards the newest n snapshots
+func (rb *rewindBuffer) drop(n int) {
+	for ; n > 0 && rb.count > 0; n-- {
+		rb.next = (rb.next - 1 + len(rb.states)) % len(rb.states)
+		rb.states[rb.next] = nil
+		rb.count--
+	}
+	rb.latest = false
+}
@@ -0,0 +1,140 @@
+package chip8
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxRomDownload is the most ReadRom downloads or unpacks, enough for the largest MegaChip ROMs
+const MaxRomDownload = megaMemorySize
+
+// romDownloadTimeout bounds how long ReadRom waits for a URL
+const romDownloadTimeout = 30 * time.Second
+
+// RomExtensions are the file extensions ReadRom looks for inside a .zip archive
+var RomExtensions = []string{".ch8", ".c8", ".sc8", ".xo8", ".mc8"}
+
+// RomInfo describes a loaded ROM, for frontends to show
+type RomInfo struct {
+	Size  int    // In bytes
+	Addr  uint16 // Where it was loaded
+	SHA1  string // Hex, as used by the compat database
+	CRC32 uint32 // IEEE, as listed by most ROM collections
+}
+
+func newRomInfo(rom []byte, addr uint16) RomInfo {
+	sum := sha1.Sum(rom)
+	return RomInfo{Size: len(rom), Addr: addr, SHA1: hex.EncodeToString(sum[:]), CRC32: crc32.ChecksumIEEE(rom)}
+}
+
+func (r RomInfo) String() string {
+	return fmt.Sprintf("%d bytes at %#03x, SHA-1 %s, CRC32 %08x", r.Size, r.Addr, r.SHA1, r.CRC32)
+}
+
+// IsRomURL reports whether ReadRom will download path rather than read it from disk
+func IsRomURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// ReadRom reads a ROM from a local file or an http(s):// URL. A .zip archive holding a single
+// ROM (see RomExtensions) is unpacked.
+func ReadRom(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if IsRomURL(path) {
+		data, err = downloadRom(path)
+	} else if data, err = ioutil.ReadFile(path); err != nil {
+		err = fmt.Errorf("readRom: failed reading file: %v", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return unzipRom(data)
+	}
+	return data, nil
+}
+
+func downloadRom(url string) ([]byte, error) {
+	client := http.Client{Timeout: romDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("readRom: failed downloading: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("readRom: failed downloading %v: %v", url, resp.Status)
+	}
+	if resp.ContentLength > MaxRomDownload {
+		return nil, fmt.Errorf("readRom: %v is %d bytes, more than the %d byte limit", url, resp.ContentLength, MaxRomDownload)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, MaxRomDownload+1))
+	if err != nil {
+		return nil, fmt.Errorf("readRom: failed downloading: %v", err)
+	}
+	if len(data) > MaxRomDownload {
+		return nil, fmt.Errorf("readRom: %v is more than the %d byte limit", url, MaxRomDownload)
+	}
+	return data, nil
+}
+
+// unzipRom returns the only ROM in a .zip archive
+func unzipRom(data []byte) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("readRom: failed reading archive: %v", err)
+	}
+	var roms []*zip.File
+	for _, f := range zr.File {
+		if !f.FileInfo().IsDir() && isRomName(f.Name) {
+			roms = append(roms, f)
+		}
+	}
+	switch len(roms) {
+	case 0:
+		return nil, fmt.Errorf("readRom: no ROM in archive (looked for %v)", strings.Join(RomExtensions, ", "))
+	case 1:
+	default:
+		names := make([]string, len(roms))
+		for i, f := range roms {
+			names[i] = f.Name
+		}
+		return nil, fmt.Errorf("readRom: archive holds %d ROMs, expected one: %v", len(roms), strings.Join(names, ", "))
+	}
+	if roms[0].UncompressedSize64 > MaxRomDownload {
+		return nil, fmt.Errorf("readRom: %v is more than the %d byte limit", roms[0].Name, MaxRomDownload)
+	}
+	r, err := roms[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("readRom: failed reading %v: %v", roms[0].Name, err)
+	}
+	defer r.Close()
+	rom, err := ioutil.ReadAll(io.LimitReader(r, MaxRomDownload+1))
+	if err != nil {
+		return nil, fmt.Errorf("readRom: failed reading %v: %v", roms[0].Name, err)
+	}
+	if len(rom) > MaxRomDownload {
+		return nil, fmt.Errorf("readRom: %v is more than the %d byte limit", roms[0].Name, MaxRomDownload)
+	}
+	return rom, nil
+}
+
+func isRomName(name string) bool {
+	ext := path.Ext(name)
+	for _, e := range RomExtensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
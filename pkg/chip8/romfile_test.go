@@ -0,0 +1,84 @@
+package chip8
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// zipOf builds a .zip archive holding files, name -> contents
+func zipOf(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(contents))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadRom(t *testing.T) {
+	dir, err := ioutil.TempDir("", "romfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"plain.ch8":  []byte("\x00\xE0"),
+		"single.zip": zipOf(t, map[string]string{"README.txt": "hi", "games/pong.ch8": "\x12\x00"}),
+		"many.zip":   zipOf(t, map[string]string{"a.ch8": "a", "b.sc8": "b"}),
+		"none.zip":   zipOf(t, map[string]string{"README.txt": "hi"}),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	tests := []struct {
+		path string
+		want string // ROM contents, or the start of the error
+	}{
+		{filepath.Join(dir, "plain.ch8"), "\x00\xE0"},
+		{filepath.Join(dir, "single.zip"), "\x12\x00"},
+		{filepath.Join(dir, "many.zip"), "readRom: archive holds 2 ROMs"},
+		{filepath.Join(dir, "none.zip"), "readRom: no ROM in archive"},
+		{filepath.Join(dir, "missing.ch8"), "readRom: failed reading file"},
+		{srv.URL + "/plain.ch8", "\x00\xE0"},
+		{srv.URL + "/single.zip", "\x12\x00"},
+		{srv.URL + "/missing.ch8", "readRom: failed downloading"},
+	}
+	for _, tt := range tests {
+		rom, err := ReadRom(tt.path)
+		got := string(rom)
+		if err != nil {
+			got = err.Error()
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("ReadRom(%v) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	big := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, MaxRomDownload+1))
+	}))
+	defer big.Close()
+	if _, err := ReadRom(big.URL + "/big.ch8"); err == nil {
+		t.Errorf("ReadRom downloaded more than MaxRomDownload")
+	}
+}
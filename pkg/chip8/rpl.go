@@ -0,0 +1,65 @@
+package chip8
+
+/*
+Fx75 / Fx85 - LD R, Vx / LD Vx, R
+
+SUPER-CHIP stores V0..Vx in the HP-48's RPL user flags and reads them back. The calculator kept
+them when the interpreter quit, so games used them for high scores. SCHIP has 8 of them, XO-CHIP
+16, and the machine always has 16. A FlagStore attached with SetFlagStore keeps them per ROM
+across runs.
+*/
+
+// FlagStore keeps the SCHIP RPL user flags (Fx75 / Fx85) that games save high scores in, e.g. on
+// disk so they outlive the emulator like they outlived a reset in the HP-48's battery-backed memory.
+// Flags are keyed by the hex SHA-1 of the ROM.
+type FlagStore interface {
+	// LoadFlags returns the flags saved for a ROM, all zero when there are none
+	LoadFlags(sha1 string) [16]byte
+	// SaveFlags is called after Fx75 changed a ROM's flags
+	SaveFlags(sha1 string, flags [16]byte)
+}
+
+// SetFlagStore attaches a FlagStore, or detaches it when s is nil, and loads the flags it saved
+// for the ROM that's loaded. s is called on the goroutine running the machine while it's locked,
+// so it must not call back into the Chip8.
+func (ch *Chip8) SetFlagStore(s FlagStore) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.flagStore = s
+	ch.loadFlags()
+}
+
+// RPLFlags returns the RPL user flags
+func (ch *Chip8) RPLFlags() [16]byte {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.rplFlags
+}
+
+// loadFlags takes the loaded ROM's flags from the FlagStore, must be called with ch.mu held
+func (ch *Chip8) loadFlags() {
+	ch.rplFlags = [16]byte{}
+	if ch.flagStore != nil && ch.rom != nil {
+		ch.rplFlags = ch.flagStore.LoadFlags(ch.romInfo.SHA1)
+	}
+}
+
+// Fx75 - LD R, Vx
+func opStoreFlags(ch *Chip8) error {
+	flags := ch.rplFlags
+	copy(flags[:], ch.V[:int(ch.x)+1])
+	if flags == ch.rplFlags {
+		return nil
+	}
+	ch.rplFlags = flags
+	if ch.flagStore != nil && ch.rom != nil {
+		ch.flagStore.SaveFlags(ch.romInfo.SHA1, flags)
+	}
+	return nil
+}
+
+// Fx85 - LD Vx, R
+func opLoadFlags(ch *Chip8) error {
+	copy(ch.V[:int(ch.x)+1], ch.rplFlags[:])
+	return nil
+}
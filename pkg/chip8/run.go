@@ -0,0 +1,100 @@
+package chip8
+
+import (
+	"context"
+	"time"
+)
+
+// RunCallbacks are made by Run on its own goroutine with the machine unlocked, so unlike Hooks
+// they may call any method of the Chip8. Any of them may be nil.
+type RunCallbacks struct {
+	Tick     func()             // Before every frame, e.g. to apply cheats
+	Frame    func(f Frame)      // After every frame that changed the screen
+	Error    func(e error) bool // When a frame fails or halts the machine, return true to keep running
+	Finished func(pc uint16)    // When the program finishes in an endless loop at pc, see Finished
+}
+
+// SetRunCallbacks installs cb for Run, replacing the callbacks set before
+func (ch *Chip8) SetRunCallbacks(cb RunCallbacks) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.runCallbacks = cb
+}
+
+// Run runs the machine hz frames a second (FrameRate when hz <= 0, which is normal speed) until
+// ctx is done, then returns ctx.Err(). While paused it keeps waiting for ticks rather than
+// blocking like RunFrame, so cancelling ctx always stops it.
+//
+// An error from a frame, or the *EmuError of an unknown opcode halting the machine, goes to the
+// Error callback. Run stops and returns it when there's no callback or the callback returns false.
+func (ch *Chip8) Run(ctx context.Context, hz int) error {
+	if hz <= 0 {
+		hz = FrameRate
+	}
+	ticker := time.NewTicker(time.Second / time.Duration(hz))
+	defer ticker.Stop()
+	_, finished := ch.Finished()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		ch.mu.Lock()
+		cb := ch.runCallbacks
+		ch.mu.Unlock()
+
+		if cb.Tick != nil {
+			cb.Tick()
+		}
+		if err := ch.runFrame(); err != nil {
+			if cb.Error == nil || !cb.Error(err) {
+				return err
+			}
+		}
+		if cb.Frame != nil {
+			if f, ok := ch.takeRunFrame(); ok {
+				cb.Frame(f)
+			}
+		}
+		pc, fin := ch.Finished()
+		if fin && !finished && cb.Finished != nil {
+			cb.Finished(pc)
+		}
+		finished = fin
+	}
+}
+
+// runFrame is RunFrame for Run: it returns instead of blocking when the machine is paused, and
+// returns the halt reason when an instruction halts it
+func (ch *Chip8) runFrame() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	frame := ch.frames
+	for ch.frames == frame && !ch.paused {
+		ch.mu.Unlock()
+		ch.mu.Lock() // Let KeyDown and friends in between instructions, as Step does
+		if ch.paused {
+			break
+		}
+		if err := ch.step(); err != nil {
+			return err
+		}
+		if ch.halt != nil {
+			return ch.halt
+		}
+	}
+	return nil
+}
+
+// takeRunFrame returns the screen for the Frame callback if it changed since the last call
+func (ch *Chip8) takeRunFrame() (Frame, bool) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.runDirty == 0 {
+		return Frame{}, false
+	}
+	f := Frame{Screen: ch.Screen.Clone(), Dirty: ch.runDirty}
+	ch.runDirty = 0
+	return f, true
+}
@@ -0,0 +1,113 @@
+package script
+
+import (
+	"math/rand"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// openLibs opens the standard libraries scripts get: base, coroutine, math, string and table,
+// without the functions that reach the file system, plus bit32 for the bitwise operators Lua 5.1
+// doesn't have
+func openLibs(L *lua.LState) {
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.CoroutineLibName, lua.OpenCoroutine},
+		{lua.MathLibName, lua.OpenMath},
+		{lua.StringLibName, lua.OpenString},
+		{lua.TabLibName, lua.OpenTable},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "module", "require"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	// Seeded the same every run, so scripts play back the same way
+	rng := rand.New(rand.NewSource(1))
+	math := L.GetGlobal(lua.MathLibName).(*lua.LTable)
+	L.SetFuncs(math, map[string]lua.LGFunction{
+		"random": func(L *lua.LState) int {
+			switch L.GetTop() {
+			case 0:
+				L.Push(lua.LNumber(rng.Float64()))
+				return 1
+			case 1:
+				L.Push(lua.LNumber(1 + rng.Int63n(int64(positive(L, 1, L.CheckInt64(1))))))
+				return 1
+			}
+			lo, hi := L.CheckInt64(1), L.CheckInt64(2)
+			if hi < lo {
+				L.ArgError(2, "interval is empty")
+			}
+			L.Push(lua.LNumber(lo + rng.Int63n(hi-lo+1)))
+			return 1
+		},
+		"randomseed": func(L *lua.LState) int {
+			rng.Seed(L.CheckInt64(1))
+			return 0
+		},
+	})
+
+	L.SetGlobal("bit32", L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"band": bitOp(func(a, b uint32) uint32 { return a & b }, 0xFFFFFFFF),
+		"bor":  bitOp(func(a, b uint32) uint32 { return a | b }, 0),
+		"bxor": bitOp(func(a, b uint32) uint32 { return a ^ b }, 0),
+		"bnot": func(L *lua.LState) int {
+			L.Push(lua.LNumber(^checkUint32(L, 1)))
+			return 1
+		},
+		"btest": func(L *lua.LState) int {
+			L.Push(lua.LBool(checkUint32(L, 1)&checkUint32(L, 2) != 0))
+			return 1
+		},
+		"lshift": func(L *lua.LState) int {
+			L.Push(lua.LNumber(shift(checkUint32(L, 1), L.CheckInt(2))))
+			return 1
+		},
+		"rshift": func(L *lua.LState) int {
+			L.Push(lua.LNumber(shift(checkUint32(L, 1), -L.CheckInt(2))))
+			return 1
+		},
+	}))
+}
+
+// bitOp makes a bit32 function that folds op over all its arguments, starting from identity
+func bitOp(op func(a, b uint32) uint32, identity uint32) lua.LGFunction {
+	return func(L *lua.LState) int {
+		v := identity
+		for i := 1; i <= L.GetTop(); i++ {
+			v = op(v, checkUint32(L, i))
+		}
+		L.Push(lua.LNumber(v))
+		return 1
+	}
+}
+
+// checkUint32 converts argument n to 32 bits the way Lua 5.2's bit32 does, modulo 2^32
+func checkUint32(L *lua.LState, n int) uint32 {
+	return uint32(int64(L.CheckNumber(n)))
+}
+
+// shift shifts v left by n bits, or right when n is negative
+func shift(v uint32, n int) uint32 {
+	switch {
+	case n <= -32 || n >= 32:
+		return 0
+	case n < 0:
+		return v >> uint(-n)
+	}
+	return v << uint(n)
+}
+
+func positive(L *lua.LState, n int, v int64) int64 {
+	if v < 1 {
+		L.ArgError(n, "interval is empty")
+	}
+	return v
+}
@@ -0,0 +1,280 @@
+// Package script runs Lua scripts alongside the emulator, for trainers, autosplitters, bots and
+// custom HUDs.
+//
+// Scripts are Lua 5.1, run by gopher-lua (github.com/yuin/gopher-lua), with the basic functions
+// and the coroutine, math, string and table libraries. Nothing reaches the file system. Lua 5.1
+// has no bitwise operators, so there's Lua 5.2's bit32 library instead (band, bor, bxor, bnot,
+// btest, lshift and rshift), and math.random is seeded the same every run.
+//
+// A script's top level code runs when it's loaded and registers the hooks it wants:
+//
+//	emu.on_frame(function(frame) ... end)          -- after every 60Hz frame
+//	emu.on_instruction(function(pc, opcode) ... end) -- before every instruction
+//	emu.on_write(function(addr, value) ... end)    -- after an instruction writes to memory
+//	emu.on_key(function(key, down) ... end)        -- when a key is pressed or released
+//
+// From anywhere in the script:
+//
+//	emu.reg(name), emu.set_reg(name, v)  -- v0..vf, i, pc, sp, dt and st
+//	emu.read(addr), emu.write(addr, v)   -- memory
+//	emu.press(key), emu.release(key)     -- the keypad
+//	emu.frame()                          -- frames run since the script was loaded
+//	osd.hud(text)                        -- text kept in the top left corner, "" removes it
+//	osd.notify(text)                     -- a message that fades after a couple of seconds
+//	print(...)                           -- to the script's log
+//
+// A hook that fails, or runs for more than Timeout, stops the script.
+package script
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Timeout is how long the top level code, and each call of a hook, may run
+const Timeout = time.Second
+
+// OSD is where scripts draw text. The SDL frontend's on-screen display implements it.
+type OSD interface {
+	SetHUD(text string)
+	Notify(text string)
+}
+
+// Script is a loaded script
+type Script struct {
+	name   string
+	emu    *chip8.Chip8
+	state  *lua.LState
+	out    io.Writer
+	osd    OSD
+	hooks  map[string]*lua.LFunction // Registered hook functions by name
+	frame  uint64
+	busy   bool        // Inside a hook, where the machine is locked
+	timer  *time.Timer // Cancels ctx when a call runs past Timeout
+	ctx    context.Context
+	cancel context.CancelFunc
+	err    error
+}
+
+// LoadFile loads the script at path, see Load
+func LoadFile(emu *chip8.Chip8, path string, out io.Writer, osd OSD) (*Script, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Load(emu, filepath.Base(path), string(src), out, osd)
+}
+
+// Load runs src and installs the hooks it registers, replacing any hooks set on emu before.
+// The top level code runs straight away, so load scripts before starting the machine.
+// print writes to out, and osd may be nil when there's no display to draw on.
+func Load(emu *chip8.Chip8, name, src string, out io.Writer, osd OSD) (*Script, error) {
+	if out == nil {
+		out = ioutil.Discard
+	}
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	s := &Script{name: name, emu: emu, state: L, out: out, osd: osd, hooks: map[string]*lua.LFunction{}}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.timer = time.AfterFunc(Timeout, s.cancel)
+	s.timer.Stop()
+	L.SetContext(s.ctx)
+	openLibs(L)
+	s.openAPI()
+
+	fn, err := L.Load(strings.NewReader(src), name)
+	if err == nil {
+		err = s.call(fn)
+	}
+	if err != nil {
+		s.close()
+		return nil, fmt.Errorf("script: %v", err)
+	}
+
+	var h chip8.Hooks
+	if s.hooks["frame"] != nil {
+		h.Frame = func(frame uint64) {
+			s.frame++
+			s.hook("frame", lua.LNumber(s.frame))
+		}
+	} else {
+		h.Frame = func(uint64) { s.frame++ }
+	}
+	if s.hooks["instruction"] != nil {
+		h.Instruction = func(pc, opcode uint16) { s.hook("instruction", lua.LNumber(pc), lua.LNumber(opcode)) }
+	}
+	if s.hooks["write"] != nil {
+		h.MemoryWrite = func(addr uint16, value byte) { s.hook("write", lua.LNumber(addr), lua.LNumber(value)) }
+	}
+	if s.hooks["key"] != nil {
+		h.Key = func(key uint8, down bool) { s.hook("key", lua.LNumber(key), lua.LBool(down)) }
+	}
+	emu.SetHooks(h)
+	return s, nil
+}
+
+// Err returns the error that stopped the script, or nil while it's running
+func (s *Script) Err() error {
+	return s.err
+}
+
+// Close removes the script's hooks from the machine
+func (s *Script) Close() {
+	s.emu.SetHooks(chip8.Hooks{})
+	s.close()
+}
+
+func (s *Script) close() {
+	s.timer.Stop()
+	s.cancel()
+	s.state.Close()
+}
+
+// call runs fn with args, stopping it after Timeout
+func (s *Script) call(fn *lua.LFunction, args ...lua.LValue) error {
+	s.timer.Reset(Timeout)
+	err := s.state.CallByParam(lua.P{Fn: fn, Protect: true}, args...)
+	s.timer.Stop()
+	if s.ctx.Err() != nil {
+		return fmt.Errorf("%s: script ran for more than %v", s.name, Timeout)
+	}
+	if e, ok := err.(*lua.ApiError); ok {
+		// Without the stack trace
+		return fmt.Errorf("%s", e.Object)
+	}
+	return err
+}
+
+// hook calls the hook function registered under name. Hooks don't run while another one is
+// running, e.g. for the key a hook presses.
+func (s *Script) hook(name string, args ...lua.LValue) {
+	if s.err != nil || s.busy {
+		return
+	}
+	s.busy = true
+	defer func() { s.busy = false }()
+	if err := s.call(s.hooks[name], args...); err != nil {
+		s.err = fmt.Errorf("script: %v", err)
+		fmt.Fprintf(s.out, "%v (script stopped)\n", s.err)
+	}
+}
+
+// openAPI adds the emu and osd tables and print
+func (s *Script) openAPI() {
+	L := s.state
+	L.SetGlobal("print", L.NewFunction(func(L *lua.LState) int {
+		parts := make([]string, L.GetTop())
+		for i := range parts {
+			parts[i] = L.ToStringMeta(L.Get(i + 1)).String()
+		}
+		fmt.Fprintln(s.out, strings.Join(parts, "\t"))
+		return 0
+	}))
+
+	emu := L.NewTable()
+	L.SetGlobal("emu", emu)
+	for _, name := range []string{"frame", "instruction", "write", "key"} {
+		name := name
+		emu.RawSetString("on_"+name, L.NewFunction(func(L *lua.LState) int {
+			s.hooks[name] = L.OptFunction(1, nil)
+			return 0
+		}))
+	}
+	L.SetFuncs(emu, map[string]lua.LGFunction{
+		"reg": func(L *lua.LState) int {
+			// Not Registers, which locks the machine
+			ch := s.emu
+			regs := chip8.Registers{V: ch.V, PC: ch.PC, I: ch.I, SP: ch.SP, DT: ch.DT, ST: ch.ST}
+			L.Push(lua.LNumber(regs.Get(s.register(L))))
+			return 1
+		},
+		"set_reg": func(L *lua.LState) int {
+			s.setRegister(L, s.register(L), L.CheckInt(2))
+			return 0
+		},
+		"read": func(L *lua.LState) int {
+			L.Push(lua.LNumber(s.emu.Memory[s.addr(L)]))
+			return 1
+		},
+		"write": func(L *lua.LState) int {
+			s.emu.Memory[s.addr(L)] = byte(L.CheckInt(2))
+			return 0
+		},
+		"press": func(L *lua.LState) int {
+			s.key(uint8(L.CheckInt(1)), true)
+			return 0
+		},
+		"release": func(L *lua.LState) int {
+			s.key(uint8(L.CheckInt(1)), false)
+			return 0
+		},
+		"frame": func(L *lua.LState) int {
+			L.Push(lua.LNumber(s.frame))
+			return 1
+		},
+	})
+
+	osd := L.NewTable()
+	L.SetGlobal("osd", osd)
+	L.SetFuncs(osd, map[string]lua.LGFunction{
+		"hud": func(L *lua.LState) int {
+			if s.osd != nil {
+				s.osd.SetHUD(L.CheckString(1))
+			}
+			return 0
+		},
+		"notify": func(L *lua.LState) int {
+			if s.osd != nil {
+				s.osd.Notify(L.CheckString(1))
+			}
+			return 0
+		},
+	})
+}
+
+// addr checks the address argument of read and write
+func (s *Script) addr(L *lua.LState) int {
+	a := L.CheckInt(1)
+	if a < 0 || a >= len(s.emu.Memory) {
+		L.RaiseError("address %#x is outside memory", a)
+	}
+	return a
+}
+
+func (s *Script) key(k uint8, down bool) {
+	switch {
+	case s.busy:
+		s.emu.HookKey(k, down)
+	case down:
+		s.emu.KeyDown(k & 0xF)
+	default:
+		s.emu.KeyUp(k & 0xF)
+	}
+}
+
+// register parses the register name in argument 1
+func (s *Script) register(L *lua.LState) chip8.Register {
+	r, err := chip8.ParseRegister(L.CheckString(1))
+	if err != nil {
+		L.RaiseError("%v", err)
+	}
+	return r
+}
+
+// setRegister sets a register, refusing values that don't fit it like Chip8.SetRegister
+func (s *Script) setRegister(L *lua.LState, r chip8.Register, v int) {
+	set := s.emu.SetRegister
+	if s.busy {
+		set = s.emu.HookSetRegister
+	}
+	if err := set(r, v); err != nil {
+		L.RaiseError("%v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package script
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"arithmetic", `print(1 + 2 * 3, 7 % 3, 2 ^ 10, 1 / 2)`, "7\t1\t1024\t0.5"},
+		{"strings", `print("a" .. 1 .. "b", #"abc", string.format("%03d %02X %s", 7, 255, "x"))`, "a1b\t3\t007 FF x"},
+		{"varargs", `local function f(...) return select("#", ...), select(2, ...) end print(f(7, 8, 9))`, "3\t8\t9"},
+		{"next", `local t = {x = 1} print(next({}), next(t))`, "nil\tx\t1"},
+		{"metatables", `
+			local v = setmetatable({x = 1}, {__add = function(a, b) return a.x + b end, __index = {y = 2}})
+			print(v + 1, v.y, getmetatable(v) ~= nil)`, "2\t2\ttrue"},
+		{"methods", `local c = {n = 0} function c:inc() self.n = self.n + 1 return self end print(c:inc():inc().n)`, "2"},
+		{"coroutines", `
+			local co = coroutine.wrap(function() coroutine.yield(1) coroutine.yield(2) end)
+			print(co(), co())`, "1\t2"},
+		{"pcall", `print(pcall(error, "boom", 0))`, "false\tboom"},
+		{"bit32", `print(bit32.band(0xFF, 0x3C, 0xF0), bit32.bor(0xF0, 0x0F), bit32.bxor(5, 3), bit32.bnot(0),
+			bit32.btest(6, 1), bit32.lshift(1, 4), bit32.rshift(0x80, 7), bit32.rshift(1, 32), bit32.band(-1))`,
+			"48\t255\t6\t4294967295\tfalse\t16\t1\t0\t4294967295"},
+		{"random", `
+			local a = {math.random(), math.random(6), math.random(3, 4)}
+			math.randomseed(1)
+			print(a[1] == math.random(), a[2] >= 1 and a[2] <= 6, a[3] >= 3 and a[3] <= 4)`, "true\ttrue\ttrue"},
+		{"no file system", `print(dofile, loadfile, require, module, io, os)`, "nil\tnil\tnil\tnil\tnil\tnil"},
+	}
+	for _, tt := range tests {
+		var out strings.Builder
+		if _, err := Load(chip8.NewChip8(), tt.name, tt.src, &out, nil); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := strings.TrimSuffix(out.String(), "\n"); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// The same seed every run
+	var first, second strings.Builder
+	for _, out := range []*strings.Builder{&first, &second} {
+		if _, err := Load(chip8.NewChip8(), "random", `print(math.random(1000000))`, out, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if first.String() != second.String() {
+		t.Errorf("math.random gave %q then %q", first.String(), second.String())
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"x = = 1", "test.lua line:1"},
+		{"local t = nil\nprint(t.x)", "test.lua:2: attempt to index"},
+		{"nothing()", "test.lua:1: attempt to call a non-function object"},
+		{"error('boom')", "test.lua:1: boom"},
+		{"while true do end", "test.lua: script ran for more than 1s"},
+		{"while true do pcall(print) end", "script ran for more than"},
+		{"local function f() return f() + 1 end f()", "stack overflow"},
+		{"bit32.band('x')", "bad argument #1 to band"},
+		{"math.random(0)", "interval is empty"},
+		{"emu.on_frame(1)", "function expected"},
+		{"emu.reg('q')", `unknown register "Q"`},
+		{"emu.set_reg('v0', 256)", "doesn't fit V0"},
+		{"emu.set_reg('sp', 17)", "doesn't fit SP"},
+		{"emu.set_reg('pc', 0x1000)", "doesn't fit PC"},
+		{"emu.set_reg('i', -1)", "doesn't fit I"},
+		{"emu.read(4096)", "outside memory"},
+		{"emu.write(0x200)", "number expected"},
+	}
+	for _, tt := range tests {
+		_, err := Load(chip8.NewChip8(), "test.lua", tt.src, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: got error %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+type fakeOSD struct {
+	hud, notified string
+}
+
+func (o *fakeOSD) SetHUD(text string) { o.hud = text }
+func (o *fakeOSD) Notify(text string) { o.notified = text }
+
+func TestHooks(t *testing.T) {
+	// LD V0, K; LD I, 0x300; LD [I], V0; ADD V1, 1; JP 0x206
+	rom := []byte{0xF0, 0x0A, 0xA3, 0x00, 0xF0, 0x55, 0x71, 0x01, 0x12, 0x06}
+	emu := chip8.NewChip8()
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	src := `
+		local writes, keys, adds = 0, "", 0
+		emu.on_instruction(function(pc, op)
+			if bit32.band(op, 0xF000) == 0x7000 then adds = adds + 1 end
+		end)
+		emu.on_write(function(addr, v)
+			writes = writes + 1
+			assert(addr == 0x300 and v == 0xB, "wrote " .. v .. " at " .. addr)
+		end)
+		emu.on_key(function(key, down)
+			keys = keys .. key .. (down and "+" or "-")
+			osd.notify(keys)
+		end)
+		emu.on_frame(function(frame)
+			if frame == 1 then emu.press(0xB) end
+			if frame == 2 then emu.release(0xB) end
+			if frame == 5 then
+				emu.set_reg("v2", emu.reg("v1"))
+				emu.write(0x301, emu.read(0x300) + 1)
+				osd.hud(string.format("adds %d writes %d keys %s", adds, writes, keys))
+			end
+		end)`
+	osd := &fakeOSD{}
+	s, err := Load(emu, "test.lua", src, nil, osd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := emu.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	adds := emu.V[1]
+	if emu.V[2] != adds || emu.Memory[0x301] != 0x0C {
+		t.Errorf("set_reg / write: got V2 = %d (V1 = %d), [0x301] = %#x", emu.V[2], adds, emu.Memory[0x301])
+	}
+	// Keys pressed by the script itself don't reach on_key
+	if want := "adds " + strconv.Itoa(int(adds)) + " writes 1 keys "; osd.hud != want {
+		t.Errorf("hud = %q, want %q", osd.hud, want)
+	}
+
+	emu.KeyDown(3)
+	emu.KeyUp(3)
+	if osd.notified != "3+3-" {
+		t.Errorf("on_key: got %q, want %q", osd.notified, "3+3-")
+	}
+	s.Close()
+	emu.KeyDown(4)
+	if osd.notified != "3+3-" {
+		t.Errorf("hooks ran after Close: %q", osd.notified)
+	}
+}
+
+func TestRegisters(t *testing.T) {
+	emu := chip8.NewChip8()
+	var out strings.Builder
+	src := `
+		emu.set_reg("sp", 16) -- A full stack
+		emu.set_reg("pc", 0xFFE)
+		emu.set_reg("VF", 1)
+		print(emu.reg("sp"), emu.reg("pc"), emu.reg("vf"))`
+	if _, err := Load(emu, "regs.lua", src, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "16\t4094\t1" {
+		t.Errorf("got %q", got)
+	}
+
+	// PC is checked against the profile's memory, not masked to 12 bits
+	small := chip8.DefaultProfile()
+	small.MemorySize = 0x400
+	emu.SetProfile(small)
+	if _, err := Load(emu, "small.lua", `emu.set_reg("pc", 0x400)`, nil, nil); err == nil || emu.PC == 0 {
+		t.Errorf("PC past a 1K profile's memory: got error %v, PC = %#x", err, emu.PC)
+	}
+
+	// From a hook, with the machine locked
+	emu = chip8.NewChip8()
+	if _, err := emu.LoadRomBytes([]byte{0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := Load(emu, "hook.lua", `emu.on_frame(function() emu.set_reg("v3", emu.reg("v3") + 1) end)`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := emu.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Err() != nil || emu.V[3] != 3 {
+		t.Errorf("got V3 = %d, Err() = %v", emu.V[3], s.Err())
+	}
+}
+
+func TestHookError(t *testing.T) {
+	emu := chip8.NewChip8()
+	if _, err := emu.LoadRomBytes([]byte{0x12, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	s, err := Load(emu, "bad.lua", `emu.on_frame(function(f) if f == 2 then error("stop") end end)`, &out, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if err := emu.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.Err() == nil || !strings.Contains(out.String(), "script: bad.lua:1: stop (script stopped)") {
+		t.Errorf("got Err() = %v, log %q", s.Err(), out.String())
+	}
+}
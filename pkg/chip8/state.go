@@ -0,0 +1,250 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+/*
+Save state layout (all multi-byte values are big-endian):
+
+	"C8ST"          4 byte magic
+	version         uint16
+	machineState    fixed-size block, see below
+	rngLen          uint16, 0 when the RNG source can't be serialized
+	rng             rngLen bytes
+	audioState      version 2 and up, see below
+	screenState     version 3 and up, see below
+	screen          width*height bytes, one per pixel row by row
+	keyWaitState    version 4 and up, see below
+	modeState       version 6 and up, see below
+
+Up to version 4 the stack started at Stack[1], leaving Stack[0] unused.
+*/
+
+var stateMagic = [4]byte{'C', '8', 'S', 'T'}
+
+const stateVersion uint16 = 6
+
+// machineState is the fixed-size part of a save state. Fields are only ever appended
+// (together with a stateVersion bump) so older snapshots remain loadable.
+type machineState struct {
+	Memory   [4096]byte
+	V        [16]byte
+	PC       uint16
+	I        uint16
+	SP       uint16
+	Stack    [16]uint16
+	DT       uint8
+	ST       uint8
+	Screen   [64][32]uint8 // Only used up to version 2, later versions keep it dark and append a screenState
+	Keyboard [16]bool
+}
+
+// audioState holds the XO-CHIP audio registers, added in version 2
+type audioState struct {
+	Pattern       [16]byte
+	Pitch         uint8
+	PatternLoaded bool
+}
+
+// screenState describes the framebuffer following it, added in version 3 for screens other than 64x32
+type screenState struct {
+	Width, Height uint16
+	Planes        uint8
+}
+
+// keyWaitState is a pending Fx0A, added in version 4 so a state saved between the press and
+// the release of a key resumes the same way (netplay rolls back to such states)
+type keyWaitState struct {
+	Waiting, Pressed, Released bool
+	Key                        uint8
+}
+
+// modeState was added in version 6. A state saved mid-frame resumes mid-frame, so movies and
+// netplay rollback stay deterministic, and a hires CHIP-8 state keeps its 64x64 screen.
+type modeState struct {
+	Hires       bool
+	FrameCycles uint32
+	RPLFlags    [16]byte
+}
+
+// SaveState serializes the full machine (memory, registers, stack, timers, screen, keyboard, RPL flags and RNG state)
+func (ch *Chip8) SaveState() ([]byte, error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.saveState()
+}
+
+// saveState must be called with ch.mu held
+func (ch *Chip8) saveState() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(stateMagic[:])
+	_ = binary.Write(&buf, binary.BigEndian, stateVersion)
+
+	ms := machineState{
+		Memory:   ch.Memory,
+		V:        ch.V,
+		PC:       ch.PC,
+		I:        ch.I,
+		SP:       ch.SP,
+		Stack:    ch.Stack,
+		DT:       ch.DT,
+		ST:       ch.ST,
+		Keyboard: ch.keyboard,
+	}
+	if err := binary.Write(&buf, binary.BigEndian, &ms); err != nil {
+		return nil, fmt.Errorf("saveState: failed writing machine state: %v", err)
+	}
+
+	var rngState []byte
+	if m, ok := ch.rng.(encoding.BinaryMarshaler); ok {
+		var err error
+		if rngState, err = m.MarshalBinary(); err != nil {
+			return nil, fmt.Errorf("saveState: failed writing rng state: %v", err)
+		}
+	}
+	_ = binary.Write(&buf, binary.BigEndian, uint16(len(rngState)))
+	buf.Write(rngState)
+
+	as := audioState{Pattern: ch.pattern, Pitch: ch.pitch, PatternLoaded: ch.patternLoaded}
+	_ = binary.Write(&buf, binary.BigEndian, &as)
+
+	ss := screenState{Width: uint16(ch.Screen.Width), Height: uint16(ch.Screen.Height), Planes: uint8(ch.Screen.Planes)}
+	_ = binary.Write(&buf, binary.BigEndian, &ss)
+	buf.Write(ch.Screen.Pix)
+
+	kw := keyWaitState{Waiting: ch.keyWait.waiting, Pressed: ch.keyWait.pressed, Released: ch.keyWait.released, Key: ch.keyWait.key}
+	_ = binary.Write(&buf, binary.BigEndian, &kw)
+
+	mode := modeState{Hires: ch.hires, FrameCycles: uint32(ch.frameCycles), RPLFlags: ch.rplFlags}
+	_ = binary.Write(&buf, binary.BigEndian, &mode)
+
+	return buf.Bytes(), nil
+}
+
+// LoadState restores a machine previously serialized with SaveState.
+// The machine is left untouched if the state can't be decoded.
+func (ch *Chip8) LoadState(data []byte) error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.loadState(data)
+}
+
+// loadState must be called with ch.mu held
+func (ch *Chip8) loadState(data []byte) error {
+	r := bytes.NewReader(data)
+
+	var magic [4]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || magic != stateMagic {
+		return fmt.Errorf("loadState: not a save state")
+	}
+	var version uint16
+	if err := binary.Read(r, binary.BigEndian, &version); err != nil {
+		return fmt.Errorf("loadState: failed reading version: %v", err)
+	}
+	if version < 1 || version > stateVersion {
+		return fmt.Errorf("loadState: unsupported version %d", version)
+	}
+
+	var ms machineState
+	if err := binary.Read(r, binary.BigEndian, &ms); err != nil {
+		return fmt.Errorf("loadState: failed reading machine state: %v", err)
+	}
+
+	if version < 5 {
+		copy(ms.Stack[:], ms.Stack[1:])
+		ms.Stack[len(ms.Stack)-1] = 0
+	}
+	if int(ms.SP) > len(ms.Stack) {
+		return fmt.Errorf("loadState: SP %d is past the %d entry stack", ms.SP, len(ms.Stack))
+	}
+	if int(ms.PC) > ch.profile.MemorySize-2 {
+		return fmt.Errorf("loadState: PC %#x is past the end of memory", ms.PC)
+	}
+
+	var rngLen uint16
+	if err := binary.Read(r, binary.BigEndian, &rngLen); err != nil {
+		return fmt.Errorf("loadState: failed reading rng state: %v", err)
+	}
+	rngState := make([]byte, rngLen)
+	if _, err := io.ReadFull(r, rngState); err != nil {
+		return fmt.Errorf("loadState: failed reading rng state: %v", err)
+	}
+	as := audioState{Pitch: DefaultPitch}
+	if version >= 2 {
+		if err := binary.Read(r, binary.BigEndian, &as); err != nil {
+			return fmt.Errorf("loadState: failed reading audio state: %v", err)
+		}
+	}
+	var screen *Framebuffer
+	if version >= 3 {
+		var ss screenState
+		if err := binary.Read(r, binary.BigEndian, &ss); err != nil {
+			return fmt.Errorf("loadState: failed reading screen: %v", err)
+		}
+		if ss.Width == 0 || ss.Height == 0 || ss.Width > 256 || ss.Height > 256 {
+			return fmt.Errorf("loadState: unsupported screen size %dx%d", ss.Width, ss.Height)
+		}
+		screen = NewFramebuffer(int(ss.Width), int(ss.Height), int(ss.Planes))
+		if _, err := io.ReadFull(r, screen.Pix); err != nil {
+			return fmt.Errorf("loadState: failed reading screen: %v", err)
+		}
+	} else {
+		screen = NewFramebuffer(64, 32, 1)
+		for x := range ms.Screen {
+			for y, p := range ms.Screen[x] {
+				screen.Set(x, y, p)
+			}
+		}
+	}
+	var kw keyWaitState
+	if version >= 4 {
+		if err := binary.Read(r, binary.BigEndian, &kw); err != nil {
+			return fmt.Errorf("loadState: failed reading key wait: %v", err)
+		}
+	}
+	// Older states ran hires CHIP-8 on the only 64x64 screen, and left the flags to the FlagStore
+	mode := modeState{Hires: screen.Width == 64 && screen.Height == HiresHeight, RPLFlags: ch.rplFlags}
+	if version >= 6 {
+		if err := binary.Read(r, binary.BigEndian, &mode); err != nil {
+			return fmt.Errorf("loadState: failed reading mode: %v", err)
+		}
+	}
+	if u, ok := ch.rng.(encoding.BinaryUnmarshaler); ok && rngLen > 0 {
+		if err := u.UnmarshalBinary(rngState); err != nil {
+			return fmt.Errorf("loadState: %v", err)
+		}
+	}
+
+	ch.Memory = ms.Memory
+	ch.V = ms.V
+	ch.PC = ms.PC
+	ch.I = ms.I
+	ch.SP = ms.SP
+	ch.Stack = ms.Stack
+	ch.DT = ms.DT
+	ch.ST = ms.ST
+	ch.Screen = screen
+	ch.keyboard = ms.Keyboard
+	ch.keyWait = keyWait{waiting: kw.Waiting, pressed: kw.Pressed, released: kw.Released, key: kw.Key}
+	ch.hires = mode.Hires
+	ch.frameCycles = int(mode.FrameCycles)
+	ch.rplFlags = mode.RPLFlags
+	ch.loop = loopWatch{}
+	ch.idle = idleWatch{}
+	ch.pattern = as.Pattern
+	ch.pitch = as.Pitch
+	ch.patternLoaded = as.PatternLoaded
+	ch.invalidate(AllDirty)
+	ch.publishFrame()
+	ch.updatePattern()
+
+	if ch.audio != nil {
+		ch.audio.Beep(ch.ST > 0)
+	}
+	return nil
+}
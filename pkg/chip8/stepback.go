@@ -0,0 +1,77 @@
+package chip8
+
+import "fmt"
+
+// stepHistory is a rewindBuffer with a snapshot taken before every instruction instead of after
+// every frame, along with where in its frame each one was taken
+type stepHistory struct {
+	rewindBuffer
+	at []framePos // Indexed like states
+}
+
+type framePos struct {
+	frames uint64
+	cycles int
+}
+
+// SetStepHistory keeps a snapshot from before each of the last n instructions so StepBack can
+// undo them one at a time, for debuggers. It's far more expensive than SetRewindBuffer, which
+// only keeps one per frame. Passing 0 disables it and frees the snapshots.
+func (ch *Chip8) SetStepHistory(n int) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if n <= 0 {
+		ch.steps = nil
+		return
+	}
+	ch.steps = &stepHistory{rewindBuffer: rewindBuffer{states: make([][]byte, n)}, at: make([]framePos, n)}
+}
+
+// StepBackAvailable returns how many instructions StepBack can currently undo
+func (ch *Chip8) StepBackAvailable() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.steps == nil {
+		return 0
+	}
+	return ch.steps.count
+}
+
+// StepBack undoes the last instruction, restoring everything a save state holds (registers,
+// memory, timers, screen, keyboard, a pending Fx0A) and the position in the frame, so stepping
+// forward again from there runs the same instructions with the timers ticking at the same points.
+// Frame snapshots for rewinding taken since are discarded and HaltReason is cleared.
+func (ch *Chip8) StepBack() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	sh := ch.steps
+	if sh == nil {
+		return fmt.Errorf("stepBack: step history is not enabled")
+	}
+	if sh.count == 0 {
+		return fmt.Errorf("stepBack: no earlier instruction recorded")
+	}
+	slot := (sh.next - 1 + len(sh.states)) % len(sh.states)
+	state, pos := sh.states[slot], sh.at[slot]
+	if err := ch.loadState(state); err != nil {
+		return fmt.Errorf("stepBack: %v", err)
+	}
+	sh.drop(1)
+	if ch.rewind != nil && pos.frames < ch.frames {
+		ch.rewind.drop(int(ch.frames - pos.frames))
+	}
+	ch.frames, ch.frameCycles = pos.frames, pos.cycles
+	ch.halt = nil
+	return nil
+}
+
+// captureStep must be called with ch.mu held, before an instruction executes
+func (ch *Chip8) captureStep() {
+	sh := ch.steps
+	state, err := ch.saveState()
+	if err != nil {
+		return
+	}
+	sh.at[sh.next] = framePos{frames: ch.frames, cycles: ch.frameCycles}
+	sh.push(state)
+}
@@ -0,0 +1,18 @@
+package chip8
+
+import "github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+
+// SetSymbols gives the machine the labels from the loaded ROM's source (see pkg/chip8/symbols), which the
+// tracer, PostMortem and tools such as the debugger name addresses after. nil removes them.
+func (ch *Chip8) SetSymbols(t *symbols.Table) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.syms = t
+}
+
+// Symbols returns the labels set by SetSymbols, nil if there are none
+func (ch *Chip8) Symbols() *symbols.Table {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.syms
+}
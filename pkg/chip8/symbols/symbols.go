@@ -0,0 +1,217 @@
+// Package symbols maps the addresses of an assembled ROM to the labels they had in its source, so
+// the disassembler, tracer, crash screen and debugger can say `draw_score` instead of 0x2A4.
+//
+// Symbol files are text, a label per line as written by Table.WriteTo:
+//
+//	0x200 main
+//	0x2A4 draw_score    # comments start with # or ;
+//
+// `draw_score = 0x2A4` lines and a JSON object of labels to addresses ({"draw_score": 676}) are
+// read as well. `chip8emu asm -symbols` writes them for both assembler syntaxes.
+package symbols
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a label and its address
+type Symbol struct {
+	Name string
+	Addr uint16
+}
+
+// Table is a set of symbols. The methods reading it also work on a nil Table, which has none.
+type Table struct {
+	names  map[uint16]string // The first label given to each address
+	addrs  map[string]uint16
+	sorted []Symbol // By address, then name
+}
+
+// New returns an empty table
+func New() *Table {
+	return &Table{names: map[uint16]string{}, addrs: map[string]uint16{}}
+}
+
+// FromMap builds a table from an assembler's labels
+func FromMap(labels map[string]int) *Table {
+	t := New()
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names) // So an address with several labels always gets the same name
+	for _, name := range names {
+		t.Add(name, uint16(labels[name]))
+	}
+	return t
+}
+
+// Add labels addr with name, replacing an earlier label of that name. An address keeps the first
+// name it was given for Name, later ones can still be looked up with Addr. Tables are safe to
+// share between goroutines once built.
+func (t *Table) Add(name string, addr uint16) {
+	if old, ok := t.addrs[name]; ok {
+		t.remove(Symbol{name, old})
+	}
+	t.addrs[name] = addr
+	if _, ok := t.names[addr]; !ok {
+		t.names[addr] = name
+	}
+	s := Symbol{name, addr}
+	i := sort.Search(len(t.sorted), func(i int) bool { return !t.sorted[i].less(s) })
+	t.sorted = append(t.sorted, Symbol{})
+	copy(t.sorted[i+1:], t.sorted[i:])
+	t.sorted[i] = s
+}
+
+func (t *Table) remove(s Symbol) {
+	for i, x := range t.sorted {
+		if x == s {
+			t.sorted = append(t.sorted[:i], t.sorted[i+1:]...)
+			break
+		}
+	}
+	if t.names[s.Addr] == s.Name {
+		delete(t.names, s.Addr)
+		for _, x := range t.sorted {
+			if x.Addr == s.Addr {
+				t.names[s.Addr] = x.Name
+				break
+			}
+		}
+	}
+}
+
+func (s Symbol) less(o Symbol) bool {
+	return s.Addr < o.Addr || s.Addr == o.Addr && s.Name < o.Name
+}
+
+// Len returns the number of labels
+func (t *Table) Len() int {
+	if t == nil {
+		return 0
+	}
+	return len(t.addrs)
+}
+
+// Name returns the label at addr
+func (t *Table) Name(addr uint16) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	name, ok := t.names[addr]
+	return name, ok
+}
+
+// Addr returns the address of the label name
+func (t *Table) Addr(name string) (uint16, bool) {
+	if t == nil {
+		return 0, false
+	}
+	addr, ok := t.addrs[name]
+	return addr, ok
+}
+
+// Locate describes addr by the nearest label at or before it: "draw_score" or "draw_score+6".
+// It returns "" when there's no label before addr.
+func (t *Table) Locate(addr uint16) string {
+	syms := t.Symbols()
+	i := sort.Search(len(syms), func(i int) bool { return syms[i].Addr > addr }) - 1
+	if i < 0 {
+		return ""
+	}
+	base := syms[i].Addr
+	if base != addr {
+		return fmt.Sprintf("%s+%d", t.names[base], addr-base)
+	}
+	return t.names[base]
+}
+
+// Symbols returns the labels sorted by address, and by name at the same address
+func (t *Table) Symbols() []Symbol {
+	if t == nil {
+		return nil
+	}
+	return t.sorted
+}
+
+// WriteTo writes the table as a symbol file
+func (t *Table) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, s := range t.Symbols() {
+		fmt.Fprintf(&buf, "0x%03X %s\n", s.Addr, s.Name)
+	}
+	return buf.WriteTo(w)
+}
+
+// Parse reads a symbol file
+func Parse(r io.Reader) (*Table, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("symbols: %v", err)
+	}
+	t := New()
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var labels map[string]int
+		if err := json.Unmarshal(trimmed, &labels); err != nil {
+			return nil, fmt.Errorf("symbols: %v", err)
+		}
+		for name, addr := range labels {
+			if addr < 0 || addr > 0xFFFF {
+				return nil, fmt.Errorf("symbols: %s: address %d out of range", name, addr)
+			}
+		}
+		return FromMap(labels), nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.IndexAny(text, "#;"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(strings.Replace(text, "=", " = ", 1))
+		if len(fields) == 0 {
+			continue
+		}
+		var name, addr string
+		switch {
+		case len(fields) == 2:
+			addr, name = fields[0], fields[1]
+		case len(fields) == 3 && fields[1] == "=":
+			name, addr = fields[0], fields[2]
+		default:
+			return nil, fmt.Errorf("symbols: line %d: expected an address and a label", line)
+		}
+		a, err := parseAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("symbols: line %d: invalid address %q", line, addr)
+		}
+		t.Add(name, a)
+	}
+	return t, nil
+}
+
+// parseAddr parses an address in hex, with or without a 0x prefix
+func parseAddr(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.ToLower(s), "0x")
+	v, err := strconv.ParseUint(s, 16, 16)
+	return uint16(v), err
+}
+
+// Load reads the symbol file at path
+func Load(path string) (*Table, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("symbols: %v", err)
+	}
+	return Parse(bytes.NewReader(data))
+}
@@ -0,0 +1,55 @@
+package symbols
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, src := range []string{
+		"0x200 main\n0x2A4 draw_score ; the HUD\n\n# done\n2a0 loop\n",
+		"main = 0x200\ndraw_score=0x2a4\nloop = 2A0",
+		`{"main": 512, "draw_score": 676, "loop": 672}`,
+	} {
+		tab, err := Parse(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		if addr, ok := tab.Addr("draw_score"); !ok || addr != 0x2A4 || tab.Len() != 3 {
+			t.Errorf("%q: draw_score at %#x (%v) of %d labels", src, addr, ok, tab.Len())
+		}
+		var buf bytes.Buffer
+		tab.WriteTo(&buf)
+		if want := "0x200 main\n0x2A0 loop\n0x2A4 draw_score\n"; buf.String() != want {
+			t.Errorf("%q: wrote %q, want %q", src, buf.String(), want)
+		}
+	}
+	for _, src := range []string{"main", "0x200 main extra", "0xZZZ main", `{"main": -1}`} {
+		if _, err := Parse(strings.NewReader(src)); err == nil {
+			t.Errorf("%q parsed", src)
+		}
+	}
+}
+
+func TestLocate(t *testing.T) {
+	tab := FromMap(map[string]int{"main": 0x200, "start": 0x200, "draw": 0x210})
+	if name, _ := tab.Name(0x200); name != "main" {
+		t.Errorf("Name(0x200) = %q, want main", name)
+	}
+	tests := map[uint16]string{0x1FE: "", 0x200: "main", 0x206: "main+6", 0x210: "draw", 0x300: "draw+240"}
+	for addr, want := range tests {
+		if got := tab.Locate(addr); got != want {
+			t.Errorf("Locate(%#x) = %q, want %q", addr, got, want)
+		}
+	}
+	tab.Add("main", 0x220)
+	if name, _ := tab.Name(0x200); name != "start" || tab.Locate(0x222) != "main+2" {
+		t.Errorf("after moving main: Name(0x200) = %q, Locate(0x222) = %q", name, tab.Locate(0x222))
+	}
+
+	var empty *Table
+	if _, ok := empty.Name(0x200); ok || empty.Locate(0x200) != "" || empty.Len() != 0 {
+		t.Error("a nil table has symbols")
+	}
+}
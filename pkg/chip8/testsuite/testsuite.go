@@ -0,0 +1,232 @@
+// Package testsuite runs the CHIP-8 test ROMs from Timendus' test suite
+// (https://github.com/Timendus/chip8-test-suite) headlessly and compares the final screen of
+// each against a stored golden screen, so compatibility can be regression tested.
+//
+// The ROMs aren't distributed with this repository. Put them in a directory along with the
+// goldens (testdata/ holds the goldens for this package's own tests) and point Check at it.
+package testsuite
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+// Quirk sets of the platforms the suite knows about
+var (
+	// CHIP8 is the original COSMAC VIP interpreter
+	CHIP8 = chip8.Quirks{ShiftUsesVy: true, LoadStoreIncrementsI: true, VFReset: true, ClipSprites: true, DisplayWait: true}
+	// SCHIP is modern SUPER-CHIP, as run by Octo and most emulators
+	SCHIP = chip8.Quirks{JumpUsesVx: true, ClipSprites: true}
+)
+
+// Case is one run of a test ROM
+type Case struct {
+	Name     string       // Also names the golden, <Name>.txt
+	ROM      string       // File name of the ROM in the suite directory
+	Quirks   chip8.Quirks // Quirks enabled for the run
+	Platform byte         // Written to 0x1FF before running, which skips the ROM's platform menu. 0 leaves it alone
+	Frames   int          // 60Hz frames to run before taking the screen
+}
+
+// Cases lists the ROMs of the suite that run without input. The quirks test is run once per
+// platform, its result screen shows which quirk checks passed.
+var Cases = []Case{
+	{Name: "chip8-logo", ROM: "1-chip8-logo.ch8", Frames: 60},
+	{Name: "ibm-logo", ROM: "2-ibm-logo.ch8", Frames: 60},
+	{Name: "corax+", ROM: "3-corax+.ch8", Frames: 60},
+	{Name: "flags", ROM: "4-flags.ch8", Frames: 60},
+	{Name: "quirks-chip8", ROM: "5-quirks.ch8", Quirks: CHIP8, Platform: 1, Frames: 600},
+	{Name: "quirks-schip", ROM: "5-quirks.ch8", Quirks: SCHIP, Platform: 2, Frames: 600},
+}
+
+// instructionsPerFrame is fast enough for every ROM to finish within its frames, except where
+// DisplayWait holds it back on purpose
+const instructionsPerFrame = 1000
+
+// Status is the outcome of a Case
+type Status int
+
+const (
+	Pass     Status = iota
+	Fail            // The screen doesn't match the golden, or the ROM crashed
+	Skipped         // The ROM isn't in the suite directory
+	NoGolden        // There's nothing to compare the screen with yet
+	Updated         // The golden was (re)written from the screen
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Fail:
+		return "FAIL"
+	case Skipped:
+		return "SKIP"
+	case NoGolden:
+		return "NEW"
+	case Updated:
+		return "UPDATED"
+	}
+	return fmt.Sprintf("Status(%d)", int(s))
+}
+
+// Result is the outcome of checking a Case
+type Result struct {
+	Case   Case
+	Status Status
+	Detail string             // Why it failed or was skipped
+	Screen *chip8.Framebuffer // nil when the ROM didn't run
+}
+
+// Run runs c with the ROM read from dir and returns the final screen
+func Run(dir string, c Case) (*chip8.Framebuffer, error) {
+	rom, err := ioutil.ReadFile(filepath.Join(dir, c.ROM))
+	if err != nil {
+		return nil, err
+	}
+	emu := chip8.NewChip8()
+	emu.SeedRand(1)
+	emu.SetQuirks(c.Quirks)
+	emu.SetInstructionsPerFrame(instructionsPerFrame)
+	if _, err := emu.LoadRomBytes(rom); err != nil {
+		return nil, err
+	}
+	if c.Platform != 0 {
+		emu.Memory[0x1FF] = c.Platform
+	}
+	for i := 0; i < c.Frames; i++ {
+		if err := emu.RunFrame(); err != nil {
+			screen, _ := emu.SnapshotScreen()
+			return screen, fmt.Errorf("frame %d: %v", i, err)
+		}
+	}
+	screen, _ := emu.SnapshotScreen()
+	return screen, nil
+}
+
+// Check runs c and compares the screen with its golden in dir. With update the golden is
+// written from the screen instead.
+func Check(dir string, c Case, update bool) Result {
+	r := Result{Case: c}
+	if _, err := os.Stat(filepath.Join(dir, c.ROM)); os.IsNotExist(err) {
+		r.Status, r.Detail = Skipped, c.ROM+" not found"
+		return r
+	}
+	screen, err := Run(dir, c)
+	r.Screen = screen
+	if err != nil {
+		r.Status, r.Detail = Fail, err.Error()
+		return r
+	}
+
+	goldenPath := filepath.Join(dir, c.Name+".txt")
+	if update {
+		if err := ioutil.WriteFile(goldenPath, []byte(FormatScreen(screen)), 0644); err != nil {
+			r.Status, r.Detail = Fail, fmt.Sprintf("testsuite: failed writing golden: %v", err)
+			return r
+		}
+		r.Status = Updated
+		return r
+	}
+	data, err := ioutil.ReadFile(goldenPath)
+	if os.IsNotExist(err) {
+		r.Status, r.Detail = NoGolden, "no golden "+c.Name+".txt, run with -update to create it"
+		return r
+	}
+	if err != nil {
+		r.Status, r.Detail = Fail, fmt.Sprintf("testsuite: failed reading golden: %v", err)
+		return r
+	}
+	golden, err := ParseScreen(string(data))
+	if err != nil {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%v: %v", goldenPath, err)
+		return r
+	}
+	if screen.Width != golden.Width || screen.Height != golden.Height {
+		r.Status, r.Detail = Fail, fmt.Sprintf("the screen is %dx%d, the golden %dx%d", screen.Width, screen.Height, golden.Width, golden.Height)
+		return r
+	}
+	if diff := countDiff(screen, golden); diff > 0 {
+		r.Status, r.Detail = Fail, fmt.Sprintf("%d pixels differ from the golden", diff)
+		return r
+	}
+	r.Status = Pass
+	return r
+}
+
+// CheckAll checks every Case in Cases
+func CheckAll(dir string, update bool) []Result {
+	results := make([]Result, len(Cases))
+	for i, c := range Cases {
+		results[i] = Check(dir, c, update)
+	}
+	return results
+}
+
+// countDiff counts the pixels lit in only one of a and b, which must be the same size
+func countDiff(a, b *chip8.Framebuffer) int {
+	n := 0
+	for i := range a.Pix {
+		if (a.Pix[i] != 0) != (b.Pix[i] != 0) {
+			n++
+		}
+	}
+	return n
+}
+
+// FormatScreen draws screen as text, one line per row with '#' for lit pixels and '.' for dark ones.
+// A nil screen is drawn as "".
+func FormatScreen(screen *chip8.Framebuffer) string {
+	if screen == nil {
+		return ""
+	}
+	var sb strings.Builder
+	for y := 0; y < screen.Height; y++ {
+		for x := 0; x < screen.Width; x++ {
+			if screen.At(x, y) != 0 {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// ParseScreen reads a 64x32 or 128x64 screen drawn by FormatScreen
+func ParseScreen(text string) (*chip8.Framebuffer, error) {
+	lines := strings.Split(strings.TrimRight(strings.Replace(text, "\r\n", "\n", -1), "\n"), "\n")
+	width, height := 64, 32
+	if len(lines) == 64 {
+		// 128x64 SCHIP hires, or 64x64 hires CHIP-8
+		width, height = 128, 64
+		if len(lines[0]) == 64 {
+			width = 64
+		}
+	}
+	if len(lines) != height {
+		return nil, fmt.Errorf("expected 32 or 64 rows, got %d", len(lines))
+	}
+	screen := chip8.NewFramebuffer(width, height, 1)
+	for y, line := range lines {
+		if len(line) != width {
+			return nil, fmt.Errorf("row %d: expected %d columns, got %d", y+1, width, len(line))
+		}
+		for x := 0; x < width; x++ {
+			switch line[x] {
+			case '#':
+				screen.Set(x, y, 1)
+			case '.':
+			default:
+				return nil, fmt.Errorf("row %d: unexpected %q", y+1, line[x])
+			}
+		}
+	}
+	return screen, nil
+}
@@ -0,0 +1,84 @@
+package testsuite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dustinbowers/chip8emu/demo"
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+)
+
+func TestSuite(t *testing.T) {
+	for _, c := range Cases {
+		t.Run(c.Name, func(t *testing.T) {
+			r := Check("testdata", c, false)
+			switch r.Status {
+			case Pass:
+			case Skipped:
+				t.Skip(r.Detail)
+			default:
+				t.Errorf("%v: %v\n%s", r.Status, r.Detail, FormatScreen(r.Screen))
+			}
+		})
+	}
+}
+
+func TestCheckGolden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "testsuite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rom, err := demo.ROM("ibm-logo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "ibm.ch8"), rom, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := Case{Name: "ibm", ROM: "ibm.ch8", Frames: 10}
+
+	if r := Check(dir, Case{Name: "missing", ROM: "missing.ch8"}, false); r.Status != Skipped {
+		t.Errorf("missing ROM: got %v, want %v", r.Status, Skipped)
+	}
+	if r := Check(dir, c, false); r.Status != NoGolden {
+		t.Errorf("before update: got %v (%v), want %v", r.Status, r.Detail, NoGolden)
+	}
+	if r := Check(dir, c, true); r.Status != Updated {
+		t.Fatalf("update: got %v (%v), want %v", r.Status, r.Detail, Updated)
+	}
+	r := Check(dir, c, false)
+	if r.Status != Pass {
+		t.Fatalf("after update: got %v (%v), want %v", r.Status, r.Detail, Pass)
+	}
+
+	// Flip a pixel in the golden
+	r.Screen.Set(0, 0, r.Screen.At(0, 0)^1)
+	if err := ioutil.WriteFile(filepath.Join(dir, "ibm.txt"), []byte(FormatScreen(r.Screen)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if r := Check(dir, c, false); r.Status != Fail || r.Detail != "1 pixels differ from the golden" {
+		t.Errorf("changed golden: got %v (%v), want %v", r.Status, r.Detail, Fail)
+	}
+}
+
+func TestParseScreen(t *testing.T) {
+	for _, size := range [][2]int{{64, 32}, {64, 64}, {128, 64}} {
+		screen := chip8.NewFramebuffer(size[0], size[1], 1)
+		screen.Set(0, 0, 1)
+		screen.Set(size[0]-1, size[1]-1, 1)
+		screen.Set(10, 20, 1)
+		got, err := ParseScreen(FormatScreen(screen))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(screen) {
+			t.Errorf("%dx%d screen changed in a FormatScreen / ParseScreen round trip", size[0], size[1])
+		}
+	}
+	if _, err := ParseScreen("#.\n"); err == nil {
+		t.Error("ParseScreen accepted a 2x1 screen")
+	}
+}
@@ -0,0 +1,125 @@
+package chip8
+
+// FrameRate is the rate the delay and sound timers count down at, and the rate frontends should call RunFrame
+const FrameRate = 60
+
+// DefaultInstructionsPerFrame gives roughly the 700Hz this emulator has always run at
+const DefaultInstructionsPerFrame = 11
+
+/*
+Timing model:
+
+The machine runs in 60Hz frames. Each frame executes up to instructionsPerFrame instructions,
+after which the frame ends: DT and ST count down once and the next frame begins.
+With the DisplayWait quirk a Dxyn ends the frame early, just like the COSMAC VIP which
+waited for the vertical blank interrupt before drawing.
+
+Frontends call RunFrame() once per 60Hz tick, or let Run() keep the time for them. Step() and
+RunFor() advance through frames one instruction at a time, so timers stay in sync regardless of
+how the machine is driven.
+
+DT and ST count frames, not wall clock time: nothing runs on a timer of its own, so they freeze
+exactly while the machine is paused and a movie played back at any speed sees the same values.
+*/
+
+// SetInstructionsPerFrame sets how many instructions run per 60Hz frame (i.e. the clock speed is n * 60 Hz)
+func (ch *Chip8) SetInstructionsPerFrame(n int) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if n < 1 {
+		n = 1
+	}
+	ch.instructionsPerFrame = n
+	ch.log.Debug(LogTimer, "clock speed set", "hz", n*FrameRate)
+}
+
+// InstructionsPerFrame returns how many instructions run per 60Hz frame
+func (ch *Chip8) InstructionsPerFrame() int {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.instructionsPerFrame
+}
+
+// SetClockSpeed sets the CPU speed in instructions per second. The speed is rounded to a whole
+// number of instructions per 60Hz frame, so it's always a multiple of FrameRate.
+func (ch *Chip8) SetClockSpeed(hz int) {
+	ch.SetInstructionsPerFrame((hz + FrameRate/2) / FrameRate)
+}
+
+// ClockSpeed returns the CPU speed in instructions per second
+func (ch *Chip8) ClockSpeed() int {
+	return ch.InstructionsPerFrame() * FrameRate
+}
+
+// Frames returns the number of 60Hz frames completed since the machine was created
+func (ch *Chip8) Frames() uint64 {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.frames
+}
+
+// RunFrame executes instructions until the current 60Hz frame ends, or an unknown opcode halts the machine
+func (ch *Chip8) RunFrame() error {
+	frame := ch.Frames()
+	for ch.Frames() == frame {
+		if err := ch.Step(); err != nil {
+			return err
+		}
+		if ch.HaltReason() != nil {
+			return nil // The next Step would block until Resume
+		}
+	}
+	return nil
+}
+
+// AdvanceFrame runs the rest of the current frame even while the machine is paused,
+// for frame-by-frame stepping
+func (ch *Chip8) AdvanceFrame() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	frame := ch.frames
+	for ch.frames == frame {
+		if err := ch.step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdvanceInstruction executes a single instruction even while the machine is paused.
+// A change to the screen is published straight away, without waiting for the frame to end.
+func (ch *Chip8) AdvanceInstruction() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	err := ch.step()
+	ch.publishFrame()
+	return err
+}
+
+// countCycle is called after every executed instruction
+func (ch *Chip8) countCycle() {
+	ch.frameCycles++
+	if ch.frameCycles >= ch.instructionsPerFrame {
+		ch.endFrame()
+	}
+}
+
+// waitForVBlank ends the current frame once the instruction being executed completes
+func (ch *Chip8) waitForVBlank() {
+	ch.frameCycles = ch.instructionsPerFrame
+}
+
+func (ch *Chip8) endFrame() {
+	ch.frameCycles = 0
+	ch.frames++
+	ch.idle.endFrame(ch.DT > 0 || ch.ST > 0)
+	ch.decrementTimers()
+	ch.releasePushed()
+	if ch.hooks.Frame != nil {
+		ch.hooks.Frame(ch.frames)
+	}
+	ch.publishFrame()
+	if ch.rewind != nil {
+		ch.captureRewind()
+	}
+}
@@ -0,0 +1,139 @@
+package chip8
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/disasm"
+	"github.com/dustinbowers/chip8emu/pkg/chip8/symbols"
+)
+
+// tracer records every executed instruction, either streaming a line for each to a writer
+// or keeping the most recent ones in a ring buffer until an instruction fails
+type tracer struct {
+	w    io.Writer
+	ring []traceEntry // nil unless ring-buffer mode is enabled
+	next int          // Next slot to overwrite in ring
+	full bool         // ring has wrapped at least once
+
+	regs traceRegs // From before the current instruction
+}
+
+// traceRegs are the registers a trace line reports changes to
+type traceRegs struct {
+	pc, i, sp uint16
+	v         [16]byte
+	dt, st    uint8
+}
+
+// traceEntry is an executed instruction, only formatted when it's written out so a ring costs
+// little more than a copy per instruction
+type traceEntry struct {
+	opcode        uint16
+	before, after traceRegs
+	err           error
+}
+
+// SetTraceWriter emits one line per executed instruction to w (PC, opcode, disassembly and changed registers).
+// Passing nil disables tracing.
+func (ch *Chip8) SetTraceWriter(w io.Writer) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if w == nil {
+		ch.trace = nil
+		return
+	}
+	if ch.trace == nil {
+		ch.trace = &tracer{}
+	}
+	ch.trace.w = w
+}
+
+// SetTraceRing switches tracing to ring-buffer mode: only the last n instructions are kept, and they're
+// dumped to the trace writer when an instruction fails. n <= 0 switches back to streaming every line.
+func (ch *Chip8) SetTraceRing(n int) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.trace == nil {
+		return
+	}
+	ch.trace.ring = nil
+	ch.trace.next, ch.trace.full = 0, false
+	if n > 0 {
+		ch.trace.ring = make([]traceEntry, n)
+	}
+}
+
+func traceRegsOf(ch *Chip8) traceRegs {
+	return traceRegs{pc: ch.PC, i: ch.I, sp: ch.SP, v: ch.V, dt: ch.DT, st: ch.ST}
+}
+
+// before is called ahead of fetching the next instruction
+func (t *tracer) before(ch *Chip8) {
+	t.regs = traceRegsOf(ch)
+}
+
+// after is called once the instruction has executed (or failed with err)
+func (t *tracer) after(ch *Chip8, err error) {
+	e := traceEntry{opcode: ch.opcode, before: t.regs, after: traceRegsOf(ch), err: err}
+	if t.ring == nil {
+		fmt.Fprintln(t.w, e.format(ch.syms))
+		return
+	}
+
+	t.ring[t.next] = e
+	t.next = (t.next + 1) % len(t.ring)
+	if t.next == 0 {
+		t.full = true
+	}
+	if err != nil {
+		t.dump(ch.syms)
+	}
+}
+
+// format gives the trace line of e: PC, opcode, disassembly and the registers that changed
+func (e traceEntry) format(syms *symbols.Table) string {
+	b, a := e.before, e.after
+	in := disasm.Decode(b.pc, e.opcode)
+	line := fmt.Sprintf("0x%03X  %04X  %-20s", b.pc, e.opcode, in.WithSymbols(syms).Text())
+
+	var changes []string
+	for r := range a.v {
+		if a.v[r] != b.v[r] {
+			changes = append(changes, fmt.Sprintf("V%X=%02X", r, a.v[r]))
+		}
+	}
+	if a.i != b.i {
+		changes = append(changes, fmt.Sprintf("I=%03X", a.i))
+	}
+	if a.sp != b.sp {
+		changes = append(changes, fmt.Sprintf("SP=%d", a.sp))
+	}
+	if a.dt != b.dt {
+		changes = append(changes, fmt.Sprintf("DT=%02X", a.dt))
+	}
+	if a.st != b.st {
+		changes = append(changes, fmt.Sprintf("ST=%02X", a.st))
+	}
+	if a.pc != b.pc+2 {
+		changes = append(changes, fmt.Sprintf("PC=%03X", a.pc))
+	}
+	line = strings.TrimRight(line+" "+strings.Join(changes, " "), " ")
+	if e.err != nil {
+		line += "  <- " + e.err.Error()
+	}
+	return line
+}
+
+// dump writes out the ring buffer, oldest instruction first
+func (t *tracer) dump(syms *symbols.Table) {
+	entries := t.ring[:t.next]
+	if t.full {
+		entries = append(append([]traceEntry{}, t.ring[t.next:]...), t.ring[:t.next]...)
+	}
+	fmt.Fprintf(t.w, "--- last %d instructions ---\n", len(entries))
+	for _, e := range entries {
+		fmt.Fprintln(t.w, e.format(syms))
+	}
+}
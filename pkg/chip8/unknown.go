@@ -0,0 +1,81 @@
+package chip8
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnknownOpcodePolicy decides what happens when the machine reaches an opcode it doesn't know.
+// Some old ROMs have data mixed in with their code, or words that were never meant to run.
+type UnknownOpcodePolicy int
+
+const (
+	UnknownOpcodeError UnknownOpcodePolicy = iota // Stop with an ErrUnknownOpcode fault (the default)
+	UnknownOpcodeSkip                             // Treat the word as a NOP
+	UnknownOpcodeHalt                             // Pause after the word so it can be inspected, see HaltReason
+)
+
+var unknownOpcodePolicyNames = []string{"error", "skip", "halt"}
+
+func (p UnknownOpcodePolicy) String() string {
+	if p >= 0 && int(p) < len(unknownOpcodePolicyNames) {
+		return unknownOpcodePolicyNames[p]
+	}
+	return fmt.Sprintf("UnknownOpcodePolicy(%d)", int(p))
+}
+
+// UnknownOpcodePolicyNames lists the names accepted by ParseUnknownOpcodePolicy
+func UnknownOpcodePolicyNames() []string {
+	return append([]string(nil), unknownOpcodePolicyNames...)
+}
+
+// ParseUnknownOpcodePolicy looks up a policy by name: error, skip or halt
+func ParseUnknownOpcodePolicy(name string) (UnknownOpcodePolicy, error) {
+	for i, n := range unknownOpcodePolicyNames {
+		if strings.EqualFold(name, n) {
+			return UnknownOpcodePolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown opcode policy %q (expected one of: %v)", name, strings.Join(unknownOpcodePolicyNames, ", "))
+}
+
+// SetUnknownOpcodePolicy sets what happens on an unknown opcode
+func (ch *Chip8) SetUnknownOpcodePolicy(p UnknownOpcodePolicy) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	ch.unknownOpcodes = p
+}
+
+// UnknownOpcodePolicy returns what happens on an unknown opcode
+func (ch *Chip8) UnknownOpcodePolicy() UnknownOpcodePolicy {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	return ch.unknownOpcodes
+}
+
+// HaltReason returns the *EmuError that paused the machine under UnknownOpcodeHalt, or nil.
+// Resume and Reset clear it.
+func (ch *Chip8) HaltReason() error {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.halt == nil {
+		return nil // Not a nil *EmuError in a non-nil error
+	}
+	return ch.halt
+}
+
+func opUnknown(ch *Chip8) error {
+	f := fault(ErrUnknownOpcode, "%04X", ch.opcode)
+	switch ch.unknownOpcodes {
+	case UnknownOpcodeSkip:
+		ch.log.Warn(LogCPU, "unknown opcode skipped", "pc", fmt.Sprintf("%#03x", ch.PC-2), "opcode", fmt.Sprintf("%04X", ch.opcode))
+		return nil
+	case UnknownOpcodeHalt:
+		f.PC, f.Opcode = ch.PC-2, ch.opcode
+		ch.halt = f
+		ch.pause()
+		ch.log.Warn(LogCPU, "unknown opcode halted the machine", "pc", fmt.Sprintf("%#03x", f.PC), "opcode", fmt.Sprintf("%04X", f.Opcode))
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,43 @@
+// Package display defines what the emulator core presents to the player, the screen and the sound,
+// and the Framebuffer the screen is drawn in.
+//
+// The chip8 package drives these interfaces and every frontend (SDL, terminal, browser) implements them,
+// so neither side has to know about the other and the core can be tested with simple fakes.
+// The keypad goes the other way, see package input.
+package display
+
+// Display presents the screen. Its size can change between calls (SCHIP's hires mode), and
+// screen is only valid until Draw returns: Clone it to keep it.
+type Display interface {
+	Draw(screen *Framebuffer) error
+}
+
+// AudioSink plays the CHIP-8 beep. Beep(true) is called when the sound timer starts and Beep(false) when it stops.
+type AudioSink interface {
+	Beep(on bool)
+}
+
+// PatternPlayer is an AudioSink that can also play XO-CHIP audio patterns. Sinks that don't
+// implement it keep playing their plain beep.
+type PatternPlayer interface {
+	AudioSink
+	// SetPattern switches the beep to pattern, a loop of 128 1-bit samples (most significant bit first)
+	// played at rate samples per second. A nil pattern switches back to the plain beep.
+	SetPattern(pattern []byte, rate float64)
+}
+
+// SamplePlayer is an AudioSink that can also play MegaChip's digitized sound
+type SamplePlayer interface {
+	AudioSink
+	// PlaySamples plays unsigned 8-bit mono samples at rate samples per second, over and over
+	// when loop is set. It replaces whatever was playing, nil samples stop it. samples is only
+	// valid until PlaySamples returns: copy it to keep it.
+	PlaySamples(samples []byte, rate int, loop bool)
+}
+
+// BeepFunc adapts a plain function to an AudioSink
+type BeepFunc func(on bool)
+
+func (f BeepFunc) Beep(on bool) {
+	f(on)
+}
@@ -0,0 +1,130 @@
+package display
+
+// Screen sizes of the CHIP-8 family
+const (
+	LoResWidth, LoResHeight = 64, 32  // CHIP-8, and SCHIP's low resolution mode
+	HiResWidth, HiResHeight = 128, 64 // SCHIP and XO-CHIP's high resolution mode
+)
+
+// Framebuffer is a display of Width x Height pixels, stored row by row so that scrolling is
+// a few copies. Each pixel holds a bitmask of the planes it's lit in: bit 0 on a single plane
+// display, bits 0 and 1 on XO-CHIP's two. The same operations serve every resolution, amounts
+// are in the framebuffer's own pixels.
+type Framebuffer struct {
+	Width, Height int
+	Planes        int     // Bit planes each pixel can be lit in
+	Pix           []uint8 // Pixel (x, y) is Pix[y*Width+x]
+
+	// Palette holds the 0xAARRGGBB color of each pixel value when the ROM picks its own colors
+	// (MegaChip), and is nil when the frontend's palette applies
+	Palette []uint32
+}
+
+// NewFramebuffer returns a dark width x height framebuffer with the given number of planes
+func NewFramebuffer(width, height, planes int) *Framebuffer {
+	return &Framebuffer{Width: width, Height: height, Planes: planes, Pix: make([]uint8, width*height)}
+}
+
+// Clone returns a copy of fb that shares nothing with it, for handing the screen to another goroutine
+func (fb *Framebuffer) Clone() *Framebuffer {
+	c := *fb
+	c.Pix = append([]uint8(nil), fb.Pix...)
+	if fb.Palette != nil {
+		c.Palette = append([]uint32(nil), fb.Palette...)
+	}
+	return &c
+}
+
+// Equal reports whether fb and other have the same size and pixels
+func (fb *Framebuffer) Equal(other *Framebuffer) bool {
+	if fb.Width != other.Width || fb.Height != other.Height || fb.Planes != other.Planes {
+		return false
+	}
+	return string(fb.Pix) == string(other.Pix)
+}
+
+// At returns the planes pixel (x, y) is lit in
+func (fb *Framebuffer) At(x, y int) uint8 {
+	return fb.Pix[y*fb.Width+x]
+}
+
+// Set sets the planes pixel (x, y) is lit in
+func (fb *Framebuffer) Set(x, y int, v uint8) {
+	fb.Pix[y*fb.Width+x] = v
+}
+
+// Locate maps a sprite pixel at (x, y) onto fb. Coordinates past the right or bottom edge
+// wrap around to the other side, or with clip are off the screen and ok is false.
+func (fb *Framebuffer) Locate(x, y int, clip bool) (int, int, bool) {
+	if clip && (x >= fb.Width || y >= fb.Height) {
+		return x, y, false
+	}
+	return x % fb.Width, y % fb.Height, true
+}
+
+// Flip toggles pixel (x, y) in planes and reports whether that turned off a pixel lit in one of them
+func (fb *Framebuffer) Flip(x, y int, planes uint8) bool {
+	i := y*fb.Width + x
+	collided := fb.Pix[i]&planes != 0
+	fb.Pix[i] ^= planes
+	return collided
+}
+
+// Clear turns every pixel off
+func (fb *Framebuffer) Clear() {
+	for i := range fb.Pix {
+		fb.Pix[i] = 0
+	}
+}
+
+// ScrollDown moves the picture down n rows (00Cn), the rows scrolled in at the top are dark
+func (fb *Framebuffer) ScrollDown(n int) {
+	if n <= 0 {
+		return
+	}
+	if n >= fb.Height {
+		fb.Clear()
+		return
+	}
+	shift := n * fb.Width
+	copy(fb.Pix[shift:], fb.Pix[:len(fb.Pix)-shift])
+	for i := range fb.Pix[:shift] {
+		fb.Pix[i] = 0
+	}
+}
+
+// ScrollRight4 moves the picture right 4 pixels (00FB)
+func (fb *Framebuffer) ScrollRight4() {
+	fb.scrollHorizontal(4)
+}
+
+// ScrollLeft4 moves the picture left 4 pixels (00FC)
+func (fb *Framebuffer) ScrollLeft4() {
+	fb.scrollHorizontal(-4)
+}
+
+// scrollHorizontal moves every row n pixels right, or left when n is negative
+func (fb *Framebuffer) scrollHorizontal(n int) {
+	dist := n
+	if dist < 0 {
+		dist = -dist
+	}
+	if dist >= fb.Width {
+		fb.Clear()
+		return
+	}
+	for y := 0; y < fb.Height; y++ {
+		row := fb.Pix[y*fb.Width : (y+1)*fb.Width]
+		var blank []uint8
+		if n > 0 {
+			copy(row[n:], row)
+			blank = row[:n]
+		} else {
+			copy(row, row[dist:])
+			blank = row[fb.Width-dist:]
+		}
+		for i := range blank {
+			blank[i] = 0
+		}
+	}
+}
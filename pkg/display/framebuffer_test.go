@@ -0,0 +1,163 @@
+package display
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseFramebuffer reads rows of '#' (lit) and '.' (dark)
+func parseFramebuffer(rows ...string) *Framebuffer {
+	fb := NewFramebuffer(len(rows[0]), len(rows), 1)
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				fb.Set(x, y, 1)
+			}
+		}
+	}
+	return fb
+}
+
+// formatFramebuffer draws fb the way parseFramebuffer reads it
+func formatFramebuffer(fb *Framebuffer) string {
+	var sb strings.Builder
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			if fb.At(x, y) != 0 {
+				sb.WriteByte('#')
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+func TestFramebufferScroll(t *testing.T) {
+	start := []string{
+		"#.......##",
+		".#......#.",
+		"..#.....#.",
+	}
+	tests := []struct {
+		name   string
+		scroll func(fb *Framebuffer)
+		want   []string
+	}{
+		{"down 0", func(fb *Framebuffer) { fb.ScrollDown(0) }, start},
+		{"down 1", func(fb *Framebuffer) { fb.ScrollDown(1) }, []string{
+			"..........",
+			"#.......##",
+			".#......#.",
+		}},
+		{"down 2", func(fb *Framebuffer) { fb.ScrollDown(2) }, []string{
+			"..........",
+			"..........",
+			"#.......##",
+		}},
+		{"down past the bottom", func(fb *Framebuffer) { fb.ScrollDown(15) }, []string{
+			"..........",
+			"..........",
+			"..........",
+		}},
+		{"right 4", (*Framebuffer).ScrollRight4, []string{
+			"....#.....",
+			".....#....",
+			"......#...",
+		}},
+		{"left 4", (*Framebuffer).ScrollLeft4, []string{
+			"....##....",
+			"....#.....",
+			"....#.....",
+		}},
+		{"left then right", func(fb *Framebuffer) { fb.ScrollLeft4(); fb.ScrollRight4() }, []string{
+			"........##",
+			"........#.",
+			"........#.",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb := parseFramebuffer(start...)
+			tt.scroll(fb)
+			if got, want := formatFramebuffer(fb), formatFramebuffer(parseFramebuffer(tt.want...)); got != want {
+				t.Errorf("got\n%swant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestFramebufferScrollKeepsPlanes(t *testing.T) {
+	for _, size := range [][2]int{{LoResWidth, LoResHeight}, {HiResWidth, HiResHeight}} {
+		fb := NewFramebuffer(size[0], size[1], 2)
+		fb.Set(10, 5, 3)
+		fb.ScrollDown(4)
+		fb.ScrollRight4()
+		fb.ScrollRight4()
+		fb.ScrollLeft4()
+		if got := fb.At(14, 9); got != 3 {
+			t.Errorf("%dx%d: got %d at (14, 9), want 3", size[0], size[1], got)
+		}
+		lit := 0
+		for _, p := range fb.Pix {
+			if p != 0 {
+				lit++
+			}
+		}
+		if lit != 1 {
+			t.Errorf("%dx%d: got %d lit pixels, want 1", size[0], size[1], lit)
+		}
+	}
+}
+
+func TestFramebufferDraw(t *testing.T) {
+	fb := NewFramebuffer(LoResWidth, LoResHeight, 2)
+	for _, tt := range []struct {
+		x, y         int
+		clip         bool
+		wantX, wantY int
+		wantOK       bool
+	}{
+		{10, 5, false, 10, 5, true},
+		{66, 33, false, 2, 1, true},
+		{66, 5, true, 66, 5, false},
+		{10, 32, true, 10, 32, false},
+		{63, 31, true, 63, 31, true},
+	} {
+		x, y, ok := fb.Locate(tt.x, tt.y, tt.clip)
+		if x != tt.wantX || y != tt.wantY || ok != tt.wantOK {
+			t.Errorf("Locate(%d, %d, %v) = %d, %d, %v, want %d, %d, %v", tt.x, tt.y, tt.clip, x, y, ok, tt.wantX, tt.wantY, tt.wantOK)
+		}
+	}
+
+	if fb.Flip(3, 4, 1) {
+		t.Error("Flip on a dark pixel reported a collision")
+	}
+	if fb.Flip(3, 4, 2) {
+		t.Error("Flip in another plane reported a collision")
+	}
+	if got := fb.At(3, 4); got != 3 {
+		t.Errorf("got planes %d, want 3", got)
+	}
+	snapshot := fb.Clone()
+	if !fb.Flip(3, 4, 3) || fb.At(3, 4) != 0 {
+		t.Errorf("Flip of a lit pixel: got planes %d, want a collision and 0", fb.At(3, 4))
+	}
+	if snapshot.At(3, 4) != 3 || snapshot.Equal(fb) {
+		t.Error("the clone changed along with the original")
+	}
+	fb.Set(3, 4, 3)
+	if !snapshot.Equal(fb) {
+		t.Error("equal framebuffers compare unequal")
+	}
+}
+
+func BenchmarkFramebufferScroll(b *testing.B) {
+	fb := NewFramebuffer(HiResWidth, HiResHeight, 1)
+	for i := 0; i < b.N; i++ {
+		fb.ScrollDown(1)
+		fb.ScrollLeft4()
+		fb.ScrollRight4()
+	}
+}
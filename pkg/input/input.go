@@ -0,0 +1,10 @@
+// Package input defines how the emulator core reads the player's keys.
+//
+// The chip8 package polls a Keypad and every frontend (SDL, terminal, browser) implements one,
+// so neither side has to know about the other and the core can be tested with simple fakes.
+package input
+
+// Keypad reports the state of the 16-key hex keypad
+type Keypad interface {
+	Keys() [16]bool
+}
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/ui/sound"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+const (
+	DefaultFrequency = 16000
+	DefaultFormat    = sdl.AUDIO_S16LSB
+	DefaultChannels  = 2
+	DefaultSamples   = 512
+
+	// Samples are generated in chunks of DefaultSamples frames, keeping audioBufferChunks
+	// chunks queued: enough to ride out scheduling hiccups without adding noticeable latency
+	audioBufferChunks = 2
+	audioPoll         = 5 * time.Millisecond
+)
+
+var audioDev sdl.AudioDeviceID
+var synth = sound.NewSynth(DefaultFrequency)
+var audioDone chan struct{}
+var audioWG sync.WaitGroup
+
+// openAudio opens the default playback device and starts feeding it from synth
+func openAudio() error {
+	spec := sdl.AudioSpec{
+		Freq:     DefaultFrequency,
+		Format:   DefaultFormat,
+		Channels: DefaultChannels,
+		Samples:  DefaultSamples,
+	}
+	dev, err := sdl.OpenAudioDevice("", false, &spec, nil, 0)
+	if err != nil {
+		return fmt.Errorf("audio: %v", err)
+	}
+	audioDev = dev
+	audioDone = make(chan struct{})
+	audioWG.Add(1)
+	go streamAudio(audioDone)
+
+	// The device always runs, Beep gates the tone in the synth
+	sdl.PauseAudioDevice(audioDev, false)
+	return nil
+}
+
+// streamAudio keeps the device's queue topped up until done is closed
+func streamAudio(done chan struct{}) {
+	defer audioWG.Done()
+	samples := make([]int16, DefaultSamples*DefaultChannels)
+	chunk := make([]byte, len(samples)*2)
+	ticker := time.NewTicker(audioPoll)
+	defer ticker.Stop()
+	for {
+		for sdl.GetQueuedAudioSize(audioDev) < uint32(len(chunk)*audioBufferChunks) {
+			synth.Fill(samples, DefaultChannels)
+			for i, s := range samples {
+				binary.LittleEndian.PutUint16(chunk[i*2:], uint16(s))
+			}
+			if err := sdl.QueueAudio(audioDev, chunk); err != nil {
+				return
+			}
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// closeAudio silences the device, stops feeding it and closes it
+func closeAudio() {
+	if audioDev == 0 {
+		return
+	}
+	sdl.PauseAudioDevice(audioDev, true)
+	close(audioDone)
+	audioWG.Wait()
+	sdl.ClearQueuedAudio(audioDev)
+	sdl.CloseAudioDevice(audioDev)
+	audioDev = 0
+}
+
+func Beep(on bool) {
+	synth.SetOn(on)
+}
+
+// SetPattern plays an XO-CHIP audio pattern in place of the beeper tone, see display.PatternPlayer
+func SetPattern(pattern []byte, rate float64) {
+	synth.SetPattern(pattern, rate)
+}
+
+// PlaySamples plays MegaChip's digitized sound, see display.SamplePlayer
+func PlaySamples(samples []byte, rate int, loop bool) {
+	synth.PlaySamples(samples, rate, loop)
+}
+
+// Synth returns the beeper's tone generator, for changing its waveform, pitch and volume
+func Synth() *sound.Synth {
+	return synth
+}
@@ -0,0 +1,40 @@
+// Package capture records the CHIP-8 display to a video file, either an animated GIF (pure Go)
+// or anything ffmpeg can write, such as mp4.
+//
+// Frames are added at 60 fps, once per emulated frame. GIF delays are in hundredths of a second,
+// so frames shorter than 2/100 s are merged into the next one, capping GIFs at around 30 fps.
+package capture
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustinbowers/chip8emu/pkg/display"
+)
+
+// FrameRate is the rate frames are expected to be added at
+const FrameRate = 60
+
+// Encoder receives frames and writes them out when closed
+type Encoder interface {
+	AddFrame(screen *display.Framebuffer) error // screen isn't kept, and may change size between frames
+	Close() error
+}
+
+// Create starts a recording to path, picking the encoder from its extension: .gif is encoded
+// in Go, anything else is handed to ffmpeg. Frames are drawn with the 4 color palette p,
+// scaled up by scale.
+func Create(path string, p color.Palette, scale int) (Encoder, error) {
+	if len(p) != 4 {
+		return nil, fmt.Errorf("capture: expected a 4 color palette, got %d colors", len(p))
+	}
+	if scale < 1 {
+		scale = 1
+	}
+	if strings.EqualFold(filepath.Ext(path), ".gif") {
+		return NewGIF(path, p, scale)
+	}
+	return NewFFmpeg(path, p, scale)
+}
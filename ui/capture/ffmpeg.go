@@ -0,0 +1,90 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"os/exec"
+	"strconv"
+
+	"github.com/dustinbowers/chip8emu/pkg/display"
+)
+
+// FFmpeg streams raw RGB frames to an ffmpeg process, which encodes them based on the output
+// file's extension (e.g. H.264 for .mp4)
+type FFmpeg struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stderr  bytes.Buffer
+	palette [4][3]byte
+	frame   []byte
+}
+
+// NewFFmpeg starts ffmpeg writing to path. ffmpeg must be on the PATH.
+func NewFFmpeg(path string, p color.Palette, scale int) (*FFmpeg, error) {
+	bin, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("capture: ffmpeg is needed for video files, record a .gif instead: %v", err)
+	}
+	// Frames are streamed at 128x64, the largest screen, with low resolution pixels doubled
+	f := &FFmpeg{frame: make([]byte, display.HiResWidth*display.HiResHeight*3)}
+	for i, c := range p {
+		r, g, b, _ := c.RGBA()
+		f.palette[i] = [3]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)}
+	}
+
+	f.cmd = exec.Command(bin,
+		"-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pix_fmt", "rgb24", "-s", fmt.Sprintf("%dx%d", display.HiResWidth, display.HiResHeight), "-r", strconv.Itoa(FrameRate), "-i", "-",
+		// Nearest neighbour keeps the pixels sharp, yuv420p keeps the result playable everywhere
+		"-vf", fmt.Sprintf("scale=%d:%d:flags=neighbor,format=yuv420p", 64*scale, 32*scale),
+		path)
+	f.cmd.Stderr = &f.stderr
+	if f.stdin, err = f.cmd.StdinPipe(); err != nil {
+		return nil, fmt.Errorf("capture: %v", err)
+	}
+	if err := f.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("capture: failed starting ffmpeg: %v", err)
+	}
+	return f, nil
+}
+
+func (f *FFmpeg) AddFrame(screen *display.Framebuffer) error {
+	// Screens that aren't 2:1, such as 64x64 hires CHIP-8, are centered with background at the sides
+	scale := display.HiResHeight / screen.Height
+	if s := display.HiResWidth / screen.Width; s < scale {
+		scale = s
+	}
+	left := (display.HiResWidth - screen.Width*scale) / 2
+	top := (display.HiResHeight - screen.Height*scale) / 2
+	i := 0
+	for y := 0; y < display.HiResHeight; y++ {
+		for x := 0; x < display.HiResWidth; x++ {
+			var p uint8
+			if sx, sy := (x-left)/scale, (y-top)/scale; x >= left && y >= top && sx < screen.Width && sy < screen.Height {
+				p = screen.At(sx, sy)
+			}
+			if screen.Palette != nil {
+				c := screen.Palette[p]
+				f.frame[i], f.frame[i+1], f.frame[i+2] = byte(c>>16), byte(c>>8), byte(c)
+			} else {
+				copy(f.frame[i:i+3], f.palette[p&3][:])
+			}
+			i += 3
+		}
+	}
+	if _, err := f.stdin.Write(f.frame); err != nil {
+		return fmt.Errorf("capture: failed writing to ffmpeg: %v", err)
+	}
+	return nil
+}
+
+// Close finishes the video and waits for ffmpeg to exit
+func (f *FFmpeg) Close() error {
+	f.stdin.Close()
+	if err := f.cmd.Wait(); err != nil {
+		return fmt.Errorf("capture: ffmpeg failed: %v: %s", err, bytes.TrimSpace(f.stderr.Bytes()))
+	}
+	return nil
+}
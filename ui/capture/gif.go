@@ -0,0 +1,91 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"os"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/display"
+)
+
+// GIF collects frames in memory and encodes them as an animated GIF on Close.
+// Consecutive identical frames are stored once, so static scenes cost next to nothing.
+type GIF struct {
+	path    string
+	palette color.Palette
+	scale   int
+	frames  []gifFrame
+}
+
+type gifFrame struct {
+	screen *display.Framebuffer
+	ticks  int // Number of 60 fps frames it's shown for
+}
+
+// NewGIF starts a GIF recording to path. The file is only created on Close.
+func NewGIF(path string, p color.Palette, scale int) (*GIF, error) {
+	return &GIF{path: path, palette: p, scale: scale}, nil
+}
+
+func (g *GIF) AddFrame(screen *display.Framebuffer) error {
+	if n := len(g.frames); n > 0 {
+		last := &g.frames[n-1]
+		switch {
+		case last.screen.Equal(screen):
+			last.ticks++
+			return nil
+		case last.ticks < 2:
+			// Too short for a GIF delay, show the newer frame in its place
+			last.screen = screen.Clone()
+			last.ticks++
+			return nil
+		}
+	}
+	g.frames = append(g.frames, gifFrame{screen: screen.Clone(), ticks: 1})
+	return nil
+}
+
+// Close encodes the recorded frames and writes the file
+func (g *GIF) Close() error {
+	if len(g.frames) == 0 {
+		return fmt.Errorf("capture: no frames recorded")
+	}
+	// Every frame is scaled to the widest one, so a ROM switching to hires keeps the same picture size
+	width := 0
+	for _, f := range g.frames {
+		if f.screen.Width > width {
+			width = f.screen.Width
+		}
+	}
+	anim := &gif.GIF{}
+	elapsed := 0 // In 60 fps frames
+	for _, f := range g.frames {
+		// Round the end of each frame to the nearest 1/100s so rounding errors don't add up
+		start := (elapsed*100 + FrameRate/2) / FrameRate
+		elapsed += f.ticks
+		end := (elapsed*100 + FrameRate/2) / FrameRate
+		anim.Image = append(anim.Image, chip8.ScreenImage(f.screen, g.palette, g.scale*width/f.screen.Width))
+		anim.Delay = append(anim.Delay, end-start)
+	}
+	anim.Config = image.Config{
+		ColorModel: g.palette,
+		Width:      anim.Image[0].Rect.Dx(),
+		Height:     anim.Image[0].Rect.Dy(),
+	}
+
+	file, err := os.Create(g.path)
+	if err != nil {
+		return fmt.Errorf("capture: %v", err)
+	}
+	if err := gif.EncodeAll(file, anim); err != nil {
+		file.Close()
+		return fmt.Errorf("capture: failed encoding gif: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("capture: %v", err)
+	}
+	return nil
+}
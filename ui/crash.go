@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var crashLines []string
+
+// ShowCrash covers the screen with a crash report, one string per line, until HideCrash is called
+func ShowCrash(lines []string) {
+	crashLines = lines
+	_ = Refresh()
+}
+
+// HideCrash removes the crash report
+func HideCrash() {
+	if crashLines != nil {
+		crashLines = nil
+		_ = Refresh()
+	}
+}
+
+// CrashShown reports whether a crash report is on screen
+func CrashShown() bool {
+	return crashLines != nil
+}
+
+// drawCrash draws the crash report in the foreground color on a nearly opaque background,
+// as large as it fits
+func drawCrash() {
+	width := 0
+	for _, line := range crashLines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	// Each line is 5 font pixels plus 2 of spacing, each character 3 plus 1
+	scale := dest.H / (int32(len(crashLines))*7 + 2)
+	if w := dest.W / (int32(width)*4 + 2); w < scale {
+		scale = w
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	bg, fg := palette[0], palette[1]
+	_ = renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	defer renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xF0)
+	_ = renderer.FillRect(&dest)
+	_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+	for i, line := range crashLines {
+		drawText(line, dest.X+scale, dest.Y+scale+int32(i)*7*scale, scale)
+	}
+}
@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Filter is a post-processing pass applied when drawing
+type Filter int
+
+const (
+	FilterNone Filter = iota // Sharp square pixels
+	FilterCRT                // Scanlines, barrel distortion and glow
+)
+
+var filterNames = map[string]Filter{
+	"none": FilterNone,
+	"crt":  FilterCRT,
+}
+
+// ParseFilter looks up a filter by name ("none" or "crt")
+func ParseFilter(name string) (Filter, error) {
+	f, ok := filterNames[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(filterNames))
+		for n := range filterNames {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return FilterNone, fmt.Errorf("unknown filter %q (expected one of: %v)", name, strings.Join(names, ", "))
+	}
+	return f, nil
+}
+
+const (
+	crtScale     = 6    // Output pixels per CHIP-8 pixel, each one gets a scanline
+	crtCurvature = 0.06 // Strength of the barrel distortion
+	crtScanline  = 0.55 // Brightness of the gap between scanlines
+	crtGlow      = 0.35 // How much neighbouring pixels bleed into each other
+)
+
+var filter Filter
+var crtTexture *sdl.Texture
+
+// SetFilter selects the post-processing filter used by Draw
+func SetFilter(f Filter) error {
+	if f == FilterCRT && crtTexture == nil {
+		if err := createCRTTexture(); err != nil {
+			return err
+		}
+	}
+	filter = f
+	redrawAll = true
+	return Draw(lastCells)
+}
+
+// createCRTTexture creates crtTexture for the current screen size
+func createCRTTexture() error {
+	// The CRT image is drawn at a higher resolution and smoothed when scaled to the window
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
+	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, cols*crtScale, rows*crtScale)
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "nearest")
+	if err != nil {
+		return fmt.Errorf("filter: failed creating texture: %v", err)
+	}
+	crtTexture = tex
+	return nil
+}
+
+// drawCRT renders frame, cols x rows pixels row by row, into crtTexture
+func drawCRT(frame []uint32) error {
+	sw, sh := int(cols), int(rows)
+	// Glow: blend each pixel with the average of its neighbours
+	lit := make([][3]float64, len(frame))
+	for i, c := range frame {
+		lit[i] = [3]float64{float64(c >> 16 & 0xFF), float64(c >> 8 & 0xFF), float64(c & 0xFF)}
+	}
+	glowed := make([][3]float64, len(frame))
+	for x := 0; x < sw; x++ {
+		for y := 0; y < sh; y++ {
+			var sum [3]float64
+			for _, d := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				nx, ny := x+d[0], y+d[1]
+				if nx < 0 || nx >= sw || ny < 0 || ny >= sh {
+					continue
+				}
+				for i := range sum {
+					sum[i] += lit[ny*sw+nx][i] / 4
+				}
+			}
+			for i := range sum {
+				glowed[y*sw+x][i] = lit[y*sw+x][i] + sum[i]*crtGlow
+			}
+		}
+	}
+
+	pixels, pitch, err := crtTexture.Lock(nil)
+	if err != nil {
+		return fmt.Errorf("draw: texture Lock failed: %v", err)
+	}
+	defer crtTexture.Unlock()
+
+	w, h := int(cols*crtScale), int(rows*crtScale)
+	for oy := 0; oy < h; oy++ {
+		v := float64(oy)/float64(h)*2 - 1
+		shade := 1.0
+		if oy%crtScale == crtScale-1 {
+			shade = crtScanline
+		}
+		for ox := 0; ox < w; ox++ {
+			u := float64(ox)/float64(w)*2 - 1
+
+			// Barrel distortion: push samples outwards the further they are from the center
+			du := u * (1 + crtCurvature*v*v)
+			dv := v * (1 + crtCurvature*u*u)
+			if du < -1 || du >= 1 || dv < -1 || dv >= 1 {
+				putPixel(pixels, pitch, ox, oy, 0xFF000000)
+				continue
+			}
+			sx := int((du + 1) / 2 * float64(sw))
+			sy := int((dv + 1) / 2 * float64(sh))
+
+			c := glowed[sy*sw+sx]
+			var out uint32 = 0xFF000000
+			for i, shift := range []uint{16, 8, 0} {
+				ch := c[i] * shade
+				if ch > 255 {
+					ch = 255
+				}
+				out |= uint32(ch) << shift
+			}
+			putPixel(pixels, pitch, ox, oy, out)
+		}
+	}
+	return nil
+}
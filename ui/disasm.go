@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"reflect"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/debugger"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var disasm *debugger.Disassembly
+var disasmRect sdl.Rect // Area of the window the disassembly pane is drawn in, beside dest
+
+// ShowDisassembly opens a pane beside the screen with a debugger.Disassembly, the instruction at
+// PC inverted, or updates the pane when it's already open. Opening it widens the window by half
+// so the screen keeps its size.
+func ShowDisassembly(d debugger.Disassembly) {
+	if disasm != nil && reflect.DeepEqual(d, *disasm) {
+		return
+	}
+	opening := disasm == nil
+	disasm = &d
+	if opening && !IsFullscreen() {
+		w, h := window.GetSize()
+		window.SetSize(w+w/2, h)
+	}
+	updateDest()
+	_ = Refresh()
+}
+
+// HideDisassembly closes the disassembly pane, narrowing the window back
+func HideDisassembly() {
+	if disasm == nil {
+		return
+	}
+	disasm = nil
+	if !IsFullscreen() {
+		w, h := window.GetSize()
+		window.SetSize(w*2/3, h)
+	}
+	updateDest()
+	_ = Refresh()
+}
+
+// DisassemblyShown reports whether the disassembly pane is open
+func DisassemblyShown() bool {
+	return disasm != nil
+}
+
+// drawDisassembly draws the pane: a title, then the instructions as large as they fit
+func drawDisassembly() {
+	d := disasm
+	title := "DISASSEMBLY"
+	if d.Paused {
+		title += "  PAUSED"
+	}
+	width := len(title)
+	for _, line := range d.Lines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	scale := disasmRect.H / (int32(len(d.Lines)+2)*7 + 2)
+	if w := disasmRect.W / (int32(width)*4 + 2); w < scale {
+		scale = w
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	bg, fg := palette[0], palette[1]
+	_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+	_ = renderer.DrawLine(disasmRect.X, disasmRect.Y, disasmRect.X, disasmRect.Y+disasmRect.H)
+	x0, y0 := disasmRect.X+2*scale, disasmRect.Y+scale
+	drawText(title, x0, y0, scale)
+	top := y0 + 2*7*scale
+	for i, line := range d.Lines {
+		y := top + int32(i)*7*scale
+		if i == d.Current {
+			box := sdl.Rect{X: x0 - scale, Y: y - scale, W: textWidth(line, scale) + 2*scale, H: 7 * scale}
+			_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+			_ = renderer.FillRect(&box)
+			_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xFF)
+			drawText(line, x0, y, scale)
+			_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+			continue
+		}
+		drawText(line, x0, y, scale)
+	}
+}
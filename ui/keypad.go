@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// keypadLayout is the order of the CHIP-8 keys on the 4x4 keypad, row by row
+var keypadLayout = [16]uint8{
+	0x1, 0x2, 0x3, 0xC,
+	0x4, 0x5, 0x6, 0xD,
+	0x7, 0x8, 0x9, 0xE,
+	0xA, 0x0, 0xB, 0xF,
+}
+
+var keypadShown bool
+var keypadLabels [16]string // Host key bound to each CHIP-8 key
+var keypadPressed [16]bool
+
+// ShowKeypad turns the virtual keypad overlay on or off. It's drawn on top of the screen
+// with the keys currently held down highlighted.
+func ShowKeypad(on bool) {
+	keypadShown = on
+	_ = Refresh()
+}
+
+// KeypadShown reports whether the virtual keypad overlay is on
+func KeypadShown() bool {
+	return keypadShown
+}
+
+// SetKeypadLabels sets the host key names shown under each key of the overlay, indexed by CHIP-8 key
+func SetKeypadLabels(labels [16]string) {
+	keypadLabels = labels
+}
+
+// SetPressedKeys updates the keys highlighted on the overlay. It reports whether the overlay
+// is on and changed, in which case Draw should be called even if the screen hasn't changed.
+func SetPressedKeys(keys [16]bool) bool {
+	changed := keys != keypadPressed
+	keypadPressed = keys
+	return changed && keypadShown
+}
+
+// drawKeypad draws the overlay as a square grid centered on the screen, on top of whatever
+// the renderer holds
+func drawKeypad() error {
+	size := dest.H * 9 / 10
+	if dest.W < size {
+		size = dest.W * 9 / 10
+	}
+	cell := size / 4
+	if cell < 8 {
+		return nil // Too small to draw anything legible
+	}
+	gap := cell / 12
+	left, top := dest.X+(dest.W-cell*4)/2, dest.Y+(dest.H-cell*4)/2
+
+	bg, fg := palette[0], palette[1]
+	_ = renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	defer renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	for i, k := range keypadLayout {
+		r := sdl.Rect{X: left + int32(i%4)*cell + gap, Y: top + int32(i/4)*cell + gap, W: cell - 2*gap, H: cell - 2*gap}
+		face, ink := bg, fg
+		var alpha uint8 = 0xC0
+		if keypadPressed[k] {
+			face, ink, alpha = fg, bg, 0xE0
+		}
+		_ = renderer.SetDrawColor(face.R, face.G, face.B, alpha)
+		if err := renderer.FillRect(&r); err != nil {
+			return fmt.Errorf("draw: keypad FillRect failed: %v", err)
+		}
+		_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+		_ = renderer.DrawRect(&r)
+
+		// The CHIP-8 key large in the middle, the host key small along the bottom
+		_ = renderer.SetDrawColor(ink.R, ink.G, ink.B, 0xFF)
+		digit := r.H / 2 / 5
+		name := fmt.Sprintf("%X", k)
+		drawText(name, r.X+(r.W-textWidth(name, digit))/2, r.Y+r.H/2-digit*5/2-digit, digit)
+		if label := keypadLabels[k]; label != "" {
+			// Shrink long names to fit the key, down to 1 pixel per font pixel
+			scale := r.W * 9 / 10 / (int32(len(label)) * 4)
+			if scale > digit/2 {
+				scale = digit / 2
+			}
+			if scale < 1 {
+				scale = 1
+			}
+			drawText(label, r.X+(r.W-textWidth(label, scale))/2, r.Y+r.H-scale*7, scale)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"reflect"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/debugger"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var memLines []string
+var memMarks []debugger.Mark
+
+// ShowMemoryView covers the screen with a debugger.MemoryView's lines and marks until
+// HideMemoryView is called. The cursor is drawn inverted, the PC half inverted and I underlined.
+func ShowMemoryView(lines []string, marks []debugger.Mark) {
+	if reflect.DeepEqual(lines, memLines) && reflect.DeepEqual(marks, memMarks) {
+		return
+	}
+	memLines, memMarks = lines, marks
+	_ = Refresh()
+}
+
+// HideMemoryView removes the memory view
+func HideMemoryView() {
+	if memLines != nil {
+		memLines, memMarks = nil, nil
+		_ = Refresh()
+	}
+}
+
+// MemoryViewShown reports whether the memory view is on screen
+func MemoryViewShown() bool {
+	return memLines != nil
+}
+
+// drawMemoryView draws the memory view like the crash report, as large as it fits
+func drawMemoryView() {
+	width := 0
+	for _, line := range memLines {
+		if n := len([]rune(line)); n > width {
+			width = n
+		}
+	}
+	scale := dest.H / (int32(len(memLines))*7 + 2)
+	if w := dest.W / (int32(width)*4 + 2); w < scale {
+		scale = w
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	bg, fg := palette[0], palette[1]
+	_ = renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	defer renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xF0)
+	_ = renderer.FillRect(&dest)
+	x0, y0 := dest.X+scale, dest.Y+scale
+	for _, m := range memMarks {
+		x, y := x0+int32(m.Col)*4*scale, y0+int32(m.Line)*7*scale
+		box := sdl.Rect{X: x - scale, Y: y - scale, W: textWidth(memLines[m.Line][m.Col:m.Col+m.Len], scale) + 2*scale, H: 7 * scale}
+		switch m.Kind {
+		case debugger.MarkCursor:
+			_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+		case debugger.MarkPC:
+			_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0x60)
+		default:
+			box = sdl.Rect{X: x, Y: y + 5*scale + scale/2, W: box.W - 2*scale, H: (scale + 1) / 2}
+			_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+		}
+		_ = renderer.FillRect(&box)
+	}
+	_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+	for i, line := range memLines {
+		drawText(line, x0, y0+int32(i)*7*scale, scale)
+	}
+	// The cursor's digits again in the background color, on its box
+	_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xFF)
+	for _, m := range memMarks {
+		if m.Kind == debugger.MarkCursor {
+			drawText(memLines[m.Line][m.Col:m.Col+m.Len], x0+int32(m.Col)*4*scale, y0+int32(m.Line)*7*scale, scale)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package menu
+
+import "unicode"
+
+// glyphs is a 3x5 pixel font, one row per byte with the leftmost pixel in bit 2.
+// Lower case letters are drawn with the upper case glyphs.
+var glyphs = map[rune][5]uint8{
+	'A':  {0b010, 0b101, 0b111, 0b101, 0b101},
+	'B':  {0b110, 0b101, 0b110, 0b101, 0b110},
+	'C':  {0b011, 0b100, 0b100, 0b100, 0b011},
+	'D':  {0b110, 0b101, 0b101, 0b101, 0b110},
+	'E':  {0b111, 0b100, 0b110, 0b100, 0b111},
+	'F':  {0b111, 0b100, 0b110, 0b100, 0b100},
+	'G':  {0b011, 0b100, 0b101, 0b101, 0b011},
+	'H':  {0b101, 0b101, 0b111, 0b101, 0b101},
+	'I':  {0b111, 0b010, 0b010, 0b010, 0b111},
+	'J':  {0b001, 0b001, 0b001, 0b101, 0b010},
+	'K':  {0b101, 0b101, 0b110, 0b101, 0b101},
+	'L':  {0b100, 0b100, 0b100, 0b100, 0b111},
+	'M':  {0b101, 0b111, 0b111, 0b101, 0b101},
+	'N':  {0b110, 0b101, 0b101, 0b101, 0b101},
+	'O':  {0b010, 0b101, 0b101, 0b101, 0b010},
+	'P':  {0b110, 0b101, 0b110, 0b100, 0b100},
+	'Q':  {0b010, 0b101, 0b101, 0b110, 0b011},
+	'R':  {0b110, 0b101, 0b110, 0b101, 0b101},
+	'S':  {0b011, 0b100, 0b010, 0b001, 0b110},
+	'T':  {0b111, 0b010, 0b010, 0b010, 0b010},
+	'U':  {0b101, 0b101, 0b101, 0b101, 0b111},
+	'V':  {0b101, 0b101, 0b101, 0b101, 0b010},
+	'W':  {0b101, 0b101, 0b111, 0b111, 0b101},
+	'X':  {0b101, 0b101, 0b010, 0b101, 0b101},
+	'Y':  {0b101, 0b101, 0b010, 0b010, 0b010},
+	'Z':  {0b111, 0b001, 0b010, 0b100, 0b111},
+	'0':  {0b111, 0b101, 0b101, 0b101, 0b111},
+	'1':  {0b010, 0b110, 0b010, 0b010, 0b111},
+	'2':  {0b110, 0b001, 0b010, 0b100, 0b111},
+	'3':  {0b110, 0b001, 0b010, 0b001, 0b110},
+	'4':  {0b101, 0b101, 0b111, 0b001, 0b001},
+	'5':  {0b111, 0b100, 0b110, 0b001, 0b110},
+	'6':  {0b011, 0b100, 0b111, 0b101, 0b111},
+	'7':  {0b111, 0b001, 0b010, 0b010, 0b010},
+	'8':  {0b111, 0b101, 0b111, 0b101, 0b111},
+	'9':  {0b111, 0b101, 0b111, 0b001, 0b110},
+	' ':  {0b000, 0b000, 0b000, 0b000, 0b000},
+	'.':  {0b000, 0b000, 0b000, 0b000, 0b010},
+	',':  {0b000, 0b000, 0b000, 0b010, 0b100},
+	':':  {0b000, 0b010, 0b000, 0b010, 0b000},
+	'!':  {0b010, 0b010, 0b010, 0b000, 0b010},
+	'?':  {0b110, 0b001, 0b010, 0b000, 0b010},
+	'-':  {0b000, 0b000, 0b111, 0b000, 0b000},
+	'_':  {0b000, 0b000, 0b000, 0b000, 0b111},
+	'+':  {0b000, 0b010, 0b111, 0b010, 0b000},
+	'/':  {0b001, 0b001, 0b010, 0b100, 0b100},
+	'\'': {0b010, 0b010, 0b000, 0b000, 0b000},
+	'&':  {0b010, 0b101, 0b010, 0b101, 0b011},
+	'(':  {0b001, 0b010, 0b010, 0b010, 0b001},
+	')':  {0b100, 0b010, 0b010, 0b010, 0b100},
+	'[':  {0b011, 0b010, 0b010, 0b010, 0b011},
+	']':  {0b110, 0b010, 0b010, 0b010, 0b110},
+	'>':  {0b100, 0b010, 0b001, 0b010, 0b100},
+	'<':  {0b001, 0b010, 0b100, 0b010, 0b001},
+	'=':  {0b000, 0b111, 0b000, 0b111, 0b000},
+	'*':  {0b000, 0b101, 0b010, 0b101, 0b000},
+	'%':  {0b101, 0b001, 0b010, 0b100, 0b101},
+}
+
+// Glyph returns the 3x5 glyph for r, or a question mark for characters the font doesn't have.
+// Row 0 is the top row, the leftmost pixel is bit 2.
+func Glyph(r rune) [5]uint8 {
+	glyph, ok := glyphs[unicode.ToUpper(r)]
+	if !ok {
+		glyph = glyphs['?']
+	}
+	return glyph
+}
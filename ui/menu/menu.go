@@ -0,0 +1,135 @@
+// Package menu draws a simple scrolling list on the 64x32 CHIP-8 display, used as a ROM launcher.
+//
+// Text is drawn with a 3x5 pixel font, which fits 16 characters on each of the 5 visible lines.
+// The selected line is drawn inverted and scrolls horizontally when its text doesn't fit.
+package menu
+
+import "github.com/dustinbowers/chip8emu/pkg/display"
+
+const (
+	charWidth   = 4 // 3 pixels plus 1 pixel of spacing
+	lineHeight  = 6 // 5 pixels plus 1 pixel of spacing
+	lineChars   = 64 / charWidth
+	lines       = 32 / lineHeight
+	scrollDelay = 12 // Frames between each step of a long item's scroll
+	scrollPause = 4  // Scroll steps to pause for at each end of a long item
+)
+
+// Menu is a list of items with a selection
+type Menu struct {
+	items    []string
+	selected int
+	top      int // First visible item
+	scroll   int // Scroll step of the selected item
+	ticks    int
+}
+
+// New creates a menu listing items
+func New(items []string) *Menu {
+	return &Menu{items: items}
+}
+
+// Len returns the number of items
+func (m *Menu) Len() int {
+	return len(m.items)
+}
+
+// Selected returns the index of the selected item, or -1 if the menu is empty
+func (m *Menu) Selected() int {
+	if len(m.items) == 0 {
+		return -1
+	}
+	return m.selected
+}
+
+// Up moves the selection up one item, wrapping around at the top
+func (m *Menu) Up() {
+	m.move(-1)
+}
+
+// Down moves the selection down one item, wrapping around at the bottom
+func (m *Menu) Down() {
+	m.move(1)
+}
+
+func (m *Menu) move(delta int) {
+	if len(m.items) == 0 {
+		return
+	}
+	m.selected = (m.selected + delta + len(m.items)) % len(m.items)
+	if m.selected < m.top {
+		m.top = m.selected
+	}
+	if m.selected >= m.top+lines {
+		m.top = m.selected - lines + 1
+	}
+	m.scroll, m.ticks = 0, 0
+}
+
+// Tick advances the scrolling of the selected item, call it once per 60Hz frame
+func (m *Menu) Tick() {
+	m.ticks++
+	if m.ticks%scrollDelay == 0 {
+		m.scroll++
+	}
+}
+
+// Render draws the visible part of the menu
+func (m *Menu) Render() *display.Framebuffer {
+	screen := display.NewFramebuffer(display.LoResWidth, display.LoResHeight, 1)
+	if len(m.items) == 0 {
+		drawText(screen, 0, 0, "NO ROMS FOUND", false)
+		return screen
+	}
+	for line := 0; line < lines && m.top+line < len(m.items); line++ {
+		i := m.top + line
+		text := []rune(m.items[i])
+		selected := i == m.selected
+		if selected && len(text) > lineChars {
+			text = text[m.scrollOffset(len(text)):]
+		}
+		drawText(screen, 0, line*lineHeight, string(text), selected)
+	}
+	return screen
+}
+
+// scrollOffset returns how many characters of a long selected item are scrolled out of view
+func (m *Menu) scrollOffset(length int) int {
+	overflow := length - lineChars
+	period := overflow + 2*scrollPause
+	step := m.scroll % period
+	switch {
+	case step < scrollPause:
+		return 0
+	case step < scrollPause+overflow:
+		return step - scrollPause
+	}
+	return overflow
+}
+
+// drawText draws up to one line of text at (x, y). Inverted text is drawn dark on a lit bar
+// spanning the whole line.
+func drawText(screen *display.Framebuffer, x, y int, text string, inverted bool) {
+	var on uint8 = 1
+	if inverted {
+		on = 0
+		for px := 0; px < screen.Width; px++ {
+			for py := y; py < y+lineHeight && py < screen.Height; py++ {
+				screen.Set(px, py, 1)
+			}
+		}
+	}
+	for _, r := range text {
+		if x+3 > screen.Width {
+			break
+		}
+		for row, bits := range Glyph(r) {
+			for col := 0; col < 3; col++ {
+				if bits&(0b100>>col) != 0 {
+					screen.Set(x+col, y+row, on)
+				}
+			}
+		}
+		x += charWidth
+	}
+}
@@ -0,0 +1,190 @@
+package ui
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/display"
+	"github.com/dustinbowers/chip8emu/ui/menu"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// The on-screen display draws text on top of the screen: a banner in the middle (e.g. "PAUSED"),
+// an info line in the top right corner (e.g. the clock speed), a script's HUD in the top left
+// corner, a panel of lines in the middle (e.g. the cheat list) and short messages in the bottom left corner that fade out after a while. Text uses the launcher's 3x5 font, scaled to the window.
+const (
+	messageTime = 2 * time.Second        // How long a message stays up, including the fade
+	messageFade = 500 * time.Millisecond // How long it takes to fade out
+)
+
+var osdEnabled = true
+var banner string
+var info string
+var hud string
+var panel string
+var message string
+var messageShown time.Time
+
+// SetOSD turns the on-screen display on or off. While it's off nothing is drawn, but the
+// texts set in the meantime are kept.
+func SetOSD(on bool) {
+	osdEnabled = on
+	_ = Refresh()
+}
+
+// OSD reports whether the on-screen display is on
+func OSD() bool {
+	return osdEnabled
+}
+
+// Notify shows text for a couple of seconds, replacing the previous message
+func Notify(text string) {
+	message = text
+	messageShown = time.Now()
+}
+
+// SetBanner shows text in the middle of the screen until it's changed, "" removes it
+func SetBanner(text string) {
+	if text != banner {
+		banner = text
+		_ = Refresh()
+	}
+}
+
+// SetInfo shows text in the top right corner until it's changed, "" removes it
+func SetInfo(text string) {
+	if text != info {
+		info = text
+		_ = Refresh()
+	}
+}
+
+// SetHUD shows text in the top left corner until it's changed, one box per line. "" removes it.
+func SetHUD(text string) {
+	if text != hud {
+		hud = text
+		_ = Refresh()
+	}
+}
+
+// SetPanel shows lines of text in the middle of the screen, over the banner, until it's changed.
+// "" removes it.
+func SetPanel(text string) {
+	if text != panel {
+		panel = text
+		_ = Refresh()
+	}
+}
+
+// messageAlpha returns the opacity of the current message, 0 once it has faded out
+func messageAlpha() uint8 {
+	if message == "" {
+		return 0
+	}
+	left := messageTime - time.Since(messageShown)
+	switch {
+	case left <= 0:
+		message = ""
+		return 0
+	case left < messageFade:
+		return uint8(0xFF * left / messageFade)
+	}
+	return 0xFF
+}
+
+// OSDAnimating reports whether a message is on screen. While it's true Refresh should be
+// called every frame so it fades out, until a Refresh after it expired has removed it.
+func OSDAnimating() bool {
+	return osdEnabled && message != ""
+}
+
+// drawOSD draws the on-screen display over dest
+func drawOSD() {
+	if !osdEnabled {
+		return
+	}
+	// One font pixel is half a CHIP-8 pixel (in low resolution), but never smaller than a real one
+	scale := dest.H / display.LoResHeight / 2
+	if scale < 1 {
+		scale = 1
+	}
+	margin := scale * 2
+	_ = renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	defer renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+
+	if banner != "" && memLines == nil && sprites == nil { // Don't cover the debug views
+		big := scale * 2
+		x := dest.X + (dest.W-textWidth(banner, big))/2
+		y := dest.Y + (dest.H-5*big)/2
+		drawTextBox(banner, x, y, big, 0xFF)
+	}
+	if info != "" {
+		drawTextBox(info, dest.X+dest.W-textWidth(info, scale)-margin, dest.Y+margin, scale, 0xFF)
+	}
+	if hud != "" {
+		for i, line := range strings.Split(hud, "\n") {
+			drawTextBox(line, dest.X+margin, dest.Y+margin+int32(i)*7*scale, scale, 0xFF)
+		}
+	}
+	if panel != "" {
+		// Lines are padded to the same length so their boxes make one block
+		lines := strings.Split(panel, "\n")
+		n := 0
+		for _, line := range lines {
+			if l := len([]rune(line)); l > n {
+				n = l
+			}
+		}
+		x := dest.X + (dest.W-textWidth(strings.Repeat(" ", n), scale))/2
+		y := dest.Y + (dest.H-int32(len(lines))*7*scale)/2
+		for i, line := range lines {
+			line += strings.Repeat(" ", n-len([]rune(line)))
+			drawTextBox(line, x, y+int32(i)*7*scale, scale, 0xFF)
+		}
+	}
+	if alpha := messageAlpha(); alpha > 0 {
+		drawTextBox(message, dest.X+margin, dest.Y+dest.H-5*scale-margin, scale, alpha)
+	}
+}
+
+// drawTextBox draws text in the foreground color on a box of the background color, so it
+// stays readable over lit pixels
+func drawTextBox(text string, x, y, scale int32, alpha uint8) {
+	bg, fg := palette[0], palette[1]
+	box := sdl.Rect{X: x - scale, Y: y - scale, W: textWidth(text, scale) + 2*scale, H: 7 * scale}
+	_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, uint8(int(alpha)*0xC0/0xFF))
+	_ = renderer.FillRect(&box)
+	_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, alpha)
+	drawText(text, x, y, scale)
+}
+
+// textWidth returns the width of text drawn by drawText
+func textWidth(text string, scale int32) int32 {
+	n := int32(len([]rune(text)))
+	if n == 0 {
+		return 0
+	}
+	return (n*4 - 1) * scale
+}
+
+// drawText draws text with the menu font with its top left corner at (x, y), each font pixel
+// a scale x scale block in the current draw color
+func drawText(text string, x, y, scale int32) {
+	if scale < 1 {
+		return
+	}
+	var rects []sdl.Rect
+	for _, r := range text {
+		for row, bits := range menu.Glyph(r) {
+			for col := int32(0); col < 3; col++ {
+				if bits&(0b100>>col) != 0 {
+					rects = append(rects, sdl.Rect{X: x + col*scale, Y: y + int32(row)*scale, W: scale, H: scale})
+				}
+			}
+		}
+		x += 4 * scale
+	}
+	if len(rects) > 0 {
+		_ = renderer.FillRects(rects)
+	}
+}
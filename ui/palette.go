@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Palette maps screen cell values to colors. Index 0 is the background and 1 the foreground,
+// 2 and 3 are reserved for XO-CHIP's second bit plane.
+type Palette [4]color.RGBA
+
+// Presets are the built-in palettes selectable by name
+var Presets = map[string]Palette{
+	"classic": {rgb(0x000000), rgb(0xFFFFFF), rgb(0xAAAAAA), rgb(0x555555)},
+	"green":   {rgb(0x001100), rgb(0x33FF33), rgb(0x1F991F), rgb(0x0F4C0F)},
+	"amber":   {rgb(0x140A00), rgb(0xFFB000), rgb(0xB37B00), rgb(0x664600)},
+	"lcd":     {rgb(0x9BBC0F), rgb(0x0F380F), rgb(0x306230), rgb(0x8BAC0F)},
+}
+
+// DefaultPalette is white on black
+var DefaultPalette = Presets["classic"]
+
+var palette = DefaultPalette
+
+// SetPalette changes the colors used by Draw
+func SetPalette(p Palette) {
+	palette = p
+	redrawAll = true
+}
+
+// CurrentPalette returns the colors used by Draw
+func CurrentPalette() Palette {
+	return palette
+}
+
+// Colors returns p as a color.Palette, for drawing images
+func (p Palette) Colors() color.Palette {
+	colors := make(color.Palette, len(p))
+	for i, c := range p {
+		colors[i] = c
+	}
+	return colors
+}
+
+// PresetNames lists the preset palette names in alphabetical order
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseColor parses a hex color such as "00ff00" or "#00FF00"
+func ParseColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: expected 6 hex digits", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: %v", s, err)
+	}
+	return rgb(uint32(v)), nil
+}
+
+func rgb(v uint32) color.RGBA {
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xFF}
+}
+
+// argb packs c for an ARGB8888 texture
+func argb(c color.RGBA) uint32 {
+	return uint32(c.A)<<24 | uint32(c.R)<<16 | uint32(c.G)<<8 | uint32(c.B)
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/display"
+)
+
+// RenderToImage draws cells with the current palette, each pixel scaled up to a scale x scale block
+func RenderToImage(cells *display.Framebuffer, scale int) image.Image {
+	return chip8.ScreenImage(cells, palette.Colors(), scale)
+}
+
+// SaveScreenshot writes cells as a PNG to dir (created if needed), named after prefix and
+// the current time. It returns the path of the new file.
+func SaveScreenshot(dir, prefix string, cells *display.Framebuffer, scale int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("screenshot: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.png", prefix, time.Now().Format("20060102-150405.000"))
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("screenshot: %v", err)
+	}
+	if err := png.Encode(f, RenderToImage(cells, scale)); err != nil {
+		f.Close()
+		return "", fmt.Errorf("screenshot: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("screenshot: %v", err)
+	}
+	return path, nil
+}
@@ -0,0 +1,297 @@
+// Package sound generates the CHIP-8 beeper tone as 16-bit PCM samples, independent of any
+// audio API. Frontends feed the samples to their audio device and gate the tone with SetOn
+// whenever the sound timer starts or stops.
+//
+// XO-CHIP audio patterns (see SetPattern) replace the tone while they're set, and MegaChip's
+// digitized sound (see PlaySamples) plays over everything regardless of the sound timer.
+package sound
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	DefaultFrequency = 440  // Hz
+	DefaultVolume    = 0.25 // Fraction of full scale
+
+	// The tone fades in and out over rampTime instead of starting and stopping abruptly,
+	// which would otherwise click
+	rampTime = 0.004 // Seconds
+)
+
+// Waveform is the shape of the beeper tone
+type Waveform int
+
+const (
+	Square Waveform = iota
+	Sine
+	Triangle
+	Noise
+)
+
+var waveformNames = map[string]Waveform{
+	"square":   Square,
+	"sine":     Sine,
+	"triangle": Triangle,
+	"noise":    Noise,
+}
+
+func (w Waveform) String() string {
+	for name, v := range waveformNames {
+		if v == w {
+			return name
+		}
+	}
+	return fmt.Sprintf("Waveform(%d)", int(w))
+}
+
+// WaveformNames lists the waveform names accepted by ParseWaveform, in order
+func WaveformNames() []string {
+	names := make([]string, 0, len(waveformNames))
+	for name := range waveformNames {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return waveformNames[names[i]] < waveformNames[names[j]] })
+	return names
+}
+
+// ParseWaveform looks up a waveform by name
+func ParseWaveform(name string) (Waveform, error) {
+	w, ok := waveformNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown waveform %q (expected %s)", name, strings.Join(WaveformNames(), ", "))
+	}
+	return w, nil
+}
+
+// Synth generates the beeper tone. It's safe to change its settings while another goroutine
+// (e.g. an audio callback) is calling Fill.
+type Synth struct {
+	mu        sync.Mutex
+	rate      float64 // Samples per second
+	wave      Waveform
+	frequency float64
+	volume    float64
+	muted     bool
+	on        bool
+	gain      float64 // Envelope, ramps between 0 and 1 as the tone starts and stops
+	phase     float64 // Position in the current cycle, 0..1
+	noise     uint32  // LFSR state for the noise waveform
+	level     float64 // Current noise sample
+
+	pattern     []byte  // XO-CHIP audio pattern, nil for the plain tone
+	patternRate float64 // Pattern samples per second
+	patternPos  float64 // Position in the pattern, 0..128
+
+	samples    []byte  // MegaChip digitized sound, unsigned 8-bit, nil when none is playing
+	sampleRate float64 // Samples per second
+	samplePos  float64 // Position in samples
+	sampleLoop bool
+}
+
+// NewSynth creates a Synth producing sampleRate samples per second
+func NewSynth(sampleRate int) *Synth {
+	return &Synth{
+		rate:      float64(sampleRate),
+		frequency: DefaultFrequency,
+		volume:    DefaultVolume,
+		noise:     0xACE1,
+	}
+}
+
+// SetWaveform changes the shape of the tone
+func (s *Synth) SetWaveform(w Waveform) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wave = w
+}
+
+// Waveform returns the shape of the tone
+func (s *Synth) Waveform() Waveform {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wave
+}
+
+// SetFrequency changes the pitch of the tone in Hz
+func (s *Synth) SetFrequency(hz float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frequency = math.Max(1, math.Min(hz, s.rate/2))
+}
+
+// Frequency returns the pitch of the tone in Hz
+func (s *Synth) Frequency() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.frequency
+}
+
+// SetVolume sets the volume from 0 (silent) to 1 (full scale)
+func (s *Synth) SetVolume(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.volume = math.Max(0, math.Min(v, 1))
+}
+
+// Volume returns the volume from 0 to 1
+func (s *Synth) Volume() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.volume
+}
+
+// SetMuted silences the tone without changing the volume
+func (s *Synth) SetMuted(muted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.muted = muted
+}
+
+// Muted reports whether the tone is muted
+func (s *Synth) Muted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.muted
+}
+
+// SetOn starts or stops the tone, call it when the sound timer starts and stops
+func (s *Synth) SetOn(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.on = on
+}
+
+// SetPattern plays pattern, a loop of 128 1-bit samples (most significant bit first) at rate
+// samples per second, in place of the tone. A nil pattern goes back to the tone.
+func (s *Synth) SetPattern(pattern []byte, rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(pattern) == 0 {
+		s.pattern = nil
+		return
+	}
+	if s.pattern == nil {
+		s.patternPos = 0
+	}
+	s.pattern = append(s.pattern[:0], pattern...)
+	s.patternRate = rate
+}
+
+// PlaySamples plays unsigned 8-bit samples at rate samples per second, looping them if loop is set.
+// It replaces any sound already playing and nil samples stop it.
+func (s *Synth) PlaySamples(samples []byte, rate int, loop bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.samples = nil
+	if len(samples) == 0 || rate <= 0 {
+		return
+	}
+	s.samples = append([]byte(nil), samples...)
+	s.sampleRate = float64(rate)
+	s.samplePos = 0
+	s.sampleLoop = loop
+}
+
+// Fill writes the next len(buf) / channels sample frames to buf, interleaved by channel.
+// Silence is written while the tone is off or muted, with a short fade at either end.
+func (s *Synth) Fill(buf []int16, channels int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if channels < 1 {
+		channels = 1
+	}
+	target := 0.0
+	if s.on && !s.muted {
+		target = 1
+	}
+	ramp := 1 / (rampTime * s.rate)
+	amplitude := s.volume * math.MaxInt16
+	step := s.frequency / s.rate
+	for i := 0; i+channels <= len(buf); i += channels {
+		switch {
+		case s.gain < target:
+			s.gain = math.Min(s.gain+ramp, target)
+		case s.gain > target:
+			s.gain = math.Max(s.gain-ramp, target)
+		}
+		var sample int16
+		switch {
+		case s.samples != nil:
+			if !s.muted {
+				sample = int16(s.digitizedSample() * amplitude)
+			}
+		case s.pattern != nil:
+			sample = int16(s.patternSample() * amplitude * s.gain)
+		default:
+			sample = int16(s.sample() * amplitude * s.gain)
+		}
+		for c := 0; c < channels; c++ {
+			buf[i+c] = sample
+		}
+		if s.pattern != nil {
+			continue
+		}
+		s.phase += step
+		if s.phase >= 1 {
+			s.phase -= 1
+			s.stepNoise()
+		}
+	}
+}
+
+// sample returns the waveform at the current phase, from -1 to 1
+func (s *Synth) sample() float64 {
+	switch s.wave {
+	case Sine:
+		return math.Sin(2 * math.Pi * s.phase)
+	case Triangle:
+		return 4*math.Abs(s.phase-0.5) - 1
+	case Noise:
+		return s.level
+	}
+	if s.phase < 0.5 {
+		return 1
+	}
+	return -1
+}
+
+// patternSample returns the current bit of the pattern as -1 or 1 and advances through the pattern
+func (s *Synth) patternSample() float64 {
+	bits := len(s.pattern) * 8
+	pos := int(s.patternPos) % bits
+	s.patternPos += s.patternRate / s.rate
+	for s.patternPos >= float64(bits) {
+		s.patternPos -= float64(bits)
+	}
+	if s.pattern[pos/8]&(0x80>>(pos%8)) != 0 {
+		return 1
+	}
+	return -1
+}
+
+// digitizedSample returns the current digitized sample from -1 to 1 and advances, stopping
+// (or starting over) at the end
+func (s *Synth) digitizedSample() float64 {
+	v := (float64(s.samples[int(s.samplePos)]) - 128) / 128
+	s.samplePos += s.sampleRate / s.rate
+	if int(s.samplePos) >= len(s.samples) {
+		if s.sampleLoop {
+			s.samplePos = 0
+		} else {
+			s.samples = nil
+		}
+	}
+	return v
+}
+
+// stepNoise picks the noise level for the next cycle from a 16-bit LFSR
+func (s *Synth) stepNoise() {
+	bit := (s.noise ^ s.noise>>2 ^ s.noise>>3 ^ s.noise>>5) & 1
+	s.noise = s.noise>>1 | bit<<15
+	s.level = float64(s.noise&1)*2 - 1
+}
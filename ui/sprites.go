@@ -0,0 +1,116 @@
+package ui
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8/debugger"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+var sprites *debugger.SpriteSheet
+
+// ShowSprites covers the screen with a magnified debugger.SpriteSheet until HideSprites is called.
+// The sprite at I is framed, the font is drawn smaller underneath.
+func ShowSprites(sheet debugger.SpriteSheet) {
+	if sprites != nil && reflect.DeepEqual(sheet, *sprites) {
+		return
+	}
+	sprites = &sheet
+	_ = Refresh()
+}
+
+// HideSprites removes the sprite viewer
+func HideSprites() {
+	if sprites != nil {
+		sprites = nil
+		_ = Refresh()
+	}
+}
+
+// SpritesShown reports whether the sprite viewer is on screen
+func SpritesShown() bool {
+	return sprites != nil
+}
+
+// drawSprites draws the sprite viewer: a title, the sprites around I with their addresses under
+// them, and the font along the bottom
+func drawSprites() {
+	sheet := sprites
+	bg, fg := palette[0], palette[1]
+	_ = renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	defer renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xF0)
+	_ = renderer.FillRect(&dest)
+
+	title := fmt.Sprintf("SPRITES AT I %04X, %d ROWS", sheet.I, sheet.Height)
+	if sheet.Drawing {
+		title += " - DRAWN NEXT"
+	}
+	// The title is 2 font pixels in from the edges, the font takes the bottom fifth
+	text := dest.W / (int32(len(title))*4 + 4)
+	if h := dest.H / 40; h < text {
+		text = h
+	}
+	if text < 1 {
+		text = 1
+	}
+	_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+	drawText(title, dest.X+2*text, dest.Y+2*text, text)
+
+	// Each sprite is 8 pixels wide with a pixel of space on either side
+	top := dest.Y + 9*text
+	fontTop := dest.Y + dest.H*4/5
+	n := int32(len(sheet.Sprites))
+	px := dest.W / (n*10 + 1)
+	if h := (fontTop - top - 8*text) / int32(sheet.Height+1); h < px {
+		px = h
+	}
+	if px < 1 {
+		px = 1
+	}
+	left := dest.X + (dest.W-n*10*px)/2
+	for i, s := range sheet.Sprites {
+		x := left + int32(i)*10*px + px
+		if i == sheet.Current && s.Addr == sheet.I {
+			frame := sdl.Rect{X: x - px/2 - text, Y: top - px/2 - text, W: 9*px + 2*text, H: int32(len(s.Rows))*px + px + 2*text}
+			_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+			_ = renderer.FillRect(&frame)
+			inner := sdl.Rect{X: frame.X + text, Y: frame.Y + text, W: frame.W - 2*text, H: frame.H - 2*text}
+			_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xFF)
+			_ = renderer.FillRect(&inner)
+		}
+		_ = renderer.SetDrawColor(fg.R, fg.G, fg.B, 0xFF)
+		drawSprite(s.Rows, x, top, px)
+		label := fmt.Sprintf("%04X", s.Addr)
+		drawText(label, x+(8*px-textWidth(label, text))/2, top+int32(sheet.Height)*px+px+2*text, text)
+	}
+
+	// The font's digits are 4 pixels wide, drawn 8 wide like Dxyn sees them
+	fpx := dest.W / (16*9 + 1)
+	if h := (dest.Y + dest.H - fontTop) / 7; h < fpx {
+		fpx = h
+	}
+	if fpx < 1 {
+		fpx = 1
+	}
+	fontLeft := dest.X + (dest.W-16*9*fpx)/2
+	for i, s := range sheet.Font {
+		drawSprite(s.Rows, fontLeft+int32(i)*9*fpx+fpx/2, fontTop+fpx, fpx)
+	}
+}
+
+// drawSprite draws rows as Dxyn would, each pixel a px x px block in the current draw color
+func drawSprite(rows []byte, x, y, px int32) {
+	var rects []sdl.Rect
+	for r, bits := range rows {
+		for c := int32(0); c < 8; c++ {
+			if bits&(0x80>>c) != 0 {
+				rects = append(rects, sdl.Rect{X: x + c*px, Y: y + int32(r)*px, W: px, H: px})
+			}
+		}
+	}
+	if len(rects) > 0 {
+		_ = renderer.FillRects(rects)
+	}
+}
@@ -0,0 +1,187 @@
+// Package terminal is a text-mode frontend that draws the screen with Unicode half-block
+// characters and reads the keypad from raw terminal input. It needs no SDL (or cgo), so it
+// works over SSH and on headless machines.
+//
+// Terminals only report key presses, not releases, so a key is held down for a short while
+// after each press; the terminal's own key repeat keeps it held for as long as it's pressed.
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dustinbowers/chip8emu/pkg/display"
+	"github.com/dustinbowers/chip8emu/pkg/input"
+)
+
+// HoldTime is how long a key stays down after a key press is read
+const HoldTime = 150 * time.Millisecond
+
+const (
+	esc        = 0x1b
+	ctrlC      = 0x03
+	hideCursor = "\x1b[?25l"
+	showCursor = "\x1b[?25h"
+	clear      = "\x1b[2J"
+	home       = "\x1b[H"
+	colors     = "\x1b[97;40m" // Bright white on black
+	resetAttrs = "\x1b[0m"
+)
+
+var (
+	_ display.Display   = (*Terminal)(nil)
+	_ input.Keypad      = (*Terminal)(nil)
+	_ display.AudioSink = (*Terminal)(nil)
+)
+
+// keyMap mirrors the SDL frontend's layout
+var keyMap = map[byte]uint8{
+	'1': 0x1, '2': 0x2, '3': 0x3, '4': 0xc,
+	'q': 0x4, 'w': 0x5, 'e': 0x6, 'r': 0xd,
+	'a': 0x7, 's': 0x8, 'd': 0x9, 'f': 0xe,
+	'z': 0xa, 'x': 0x0, 'c': 0xb, 'v': 0xf,
+}
+
+// Terminal implements the display and input interfaces on top of stdin / stdout
+type Terminal struct {
+	out      *bufio.Writer
+	sttyMode string // Terminal settings to restore on Close
+	width    int    // Size of the last screen drawn, the terminal is cleared when it changes
+	height   int
+
+	mu      sync.Mutex
+	pressed [16]time.Time // Last time each key was read
+	beeping bool
+	quit    chan struct{}
+}
+
+// Open switches the terminal into raw mode and starts reading keys. Call Close to restore it.
+func Open() (*Terminal, error) {
+	mode, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("terminal: stdin is not a terminal: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("terminal: failed entering raw mode: %v", err)
+	}
+
+	t := &Terminal{
+		out:      bufio.NewWriterSize(os.Stdout, 64*16*4),
+		sttyMode: strings.TrimSpace(mode),
+		quit:     make(chan struct{}),
+	}
+	t.out.WriteString(hideCursor + clear)
+	t.out.Flush()
+
+	go t.readKeys(os.Stdin)
+	return t, nil
+}
+
+// Close restores the terminal to how it was before Open
+func (t *Terminal) Close() error {
+	t.out.WriteString(resetAttrs + showCursor + "\r\n")
+	t.out.Flush()
+	_, err := stty(t.sttyMode)
+	return err
+}
+
+// Quit is closed when the user presses Esc or Ctrl-C
+func (t *Terminal) Quit() <-chan struct{} {
+	return t.quit
+}
+
+// Draw implements display.Display. Each character cell covers two pixel rows:
+// the upper half block is drawn in the foreground color, the lower half in the background.
+// A 128x64 screen needs a terminal 128 columns wide.
+func (t *Terminal) Draw(screen *display.Framebuffer) error {
+	if screen.Width != t.width || screen.Height != t.height {
+		t.out.WriteString(clear)
+		t.width, t.height = screen.Width, screen.Height
+	}
+	t.out.WriteString(home + colors)
+	for y := 0; y+1 < screen.Height; y += 2 {
+		for x := 0; x < screen.Width; x++ {
+			top, bottom := screen.At(x, y) == 1, screen.At(x, y+1) == 1
+			switch {
+			case top && bottom:
+				t.out.WriteString("█")
+			case top:
+				t.out.WriteString("▀")
+			case bottom:
+				t.out.WriteString("▄")
+			default:
+				t.out.WriteByte(' ')
+			}
+		}
+		t.out.WriteString("\r\n")
+	}
+	t.out.WriteString(resetAttrs)
+	return t.out.Flush()
+}
+
+// Keys implements input.Keypad
+func (t *Terminal) Keys() (keys [16]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for k, at := range t.pressed {
+		keys[k] = now.Sub(at) < HoldTime
+	}
+	return keys
+}
+
+// Beep rings the terminal bell when the sound timer starts
+func (t *Terminal) Beep(on bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if on && !t.beeping {
+		os.Stdout.WriteString("\a")
+	}
+	t.beeping = on
+}
+
+func (t *Terminal) readKeys(in io.Reader) {
+	buf := make([]byte, 32)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			close(t.quit)
+			return
+		}
+		// A lone Esc quits, Esc followed by more bytes is an escape sequence (arrow keys etc.) and is ignored
+		if buf[0] == esc && n == 1 {
+			close(t.quit)
+			return
+		}
+		if buf[0] == esc {
+			continue
+		}
+
+		t.mu.Lock()
+		for _, b := range buf[:n] {
+			if b == ctrlC {
+				t.mu.Unlock()
+				close(t.quit)
+				return
+			}
+			if k, ok := keyMap[strings.ToLower(string(b))[0]]; ok {
+				t.pressed[k] = time.Now()
+			}
+		}
+		t.mu.Unlock()
+	}
+}
+
+// stty runs stty against the controlling terminal
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
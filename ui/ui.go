@@ -1,41 +1,48 @@
 package ui
 
-// typedef unsigned char Uint8;
-// void SineWave(void *userdata, Uint8 *stream, int len);
-import "C"
 import (
 	"fmt"
-	"github.com/veandco/go-sdl2/sdl"
-	"log"
+	"image/color"
 	"math"
-	"reflect"
 	"unsafe"
+
+	"github.com/dustinbowers/chip8emu/pkg/chip8"
+	"github.com/dustinbowers/chip8emu/pkg/display"
+	"github.com/veandco/go-sdl2/sdl"
 )
 
 var (
-	width       int32
-	height      int32
-	rows        int32
-	cols        int32
-	blockWidth  int32
-	blockHeight int32
+	width  int32
+	height int32
+	rows   int32
+	cols   int32
 )
 
-const (
-	DefaultFrequency = 16000
-	DefaultFormat    = sdl.AUDIO_S16
-	DefaultChannels  = 2
-	DefaultSamples   = 512
+var window *sdl.Window
+var renderer *sdl.Renderer
+var texture *sdl.Texture // cols x rows streaming texture, scaled up to the window by the GPU
+var dest sdl.Rect        // Letterboxed area of the window the texture is drawn into
+var integerScale bool
+var lastCells = display.NewFramebuffer(display.LoResWidth, display.LoResHeight, 1)
+var lastTexture *sdl.Texture // Texture holding lastCells, texture or crtTexture
+var redrawAll = true         // The texture is out of date everywhere, e.g. after a palette change
 
-	toneHz = 200
-	dPhase = 2 * math.Pi * toneHz / DefaultSamples
-)
+// VSync is requested by default, SetVSync changes that before Init
+var vsyncWanted = true
+var vsync bool
 
-var window *sdl.Window
-var audioDev sdl.AudioDeviceID
+// Nothing is logged until SetLogger
+var logger chip8.ComponentLogger
+
+// Phosphor persistence: lit pixels fade out over a few frames instead of switching off instantly
+const ghostDecay = 0.55 // Brightness kept per frame once a pixel is switched off
+
+var ghosting bool
+var glow []float64 // Current brightness of each pixel row by row, 0..1
+var fading bool    // Some pixels are still fading out
 
 func Init(screenWidth int, screenHeight int, screenCols int, screenRows int) {
-	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_AUDIO); err != nil {
+	if err := sdl.Init(sdl.INIT_VIDEO | sdl.INIT_AUDIO | sdl.INIT_GAMECONTROLLER); err != nil {
 		panic(err)
 	}
 
@@ -43,93 +50,362 @@ func Init(screenWidth int, screenHeight int, screenCols int, screenRows int) {
 	height = int32(screenHeight)
 	cols = int32(screenCols)
 	rows = int32(screenRows)
-	blockWidth = width / cols
-	blockHeight = height / rows
 
 	win, err := sdl.CreateWindow("Chip8", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		width, height, sdl.WINDOW_SHOWN)
+		width, height, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE|sdl.WINDOW_ALLOW_HIGHDPI)
 	if err != nil {
 		panic(err)
 	}
 	window = win
 
-	// Audio
-	// Specify the configuration for our default playback device
-	spec := sdl.AudioSpec{
-		Freq:     DefaultFrequency,
-		Format:   DefaultFormat,
-		Channels: DefaultChannels,
-		Samples:  DefaultSamples,
-		Callback: sdl.AudioCallback(C.SineWave),
+	var flags uint32 = sdl.RENDERER_ACCELERATED
+	if vsyncWanted {
+		flags |= sdl.RENDERER_PRESENTVSYNC
+	}
+	renderer, err = sdl.CreateRenderer(window, -1, flags)
+	if err != nil {
+		// Fall back to whatever SDL can give us, e.g. on headless or GPU-less machines
+		if renderer, err = sdl.CreateRenderer(window, -1, flags&sdl.RENDERER_PRESENTVSYNC); err != nil {
+			panic(err)
+		}
+	}
+	// Not every renderer can sync, e.g. the software one, so check what we actually got
+	if info, err := renderer.GetInfo(); err == nil {
+		vsync = info.Flags&sdl.RENDERER_PRESENTVSYNC != 0
+		logger.Info(chip8.LogUI, "renderer created", "name", info.Name, "vsync", vsync)
 	}
+	texture, err = renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, cols, rows)
+	if err != nil {
+		panic(err)
+	}
+	glow = make([]float64, cols*rows)
+	window.SetMinimumSize(cols, rows)
+	updateDest()
 
-	// Open default playback device
-	if audioDev, err = sdl.OpenAudioDevice("", false, &spec, nil, 0); err != nil {
-		log.Println(err)
-		return
+	if err := openAudio(); err != nil {
+		logger.Warn(chip8.LogAudio, "audio unavailable", "err", err)
 	}
 }
 
-func Draw(cells [64][32]uint8) error {
-	surface, err := window.GetSurface()
+// SetLogger sends the log messages of the enabled components (LogUI and LogAudio) to l
+func SetLogger(l chip8.Logger, enabled chip8.LogComponents) {
+	logger = chip8.ComponentLogger{Logger: l, Enabled: enabled}
+}
+
+// SetVSync chooses whether presenting a frame waits for the display's vertical blank, which
+// paces drawing to the refresh rate without tearing. It must be called before Init.
+func SetVSync(on bool) {
+	vsyncWanted = on
+}
+
+// VSync reports whether Draw and Refresh wait for the vertical blank, so the caller doesn't
+// have to pace its loop itself
+func VSync() bool {
+	return vsync
+}
+
+// RefreshRate returns the refresh rate of the display the window is on, or 0 if it's unknown
+func RefreshRate() int {
+	mode, err := window.GetDisplayMode()
 	if err != nil {
-		panic(err)
+		return 0
 	}
-	err = surface.FillRect(nil, 0)
-	if err != nil {
-		return fmt.Errorf("draw: FillRect failed: %v", err)
+	return int(mode.RefreshRate)
+}
+
+// SetStatus shows a short status line (e.g. the clock speed) in the window title
+func SetStatus(status string) {
+	title := "Chip8"
+	if status != "" {
+		title += " - " + status
+	}
+	window.SetTitle(title)
+}
+
+// SetIntegerScale restricts scaling to whole multiples of the display resolution,
+// trading some unused border for perfectly even pixels
+func SetIntegerScale(on bool) {
+	integerScale = on
+	updateDest()
+}
+
+// SetFullscreen switches between a desktop fullscreen window (with the mouse cursor hidden) and a regular window
+func SetFullscreen(on bool) error {
+	var flags uint32
+	cursor := sdl.ENABLE
+	if on {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+		cursor = sdl.DISABLE
+	}
+	if err := window.SetFullscreen(flags); err != nil {
+		return fmt.Errorf("fullscreen: %v", err)
+	}
+	_, _ = sdl.ShowCursor(cursor)
+	updateDest()
+	return Refresh()
+}
+
+// ToggleFullscreen flips between fullscreen and windowed mode
+func ToggleFullscreen() error {
+	return SetFullscreen(!IsFullscreen())
+}
+
+// IsFullscreen reports whether the window is currently fullscreen
+func IsFullscreen() bool {
+	return window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP != 0
+}
+
+// SetGhosting enables or disables the phosphor persistence filter
+func SetGhosting(on bool) {
+	ghosting = on
+	redrawAll = true
+	glow = make([]float64, cols*rows)
+	fading = false
+}
+
+// Ghosting reports whether the phosphor persistence filter is enabled
+func Ghosting() bool {
+	return ghosting
+}
+
+// Fading reports whether pixels are still fading out. While it's true Draw should be called
+// every frame, even if the screen hasn't changed.
+func Fading() bool {
+	return fading
+}
+
+// HandleEvent reacts to window events (resizes) and reports whether the event was consumed
+func HandleEvent(event sdl.Event) bool {
+	e, ok := event.(*sdl.WindowEvent)
+	if !ok {
+		return false
+	}
+	if e.Event == sdl.WINDOWEVENT_SIZE_CHANGED || e.Event == sdl.WINDOWEVENT_EXPOSED {
+		updateDest()
+		_ = Refresh()
 	}
+	return true
+}
 
-	for x, col := range cells {
-		for y, cell := range col {
+// updateDest recomputes the letterboxed destination rect for the current output size, and the
+// disassembly pane's, which takes the right third of the window when it's open.
+// The output size is queried from the renderer since it differs from the window size on high-DPI displays.
+func updateDest() {
+	w, h, err := renderer.GetOutputSize()
+	if err != nil {
+		w, h = window.GetSize()
+	}
+	if disasm != nil {
+		disasmRect = sdl.Rect{X: w - w/3, Y: 0, W: w / 3, H: h}
+		w -= w / 3
+	}
+	scale := math.Min(float64(w)/float64(cols), float64(h)/float64(rows))
+	if integerScale && scale >= 1 {
+		scale = math.Floor(scale)
+	}
+	dw, dh := int32(float64(cols)*scale), int32(float64(rows)*scale)
+	dest = sdl.Rect{X: (w - dw) / 2, Y: (h - dh) / 2, W: dw, H: dh}
+}
 
-			xPos := int32(x) * blockWidth
-			yPos := int32(y) * blockHeight
+// Draw redraws the whole screen
+func Draw(cells *display.Framebuffer) error {
+	return DrawDirty(cells, chip8.AllDirty)
+}
 
-			// Yes, it is inefficient to re-draw the entire screen when not needed.
-			// It's done to ensure that each frame's blitting ops take approximately
-			// the same amount of time to complete regardless of 'on' pixels
-			var color uint32 = 0x00000000
+// DrawDirty draws cells, only updating the blocks marked in dirty (plus any that are fading out
+// with ghosting). The CRT filter always redraws everything. The display follows the size of
+// cells, e.g. when a ROM switches to SCHIP's hires mode.
+func DrawDirty(cells *display.Framebuffer, dirty chip8.DirtyBlocks) error {
+	if int32(cells.Width) != cols || int32(cells.Height) != rows {
+		if err := resize(cells.Width, cells.Height); err != nil {
+			return err
+		}
+	}
+	if redrawAll || filter == FilterCRT {
+		dirty = chip8.AllDirty
+	}
+	redrawAll = false
+	lastCells = cells.Clone()
+	var colors [4]uint32
+	for i, c := range palette {
+		colors[i] = argb(c)
+	}
+	frame := make([]uint32, len(cells.Pix))
+	fading = false
+	for i, cell := range cells.Pix {
+		if cells.Palette != nil {
+			// MegaChip colors, which ghosting doesn't apply to
+			frame[i] = cells.Palette[cell]
+			continue
+		}
+		color := colors[cell&3]
+		if ghosting && cell <= 1 {
+			if cell == 0 && glow[i] > 0 {
+				dirty |= chip8.BlockOf(cells, i%cells.Width, i/cells.Width)
+			}
 			if cell == 1 {
-				color = 0xffffffff
+				glow[i] = 1
+			} else if glow[i] *= ghostDecay; glow[i] < 0.02 {
+				glow[i] = 0
+			} else {
+				color = blend(palette[0], palette[1], glow[i])
+				fading = true
 			}
+		}
+		frame[i] = color
+	}
 
-			rect := sdl.Rect{
-				X: xPos,
-				Y: yPos,
-				W: blockWidth,
-				H: blockHeight,
+	tex := texture
+	if filter == FilterCRT {
+		if err := drawCRT(frame); err != nil {
+			return err
+		}
+		tex = crtTexture
+	} else {
+		for _, r := range dirty.RectsOf(cells) {
+			rect := sdl.Rect{X: int32(r.Min.X), Y: int32(r.Min.Y), W: int32(r.Dx()), H: int32(r.Dy())}
+			pixels, pitch, err := texture.Lock(&rect)
+			if err != nil {
+				return fmt.Errorf("draw: texture Lock failed: %v", err)
+			}
+			for x := r.Min.X; x < r.Max.X; x++ {
+				for y := r.Min.Y; y < r.Max.Y; y++ {
+					putPixel(pixels, pitch, x-r.Min.X, y-r.Min.Y, frame[y*cells.Width+x])
+				}
 			}
-			_ = surface.FillRect(&rect, color)
+			texture.Unlock()
 		}
 	}
-	err = window.UpdateSurface()
+
+	lastTexture = tex
+	return present()
+}
+
+// Refresh presents the last frame again, along with any overlays. Unlike Draw it doesn't
+// advance ghosting, so it can be called at any rate, e.g. once per refresh to pace a loop with VSync.
+func Refresh() error {
+	if lastTexture == nil {
+		return Draw(lastCells)
+	}
+	return present()
+}
+
+// resize switches the textures to a width x height screen
+func resize(width, height int) error {
+	tex, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING, int32(width), int32(height))
 	if err != nil {
-		return fmt.Errorf("draw: UpdateSurface failed: %v", err)
+		return fmt.Errorf("draw: failed creating texture: %v", err)
+	}
+	_ = texture.Destroy()
+	texture = tex
+	cols, rows = int32(width), int32(height)
+	if crtTexture != nil {
+		_ = crtTexture.Destroy()
+		crtTexture = nil
+		if err := createCRTTexture(); err != nil {
+			return err
+		}
 	}
+	glow = make([]float64, width*height)
+	redrawAll = true
+	lastTexture = nil
+	updateDest()
 	return nil
 }
 
-func Beep(on bool) {
-	sdl.PauseAudioDevice(audioDev, !on)
+// present copies lastTexture to the window with the overlays on top
+func present() error {
+	bg := palette[0]
+	_ = renderer.SetDrawColor(bg.R, bg.G, bg.B, 0xFF)
+	if err := renderer.Clear(); err != nil {
+		return fmt.Errorf("draw: Clear failed: %v", err)
+	}
+	if err := renderer.Copy(lastTexture, nil, &dest); err != nil {
+		return fmt.Errorf("draw: Copy failed: %v", err)
+	}
+	if keypadShown {
+		if err := drawKeypad(); err != nil {
+			return err
+		}
+	}
+	if crashLines != nil {
+		drawCrash()
+	}
+	if memLines != nil {
+		drawMemoryView()
+	}
+	if sprites != nil {
+		drawSprites()
+	}
+	if disasm != nil {
+		drawDisassembly()
+	}
+	drawOSD()
+	renderer.Present()
+	return nil
 }
 
-//export SineWave
-func SineWave(userdata unsafe.Pointer, stream *C.Uint8, length C.int) {
-	n := int(length) / 2
-	hdr := reflect.SliceHeader{Data: uintptr(unsafe.Pointer(stream)), Len: n, Cap: n}
-	buf := *(*[]C.ushort)(unsafe.Pointer(&hdr))
+// putPixel writes an ARGB8888 pixel into a locked texture.
+// ARGB8888 is stored as a native-endian uint32, i.e. B, G, R, A on little-endian machines.
+func putPixel(pixels []byte, pitch int, x, y int, color uint32) {
+	*(*uint32)(unsafe.Pointer(&pixels[y*pitch+x*4])) = color
+}
 
-	var phase float64
-	for i := 0; i < n; i++ {
-		phase += dPhase
-		sample := C.ushort((math.Sin(phase) + 0.999999) * 32768)
-		buf[i] = sample
+// blend mixes from and to, t=0 is all from and t=1 all to
+func blend(from, to color.RGBA, t float64) uint32 {
+	mix := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*t)
 	}
+	return argb(color.RGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: 0xFF})
+}
+
+// Window adapts the SDL window and audio device to the display and input interfaces.
+// SDL must only be used from the main thread, so don't attach it with Chip8.SetDisplay
+// when the machine runs on another goroutine; draw the frames from SetFrameChannel() in the main loop instead.
+type Window struct{}
+
+var (
+	_ display.Display       = Window{}
+	_ display.AudioSink     = Window{}
+	_ display.PatternPlayer = Window{}
+	_ display.SamplePlayer  = Window{}
+)
+
+func (Window) Draw(screen *display.Framebuffer) error {
+	return Draw(screen)
+}
+
+func (Window) Beep(on bool) {
+	Beep(on)
+}
+
+func (Window) SetPattern(pattern []byte, rate float64) {
+	SetPattern(pattern, rate)
 }
 
+func (Window) PlaySamples(samples []byte, rate int, loop bool) {
+	PlaySamples(samples, rate, loop)
+}
+
+// Cleanup closes the audio device, then the window, then SDL. Whatever beeps or draws from other
+// goroutines has to be stopped first.
 func Cleanup() {
+	closeAudio()
+	if texture != nil {
+		_ = texture.Destroy()
+		texture = nil
+	}
+	if crtTexture != nil {
+		_ = crtTexture.Destroy()
+		crtTexture = nil
+	}
+	if renderer != nil {
+		_ = renderer.Destroy()
+		renderer = nil
+	}
+	if window != nil {
+		_ = window.Destroy()
+		window = nil
+	}
 	sdl.Quit()
-	sdl.CloseAudioDevice(audioDev)
-	_ = window.Destroy()
 }
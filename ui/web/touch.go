@@ -0,0 +1,58 @@
+//go:build js && wasm
+// +build js,wasm
+
+package web
+
+import (
+	"fmt"
+	"syscall/js"
+)
+
+// touchLayout is the order of the keys on the on-screen keypad, row by row
+var touchLayout = [16]uint8{
+	0x1, 0x2, 0x3, 0xC,
+	0x4, 0x5, 0x6, 0xD,
+	0x7, 0x8, 0x9, 0xE,
+	0xA, 0x0, 0xB, 0xF,
+}
+
+// AttachTouch fills the element with the given id with a 4x4 grid of buttons, one per key,
+// which press their key while touched or clicked. Style the grid with CSS, buttons get the
+// "key" class and "pressed" while held.
+//
+// Pointer events cover touch, mouse and pen alike, and every finger gets its own pointer so
+// several keys can be held at once.
+func (k *Keypad) AttachTouch(id string) {
+	doc := js.Global().Get("document")
+	container := doc.Call("getElementById", id)
+	if container.IsNull() {
+		return
+	}
+	for _, key := range touchLayout {
+		key := key
+		button := doc.Call("createElement", "button")
+		button.Set("type", "button")
+		button.Set("className", "key")
+		button.Set("textContent", fmt.Sprintf("%X", key))
+		press := func(down bool) js.Func {
+			return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+				args[0].Call("preventDefault") // No scrolling, zooming or focus changes
+				k.mu.Lock()
+				k.touched[key] = down
+				k.mu.Unlock()
+				button.Get("classList").Call("toggle", "pressed", down)
+				return nil
+			})
+		}
+		button.Call("addEventListener", "pointerdown", press(true))
+		for _, event := range []string{"pointerup", "pointercancel", "pointerleave"} {
+			button.Call("addEventListener", event, press(false))
+		}
+		// Long presses would otherwise open the context menu on touch screens
+		button.Call("addEventListener", "contextmenu", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			args[0].Call("preventDefault")
+			return nil
+		}))
+		container.Call("appendChild", button)
+	}
+}
@@ -0,0 +1,237 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Package web is a browser frontend for the emulator, built with GOOS=js GOARCH=wasm.
+// It draws to an HTML canvas, reads the keypad from keyboard events (and an on-screen keypad
+// for touch screens) and beeps through WebAudio.
+package web
+
+import (
+	"strings"
+	"sync"
+	"syscall/js"
+
+	"github.com/dustinbowers/chip8emu/pkg/display"
+	"github.com/dustinbowers/chip8emu/pkg/input"
+)
+
+var (
+	_ display.Display       = (*Canvas)(nil)
+	_ input.Keypad          = (*Keypad)(nil)
+	_ display.AudioSink     = (*Beeper)(nil)
+	_ display.SamplePlayer  = (*Beeper)(nil)
+	_ display.PatternPlayer = (*Beeper)(nil)
+)
+
+// keyMap mirrors the SDL frontend's layout:
+//
+//	1 2 3 4        1 2 3 C
+//	Q W E R   ->   4 5 6 D
+//	A S D F        7 8 9 E
+//	Z X C V        A 0 B F
+var keyMap = map[string]uint8{
+	"1": 0x1, "2": 0x2, "3": 0x3, "4": 0xc,
+	"q": 0x4, "w": 0x5, "e": 0x6, "r": 0xd,
+	"a": 0x7, "s": 0x8, "d": 0x9, "f": 0xe,
+	"z": 0xa, "x": 0x0, "c": 0xb, "v": 0xf,
+}
+
+// Canvas draws the screen onto a <canvas> sized to it, 64x32 or 128x64. Scale it up with CSS
+// (image-rendering: pixelated).
+type Canvas struct {
+	canvas        js.Value
+	width, height int
+	ctx           js.Value
+	imageData     js.Value
+	pixels        js.Value
+	buf           []byte
+}
+
+// NewCanvas attaches to the <canvas> element with the given id
+func NewCanvas(id string) *Canvas {
+	canvas := js.Global().Get("document").Call("getElementById", id)
+	c := &Canvas{canvas: canvas, ctx: canvas.Call("getContext", "2d")}
+	c.resize(display.LoResWidth, display.LoResHeight)
+	return c
+}
+
+// resize switches the canvas to a width x height screen
+func (c *Canvas) resize(width, height int) {
+	c.width, c.height = width, height
+	c.canvas.Set("width", width)
+	c.canvas.Set("height", height)
+	c.imageData = c.ctx.Call("createImageData", width, height)
+	c.pixels = c.imageData.Get("data")
+	c.buf = make([]byte, width*height*4)
+}
+
+// Draw implements display.Display
+func (c *Canvas) Draw(screen *display.Framebuffer) error {
+	if screen.Width != c.width || screen.Height != c.height {
+		c.resize(screen.Width, screen.Height)
+	}
+	for i, cell := range screen.Pix {
+		if screen.Palette != nil {
+			p := screen.Palette[cell]
+			c.buf[i*4], c.buf[i*4+1], c.buf[i*4+2], c.buf[i*4+3] = byte(p>>16), byte(p>>8), byte(p), 0xff
+			continue
+		}
+		var v byte
+		if cell == 1 {
+			v = 0xff
+		}
+		c.buf[i*4], c.buf[i*4+1], c.buf[i*4+2], c.buf[i*4+3] = v, v, v, 0xff
+	}
+	js.CopyBytesToJS(c.pixels, c.buf)
+	c.ctx.Call("putImageData", c.imageData, 0, 0)
+	return nil
+}
+
+// Keypad tracks the 16-key keypad from document keyboard events, and the on-screen keypad once attached with AttachTouch
+type Keypad struct {
+	mu      sync.Mutex
+	keys    [16]bool
+	touched [16]bool
+}
+
+// NewKeypad starts listening for keyboard events on the document
+func NewKeypad() *Keypad {
+	k := &Keypad{}
+	listen := func(down bool) js.Func {
+		return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			key, ok := keyMap[strings.ToLower(args[0].Get("key").String())]
+			if !ok {
+				return nil
+			}
+			k.mu.Lock()
+			k.keys[key] = down
+			k.mu.Unlock()
+			return nil
+		})
+	}
+	doc := js.Global().Get("document")
+	doc.Call("addEventListener", "keydown", listen(true))
+	doc.Call("addEventListener", "keyup", listen(false))
+	return k
+}
+
+// Keys implements input.Keypad
+func (k *Keypad) Keys() [16]bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := k.keys
+	for i, down := range k.touched {
+		keys[i] = keys[i] || down
+	}
+	return keys
+}
+
+// Beeper plays a square wave (or an XO-CHIP audio pattern) through WebAudio while the sound timer is active.
+// Browsers only allow audio after a user gesture, so the context is resumed lazily on the first beep.
+type Beeper struct {
+	ctx     js.Value
+	gain    js.Value
+	osc     js.Value
+	pattern js.Value // AudioBufferSourceNode looping the current pattern, undefined when there is none
+	samples js.Value // AudioBufferSourceNode playing MegaChip digitized sound, undefined when there is none
+}
+
+// patternBufferRate is the sample rate of pattern buffers, playbackRate scales it to the pattern's rate
+const patternBufferRate = 4000
+
+// beepRampTime is the time constant, in seconds, of the fade as the beep starts and stops
+const beepRampTime = 0.002
+
+func NewBeeper() *Beeper {
+	audioContext := js.Global().Get("AudioContext")
+	if audioContext.IsUndefined() {
+		return &Beeper{}
+	}
+	ctx := audioContext.New()
+	osc := ctx.Call("createOscillator")
+	osc.Set("type", "square")
+	osc.Get("frequency").Set("value", 200)
+	gain := ctx.Call("createGain")
+	gain.Get("gain").Set("value", 0)
+	osc.Call("connect", gain)
+	gain.Call("connect", ctx.Get("destination"))
+	osc.Call("start")
+	return &Beeper{ctx: ctx, gain: gain, osc: osc, pattern: js.Undefined(), samples: js.Undefined()}
+}
+
+// Beep implements display.AudioSink
+func (b *Beeper) Beep(on bool) {
+	if b.ctx.IsUndefined() {
+		return
+	}
+	if b.ctx.Get("state").String() == "suspended" {
+		b.ctx.Call("resume")
+	}
+	volume := 0.0
+	if on {
+		volume = 0.1
+	}
+	// Ramp rather than jump to the new volume, an abrupt change clicks
+	b.gain.Get("gain").Call("setTargetAtTime", volume, b.ctx.Get("currentTime"), beepRampTime)
+}
+
+// SetPattern implements display.PatternPlayer
+func (b *Beeper) SetPattern(pattern []byte, rate float64) {
+	if b.ctx.IsUndefined() {
+		return
+	}
+	if !b.pattern.IsUndefined() {
+		b.pattern.Call("stop")
+		b.pattern.Call("disconnect")
+		b.pattern = js.Undefined()
+	}
+	if len(pattern) == 0 {
+		b.osc.Call("connect", b.gain)
+		return
+	}
+	b.osc.Call("disconnect")
+
+	bits := len(pattern) * 8
+	buffer := b.ctx.Call("createBuffer", 1, bits, patternBufferRate)
+	samples := buffer.Call("getChannelData", 0)
+	for i := 0; i < bits; i++ {
+		v := -1
+		if pattern[i/8]&(0x80>>(i%8)) != 0 {
+			v = 1
+		}
+		samples.SetIndex(i, v)
+	}
+	source := b.ctx.Call("createBufferSource")
+	source.Set("buffer", buffer)
+	source.Set("loop", true)
+	source.Get("playbackRate").Set("value", rate/patternBufferRate)
+	source.Call("connect", b.gain)
+	source.Call("start")
+	b.pattern = source
+}
+
+// PlaySamples implements display.SamplePlayer. The sound plays at full volume whatever the sound timer does.
+func (b *Beeper) PlaySamples(samples []byte, rate int, loop bool) {
+	if b.ctx.IsUndefined() {
+		return
+	}
+	if !b.samples.IsUndefined() {
+		b.samples.Call("stop")
+		b.samples.Call("disconnect")
+		b.samples = js.Undefined()
+	}
+	if len(samples) == 0 || rate <= 0 {
+		return
+	}
+	buffer := b.ctx.Call("createBuffer", 1, len(samples), rate)
+	data := buffer.Call("getChannelData", 0)
+	for i, s := range samples {
+		data.SetIndex(i, (float64(s)-128)/128)
+	}
+	source := b.ctx.Call("createBufferSource")
+	source.Set("buffer", buffer)
+	source.Set("loop", loop)
+	source.Call("connect", b.ctx.Get("destination"))
+	source.Call("start")
+	b.samples = source
+}